@@ -8,6 +8,8 @@ import (
 	"github.com/sfdeloach/churchsite/internal/config"
 	"github.com/sfdeloach/churchsite/internal/database"
 	"github.com/sfdeloach/churchsite/internal/models"
+	"github.com/sfdeloach/churchsite/internal/services"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func main() {
@@ -27,14 +29,17 @@ func main() {
 	defer db.Close()
 
 	now := time.Now()
+	picnicCapacity := 120
 
 	events := []models.Event{
 		{
-			Title:       "Lord's Day Morning Worship",
-			Description: "Join us for our regular Lord's Day morning worship service with preaching from God's Word.",
-			EventDate:   nextSunday(now).Add(10*time.Hour + 30*time.Minute),
-			Location:    "Main Sanctuary",
-			IsPublic:    true,
+			Title:          "Lord's Day Morning Worship",
+			Description:    "Join us for our regular Lord's Day morning worship service with preaching from God's Word.",
+			EventDate:      nextSunday(now).Add(10*time.Hour + 30*time.Minute),
+			Location:       "Main Sanctuary",
+			IsPublic:       true,
+			IsRecurring:    true,
+			RecurrenceRule: models.RecurrenceWeekly,
 		},
 		{
 			Title:       "Men's Prayer Breakfast",
@@ -44,11 +49,13 @@ func main() {
 			IsPublic:    true,
 		},
 		{
-			Title:       "Women's Bible Study",
-			Description: "Weekly women's Bible study exploring the book of Ruth.",
-			EventDate:   nextWeekday(now, time.Tuesday).Add(10 * time.Hour),
-			Location:    "Room 204",
-			IsPublic:    true,
+			Title:          "Women's Bible Study",
+			Description:    "Weekly women's Bible study exploring the book of Ruth.",
+			EventDate:      nextWeekday(now, time.Tuesday).Add(10 * time.Hour),
+			Location:       "Room 204",
+			IsPublic:       true,
+			IsRecurring:    true,
+			RecurrenceRule: models.RecurrenceWeekly,
 		},
 		{
 			Title:       "Youth Group Game Night",
@@ -58,11 +65,13 @@ func main() {
 			IsPublic:    true,
 		},
 		{
-			Title:       "Church Picnic",
-			Description: "Annual church picnic at the park. Bring a dish to share. Hamburgers and hot dogs provided.",
-			EventDate:   now.AddDate(0, 1, 0).Truncate(24*time.Hour).Add(11 * time.Hour),
-			Location:    "Riverside Park — Shelter 3",
-			IsPublic:    true,
+			Title:               "Church Picnic",
+			Description:         "Annual church picnic at the park. Bring a dish to share. Hamburgers and hot dogs provided.",
+			EventDate:           now.AddDate(0, 1, 0).Truncate(24*time.Hour).Add(11 * time.Hour),
+			Location:            "Riverside Park — Shelter 3",
+			IsPublic:            true,
+			RegistrationEnabled: true,
+			CapacityLimit:       &picnicCapacity,
 		},
 	}
 
@@ -74,6 +83,39 @@ func main() {
 		slog.Info("seeded event", "title", event.Title, "date", event.EventDate.Format("Jan 2, 2006 3:04 PM"))
 	}
 
+	// Seed announcements
+	announcements := []models.Announcement{
+		{
+			Title:    "New Members Class",
+			Content:  "A four-week class for those interested in membership begins next month after morning worship. Speak with an elder to sign up.",
+			Audience: models.AudiencePublic,
+			Priority: models.PriorityNormal,
+			IsActive: true,
+		},
+		{
+			Title:    "Congregational Meeting",
+			Content:  "The annual congregational meeting will follow evening worship. Members will receive the budget and elect officers.",
+			Audience: models.AudienceMembers,
+			Priority: models.PriorityNormal,
+			IsActive: true,
+		},
+		{
+			Title:    "Nursery Volunteers Needed",
+			Content:  "We are looking for volunteers to serve in the nursery during morning worship. Training is provided.",
+			Audience: models.AudiencePublic,
+			Priority: models.PriorityNormal,
+			IsActive: true,
+		},
+	}
+
+	for _, announcement := range announcements {
+		if err := db.Postgres.Create(&announcement).Error; err != nil {
+			slog.Error("failed to seed announcement", "title", announcement.Title, "error", err)
+			continue
+		}
+		slog.Info("seeded announcement", "title", announcement.Title)
+	}
+
 	// Seed staff members
 	staffMembers := []models.StaffMember{
 		{
@@ -210,9 +252,160 @@ func main() {
 		slog.Info("seeded ministry", "name", ministry.Name, "slug", ministry.Slug)
 	}
 
-	slog.Info("seeding complete", "events", len(events), "staff_members", len(staffMembers), "ministries", len(ministries))
+	// Seed development accounts (use FirstOrCreate because email has UNIQUE constraint)
+	users := []struct {
+		user     models.User
+		password string
+		roles    []string
+		profile  *models.MemberProfile
+	}{
+		{
+			user:     models.User{Email: "admin@sachapel.test", FirstName: "Admin", LastName: "User", IsVerified: true},
+			password: "AdminPass123!",
+			roles:    []string{models.RoleAdmin},
+		},
+		{
+			user:     models.User{Email: "staff@sachapel.test", FirstName: "Staff", LastName: "User", IsVerified: true},
+			password: "StaffPass123!",
+			roles:    []string{models.RoleStaff, models.RoleMember},
+			profile:  &models.MemberProfile{DirectoryOptIn: true, ShowEmail: true},
+		},
+		{
+			user:     models.User{Email: "member@sachapel.test", FirstName: "Member", LastName: "User", IsVerified: true},
+			password: "MemberPass123!",
+			roles:    []string{models.RoleMember},
+			profile: &models.MemberProfile{
+				AddressLine1: "1 Church Street", City: "Sanford", State: "FL", ZipCode: "32771",
+				DirectoryOptIn: true, ShowEmail: true, ShowPhone: true, ShowAddress: true,
+			},
+		},
+	}
+
+	for _, u := range users {
+		hash, err := bcrypt.GenerateFromPassword([]byte(u.password), 12)
+		if err != nil {
+			slog.Error("failed to hash seed password", "email", u.user.Email, "error", err)
+			continue
+		}
+		u.user.PasswordHash = string(hash)
+
+		user := u.user
+		if err := db.Postgres.Where(models.User{Email: user.Email}).FirstOrCreate(&user).Error; err != nil {
+			slog.Error("failed to seed user", "email", user.Email, "error", err)
+			continue
+		}
+
+		var roles []models.Role
+		if err := db.Postgres.Where("name IN ?", u.roles).Find(&roles).Error; err != nil {
+			slog.Error("failed to load roles", "email", user.Email, "error", err)
+			continue
+		}
+		for _, role := range roles {
+			userRole := models.UserRole{UserID: user.ID, RoleID: role.ID}
+			if err := db.Postgres.Where(userRole).FirstOrCreate(&userRole).Error; err != nil {
+				slog.Error("failed to assign role", "email", user.Email, "role", role.Name, "error", err)
+			}
+		}
+		if u.profile != nil {
+			profile := *u.profile
+			profile.UserID = user.ID
+			if err := db.Postgres.Where(models.MemberProfile{UserID: user.ID}).FirstOrCreate(&profile).Error; err != nil {
+				slog.Error("failed to seed member profile", "email", user.Email, "error", err)
+			}
+		}
+		slog.Info("seeded user", "email", user.Email, "roles", u.roles)
+	}
+
+	// Seed a household for the member account, with a child who has no login.
+	var member models.User
+	if err := db.Postgres.Where("email = ?", "member@sachapel.test").First(&member).Error; err != nil {
+		slog.Error("failed to load seeded member", "error", err)
+	} else {
+		var count int64
+		db.Postgres.Model(&models.HouseholdMember{}).Where("user_id = ?", member.ID).Count(&count)
+		if count == 0 {
+			household := models.Household{
+				Name:         member.LastName,
+				AddressLine1: "1 Church Street", City: "Sanford", State: "FL", ZipCode: "32771",
+				Members: []models.HouseholdMember{
+					{UserID: &member.ID, Relationship: models.RelationshipAdult},
+					{FirstName: "Junior", LastName: member.LastName, Relationship: models.RelationshipChild},
+				},
+			}
+			if err := db.Postgres.Create(&household).Error; err != nil {
+				slog.Error("failed to seed household", "error", err)
+			}
+		}
+
+		// Let the member account maintain the Sunday School page.
+		var ministry models.Ministry
+		if err := db.Postgres.Where("slug = ?", "sunday-school").First(&ministry).Error; err != nil {
+			slog.Error("failed to load seeded ministry", "error", err)
+		} else {
+			assignment := models.MinistryAssignment{UserID: member.ID, MinistryID: ministry.ID, CanEdit: true}
+			if err := db.Postgres.Where(models.MinistryAssignment{UserID: member.ID, MinistryID: ministry.ID}).FirstOrCreate(&assignment).Error; err != nil {
+				slog.Error("failed to seed ministry assignment", "error", err)
+			}
+		}
+	}
+
+	// Seed a form defined by its JSON schema.
+	newMemberForm := models.Form{
+		Title:       "New Members Class",
+		Description: "Sign up for the next New Members Class. We will contact you with the dates and details.",
+		Schema:      newMemberFormSchema,
+		IsActive:    true,
+	}
+	if _, err := services.ParseFormSchema(newMemberForm.Schema); err != nil {
+		slog.Error("invalid seed form schema", "title", newMemberForm.Title, "error", err)
+	} else if err := db.Postgres.Where(models.Form{Title: newMemberForm.Title}).FirstOrCreate(&newMemberForm).Error; err != nil {
+		slog.Error("failed to seed form", "title", newMemberForm.Title, "error", err)
+	} else {
+		slog.Info("seeded form", "title", newMemberForm.Title, "id", newMemberForm.ID)
+	}
+
+	slog.Info("seeding complete", "events", len(events), "announcements", len(announcements), "staff_members", len(staffMembers), "ministries", len(ministries), "users", len(users))
 }
 
+// newMemberFormSchema asks for what the elders need to follow up with a
+// prospective member.
+const newMemberFormSchema = `{
+  "type": "object",
+  "required": ["name", "email", "attending_since"],
+  "properties": {
+    "name": {"type": "string", "title": "Full Name", "maxLength": 200, "propertyOrder": 1},
+    "email": {"type": "string", "title": "Email", "format": "email", "maxLength": 255, "propertyOrder": 2},
+    "phone": {"type": "string", "title": "Phone", "format": "tel", "propertyOrder": 3},
+    "attending_since": {
+      "type": "string",
+      "title": "How long have you attended Saint Andrew's?",
+      "enum": ["Less than 3 months", "3 to 12 months", "More than a year"],
+      "propertyOrder": 4
+    },
+    "household_size": {
+      "type": "integer",
+      "title": "People in your household joining",
+      "minimum": 1,
+      "maximum": 12,
+      "propertyOrder": 5
+    },
+    "sessions": {
+      "type": "array",
+      "title": "Which sessions could you attend?",
+      "items": {"type": "string", "enum": ["Sunday after worship", "Wednesday evening"]},
+      "propertyOrder": 6
+    },
+    "questions": {
+      "type": "string",
+      "title": "Questions for the elders",
+      "format": "textarea",
+      "maxLength": 2000,
+      "propertyOrder": 7
+    },
+    "baptized": {"type": "boolean", "title": "I have been baptized", "propertyOrder": 8}
+  }
+}`
+
 func nextSunday(from time.Time) time.Time {
 	return nextWeekday(from, time.Sunday)
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -14,8 +15,13 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/sfdeloach/churchsite/internal/config"
 	"github.com/sfdeloach/churchsite/internal/database"
+	"github.com/sfdeloach/churchsite/internal/directorypdf"
 	"github.com/sfdeloach/churchsite/internal/handlers"
+	"github.com/sfdeloach/churchsite/internal/mail"
+	mw "github.com/sfdeloach/churchsite/internal/middleware"
+	"github.com/sfdeloach/churchsite/internal/models"
 	"github.com/sfdeloach/churchsite/internal/services"
+	"github.com/sfdeloach/churchsite/internal/storage"
 )
 
 func main() {
@@ -31,6 +37,15 @@ func main() {
 		case "migrate":
 			runMigrate()
 			return
+		case "cleanup-bulletins":
+			runCleanupBulletins()
+			return
+		case "directory-pdf":
+			runDirectoryPDF()
+			return
+		case "storage":
+			runStorage()
+			return
 		default:
 			slog.Error("unknown command", "command", os.Args[1])
 			os.Exit(1)
@@ -59,31 +74,94 @@ func main() {
 	}
 	defer db.Close()
 
+	// Transactional email: queued in Postgres, delivered by a background worker
+	mailer, err := newMailer(cfg)
+	if err != nil {
+		slog.Error("failed to configure mailer", "error", err)
+		os.Exit(1)
+	}
+	outbox := mail.NewOutbox(db.Postgres, mailer)
+
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	go outbox.Run(workerCtx, 15*time.Second)
+
+	// Uploaded files: a local directory or an S3 bucket
+	store, err := newStorage(cfg, cfg.StorageDriver)
+	if err != nil {
+		slog.Error("failed to configure storage", "error", err)
+		os.Exit(1)
+	}
+
 	// Initialize services
 	eventSvc := services.NewEventService(db.Postgres)
-	staffMemberSvc := services.NewStaffMemberService(db.Postgres)
+	staffMemberSvc := services.NewStaffMemberService(db.Postgres, store, cfg.MaxUploadSize)
+	staffCategorySvc := services.NewStaffCategoryService(db.Postgres)
+	photoSvc := services.NewPhotoService(db.Postgres, store, cfg.MaxUploadSize)
 	ministrySvc := services.NewMinistryService(db.Postgres)
+	registrationSvc := services.NewRegistrationService(db.Postgres)
+	bulletinSvc := services.NewBulletinService(db.Postgres, store, cfg.MaxUploadSize)
+	announcementSvc := services.NewAnnouncementService(db.Postgres)
+	directorySvc := services.NewDirectoryService(db.Postgres, store, cfg.MaxUploadSize)
+	householdSvc := services.NewHouseholdService(db.Postgres)
+	formSvc := services.NewFormService(db.Postgres)
+	revisionSvc := services.NewRevisionService(db.Postgres, ministrySvc, announcementSvc, staffMemberSvc)
+	authSvc := services.NewAuthService(db.Postgres)
+	sessionSvc := services.NewSessionService(cfg.JWTSecret, cfg.JWTExpiration, db.Redis)
+	rateLimiter := services.NewRateLimiter(db.Redis)
+	urlSigner := services.NewURLSigner(cfg.JWTSecret)
+
+	go ministrySvc.RunPublisher(workerCtx, time.Minute)
 
 	// Initialize handlers
 	healthHandler := handlers.NewHealthHandler(db)
-	homeHandler := handlers.NewHomeHandler(eventSvc)
-	aboutHandler := handlers.NewAboutHandler(staffMemberSvc)
-	ministryHandler := handlers.NewMinistryHandler(ministrySvc)
+	homeHandler := handlers.NewHomeHandler(eventSvc, announcementSvc)
+	aboutHandler := handlers.NewAboutHandler(staffMemberSvc, staffCategorySvc)
+	ministryHandler := handlers.NewMinistryHandler(ministrySvc, urlSigner)
+	calendarHandler := handlers.NewCalendarHandler(eventSvc, ministrySvc, cfg.AppURL)
+	registrationHandler := handlers.NewRegistrationHandler(eventSvc, registrationSvc, householdSvc, outbox, cfg.AppURL)
+	authHandler := handlers.NewAuthHandler(authSvc, sessionSvc, rateLimiter, outbox, cfg.AppURL, cfg.IsDevelopment())
+	bulletinHandler := handlers.NewBulletinHandler(bulletinSvc)
+	staffEventHandler := handlers.NewStaffEventHandler(eventSvc, ministrySvc, outbox, cfg.AppURL)
+	staffBulletinHandler := handlers.NewStaffBulletinHandler(bulletinSvc)
+	staffAnnouncementHandler := handlers.NewStaffAnnouncementHandler(announcementSvc)
+	staffMinistryHandler := handlers.NewStaffMinistryHandler(ministrySvc, urlSigner)
+	staffRevisionHandler := handlers.NewStaffRevisionHandler(revisionSvc)
+	staffMemberHandler := handlers.NewStaffMemberHandler(staffMemberSvc, staffCategorySvc)
+	adminStaffCategoryHandler := handlers.NewAdminStaffCategoryHandler(staffCategorySvc)
+	galleryHandler := handlers.NewGalleryHandler(photoSvc)
+	adminPhotoHandler := handlers.NewAdminPhotoHandler(photoSvc)
+	fileHandler := handlers.NewFileHandler(store, cfg.AccelRedirectPrefix, map[string]services.FileOwner{
+		"bulletins": bulletinSvc,
+		"staff":     staffMemberSvc,
+		"photos":    photoSvc,
+		"directory": directorySvc,
+	})
+	formHandler := handlers.NewFormHandler(formSvc, rateLimiter)
+	staffFormHandler := handlers.NewStaffFormHandler(formSvc)
+	staffHouseholdHandler := handlers.NewStaffHouseholdHandler(householdSvc)
+	directoryHandler := handlers.NewDirectoryHandler(directorySvc, householdSvc, staffMemberSvc, staffCategorySvc)
+	dashboardHandler := handlers.NewDashboardHandler()
 
 	// Build router
 	r := chi.NewRouter()
 
 	// Middleware
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	r.Use(mw.RealIP)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Compress(5))
+	r.Use(mw.Authenticate(sessionSvc))
+	r.Use(mw.Banners(announcementSvc))
 
 	// Static files
 	fileServer := http.FileServer(http.Dir("static"))
 	r.Handle("/static/*", http.StripPrefix("/static/", fileServer))
 
+	// Uploaded files
+	r.Get(services.FileURLPrefix+"*", fileHandler.Serve)
+
 	// Health checks
 	r.Get("/health", healthHandler.Liveness)
 	r.Get("/health/ready", healthHandler.Readiness)
@@ -99,6 +177,146 @@ func main() {
 	r.Get("/about/sanctuary", aboutHandler.Sanctuary)
 	r.Get("/ministries", ministryHandler.Index)
 	r.Get("/ministries/{slug}", ministryHandler.Show)
+	r.Get("/ministries/{slug}/events.ics", calendarHandler.MinistryFeed)
+	r.Get("/ministries/{slug}/preview", ministryHandler.Preview)
+	r.Get("/calendar/events", calendarHandler.Index)
+	r.Get("/calendar/events.ics", calendarHandler.Feed)
+	r.Get("/calendar/events/{id}", calendarHandler.Show)
+	r.Get("/calendar/events/{id}.ics", calendarHandler.Download)
+	r.Get("/calendar/registrations/{token}/cancel", registrationHandler.CancelPage)
+	r.Post("/calendar/registrations/{token}/cancel", registrationHandler.CancelByToken)
+	r.Get("/resources/bulletins", bulletinHandler.Index)
+	r.Get("/resources/bulletins/{id}.pdf", bulletinHandler.Download)
+	r.Get("/gallery", galleryHandler.Index)
+	r.Get("/gallery/{slug}", galleryHandler.Album)
+	r.Get("/forms/{id}", formHandler.Show)
+	r.Post("/forms/{id}", formHandler.Submit)
+
+	// Authentication
+	r.Get("/login", authHandler.LoginPage)
+	r.Post("/login", authHandler.Login)
+	r.Post("/logout", authHandler.Logout)
+	r.Get("/register", authHandler.RegisterPage)
+	r.Post("/register", authHandler.Register)
+	r.Get("/verify-email/{token}", authHandler.VerifyEmail)
+	r.Get("/forgot-password", authHandler.ForgotPasswordPage)
+	r.Post("/forgot-password", authHandler.ForgotPassword)
+	r.Get("/reset-password/{token}", authHandler.ResetPasswordPage)
+	r.Post("/reset-password/{token}", authHandler.ResetPassword)
+
+	// Member routes
+	r.Route("/member", func(r chi.Router) {
+		r.Use(mw.RequireAnyRole(models.RoleMember, models.RoleStaff, models.RoleElder, models.RolePastor))
+		r.Get("/dashboard", dashboardHandler.Member)
+		r.Get("/events/register/{id}", registrationHandler.Page)
+		r.Post("/events/register/{id}", registrationHandler.Register)
+		r.Post("/events/register/{id}/cancel", registrationHandler.Cancel)
+		r.Get("/directory", directoryHandler.Index)
+		r.Get("/directory.pdf", directoryHandler.PDF)
+		r.Get("/profile", directoryHandler.ProfilePage)
+		r.Post("/profile", directoryHandler.SaveProfile)
+		r.Post("/profile/photo", directoryHandler.UploadPhoto)
+		r.Post("/profile/photo/delete", directoryHandler.RemovePhoto)
+		r.Post("/household", directoryHandler.CreateHousehold)
+		r.Post("/household/children", directoryHandler.AddChild)
+		r.Post("/household/members/{id}/delete", directoryHandler.RemoveChild)
+	})
+
+	// Staff routes
+	r.Route("/staff", func(r chi.Router) {
+		r.Use(mw.RequireAnyRole(models.RoleStaff))
+		r.Get("/dashboard", dashboardHandler.Staff)
+		r.Get("/events", staffEventHandler.Index)
+		r.Get("/events/create", staffEventHandler.New)
+		r.Post("/events/create", staffEventHandler.Create)
+		r.Get("/events/{id}/edit", staffEventHandler.Edit)
+		r.Post("/events/{id}/edit", staffEventHandler.Update)
+		r.Post("/events/{id}/delete", staffEventHandler.Delete)
+		r.Get("/events/{id}/occurrences", staffEventHandler.Occurrences)
+		r.Get("/events/{id}/occurrences/{date}/move", staffEventHandler.MovePage)
+		r.Post("/events/{id}/occurrences/{date}/move", staffEventHandler.MoveOccurrence)
+		r.Post("/events/{id}/occurrences/{date}/cancel", staffEventHandler.CancelOccurrence)
+		r.Post("/events/{id}/occurrences/{date}/restore", staffEventHandler.RestoreOccurrence)
+		r.Get("/bulletins", staffBulletinHandler.Index)
+		r.Get("/bulletins/upload", staffBulletinHandler.UploadPage)
+		r.Post("/bulletins/upload", staffBulletinHandler.Upload)
+		r.Post("/bulletins/{id}/delete", staffBulletinHandler.Delete)
+		r.Get("/announcements", staffAnnouncementHandler.Index)
+		r.Get("/announcements/create", staffAnnouncementHandler.New)
+		r.Post("/announcements/create", staffAnnouncementHandler.Create)
+		r.Get("/announcements/{id}/edit", staffAnnouncementHandler.Edit)
+		r.Post("/announcements/{id}/edit", staffAnnouncementHandler.Update)
+		r.Post("/announcements/{id}/delete", staffAnnouncementHandler.Delete)
+		r.Get("/ministries", staffMinistryHandler.Index)
+		r.Post("/ministry/{slug}/assignments", staffMinistryHandler.Assign)
+		r.Post("/ministry/{slug}/assignments/{id}/delete", staffMinistryHandler.Unassign)
+		r.Get("/about/staff", staffMemberHandler.Index)
+		r.Get("/about/staff/create", staffMemberHandler.New)
+		r.Post("/about/staff/create", staffMemberHandler.Create)
+		r.Get("/about/staff/{id}/edit", staffMemberHandler.Edit)
+		r.Post("/about/staff/{id}/edit", staffMemberHandler.Update)
+		r.Post("/about/staff/order", staffMemberHandler.Reorder)
+		r.Get("/history/{type}/{id}", staffRevisionHandler.History)
+		r.Get("/revisions/{id}", staffRevisionHandler.Show)
+		r.Post("/revisions/{id}/restore", staffRevisionHandler.Restore)
+		r.Get("/forms", staffFormHandler.Index)
+		r.Get("/forms/{id}/submissions", staffFormHandler.Submissions)
+		r.Get("/forms/{id}/export", staffFormHandler.Export)
+		r.Get("/households", staffHouseholdHandler.Index)
+		r.Get("/households/{id}", staffHouseholdHandler.Show)
+		r.Post("/households/{id}", staffHouseholdHandler.Update)
+		r.Post("/households/{id}/adults", staffHouseholdHandler.AddAdult)
+		r.Post("/households/{id}/merge", staffHouseholdHandler.Merge)
+		r.Post("/households/{id}/split", staffHouseholdHandler.Split)
+	})
+
+	// Ministry pages can also be edited by members assigned through
+	// ministry_assignments, so the handler checks access rather than the role.
+	r.With(mw.RequireAuth).Get("/staff/ministry/{slug}", staffMinistryHandler.Edit)
+	r.With(mw.RequireAuth).Post("/staff/ministry/{slug}", staffMinistryHandler.Update)
+	r.With(mw.RequireAuth).Post("/staff/ministry/{slug}/draft/delete", staffMinistryHandler.DiscardDraft)
+
+	// Elder/pastor routes
+	r.Route("/elder", func(r chi.Router) {
+		r.Use(mw.RequireAnyRole(models.RoleElder, models.RolePastor))
+		r.Get("/dashboard", dashboardHandler.Elder)
+	})
+
+	// Admin routes
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(mw.RequireAnyRole(models.RoleAdmin))
+		r.Get("/dashboard", dashboardHandler.Admin)
+		r.Get("/staff-categories", adminStaffCategoryHandler.Index)
+		r.Get("/staff-categories/create", adminStaffCategoryHandler.New)
+		r.Post("/staff-categories/create", adminStaffCategoryHandler.Create)
+		r.Get("/staff-categories/{id}/edit", adminStaffCategoryHandler.Edit)
+		r.Post("/staff-categories/{id}/edit", adminStaffCategoryHandler.Update)
+		r.Post("/staff-categories/{id}/delete", adminStaffCategoryHandler.Delete)
+		r.Post("/staff-categories/order", adminStaffCategoryHandler.Reorder)
+		r.Get("/photos", adminPhotoHandler.Index)
+		r.Get("/photos/upload", adminPhotoHandler.UploadPage)
+		r.Post("/photos/upload", adminPhotoHandler.Upload)
+		r.Get("/photos/albums/create", adminPhotoHandler.NewAlbum)
+		r.Post("/photos/albums/create", adminPhotoHandler.CreateAlbum)
+		r.Get("/photos/albums/{id}", adminPhotoHandler.Album)
+		r.Get("/photos/albums/{id}/edit", adminPhotoHandler.EditAlbum)
+		r.Post("/photos/albums/{id}/edit", adminPhotoHandler.UpdateAlbum)
+		r.Post("/photos/albums/{id}/delete", adminPhotoHandler.DeleteAlbum)
+		r.Post("/photos/{id}/edit", adminPhotoHandler.UpdatePhoto)
+		r.Post("/photos/{id}/cover", adminPhotoHandler.SetCover)
+		r.Post("/photos/{id}/delete", adminPhotoHandler.DeletePhoto)
+	})
+
+	// API
+	r.Route("/api/v1", func(r chi.Router) {
+		r.Post("/auth/refresh", authHandler.APIRefresh)
+		r.Post("/auth/logout", authHandler.APILogout)
+		r.Post("/events/{id}/register", registrationHandler.APIRegister)
+		r.Get("/bulletins/current", bulletinHandler.APICurrent)
+		r.Post("/forms/{id}/submit", formHandler.APISubmit)
+		r.With(mw.RequireAnyRole(models.RoleMember, models.RoleStaff, models.RoleElder, models.RolePastor)).
+			Get("/member/directory/search", directoryHandler.APISearch)
+	})
 
 	// Start server
 	addr := fmt.Sprintf(":%s", cfg.AppPort)
@@ -124,6 +342,7 @@ func main() {
 
 	<-done
 	slog.Info("shutting down server")
+	stopWorkers()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -136,6 +355,42 @@ func main() {
 	slog.Info("server stopped")
 }
 
+// newMailer returns the Mailer selected by MAIL_DRIVER.
+func newMailer(cfg *config.Config) (mail.Mailer, error) {
+	from := mail.Address{Name: cfg.FromName, Email: cfg.FromEmail}
+
+	switch cfg.MailDriver {
+	case "file":
+		slog.Info("writing outgoing email to directory", "dir", cfg.MailDir)
+		return mail.NewFileMailer(cfg.MailDir, from)
+	default:
+		if cfg.SMTPHost == "" || cfg.SMTPPort == "" {
+			return nil, fmt.Errorf("SMTP_HOST and SMTP_PORT are required when MAIL_DRIVER is smtp")
+		}
+		return mail.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUser, cfg.SMTPPass, from), nil
+	}
+}
+
+// newStorage returns the Storage for driver, local or s3, as configured by
+// STORAGE_DIR or the S3 settings.
+func newStorage(cfg *config.Config, driver string) (storage.Storage, error) {
+	switch driver {
+	case "local":
+		return storage.NewLocal(cfg.StorageDir), nil
+	case "s3":
+		return storage.NewS3(storage.S3Config{
+			Endpoint:        cfg.S3Endpoint,
+			Region:          cfg.S3Region,
+			Bucket:          cfg.S3Bucket,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+			PathStyle:       cfg.S3PathStyle,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q, use local or s3", driver)
+	}
+}
+
 func runMigrate() {
 	cfg, err := config.Load()
 	if err != nil {
@@ -166,3 +421,137 @@ func runMigrate() {
 		os.Exit(1)
 	}
 }
+
+func runCleanupBulletins() {
+	flags := flag.NewFlagSet("cleanup-bulletins", flag.ExitOnError)
+	days := flags.Int("days", services.BulletinRetentionDays, "remove bulletins dated more than this many days ago")
+	if err := flags.Parse(os.Args[2:]); err != nil {
+		os.Exit(1)
+	}
+	if *days < 0 {
+		slog.Error("--days must not be negative", "days", *days)
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		slog.Error("failed to load config", "error", err)
+		os.Exit(1)
+	}
+
+	db, err := database.Connect(cfg)
+	if err != nil {
+		slog.Error("failed to connect to database", "error", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	store, err := newStorage(cfg, cfg.StorageDriver)
+	if err != nil {
+		slog.Error("failed to configure storage", "error", err)
+		os.Exit(1)
+	}
+
+	bulletinSvc := services.NewBulletinService(db.Postgres, store, cfg.MaxUploadSize)
+	removed, err := bulletinSvc.Cleanup(time.Now(), *days)
+	if err != nil {
+		slog.Error("bulletin cleanup failed", "removed", removed, "error", err)
+		os.Exit(1)
+	}
+	slog.Info("bulletin cleanup complete", "removed", removed, "days", *days)
+}
+
+func runDirectoryPDF() {
+	flags := flag.NewFlagSet("directory-pdf", flag.ExitOnError)
+	out := flags.String("out", "member-directory.pdf", "file to write the PDF to")
+	staticDir := flags.String("static", "static", "directory that /static photo URLs are served from")
+	if err := flags.Parse(os.Args[2:]); err != nil {
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		slog.Error("failed to load config", "error", err)
+		os.Exit(1)
+	}
+
+	db, err := database.Connect(cfg)
+	if err != nil {
+		slog.Error("failed to connect to database", "error", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	store, err := newStorage(cfg, cfg.StorageDriver)
+	if err != nil {
+		slog.Error("failed to configure storage", "error", err)
+		os.Exit(1)
+	}
+
+	directorySvc := services.NewDirectoryService(db.Postgres, store, cfg.MaxUploadSize)
+	staffMemberSvc := services.NewStaffMemberService(db.Postgres, store, cfg.MaxUploadSize)
+	staffCategorySvc := services.NewStaffCategoryService(db.Postgres)
+	book, err := directorypdf.Load(directorySvc, staffMemberSvc, staffCategorySvc, time.Now())
+	if err != nil {
+		slog.Error("failed to load directory", "error", err)
+		os.Exit(1)
+	}
+	book.Photo = directorypdf.LocalPhotos(*staticDir, staffMemberSvc, directorySvc)
+
+	f, err := os.Create(*out)
+	if err != nil {
+		slog.Error("failed to create output file", "path", *out, "error", err)
+		os.Exit(1)
+	}
+	size, err := book.WriteTo(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		slog.Error("failed to write directory PDF", "path", *out, "error", err)
+		os.Exit(1)
+	}
+	slog.Info("directory PDF written", "path", *out, "entries", len(book.Entries), "bytes", size)
+}
+
+func runStorage() {
+	if len(os.Args) < 3 || os.Args[2] != "migrate" {
+		slog.Error("usage: sachapel storage migrate --from=local --to=s3")
+		os.Exit(1)
+	}
+
+	flags := flag.NewFlagSet("storage migrate", flag.ExitOnError)
+	from := flags.String("from", "local", "storage driver to copy objects from (local or s3)")
+	to := flags.String("to", "s3", "storage driver to copy objects to (local or s3)")
+	if err := flags.Parse(os.Args[3:]); err != nil {
+		os.Exit(1)
+	}
+	if *from == *to {
+		slog.Error("--from and --to must differ", "driver", *from)
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		slog.Error("failed to load config", "error", err)
+		os.Exit(1)
+	}
+
+	src, err := newStorage(cfg, *from)
+	if err != nil {
+		slog.Error("failed to configure source storage", "error", err)
+		os.Exit(1)
+	}
+	dst, err := newStorage(cfg, *to)
+	if err != nil {
+		slog.Error("failed to configure destination storage", "error", err)
+		os.Exit(1)
+	}
+
+	copied, skipped, err := storage.Migrate(dst, src)
+	if err != nil {
+		slog.Error("storage migration failed", "copied", copied, "skipped", skipped, "error", err)
+		os.Exit(1)
+	}
+	slog.Info("storage migration complete", "from", *from, "to", *to, "copied", copied, "skipped", skipped)
+}
@@ -3,6 +3,9 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Config holds application configuration loaded from environment variables.
@@ -15,7 +18,7 @@ type Config struct {
 	RedisURL    string
 
 	JWTSecret     string
-	JWTExpiration string
+	JWTExpiration time.Duration
 
 	SMTPHost  string
 	SMTPPort  string
@@ -24,7 +27,26 @@ type Config struct {
 	FromEmail string
 	FromName  string
 
-	MaxUploadSize string
+	MailDriver string
+	MailDir    string
+
+	// StorageDriver is where uploaded files are kept: local, in StorageDir,
+	// or s3, in the bucket described by the S3 settings.
+	StorageDriver string
+	StorageDir    string
+	MaxUploadSize int64 // bytes
+
+	S3Endpoint        string
+	S3Region          string
+	S3Bucket          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	S3PathStyle       bool
+
+	// AccelRedirectPrefix is the internal nginx location that serves
+	// STORAGE_DIR, e.g. /uploads/. When empty the app streams uploaded files
+	// itself.
+	AccelRedirectPrefix string
 }
 
 // Load reads configuration from environment variables and returns a Config.
@@ -38,8 +60,7 @@ func Load() (*Config, error) {
 		DatabaseURL: os.Getenv("DATABASE_URL"),
 		RedisURL:    os.Getenv("REDIS_URL"),
 
-		JWTSecret:     os.Getenv("JWT_SECRET"),
-		JWTExpiration: getEnv("JWT_EXPIRATION", "24h"),
+		JWTSecret: os.Getenv("JWT_SECRET"),
 
 		SMTPHost:  os.Getenv("SMTP_HOST"),
 		SMTPPort:  os.Getenv("SMTP_PORT"),
@@ -48,7 +69,18 @@ func Load() (*Config, error) {
 		FromEmail: os.Getenv("FROM_EMAIL"),
 		FromName:  os.Getenv("FROM_NAME"),
 
-		MaxUploadSize: getEnv("MAX_UPLOAD_SIZE", "10485760"),
+		MailDriver: getEnv("MAIL_DRIVER", "smtp"),
+		MailDir:    getEnv("MAIL_DIR", "tmp/mail"),
+
+		StorageDriver:       getEnv("STORAGE_DRIVER", "local"),
+		StorageDir:          getEnv("STORAGE_DIR", "storage"),
+		AccelRedirectPrefix: os.Getenv("ACCEL_REDIRECT_PREFIX"),
+
+		S3Endpoint:        os.Getenv("S3_ENDPOINT"),
+		S3Region:          getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:          os.Getenv("S3_BUCKET"),
+		S3AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+		S3SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
 	}
 
 	if cfg.DatabaseURL == "" {
@@ -57,6 +89,44 @@ func Load() (*Config, error) {
 	if cfg.RedisURL == "" {
 		return nil, fmt.Errorf("REDIS_URL is required")
 	}
+	if cfg.JWTSecret == "" {
+		return nil, fmt.Errorf("JWT_SECRET is required")
+	}
+
+	if cfg.MailDriver != "smtp" && cfg.MailDriver != "file" {
+		return nil, fmt.Errorf("MAIL_DRIVER must be smtp or file: %q", cfg.MailDriver)
+	}
+
+	jwtExpiration, err := time.ParseDuration(getEnv("JWT_EXPIRATION", "24h"))
+	if err != nil || jwtExpiration <= 0 {
+		return nil, fmt.Errorf("JWT_EXPIRATION must be a positive duration such as 24h: %q", os.Getenv("JWT_EXPIRATION"))
+	}
+	cfg.JWTExpiration = jwtExpiration
+
+	maxUploadSize, err := strconv.ParseInt(getEnv("MAX_UPLOAD_SIZE", "10485760"), 10, 64)
+	if err != nil || maxUploadSize <= 0 {
+		return nil, fmt.Errorf("MAX_UPLOAD_SIZE must be a positive number of bytes: %q", os.Getenv("MAX_UPLOAD_SIZE"))
+	}
+	cfg.MaxUploadSize = maxUploadSize
+
+	if cfg.StorageDriver != "local" && cfg.StorageDriver != "s3" {
+		return nil, fmt.Errorf("STORAGE_DRIVER must be local or s3: %q", cfg.StorageDriver)
+	}
+
+	if v := os.Getenv("S3_PATH_STYLE"); v != "" {
+		pathStyle, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("S3_PATH_STYLE must be true or false: %q", v)
+		}
+		cfg.S3PathStyle = pathStyle
+	}
+
+	if cfg.AccelRedirectPrefix != "" && cfg.StorageDriver != "local" {
+		return nil, fmt.Errorf("ACCEL_REDIRECT_PREFIX requires STORAGE_DRIVER=local")
+	}
+	if p := cfg.AccelRedirectPrefix; p != "" && (!strings.HasPrefix(p, "/") || !strings.HasSuffix(p, "/")) {
+		return nil, fmt.Errorf("ACCEL_REDIRECT_PREFIX must begin and end with /: %q", p)
+	}
 
 	return cfg, nil
 }
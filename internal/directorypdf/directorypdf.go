@@ -0,0 +1,395 @@
+// Package directorypdf lays out the printable member directory: a cover, the
+// pastors and staff grouped by category, and the opted-in members and
+// households in two columns with letter dividers.
+//
+// Entries arrive with the web directory's privacy settings already applied,
+// so the printed book shows exactly what members see online.
+package directorypdf
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sfdeloach/churchsite/internal/models"
+	"github.com/sfdeloach/churchsite/internal/pdf"
+	"github.com/sfdeloach/churchsite/internal/services"
+)
+
+// ContentType is the media type for directory downloads.
+const ContentType = "application/pdf"
+
+const churchName = "Saint Andrew's Chapel"
+
+// Filename returns the suggested file name for a directory generated at t,
+// e.g. "member-directory-2025-03.pdf".
+func Filename(t time.Time) string {
+	return "member-directory-" + t.Format("2006-01") + ".pdf"
+}
+
+const (
+	margin      = 54.0
+	gutter      = 24.0
+	columnWidth = (pdf.PageWidth - 2*margin - gutter) / 2
+	contentTop  = 72.0
+	contentEnd  = pdf.PageHeight - 60
+	photoSize   = 48.0
+	textIndent  = photoSize + 10
+	lineHeight  = 12.0
+	blockGap    = 14.0
+)
+
+// PhotoLoader returns the image for a photo URL, or nil if it cannot be
+// shown. The book is still produced when photos are missing.
+type PhotoLoader func(url string) *pdf.Image
+
+// LocalPhotos returns a PhotoLoader for photos kept by this site: those
+// served from the site's static directory, such as
+// /static/images/staff/jane.jpg, and staff headshots and members' profile
+// photos uploaded through the site, read from storage. Other URLs and
+// unreadable files are skipped with a warning.
+func LocalPhotos(staticDir string, staff *services.StaffMemberService, directory *services.DirectoryService) PhotoLoader {
+	return func(url string) *pdf.Image {
+		var (
+			f   io.ReadCloser
+			err error
+		)
+		if rel, ok := strings.CutPrefix(url, "/static/"); ok && filepath.IsLocal(rel) {
+			f, err = os.Open(filepath.Join(staticDir, filepath.FromSlash(rel)))
+		} else if name, ok := strings.CutPrefix(url, services.StaffPhotoURLPrefix); ok {
+			f, err = staff.OpenPhoto(name)
+		} else if name, ok := strings.CutPrefix(url, services.MemberPhotoURLPrefix); ok {
+			f, err = directory.OpenPhoto(name)
+		} else {
+			slog.Warn("directory photo not printable", "url", url)
+			return nil
+		}
+		if err != nil {
+			slog.Warn("failed to open directory photo", "url", url, "error", err)
+			return nil
+		}
+		defer f.Close()
+
+		img, err := pdf.LoadImage(f)
+		if err != nil {
+			slog.Warn("failed to load directory photo", "url", url, "error", err)
+			return nil
+		}
+		return img
+	}
+}
+
+// StaffSection is one category of staff, e.g. "Teaching Elders".
+type StaffSection struct {
+	Label   string
+	Members []models.StaffMember
+}
+
+// StaffSections groups active staff members by category in the order of
+// categories, skipping empty categories.
+func StaffSections(categories []models.StaffCategoryInfo, members []models.StaffMember) []StaffSection {
+	grouped := services.GroupByCategory(members)
+
+	var sections []StaffSection
+	for _, c := range categories {
+		if len(grouped[c.Slug]) > 0 {
+			sections = append(sections, StaffSection{Label: c.Label, Members: grouped[c.Slug]})
+		}
+	}
+	return sections
+}
+
+// Book is the printed directory to render.
+type Book struct {
+	GeneratedAt time.Time
+	Staff       []StaffSection
+	Entries     []services.DirectoryEntry
+	Photo       PhotoLoader // optional
+}
+
+// Load builds a book from the listed members and the active staff. The
+// caller sets Photo.
+func Load(directory *services.DirectoryService, staff *services.StaffMemberService, categories *services.StaffCategoryService, now time.Time) (Book, error) {
+	book := Book{GeneratedAt: now}
+
+	entries, err := directory.Search("", "")
+	if err != nil {
+		return book, err
+	}
+	book.Entries = entries
+
+	members, err := staff.GetActive()
+	if err != nil {
+		return book, err
+	}
+	ordered, err := categories.Ordered()
+	if err != nil {
+		return book, err
+	}
+	book.Staff = StaffSections(ordered, members)
+
+	return book, nil
+}
+
+// WriteTo renders the book as a PDF to w.
+func (b Book) WriteTo(w io.Writer) (int64, error) {
+	doc := pdf.New(churchName+" Member Directory", b.GeneratedAt)
+	l := &layout{doc: doc, book: b}
+
+	l.cover()
+
+	if len(b.Staff) > 0 {
+		l.newPage()
+		l.title("Pastors and Staff")
+		for _, section := range b.Staff {
+			l.heading(section.Label)
+			for _, m := range section.Members {
+				l.block(m.ThumbnailURL(), staffLines(m))
+			}
+		}
+	}
+
+	entries := slices.Clone(b.Entries)
+	slices.SortStableFunc(entries, func(a, b services.DirectoryEntry) int {
+		if c := strings.Compare(strings.ToLower(a.SortName), strings.ToLower(b.SortName)); c != 0 {
+			return c
+		}
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+
+	l.newPage()
+	l.title("Members")
+	letter := ""
+	for _, e := range entries {
+		if initial := initial(e.SortName); initial != letter {
+			letter = initial
+			l.heading(letter)
+		}
+		l.block(e.PhotoURL, entryLines(e))
+	}
+	if len(entries) == 0 {
+		l.doc.SetFont(pdf.Helvetica, 10)
+		l.doc.Text(margin, l.y+lineHeight, "No members have opted in to the directory yet.")
+	}
+
+	return doc.WriteTo(w)
+}
+
+// line is a single line of text in an entry block.
+type line struct {
+	text   string
+	font   pdf.Font
+	size   float64
+	indent float64
+}
+
+func staffLines(m models.StaffMember) []line {
+	lines := []line{{text: m.Name, font: pdf.HelveticaBold, size: 10.5}}
+	if m.Title != "" {
+		lines = append(lines, line{text: m.Title, font: pdf.Helvetica, size: 9})
+	}
+	if m.Email != "" {
+		lines = append(lines, line{text: m.Email, font: pdf.Helvetica, size: 9})
+	}
+	if m.Phone != "" {
+		lines = append(lines, line{text: m.Phone, font: pdf.Helvetica, size: 9})
+	}
+	return lines
+}
+
+func entryLines(e services.DirectoryEntry) []line {
+	lines := []line{{text: e.Name, font: pdf.HelveticaBold, size: 10.5}}
+
+	for _, p := range e.People {
+		indent := 0.0
+		if e.HouseholdID != 0 {
+			name := p.FirstName
+			if p.LastName != "" && p.LastName != e.SortName {
+				name += " " + p.LastName
+			}
+			if p.IsChild {
+				name += " (child)"
+			}
+			lines = append(lines, line{text: name, font: pdf.HelveticaBold, size: 9})
+			indent = 8
+		}
+		if p.Email != "" {
+			lines = append(lines, line{text: p.Email, font: pdf.Helvetica, size: 9, indent: indent})
+		}
+		if p.Phone != "" {
+			lines = append(lines, line{text: p.Phone, font: pdf.Helvetica, size: 9, indent: indent})
+		}
+	}
+
+	if e.Phone != "" {
+		lines = append(lines, line{text: e.Phone, font: pdf.Helvetica, size: 9})
+	}
+	if a := e.Address; a != nil {
+		lines = append(lines, line{text: a.Line1, font: pdf.Helvetica, size: 9})
+		if a.Line2 != "" {
+			lines = append(lines, line{text: a.Line2, font: pdf.Helvetica, size: 9})
+		}
+		lines = append(lines, line{text: a.City + ", " + a.State + " " + a.ZipCode, font: pdf.Helvetica, size: 9})
+	}
+	return lines
+}
+
+// initial returns the upper-case first letter of name, or "#" for names that
+// do not start with a letter.
+func initial(name string) string {
+	r, _ := utf8.DecodeRuneInString(strings.TrimSpace(name))
+	if !unicode.IsLetter(r) {
+		return "#"
+	}
+	return string(unicode.ToUpper(r))
+}
+
+// layout flows headings and entry blocks down two columns, page by page.
+type layout struct {
+	doc    *pdf.Document
+	book   Book
+	column int
+	top    float64 // where columns start on the current page
+	y      float64
+	photos map[string]*pdf.Image
+}
+
+func (l *layout) cover() {
+	d := l.doc
+	d.AddPage()
+
+	center := func(y float64, s string) {
+		d.Text((pdf.PageWidth-d.TextWidth(s))/2, y, s)
+	}
+
+	d.SetColor(0.1, 0.1, 0.1)
+	d.SetFont(pdf.HelveticaBold, 28)
+	center(300, churchName)
+	d.SetFont(pdf.Helvetica, 18)
+	center(334, "Member Directory")
+	d.Line(pdf.PageWidth/2-72, 356, pdf.PageWidth/2+72, 356, 0.75)
+	d.SetFont(pdf.Helvetica, 11)
+	center(380, l.book.GeneratedAt.Format("January 2006"))
+
+	d.SetColor(0.4, 0.4, 0.4)
+	d.SetFont(pdf.Helvetica, 9)
+	center(contentEnd, "For members of "+churchName+" only. Please do not share or copy.")
+}
+
+// newPage starts a page with the running header and page number.
+func (l *layout) newPage() {
+	d := l.doc
+	d.AddPage()
+
+	d.SetColor(0.4, 0.4, 0.4)
+	d.SetFont(pdf.Helvetica, 8)
+	header := churchName + " Member Directory"
+	d.Text(margin, 40, header)
+	number := strconv.Itoa(d.PageCount())
+	d.Text((pdf.PageWidth-d.TextWidth(number))/2, pdf.PageHeight-30, number)
+	d.SetColor(0.1, 0.1, 0.1)
+
+	l.column = 0
+	l.top = contentTop
+	l.y = contentTop
+}
+
+// nextColumn moves to the top of the next column, starting a page if needed.
+func (l *layout) nextColumn() {
+	if l.column == 0 {
+		l.column = 1
+		l.y = l.top
+		return
+	}
+	l.newPage()
+}
+
+func (l *layout) x() float64 {
+	return margin + float64(l.column)*(columnWidth+gutter)
+}
+
+// title draws a full-width section title at the top of a fresh page.
+func (l *layout) title(s string) {
+	l.doc.SetFont(pdf.HelveticaBold, 18)
+	l.doc.Text(margin, l.y+18, s)
+	l.doc.Line(margin, l.y+26, pdf.PageWidth-margin, l.y+26, 1)
+	l.y += 42
+	l.top = l.y
+}
+
+// heading draws a column heading, keeping room for at least one block
+// beneath it.
+func (l *layout) heading(s string) {
+	const height = 24.0
+	if l.y+height+photoSize > contentEnd {
+		l.nextColumn()
+	}
+	l.doc.SetFont(pdf.HelveticaBold, 13)
+	l.doc.Text(l.x(), l.y+13, s)
+	l.doc.Line(l.x(), l.y+18, l.x()+columnWidth, l.y+18, 0.5)
+	l.y += height
+}
+
+// block draws a photo with lines of text beside it, moving to the next
+// column first if the block does not fit.
+func (l *layout) block(photoURL string, lines []line) {
+	var wrapped []line
+	for _, ln := range lines {
+		l.doc.SetFont(ln.font, ln.size)
+		for _, text := range l.doc.Wrap(ln.text, columnWidth-textIndent-ln.indent) {
+			wrapped = append(wrapped, line{text: text, font: ln.font, size: ln.size, indent: ln.indent})
+		}
+	}
+
+	height := max(photoSize, float64(len(wrapped))*lineHeight)
+	if l.y+height > contentEnd {
+		l.nextColumn()
+	}
+
+	x := l.x()
+	if img := l.photo(photoURL); img != nil {
+		// Fit the photo inside the square without distorting it.
+		pw, ph := img.Size()
+		w, h := photoSize, photoSize
+		if pw > ph {
+			h = photoSize * float64(ph) / float64(pw)
+		} else {
+			w = photoSize * float64(pw) / float64(ph)
+		}
+		l.doc.Image(img, x+(photoSize-w)/2, l.y+(photoSize-h)/2, w, h)
+	} else {
+		l.doc.SetColor(0.9, 0.9, 0.9)
+		l.doc.Rect(x, l.y, photoSize, photoSize)
+		l.doc.SetColor(0.1, 0.1, 0.1)
+	}
+
+	for i, ln := range wrapped {
+		l.doc.SetFont(ln.font, ln.size)
+		l.doc.Text(x+textIndent+ln.indent, l.y+float64(i+1)*lineHeight-2, ln.text)
+	}
+
+	l.y += height + blockGap
+}
+
+// photo loads each photo URL once; nil means draw a placeholder.
+func (l *layout) photo(url string) *pdf.Image {
+	if url == "" || l.book.Photo == nil {
+		return nil
+	}
+	if l.photos == nil {
+		l.photos = make(map[string]*pdf.Image)
+	}
+	img, ok := l.photos[url]
+	if !ok {
+		img = l.book.Photo(url)
+		l.photos[url] = img
+	}
+	return img
+}
@@ -11,12 +11,14 @@ import (
 // AboutHandler handles about section pages.
 type AboutHandler struct {
 	staffMembers *services.StaffMemberService
+	categories   *services.StaffCategoryService
 }
 
 // NewAboutHandler creates a new AboutHandler.
-func NewAboutHandler(staffMembers *services.StaffMemberService) *AboutHandler {
+func NewAboutHandler(staffMembers *services.StaffMemberService, categories *services.StaffCategoryService) *AboutHandler {
 	return &AboutHandler{
 		staffMembers: staffMembers,
+		categories:   categories,
 	}
 }
 
@@ -66,8 +68,15 @@ func (h *AboutHandler) Staff(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	categories, err := h.categories.Ordered()
+	if err != nil {
+		slog.Error("failed to load staff categories", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
 	grouped := services.GroupByCategory(members)
-	component := pages.AboutStaff(grouped)
+	component := pages.AboutStaff(categories, grouped)
 	if err := component.Render(r.Context(), w); err != nil {
 		slog.Error("failed to render about staff page", "error", err)
 	}
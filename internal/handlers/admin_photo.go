@@ -0,0 +1,560 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sfdeloach/churchsite/internal/models"
+	"github.com/sfdeloach/churchsite/internal/services"
+	"github.com/sfdeloach/churchsite/templates/pages"
+	"gorm.io/gorm"
+)
+
+// AdminPhotoHandler handles gallery albums, photo uploads, and photo
+// moderation for admins.
+type AdminPhotoHandler struct {
+	photos *services.PhotoService
+}
+
+// NewAdminPhotoHandler creates a new AdminPhotoHandler.
+func NewAdminPhotoHandler(photos *services.PhotoService) *AdminPhotoHandler {
+	return &AdminPhotoHandler{photos: photos}
+}
+
+// Index lists every album with its cover and photo counts.
+func (h *AdminPhotoHandler) Index(w http.ResponseWriter, r *http.Request) {
+	albums, err := h.photos.ListAlbums()
+	if err != nil {
+		slog.Error("failed to list photo albums", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var notice, errMsg string
+	switch r.URL.Query().Get("status") {
+	case "saved":
+		notice = "The album has been saved."
+	case "deleted":
+		notice = "The album has been deleted."
+	case "not-empty":
+		errMsg = "The album still has photos, so it cannot be deleted. Delete or move them first."
+	}
+
+	component := pages.AdminPhotos(albums, notice, errMsg)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render photo albums page", "error", err)
+	}
+}
+
+// NewAlbum renders an empty album form.
+func (h *AdminPhotoHandler) NewAlbum(w http.ResponseWriter, r *http.Request) {
+	form := pages.PhotoAlbumForm{Input: services.AlbumInput{Visibility: models.AudiencePublic}}
+	h.renderAlbumForm(w, r, form, http.StatusOK)
+}
+
+// CreateAlbum stores a new album and continues to its page, ready for
+// uploads.
+func (h *AdminPhotoHandler) CreateAlbum(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	form := pages.PhotoAlbumForm{Input: albumInput(r)}
+
+	user := services.CurrentUser(r.Context())
+	album, err := h.photos.CreateAlbum(form.Input, user.UserID)
+	if err != nil {
+		var verrs services.ValidationErrors
+		if errors.As(err, &verrs) {
+			form.Errors = verrs
+			h.renderAlbumForm(w, r, form, http.StatusUnprocessableEntity)
+			return
+		}
+		slog.Error("failed to create photo album", "user_id", user.UserID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("photo album created", "album_id", album.ID, "slug", album.Slug, "user_id", user.UserID)
+	redirect(w, r, adminAlbumPath(album.ID, "?status=created"))
+}
+
+// EditAlbum renders the form for an existing album.
+func (h *AdminPhotoHandler) EditAlbum(w http.ResponseWriter, r *http.Request) {
+	album, ok := h.albumFromParam(w, r)
+	if !ok {
+		return
+	}
+
+	form := pages.PhotoAlbumForm{ID: album.ID, Input: services.AlbumInput{
+		Title:       album.Title,
+		Slug:        album.Slug,
+		Description: album.Description,
+		Visibility:  album.Visibility,
+	}}
+	h.renderAlbumForm(w, r, form, http.StatusOK)
+}
+
+// UpdateAlbum saves changes to an existing album.
+func (h *AdminPhotoHandler) UpdateAlbum(w http.ResponseWriter, r *http.Request) {
+	album, ok := h.albumFromParam(w, r)
+	if !ok {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	form := pages.PhotoAlbumForm{ID: album.ID, Input: albumInput(r)}
+	form.Input.Slug = album.Slug
+
+	user := services.CurrentUser(r.Context())
+	if _, err := h.photos.UpdateAlbum(album.ID, form.Input); err != nil {
+		var verrs services.ValidationErrors
+		switch {
+		case errors.As(err, &verrs):
+			form.Errors = verrs
+			h.renderAlbumForm(w, r, form, http.StatusUnprocessableEntity)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "Album not found", http.StatusNotFound)
+		default:
+			slog.Error("failed to update photo album", "album_id", album.ID, "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	slog.Info("photo album updated", "album_id", album.ID, "user_id", user.UserID)
+	redirect(w, r, "/admin/photos?status=saved")
+}
+
+// DeleteAlbum removes an album without photos. HTMX requests get an empty
+// response so the table row is removed in place.
+func (h *AdminPhotoHandler) DeleteAlbum(w http.ResponseWriter, r *http.Request) {
+	id, ok := photoIDParam(w, r, "Album not found")
+	if !ok {
+		return
+	}
+
+	if err := h.photos.DeleteAlbum(id); err != nil {
+		switch {
+		case errors.Is(err, services.ErrAlbumNotEmpty):
+			redirect(w, r, "/admin/photos?status=not-empty")
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "Album not found", http.StatusNotFound)
+		default:
+			slog.Error("failed to delete photo album", "album_id", id, "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	slog.Info("photo album deleted", "album_id", id, "user_id", user.UserID)
+
+	if r.Header.Get("HX-Request") == "true" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(w, r, "/admin/photos?status=deleted", http.StatusSeeOther)
+}
+
+// Album lists every photo in an album, drafts included, for moderation.
+func (h *AdminPhotoHandler) Album(w http.ResponseWriter, r *http.Request) {
+	album, ok := h.albumFromParam(w, r)
+	if !ok {
+		return
+	}
+
+	var notice, errMsg string
+	switch r.URL.Query().Get("status") {
+	case "created":
+		notice = "The album has been created. Upload photos to fill it."
+	case "uploaded":
+		notice = "The photos have been uploaded as drafts. Add alt text to each and publish them."
+	case "saved":
+		notice = "The photo has been saved."
+	case "cover":
+		notice = "The album cover has been changed."
+	case "deleted":
+		notice = "The photo has been deleted."
+	case "cover-draft":
+		errMsg = "Publish the photo before making it the album cover."
+	}
+
+	h.renderAlbum(w, r, *album, notice, errMsg, nil, http.StatusOK)
+}
+
+// UploadPage renders the upload form, with the album given by ?album=
+// chosen.
+func (h *AdminPhotoHandler) UploadPage(w http.ResponseWriter, r *http.Request) {
+	form := pages.PhotoUploadForm{AlbumID: r.URL.Query().Get("album")}
+	h.renderUploadForm(w, r, form, http.StatusOK)
+}
+
+// Upload stores the submitted photos as drafts. The request body is capped
+// just above MaxPhotosPerUpload files of the configured upload limit, so
+// oversized uploads are rejected while streaming. Requests that accept JSON,
+// as the upload page's script sends, get each file's outcome as JSON;
+// others continue to the album or see the files that were not accepted.
+func (h *AdminPhotoHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	form := pages.PhotoUploadForm{}
+	asJSON := strings.Contains(r.Header.Get("Accept"), "application/json")
+
+	limit := h.photos.MaxUploadSize()*services.MaxPhotosPerUpload + multipartOverhead
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+	if err := r.ParseMultipartForm(multipartOverhead); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			msg := fmt.Sprintf("Upload at most %d photos of %s or smaller at a time.", services.MaxPhotosPerUpload, services.FormatBytes(h.photos.MaxUploadSize()))
+			if asJSON {
+				writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"error": "validation failed", "fields": map[string]string{"photos": msg}})
+				return
+			}
+			form.Errors = map[string]string{"photos": msg}
+			h.renderUploadForm(w, r, form, http.StatusUnprocessableEntity)
+			return
+		}
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	form.AlbumID = r.PostFormValue("album_id")
+
+	headers := r.MultipartForm.File["photos"]
+	files := make([]services.PhotoUpload, 0, len(headers))
+	for _, header := range headers {
+		file, err := header.Open()
+		if err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		files = append(files, services.PhotoUpload{Name: header.Filename, File: file, Size: header.Size})
+	}
+
+	user := services.CurrentUser(r.Context())
+	results, err := h.photos.Upload(form.AlbumID, files, user.UserID)
+	if err != nil {
+		var verrs services.ValidationErrors
+		switch {
+		case errors.As(err, &verrs) && asJSON:
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"error": "validation failed", "fields": verrs})
+		case errors.As(err, &verrs):
+			form.Errors = verrs
+			h.renderUploadForm(w, r, form, http.StatusUnprocessableEntity)
+		default:
+			slog.Error("failed to upload photos", "album_id", form.AlbumID, "user_id", user.UserID, "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	uploaded := 0
+	for _, res := range results {
+		if res.Photo != nil {
+			uploaded++
+		}
+	}
+	slog.Info("photos uploaded", "album_id", form.AlbumID, "count", uploaded, "rejected", len(results)-uploaded, "user_id", user.UserID)
+
+	if asJSON {
+		writeJSON(w, http.StatusOK, map[string]any{"photos": uploadResultsJSON(results)})
+		return
+	}
+	if uploaded == len(results) {
+		redirect(w, r, adminAlbumPath(results[0].Photo.AlbumID, "?status=uploaded"))
+		return
+	}
+	form.Results = results
+	h.renderUploadForm(w, r, form, http.StatusUnprocessableEntity)
+}
+
+// uploadResultsJSON returns each file's outcome for the upload page's script.
+func uploadResultsJSON(results []services.UploadResult) []map[string]any {
+	out := make([]map[string]any, len(results))
+	for i, res := range results {
+		out[i] = map[string]any{"name": res.Name}
+		if res.Photo != nil {
+			out[i]["id"] = res.Photo.ID
+		} else {
+			out[i]["error"] = res.Error
+		}
+	}
+	return out
+}
+
+// UpdatePhoto saves a photo's alt text, caption, album, and whether it is
+// published. HTMX requests get the photo's card back, or an empty response
+// if the photo moved to another album, so the card is swapped in place.
+func (h *AdminPhotoHandler) UpdatePhoto(w http.ResponseWriter, r *http.Request) {
+	id, ok := photoIDParam(w, r, "Photo not found")
+	if !ok {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	photo, err := h.photos.GetPhoto(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Photo not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to get photo", "photo_id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	in := services.PhotoInput{
+		AltText:     r.PostFormValue("alt_text"),
+		Caption:     r.PostFormValue("caption"),
+		AlbumID:     r.PostFormValue("album_id"),
+		IsPublished: r.PostFormValue("is_published") == "1",
+	}
+
+	user := services.CurrentUser(r.Context())
+	updated, err := h.photos.UpdatePhoto(id, in)
+	if err != nil {
+		var verrs services.ValidationErrors
+		switch {
+		case errors.As(err, &verrs):
+			card := pages.PhotoCard{Photo: *photo, Input: in, Errors: verrs}
+			h.renderPhotoCard(w, r, card, http.StatusUnprocessableEntity)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "Photo not found", http.StatusNotFound)
+		default:
+			slog.Error("failed to update photo", "photo_id", id, "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	slog.Info("photo updated", "photo_id", id, "album_id", updated.AlbumID, "published", updated.IsPublished, "user_id", user.UserID)
+
+	if r.Header.Get("HX-Request") != "true" {
+		http.Redirect(w, r, adminAlbumPath(photo.AlbumID, "?status=saved"), http.StatusSeeOther)
+		return
+	}
+	if updated.AlbumID != photo.AlbumID {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	card := pages.PhotoCard{Photo: *updated, Input: services.PhotoInputFrom(*updated), Notice: "Saved."}
+	h.renderPhotoCard(w, r, card, http.StatusOK)
+}
+
+// SetCover makes a published photo the cover of its album.
+func (h *AdminPhotoHandler) SetCover(w http.ResponseWriter, r *http.Request) {
+	id, ok := photoIDParam(w, r, "Photo not found")
+	if !ok {
+		return
+	}
+
+	photo, err := h.photos.GetPhoto(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Photo not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to get photo", "photo_id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	if err := h.photos.SetCover(id); err != nil {
+		var verrs services.ValidationErrors
+		switch {
+		case errors.As(err, &verrs):
+			redirect(w, r, adminAlbumPath(photo.AlbumID, "?status=cover-draft"))
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "Photo not found", http.StatusNotFound)
+		default:
+			slog.Error("failed to set album cover", "photo_id", id, "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	slog.Info("album cover set", "album_id", photo.AlbumID, "photo_id", id, "user_id", user.UserID)
+	redirect(w, r, adminAlbumPath(photo.AlbumID, "?status=cover"))
+}
+
+// DeletePhoto removes a photo and its files. HTMX requests get an empty
+// response so the card is removed in place.
+func (h *AdminPhotoHandler) DeletePhoto(w http.ResponseWriter, r *http.Request) {
+	id, ok := photoIDParam(w, r, "Photo not found")
+	if !ok {
+		return
+	}
+
+	photo, err := h.photos.DeletePhoto(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Photo not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to delete photo", "photo_id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	slog.Info("photo deleted", "photo_id", id, "album_id", photo.AlbumID, "user_id", user.UserID)
+
+	if r.Header.Get("HX-Request") == "true" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(w, r, adminAlbumPath(photo.AlbumID, "?status=deleted"), http.StatusSeeOther)
+}
+
+// renderAlbum renders an album's moderation page. A card given in override
+// replaces the saved state of its photo, to show validation errors.
+func (h *AdminPhotoHandler) renderAlbum(w http.ResponseWriter, r *http.Request, album models.PhotoAlbum, notice, errMsg string, override *pages.PhotoCard, status int) {
+	photos, err := h.photos.AlbumPhotos(album.ID, false)
+	if err != nil {
+		slog.Error("failed to list album photos", "album_id", album.ID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	albums, err := h.photos.Albums()
+	if err != nil {
+		slog.Error("failed to list photo albums", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	cards := make([]pages.PhotoCard, len(photos))
+	for i, p := range photos {
+		cards[i] = pages.PhotoCard{Photo: p, Input: services.PhotoInputFrom(p)}
+		if override != nil && override.Photo.ID == p.ID {
+			cards[i] = *override
+		}
+		cards[i].Albums = albums
+		cards[i].IsCover = album.CoverPhotoID != nil && *album.CoverPhotoID == p.ID
+	}
+
+	w.WriteHeader(status)
+	component := pages.AdminPhotoAlbum(album, cards, notice, errMsg)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render photo album page", "album_id", album.ID, "error", err)
+	}
+}
+
+// renderPhotoCard renders just the card for HTMX submissions and the whole
+// album page otherwise.
+func (h *AdminPhotoHandler) renderPhotoCard(w http.ResponseWriter, r *http.Request, card pages.PhotoCard, status int) {
+	album, err := h.photos.GetAlbum(card.Photo.AlbumID)
+	if err != nil {
+		slog.Error("failed to get photo album", "album_id", card.Photo.AlbumID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Header.Get("HX-Request") != "true" {
+		h.renderAlbum(w, r, *album, "", "", &card, status)
+		return
+	}
+
+	card.Albums, err = h.photos.Albums()
+	if err != nil {
+		slog.Error("failed to list photo albums", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	card.IsCover = album.CoverPhotoID != nil && *album.CoverPhotoID == card.Photo.ID
+
+	w.WriteHeader(status)
+	if err := pages.PhotoCardFragment(card).Render(r.Context(), w); err != nil {
+		slog.Error("failed to render photo card", "photo_id", card.Photo.ID, "error", err)
+	}
+}
+
+// renderAlbumForm renders just the form for HTMX submissions and the full
+// page otherwise.
+func (h *AdminPhotoHandler) renderAlbumForm(w http.ResponseWriter, r *http.Request, form pages.PhotoAlbumForm, status int) {
+	component := pages.AdminPhotoAlbumForm(form)
+	if r.Header.Get("HX-Request") == "true" {
+		component = pages.PhotoAlbumFormFragment(form)
+	}
+
+	w.WriteHeader(status)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render photo album form", "album_id", form.ID, "error", err)
+	}
+}
+
+// renderUploadForm renders the upload page.
+func (h *AdminPhotoHandler) renderUploadForm(w http.ResponseWriter, r *http.Request, form pages.PhotoUploadForm, status int) {
+	albums, err := h.photos.Albums()
+	if err != nil {
+		slog.Error("failed to list photo albums", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	form.Albums = albums
+	form.MaxUploadSize = h.photos.MaxUploadSize()
+
+	w.WriteHeader(status)
+	if err := pages.AdminPhotoUpload(form).Render(r.Context(), w); err != nil {
+		slog.Error("failed to render photo upload page", "error", err)
+	}
+}
+
+// albumFromParam loads the album named by the {id} URL parameter, writing
+// an error response if there is none.
+func (h *AdminPhotoHandler) albumFromParam(w http.ResponseWriter, r *http.Request) (*models.PhotoAlbum, bool) {
+	id, ok := photoIDParam(w, r, "Album not found")
+	if !ok {
+		return nil, false
+	}
+
+	album, err := h.photos.GetAlbum(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Album not found", http.StatusNotFound)
+			return nil, false
+		}
+		slog.Error("failed to get photo album", "album_id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return album, true
+}
+
+// albumInput reads the album form.
+func albumInput(r *http.Request) services.AlbumInput {
+	return services.AlbumInput{
+		Title:       r.PostFormValue("title"),
+		Slug:        r.PostFormValue("slug"),
+		Description: r.PostFormValue("description"),
+		Visibility:  r.PostFormValue("visibility"),
+	}
+}
+
+func adminAlbumPath(id uint, query string) string {
+	return fmt.Sprintf("/admin/photos/albums/%d%s", id, query)
+}
+
+// photoIDParam parses the {id} URL parameter, writing a 404 with notFound
+// if it is invalid.
+func photoIDParam(w http.ResponseWriter, r *http.Request, notFound string) (uint, bool) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, notFound, http.StatusNotFound)
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sfdeloach/churchsite/internal/services"
+	"github.com/sfdeloach/churchsite/templates/pages"
+	"gorm.io/gorm"
+)
+
+// AdminStaffCategoryHandler handles management of staff categories for
+// admins.
+type AdminStaffCategoryHandler struct {
+	categories *services.StaffCategoryService
+}
+
+// NewAdminStaffCategoryHandler creates a new AdminStaffCategoryHandler.
+func NewAdminStaffCategoryHandler(categories *services.StaffCategoryService) *AdminStaffCategoryHandler {
+	return &AdminStaffCategoryHandler{categories: categories}
+}
+
+// Index lists every category in display order with how many staff members
+// belong to it.
+func (h *AdminStaffCategoryHandler) Index(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.categories.Ordered()
+	if err != nil {
+		slog.Error("failed to list staff categories", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	counts, err := h.categories.MemberCounts()
+	if err != nil {
+		slog.Error("failed to count staff members by category", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var notice, errMsg string
+	switch r.URL.Query().Get("status") {
+	case "saved":
+		notice = "The category has been saved."
+	case "deleted":
+		notice = "The category has been deleted."
+	case "in-use":
+		errMsg = "The category still has staff members, including deleted ones, so it cannot be deleted. Move them to another category first."
+	}
+
+	component := pages.AdminStaffCategories(categories, counts, notice, errMsg)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render staff categories page", "error", err)
+	}
+}
+
+// New renders an empty category form.
+func (h *AdminStaffCategoryHandler) New(w http.ResponseWriter, r *http.Request) {
+	h.renderForm(w, r, pages.StaffCategoryForm{}, http.StatusOK)
+}
+
+// Create stores a new category at the end of the display order.
+func (h *AdminStaffCategoryHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	form := pages.StaffCategoryForm{Input: services.StaffCategoryInput{
+		Label: r.PostFormValue("label"),
+		Slug:  r.PostFormValue("slug"),
+	}}
+
+	user := services.CurrentUser(r.Context())
+	category, err := h.categories.Create(form.Input)
+	if err != nil {
+		var verrs services.ValidationErrors
+		if errors.As(err, &verrs) {
+			form.Errors = verrs
+			h.renderForm(w, r, form, http.StatusUnprocessableEntity)
+			return
+		}
+		slog.Error("failed to create staff category", "user_id", user.UserID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("staff category created", "staff_category_id", category.ID, "slug", category.Slug, "user_id", user.UserID)
+	redirect(w, r, "/admin/staff-categories?status=saved")
+}
+
+// Edit renders the form for an existing category.
+func (h *AdminStaffCategoryHandler) Edit(w http.ResponseWriter, r *http.Request) {
+	id, ok := staffCategoryIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	category, err := h.categories.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Category not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to get staff category", "id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	form := pages.StaffCategoryForm{ID: id, Input: services.StaffCategoryInput{Label: category.Label, Slug: string(category.Slug)}}
+	h.renderForm(w, r, form, http.StatusOK)
+}
+
+// Update renames an existing category.
+func (h *AdminStaffCategoryHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, ok := staffCategoryIDParam(w, r)
+	if !ok {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	category, err := h.categories.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Category not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to get staff category", "id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	form := pages.StaffCategoryForm{ID: id, Input: services.StaffCategoryInput{
+		Label: r.PostFormValue("label"),
+		Slug:  string(category.Slug),
+	}}
+
+	user := services.CurrentUser(r.Context())
+	if _, err := h.categories.Update(id, form.Input); err != nil {
+		var verrs services.ValidationErrors
+		switch {
+		case errors.As(err, &verrs):
+			form.Errors = verrs
+			h.renderForm(w, r, form, http.StatusUnprocessableEntity)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "Category not found", http.StatusNotFound)
+		default:
+			slog.Error("failed to update staff category", "id", id, "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	slog.Info("staff category updated", "staff_category_id", id, "user_id", user.UserID)
+	redirect(w, r, "/admin/staff-categories?status=saved")
+}
+
+// Delete removes a category no staff member belongs to. HTMX requests get an
+// empty response so the table row is removed in place.
+func (h *AdminStaffCategoryHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := staffCategoryIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.categories.Delete(id); err != nil {
+		switch {
+		case errors.Is(err, services.ErrStaffCategoryInUse):
+			redirect(w, r, "/admin/staff-categories?status=in-use")
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "Category not found", http.StatusNotFound)
+		default:
+			slog.Error("failed to delete staff category", "id", id, "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	slog.Info("staff category deleted", "staff_category_id", id, "user_id", user.UserID)
+
+	if r.Header.Get("HX-Request") == "true" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(w, r, "/admin/staff-categories?status=deleted", http.StatusSeeOther)
+}
+
+// Reorder saves the display order posted by the drag-and-drop list. HTMX
+// requests get a status message to show in place.
+func (h *AdminStaffCategoryHandler) Reorder(w http.ResponseWriter, r *http.Request) {
+	ids, ok := parseOrderIDs(w, r)
+	if !ok {
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	if err := h.categories.Reorder(ids); err != nil {
+		var verrs services.ValidationErrors
+		if errors.As(err, &verrs) {
+			renderOrderStatus(w, r, http.StatusUnprocessableEntity, "error", verrs["order"])
+			return
+		}
+		slog.Error("failed to reorder staff categories", "user_id", user.UserID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("staff categories reordered", "count", len(ids), "user_id", user.UserID)
+
+	if r.Header.Get("HX-Request") == "true" {
+		renderOrderStatus(w, r, http.StatusOK, "success", "The new order has been saved.")
+		return
+	}
+	http.Redirect(w, r, "/admin/staff-categories?status=saved", http.StatusSeeOther)
+}
+
+// renderForm renders just the form for HTMX submissions and the full page
+// otherwise.
+func (h *AdminStaffCategoryHandler) renderForm(w http.ResponseWriter, r *http.Request, form pages.StaffCategoryForm, status int) {
+	component := pages.AdminStaffCategoryForm(form)
+	if r.Header.Get("HX-Request") == "true" {
+		component = pages.StaffCategoryFormFragment(form)
+	}
+
+	w.WriteHeader(status)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render staff category form", "id", form.ID, "error", err)
+	}
+}
+
+// staffCategoryIDParam parses the {id} URL parameter, writing a 404 if it is invalid.
+func staffCategoryIDParam(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Category not found", http.StatusNotFound)
+		return 0, false
+	}
+	return uint(id), true
+}
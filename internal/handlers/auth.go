@@ -0,0 +1,459 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sfdeloach/churchsite/internal/mail"
+	"github.com/sfdeloach/churchsite/internal/middleware"
+	"github.com/sfdeloach/churchsite/internal/models"
+	"github.com/sfdeloach/churchsite/internal/services"
+	"github.com/sfdeloach/churchsite/templates/emails"
+	"github.com/sfdeloach/churchsite/templates/pages"
+	"gorm.io/gorm"
+)
+
+// AuthHandler handles registration, login, logout, and email verification.
+type AuthHandler struct {
+	auth          *services.AuthService
+	sessions      *services.SessionService
+	limiter       *services.RateLimiter
+	outbox        *mail.Outbox
+	appURL        string
+	secureCookies bool
+}
+
+// NewAuthHandler creates a new AuthHandler. Cookies are marked Secure unless
+// running in development, where the site is served over plain HTTP.
+func NewAuthHandler(auth *services.AuthService, sessions *services.SessionService, limiter *services.RateLimiter, outbox *mail.Outbox, appURL string, devMode bool) *AuthHandler {
+	return &AuthHandler{
+		auth:          auth,
+		sessions:      sessions,
+		limiter:       limiter,
+		outbox:        outbox,
+		appURL:        appURL,
+		secureCookies: !devMode,
+	}
+}
+
+// LoginPage renders the login form. A ?next= path is carried through the form
+// so the user returns to the page that required authentication.
+func (h *AuthHandler) LoginPage(w http.ResponseWriter, r *http.Request) {
+	form := pages.LoginForm{Next: middleware.SafeRedirect(r.URL.Query().Get("next"), "")}
+	switch {
+	case r.URL.Query().Get("verified") == "1":
+		form.Success = "Your email address has been verified. You may now log in."
+	case r.URL.Query().Get("reset") == "1":
+		form.Success = "Your password has been reset. Please log in with your new password."
+	}
+	h.renderLogin(w, r, form, http.StatusOK)
+}
+
+// Login validates credentials and sets the session cookie.
+func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	email := r.PostFormValue("email")
+	password := r.PostFormValue("password")
+	next := middleware.SafeRedirect(r.PostFormValue("next"), "")
+	form := pages.LoginForm{Email: email, Next: next}
+
+	user, err := h.auth.Authenticate(email, password)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidCredentials):
+			form.Error = "Invalid email or password."
+		case errors.Is(err, services.ErrAccountLocked):
+			form.Error = "Too many failed attempts. Your account is locked for 15 minutes."
+		case errors.Is(err, services.ErrEmailNotVerified):
+			form.Error = "Please verify your email address before logging in."
+		default:
+			slog.Error("failed to authenticate user", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		h.renderLogin(w, r, form, http.StatusUnauthorized)
+		return
+	}
+
+	if _, err := h.startSession(w, user); err != nil {
+		slog.Error("failed to issue session", "user_id", user.ID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("user logged in", "user_id", user.ID)
+	http.Redirect(w, r, middleware.SafeRedirect(next, "/"), http.StatusSeeOther)
+}
+
+// Logout revokes the current session token and clears the session cookie.
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if err := h.revokeSession(r); err != nil {
+		slog.Error("failed to revoke session", "error", err)
+	}
+	h.clearSessionCookie(w)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// APILogout revokes the current session token server-side and clears the cookie.
+func (h *AuthHandler) APILogout(w http.ResponseWriter, r *http.Request) {
+	if err := h.revokeSession(r); err != nil {
+		slog.Error("failed to revoke session", "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to revoke session"})
+		return
+	}
+	h.clearSessionCookie(w)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// APIRefresh exchanges a valid session token for a new one with a fresh
+// expiry and current roles. The old token is revoked.
+func (h *AuthHandler) APIRefresh(w http.ResponseWriter, r *http.Request) {
+	claims, err := h.sessions.Parse(r.Context(), middleware.SessionToken(r))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSession) {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid session"})
+			return
+		}
+		slog.Error("failed to parse session", "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to refresh session"})
+		return
+	}
+
+	user, err := h.auth.GetByID(claims.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid session"})
+			return
+		}
+		slog.Error("failed to load user for refresh", "user_id", claims.UserID, "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to refresh session"})
+		return
+	}
+
+	if err := h.sessions.Revoke(r.Context(), claims); err != nil {
+		slog.Error("failed to revoke session", "user_id", user.ID, "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to refresh session"})
+		return
+	}
+
+	expires, err := h.startSession(w, user)
+	if err != nil {
+		slog.Error("failed to issue session", "user_id", user.ID, "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to refresh session"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "expires_at": expires})
+}
+
+// RegisterPage renders the registration form.
+func (h *AuthHandler) RegisterPage(w http.ResponseWriter, r *http.Request) {
+	h.renderRegister(w, r, pages.RegisterForm{}, http.StatusOK)
+}
+
+// Register creates a new unverified account and sends the verification link.
+func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	in := services.RegisterInput{
+		Email:     r.PostFormValue("email"),
+		Password:  r.PostFormValue("password"),
+		FirstName: r.PostFormValue("first_name"),
+		LastName:  r.PostFormValue("last_name"),
+		Phone:     r.PostFormValue("phone"),
+	}
+	form := pages.RegisterForm{
+		Email:     in.Email,
+		FirstName: in.FirstName,
+		LastName:  in.LastName,
+		Phone:     in.Phone,
+	}
+
+	user, token, err := h.auth.Register(in)
+	if err != nil {
+		var verrs services.ValidationErrors
+		switch {
+		case errors.As(err, &verrs):
+			form.Errors = verrs
+		case errors.Is(err, services.ErrEmailTaken):
+			form.Errors = map[string]string{"email": "An account with that email already exists."}
+		default:
+			slog.Error("failed to register user", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		h.renderRegister(w, r, form, http.StatusUnprocessableEntity)
+		return
+	}
+
+	link := h.appURL + "/verify-email/" + token
+	if err := h.outbox.Queue(r.Context(), userAddress(user), "Verify your email address", emails.VerifyEmail(user.FirstName, link)); err != nil {
+		slog.Error("failed to queue verification email", "user_id", user.ID, "error", err)
+	}
+
+	component := pages.AuthMessage(
+		"Check Your Email",
+		"We sent a verification link to "+user.Email+". Follow the link to activate your account.",
+		"/login",
+		"Go to Login",
+	)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render registration confirmation page", "error", err)
+	}
+}
+
+// VerifyEmail consumes a verification token from the emailed link.
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+
+	user, err := h.auth.VerifyEmail(token)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidToken) {
+			w.WriteHeader(http.StatusNotFound)
+			component := pages.AuthMessage(
+				"Verification Failed",
+				"This verification link is invalid or has already been used.",
+				"/login",
+				"Go to Login",
+			)
+			if err := component.Render(r.Context(), w); err != nil {
+				slog.Error("failed to render verification failure page", "error", err)
+			}
+			return
+		}
+		slog.Error("failed to verify email", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("user verified email", "user_id", user.ID)
+	http.Redirect(w, r, "/login?verified=1", http.StatusSeeOther)
+}
+
+// ForgotPasswordPage renders the password reset request form.
+func (h *AuthHandler) ForgotPasswordPage(w http.ResponseWriter, r *http.Request) {
+	h.renderForgotPassword(w, r, "", "", http.StatusOK)
+}
+
+// ForgotPassword issues a reset link. The response is identical whether or
+// not the address exists, and requests are limited to 3 per hour per email.
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	email := services.NormalizeEmail(r.PostFormValue("email"))
+	if email == "" {
+		h.renderForgotPassword(w, r, email, "Enter your email address.", http.StatusUnprocessableEntity)
+		return
+	}
+
+	allowed, err := h.limiter.Allow(r.Context(), "rate:reset:"+email, 3, time.Hour)
+	if err != nil {
+		slog.Error("failed to check reset rate limit", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if allowed {
+		user, token, err := h.auth.RequestPasswordReset(email)
+		if err != nil {
+			slog.Error("failed to create password reset", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if user != nil {
+			link := h.appURL + "/reset-password/" + token
+			if err := h.outbox.Queue(r.Context(), userAddress(user), "Reset your password", emails.PasswordReset(user.FirstName, link)); err != nil {
+				slog.Error("failed to queue password reset email", "user_id", user.ID, "error", err)
+			}
+		}
+	} else {
+		slog.Warn("password reset rate limit exceeded")
+	}
+
+	component := pages.AuthMessage(
+		"Check Your Email",
+		"If an account exists for "+email+", you will receive an email with a link to reset your password. The link expires in one hour.",
+		"/login",
+		"Back to Login",
+	)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render forgot password confirmation page", "error", err)
+	}
+}
+
+// ResetPasswordPage renders the new password form if the token is valid.
+func (h *AuthHandler) ResetPasswordPage(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+
+	if _, err := h.auth.CheckResetToken(token); err != nil {
+		if errors.Is(err, services.ErrInvalidToken) {
+			h.renderInvalidResetLink(w, r)
+			return
+		}
+		slog.Error("failed to check reset token", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.renderResetPassword(w, r, token, "", http.StatusOK)
+}
+
+// ResetPassword sets the new password and revokes every outstanding session
+// for the user.
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	password := r.PostFormValue("password")
+	if password != r.PostFormValue("password_confirm") {
+		h.renderResetPassword(w, r, token, "Passwords do not match.", http.StatusUnprocessableEntity)
+		return
+	}
+
+	user, err := h.auth.ResetPassword(token, password)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidToken):
+			h.renderInvalidResetLink(w, r)
+		case errors.Is(err, services.ErrWeakPassword):
+			h.renderResetPassword(w, r, token, services.PasswordPolicy, http.StatusUnprocessableEntity)
+		default:
+			slog.Error("failed to reset password", "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := h.sessions.RevokeAllForUser(r.Context(), user.ID); err != nil {
+		slog.Error("failed to revoke sessions after password reset", "user_id", user.ID, "error", err)
+	}
+	h.clearSessionCookie(w)
+
+	slog.Info("user reset password", "user_id", user.ID)
+	http.Redirect(w, r, "/login?reset=1", http.StatusSeeOther)
+}
+
+// userAddress returns the mail recipient for a user.
+func userAddress(user *models.User) mail.Address {
+	return mail.Address{Name: user.FullName(), Email: user.Email}
+}
+
+func (h *AuthHandler) renderForgotPassword(w http.ResponseWriter, r *http.Request, email, errMsg string, status int) {
+	w.WriteHeader(status)
+	component := pages.AuthForgotPassword(email, errMsg)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render forgot password page", "error", err)
+	}
+}
+
+func (h *AuthHandler) renderResetPassword(w http.ResponseWriter, r *http.Request, token, errMsg string, status int) {
+	w.WriteHeader(status)
+	component := pages.AuthResetPassword(token, errMsg)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render reset password page", "error", err)
+	}
+}
+
+func (h *AuthHandler) renderInvalidResetLink(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNotFound)
+	component := pages.AuthMessage(
+		"Reset Link Expired",
+		"This password reset link is invalid or has expired. Reset links are valid for one hour.",
+		"/forgot-password",
+		"Request a New Link",
+	)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render invalid reset link page", "error", err)
+	}
+}
+
+func (h *AuthHandler) renderLogin(w http.ResponseWriter, r *http.Request, form pages.LoginForm, status int) {
+	w.WriteHeader(status)
+	component := pages.AuthLogin(form)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render login page", "error", err)
+	}
+}
+
+func (h *AuthHandler) renderRegister(w http.ResponseWriter, r *http.Request, form pages.RegisterForm, status int) {
+	w.WriteHeader(status)
+	component := pages.AuthRegister(form)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render register page", "error", err)
+	}
+}
+
+// startSession issues a token carrying the user's current roles and sets it
+// as the session cookie. Returns the token's expiry.
+func (h *AuthHandler) startSession(w http.ResponseWriter, user *models.User) (time.Time, error) {
+	roles, err := h.auth.RoleNames(user.ID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	token, expires, err := h.sessions.Issue(user, roles)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	h.setSessionCookie(w, token, expires)
+	return expires, nil
+}
+
+// revokeSession blacklists the request's session token, if it carries a valid one.
+func (h *AuthHandler) revokeSession(r *http.Request) error {
+	token := middleware.SessionToken(r)
+	if token == "" {
+		return nil
+	}
+
+	claims, err := h.sessions.Parse(r.Context(), token)
+	if errors.Is(err, services.ErrInvalidSession) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return h.sessions.Revoke(r.Context(), claims)
+}
+
+func (h *AuthHandler) setSessionCookie(w http.ResponseWriter, token string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     middleware.SessionCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   h.secureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (h *AuthHandler) clearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     middleware.SessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   h.secureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
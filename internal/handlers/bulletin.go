@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sfdeloach/churchsite/internal/models"
+	"github.com/sfdeloach/churchsite/internal/services"
+	"github.com/sfdeloach/churchsite/templates/pages"
+	"gorm.io/gorm"
+)
+
+// BulletinHandler handles the public bulletin listing and PDF downloads.
+type BulletinHandler struct {
+	bulletins *services.BulletinService
+}
+
+// NewBulletinHandler creates a new BulletinHandler.
+func NewBulletinHandler(bulletins *services.BulletinService) *BulletinHandler {
+	return &BulletinHandler{bulletins: bulletins}
+}
+
+// bulletinJSON is a single bulletin in API responses.
+type bulletinJSON struct {
+	ID          uint      `json:"id"`
+	URL         string    `json:"url"`
+	FileSize    int64     `json:"file_size"`
+	MembersOnly bool      `json:"members_only"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+}
+
+// Index lists the bulletins from the past two weeks, grouped by Lord's Day.
+func (h *BulletinHandler) Index(w http.ResponseWriter, r *http.Request) {
+	days, err := h.bulletins.GetRecent(time.Now())
+	if err != nil {
+		slog.Error("failed to get bulletins", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	component := pages.BulletinsIndex(days)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render bulletins page", "error", err)
+	}
+}
+
+// Download redirects the bulletin's former address, by ID, to its file.
+func (h *BulletinHandler) Download(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Bulletin not found", http.StatusNotFound)
+		return
+	}
+
+	bulletin, err := h.bulletins.GetByID(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Bulletin not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to get bulletin", "id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, pages.BulletinURL(*bulletin), http.StatusMovedPermanently)
+}
+
+// APICurrent returns the bulletins for the current Lord's Day.
+func (h *BulletinHandler) APICurrent(w http.ResponseWriter, r *http.Request) {
+	day, err := h.bulletins.GetCurrent(time.Now())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "no current bulletins"})
+			return
+		}
+		slog.Error("failed to get current bulletins", "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to get bulletins"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"date":    day.Date.Format(services.DateInputLayout),
+		"morning": toBulletinJSON(day.Morning),
+		"evening": toBulletinJSON(day.Evening),
+	})
+}
+
+func toBulletinJSON(b *models.Bulletin) *bulletinJSON {
+	if b == nil {
+		return nil
+	}
+	return &bulletinJSON{
+		ID:          b.ID,
+		URL:         pages.BulletinURL(*b),
+		FileSize:    b.FileSize,
+		MembersOnly: b.MembersOnly,
+		UploadedAt:  b.UploadedAt,
+	}
+}
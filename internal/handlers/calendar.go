@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sfdeloach/churchsite/internal/ical"
+	"github.com/sfdeloach/churchsite/internal/models"
+	"github.com/sfdeloach/churchsite/internal/services"
+	"github.com/sfdeloach/churchsite/templates/components"
+	"github.com/sfdeloach/churchsite/templates/pages"
+	"gorm.io/gorm"
+)
+
+// Calendar view names accepted by the ?view= query parameter.
+const (
+	calendarViewMonth = "month"
+	calendarViewWeek  = "week"
+	calendarViewList  = "list"
+)
+
+// calendarName is the subscription name shown by calendar apps.
+const calendarName = "Saint Andrew's Chapel"
+
+// CalendarHandler handles the public events calendar and its iCalendar feeds.
+type CalendarHandler struct {
+	events     *services.EventService
+	ministries *services.MinistryService
+	appURL     string
+}
+
+// NewCalendarHandler creates a new CalendarHandler. appURL is used for
+// absolute links and UIDs in iCalendar output.
+func NewCalendarHandler(events *services.EventService, ministries *services.MinistryService, appURL string) *CalendarHandler {
+	return &CalendarHandler{events: events, ministries: ministries, appURL: appURL}
+}
+
+// Index renders the calendar in month, week, or list view for the date given
+// by ?date=YYYY-MM-DD (default today). HTMX requests receive only the
+// calendar fragment so navigation can swap it in place; history restores
+// get the full page.
+func (h *CalendarHandler) Index(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+
+	anchor := now
+	if d := r.URL.Query().Get("date"); d != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", d, time.Local)
+		if err != nil {
+			http.Error(w, "Invalid date", http.StatusBadRequest)
+			return
+		}
+		anchor = parsed
+	}
+
+	view := components.CalendarView{View: r.URL.Query().Get("view"), Anchor: anchor}
+
+	var start, end time.Time
+	switch view.View {
+	case calendarViewWeek:
+		start = services.StartOfWeek(anchor)
+		end = start.AddDate(0, 0, 7)
+		view.Title = "Week of " + start.Format("January 2, 2006")
+		view.Prev = start.AddDate(0, 0, -7)
+		view.Next = end
+	case calendarViewList:
+		start = services.StartOfMonth(anchor)
+		end = start.AddDate(0, 1, 0)
+		view.Title = start.Format("January 2006")
+		view.Prev = start.AddDate(0, -1, 0)
+		view.Next = end
+	default:
+		view.View = calendarViewMonth
+		start, end = services.MonthGridRange(anchor)
+		month := services.StartOfMonth(anchor)
+		view.Title = month.Format("January 2006")
+		view.Prev = month.AddDate(0, -1, 0)
+		view.Next = month.AddDate(0, 1, 0)
+	}
+
+	events, err := h.events.GetPublicInRange(start, end)
+	if err != nil {
+		slog.Error("failed to load calendar events", "start", start, "end", end, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	switch view.View {
+	case calendarViewWeek:
+		view.Days = services.BuildWeek(start, events, now)
+	case calendarViewList:
+		view.Days = services.BuildAgenda(events, now)
+	default:
+		view.Weeks = services.BuildMonthGrid(anchor, events, now)
+	}
+
+	w.Header().Add("Vary", "HX-Request")
+	if r.Header.Get("HX-Request") == "true" && r.Header.Get("HX-History-Restore-Request") != "true" {
+		if err := components.Calendar(view).Render(r.Context(), w); err != nil {
+			slog.Error("failed to render calendar fragment", "error", err)
+		}
+		return
+	}
+
+	component := pages.CalendarIndex(view)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render calendar page", "error", err)
+	}
+}
+
+// Show renders a single event detail page. For recurring events ?date=YYYY-MM-DD
+// selects which occurrence to show.
+func (h *CalendarHandler) Show(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return
+	}
+
+	var event *models.Event
+	if d := r.URL.Query().Get("date"); d != "" {
+		day, parseErr := time.ParseInLocation("2006-01-02", d, time.Local)
+		if parseErr != nil {
+			http.Error(w, "Invalid date", http.StatusBadRequest)
+			return
+		}
+		event, err = h.events.GetPublicOccurrence(uint(id), day)
+	} else {
+		event, err = h.events.GetPublicByID(uint(id))
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Event not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to load event", "id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	component := pages.EventShow(*event)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render event page", "id", id, "error", err)
+	}
+}
+
+// Feed serves every public event as an iCalendar subscription.
+func (h *CalendarHandler) Feed(w http.ResponseWriter, r *http.Request) {
+	h.serveFeed(w, r, calendarName, nil)
+}
+
+// MinistryFeed serves one ministry's public events as an iCalendar subscription.
+func (h *CalendarHandler) MinistryFeed(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+
+	ministry, err := h.ministries.GetBySlug(slug)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Ministry not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to load ministry", "slug", slug, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.serveFeed(w, r, calendarName+" — "+ministry.Name, &ministry.ID)
+}
+
+// serveFeed writes an iCalendar feed, answering If-None-Match from a cheap
+// version query so polling clients don't cause the events to be reloaded.
+func (h *CalendarHandler) serveFeed(w http.ResponseWriter, r *http.Request, name string, ministryID *uint) {
+	version, err := h.events.PublicFeedVersion(ministryID)
+	if err != nil {
+		slog.Error("failed to compute feed version", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	etag := `"` + version + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "public, max-age=900")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	events, exceptions, err := h.events.GetPublicFeed(ministryID)
+	if err != nil {
+		slog.Error("failed to load feed events", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	cal := ical.Calendar{Name: name, BaseURL: h.appURL, Events: events, Exceptions: exceptions}
+	w.Header().Set("Content-Type", ical.ContentType)
+	if _, err := w.Write(cal.Encode()); err != nil {
+		slog.Error("failed to write feed", "error", err)
+	}
+}
+
+// Download serves a single event, including its recurrence, as an .ics file.
+func (h *CalendarHandler) Download(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return
+	}
+
+	event, err := h.events.GetPublicByID(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Event not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to load event", "id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	exceptions, err := h.events.GetExceptions([]models.Event{*event})
+	if err != nil {
+		slog.Error("failed to load event exceptions", "id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	cal := ical.Calendar{Name: event.Title, BaseURL: h.appURL, Events: []models.Event{*event}, Exceptions: exceptions}
+	w.Header().Set("Content-Type", ical.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="event-%d.ics"`, event.ID))
+	if _, err := w.Write(cal.Encode()); err != nil {
+		slog.Error("failed to write event download", "id", id, "error", err)
+	}
+}
+
+// etagMatches reports whether an If-None-Match header matches etag, using
+// the weak comparison RFC 9110 prescribes for If-None-Match.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/sfdeloach/churchsite/templates/pages"
+)
+
+// DashboardHandler handles the landing pages for each gated section.
+type DashboardHandler struct{}
+
+// NewDashboardHandler creates a new DashboardHandler.
+func NewDashboardHandler() *DashboardHandler {
+	return &DashboardHandler{}
+}
+
+// Member renders the member dashboard.
+func (h *DashboardHandler) Member(w http.ResponseWriter, r *http.Request) {
+	h.render(w, r, "Member Dashboard", "Resources for members of Saint Andrew's Chapel", memberLinks)
+}
+
+// Staff renders the staff dashboard.
+func (h *DashboardHandler) Staff(w http.ResponseWriter, r *http.Request) {
+	h.render(w, r, "Staff Dashboard", "Manage site content", staffLinks)
+}
+
+// Elder renders the elder/pastor dashboard.
+func (h *DashboardHandler) Elder(w http.ResponseWriter, r *http.Request) {
+	h.render(w, r, "Elder Dashboard", "Shepherding resources for elders and pastors", elderLinks)
+}
+
+// Admin renders the admin dashboard.
+func (h *DashboardHandler) Admin(w http.ResponseWriter, r *http.Request) {
+	h.render(w, r, "Admin Dashboard", "Site administration", adminLinks)
+}
+
+func (h *DashboardHandler) render(w http.ResponseWriter, r *http.Request, title, subtitle string, links []pages.DashboardLink) {
+	component := pages.Dashboard(title, subtitle, links)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render dashboard", "title", title, "error", err)
+	}
+}
+
+var memberLinks = []pages.DashboardLink{
+	{Href: "/member/directory", Label: "Member Directory", Description: "Find contact details for members who have opted in."},
+	{Href: "/member/profile", Label: "My Profile", Description: "Update your contact details, directory privacy settings, and household."},
+}
+
+var staffLinks = []pages.DashboardLink{
+	{Href: "/staff/events", Label: "Events", Description: "Create and edit calendar events, recurrence, and registration."},
+	{Href: "/staff/announcements", Label: "Announcements", Description: "Publish announcements and urgent site-wide banners."},
+	{Href: "/staff/bulletins", Label: "Bulletins", Description: "Upload morning and evening bulletins for the Lord's Day."},
+	{Href: "/staff/ministries", Label: "Ministries", Description: "Edit ministry pages and choose which members may edit them."},
+	{Href: "/staff/about/staff", Label: "Pastors & Staff", Description: "Add and edit staff members, upload headshots, and set the order they appear in."},
+	{Href: "/staff/forms", Label: "Forms", Description: "Review responses to the site's forms and export them as CSV."},
+	{Href: "/staff/households", Label: "Households", Description: "Add adults to families, and merge or split households."},
+}
+
+var elderLinks = []pages.DashboardLink{}
+
+var adminLinks = []pages.DashboardLink{
+	{Href: "/admin/staff-categories", Label: "Staff Categories", Description: "Add, rename, and order the groups on the Pastors & Staff page."},
+	{Href: "/admin/photos", Label: "Photo Gallery", Description: "Create albums, upload photos, and review them before they are published."},
+}
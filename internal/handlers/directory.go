@@ -0,0 +1,492 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sfdeloach/churchsite/internal/directorypdf"
+	"github.com/sfdeloach/churchsite/internal/models"
+	"github.com/sfdeloach/churchsite/internal/services"
+	"github.com/sfdeloach/churchsite/templates/pages"
+	"gorm.io/gorm"
+)
+
+// maxDirectoryQuery bounds the length of a directory search.
+const maxDirectoryQuery = 100
+
+// DirectoryHandler handles the member directory, members' own profiles, and
+// the households they belong to. Members can start a household and manage its
+// children; staff join, merge, and split adults' households (see
+// StaffHouseholdHandler).
+type DirectoryHandler struct {
+	directory  *services.DirectoryService
+	households *services.HouseholdService
+	staff      *services.StaffMemberService
+	categories *services.StaffCategoryService
+}
+
+// NewDirectoryHandler creates a new DirectoryHandler.
+func NewDirectoryHandler(directory *services.DirectoryService, households *services.HouseholdService, staff *services.StaffMemberService, categories *services.StaffCategoryService) *DirectoryHandler {
+	return &DirectoryHandler{directory: directory, households: households, staff: staff, categories: categories}
+}
+
+// Index renders the directory, filtered by ?q= name search or ?letter=
+// initial. HTMX requests receive only the results so the search box and
+// letter links can swap them in place.
+func (h *DirectoryHandler) Index(w http.ResponseWriter, r *http.Request) {
+	query, letter := directoryFilters(r)
+
+	entries, err := h.directory.Search(query, letter)
+	if err != nil {
+		slog.Error("failed to search directory", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	view := pages.DirectoryView{Query: query, Letter: letter, Entries: entries}
+
+	w.Header().Add("Vary", "HX-Request")
+	if r.Header.Get("HX-Request") == "true" && r.Header.Get("HX-History-Restore-Request") != "true" {
+		if err := pages.DirectoryResults(view).Render(r.Context(), w); err != nil {
+			slog.Error("failed to render directory results", "error", err)
+		}
+		return
+	}
+
+	view.Letters, err = h.directory.Letters()
+	if err != nil {
+		slog.Error("failed to load directory letters", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := pages.Directory(view).Render(r.Context(), w); err != nil {
+		slog.Error("failed to render directory", "error", err)
+	}
+}
+
+// PDF downloads the printable directory, built on request so it always
+// reflects members' current privacy settings.
+func (h *DirectoryHandler) PDF(w http.ResponseWriter, r *http.Request) {
+	book, err := directorypdf.Load(h.directory, h.staff, h.categories, time.Now())
+	if err != nil {
+		slog.Error("failed to load directory for PDF", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	book.Photo = directorypdf.LocalPhotos("static", h.staff, h.directory)
+
+	var buf bytes.Buffer
+	if _, err := book.WriteTo(&buf); err != nil {
+		slog.Error("failed to render directory PDF", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	slog.Info("directory PDF downloaded", "user_id", user.UserID, "entries", len(book.Entries), "size", buf.Len())
+
+	w.Header().Set("Content-Type", directorypdf.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, directorypdf.Filename(book.GeneratedAt)))
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	if _, err := buf.WriteTo(w); err != nil {
+		slog.Error("failed to write directory PDF", "error", err)
+	}
+}
+
+// APISearch returns directory entries matching ?q= and ?letter= as JSON.
+// Only the contact details each member has chosen to share are included.
+func (h *DirectoryHandler) APISearch(w http.ResponseWriter, r *http.Request) {
+	query, letter := directoryFilters(r)
+
+	entries, err := h.directory.Search(query, letter)
+	if err != nil {
+		slog.Error("failed to search directory", "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to search directory"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"members": entries})
+}
+
+// ProfilePage renders the logged-in member's profile form and household.
+func (h *DirectoryHandler) ProfilePage(w http.ResponseWriter, r *http.Request) {
+	user := services.CurrentUser(r.Context())
+
+	form, err := h.profileForm(user.UserID)
+	if err != nil {
+		slog.Error("failed to load profile", "user_id", user.UserID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	switch r.URL.Query().Get("status") {
+	case "saved":
+		form.Success = "Your profile has been saved."
+	case "household-created":
+		form.Success = "Your household has been created."
+	case "child-added":
+		form.Success = "The child has been added to your household."
+	case "photo-saved":
+		form.PhotoSuccess = "Your photo has been saved."
+	case "photo-removed":
+		form.PhotoSuccess = "Your photo has been removed."
+	}
+	h.renderProfile(w, r, form, http.StatusOK)
+}
+
+// SaveProfile updates the logged-in member's profile and privacy settings.
+func (h *DirectoryHandler) SaveProfile(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	form := pages.ProfileForm{
+		UserID: user.UserID,
+		Email:  user.Email,
+		Input: services.ProfileInput{
+			FirstName:        r.PostFormValue("first_name"),
+			LastName:         r.PostFormValue("last_name"),
+			Phone:            r.PostFormValue("phone"),
+			AddressInput:     addressInputFrom(r),
+			HouseholdPhone:   r.PostFormValue("household_phone"),
+			EmergencyContact: r.PostFormValue("emergency_contact"),
+			EmergencyPhone:   r.PostFormValue("emergency_phone"),
+			DirectoryOptIn:   r.PostFormValue("directory_opt_in") == "1",
+			ShowEmail:        r.PostFormValue("show_email") == "1",
+			ShowPhone:        r.PostFormValue("show_phone") == "1",
+			ShowAddress:      r.PostFormValue("show_address") == "1",
+		},
+	}
+
+	if _, err := h.directory.SaveProfile(user.UserID, form.Input); err != nil {
+		var verrs services.ValidationErrors
+		if errors.As(err, &verrs) {
+			saved, err := h.profileForm(user.UserID)
+			if err != nil {
+				slog.Error("failed to load profile", "user_id", user.UserID, "error", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			form.Errors = verrs
+			form.Household = saved.Household
+			form.PhotoURL = saved.PhotoURL
+			form.MaxUploadSize = saved.MaxUploadSize
+			h.renderProfile(w, r, form, http.StatusUnprocessableEntity)
+			return
+		}
+		slog.Error("failed to save profile", "user_id", user.UserID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("member profile saved", "user_id", user.UserID, "directory_opt_in", form.Input.DirectoryOptIn)
+	redirect(w, r, "/member/profile?status=saved")
+}
+
+// UploadPhoto replaces the logged-in member's directory photo. The request
+// body is capped just above the configured upload limit so oversized photos
+// are rejected while streaming.
+func (h *DirectoryHandler) UploadPhoto(w http.ResponseWriter, r *http.Request) {
+	user := services.CurrentUser(r.Context())
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.directory.MaxUploadSize()+multipartOverhead)
+	if err := r.ParseMultipartForm(multipartOverhead); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.renderPhotoError(w, r, user.UserID, "The photo must be "+services.FormatBytes(h.directory.MaxUploadSize())+" or smaller.")
+			return
+		}
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	var (
+		file io.Reader
+		size int64
+	)
+	f, header, err := r.FormFile("photo")
+	switch {
+	case err == nil:
+		defer f.Close()
+		file, size = f, header.Size
+	case !errors.Is(err, http.ErrMissingFile):
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.directory.SavePhoto(user.UserID, file, size); err != nil {
+		var verrs services.ValidationErrors
+		if errors.As(err, &verrs) {
+			h.renderPhotoError(w, r, user.UserID, verrs["photo"])
+			return
+		}
+		slog.Error("failed to save profile photo", "user_id", user.UserID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("profile photo saved", "user_id", user.UserID)
+	redirect(w, r, "/member/profile?status=photo-saved")
+}
+
+// RemovePhoto removes the logged-in member's directory photo.
+func (h *DirectoryHandler) RemovePhoto(w http.ResponseWriter, r *http.Request) {
+	user := services.CurrentUser(r.Context())
+
+	if err := h.directory.RemovePhoto(user.UserID); err != nil {
+		slog.Error("failed to remove profile photo", "user_id", user.UserID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("profile photo removed", "user_id", user.UserID)
+	redirect(w, r, "/member/profile?status=photo-removed")
+}
+
+// renderPhotoError re-renders the whole profile page with a problem with the
+// uploaded photo.
+func (h *DirectoryHandler) renderPhotoError(w http.ResponseWriter, r *http.Request, userID uint, msg string) {
+	form, err := h.profileForm(userID)
+	if err != nil {
+		slog.Error("failed to load profile", "user_id", userID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	form.PhotoError = msg
+
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	if err := pages.Profile(form).Render(r.Context(), w); err != nil {
+		slog.Error("failed to render profile page", "error", err)
+	}
+}
+
+// Create starts a household for the logged-in member, named after them and
+// using their current address and phone.
+func (h *DirectoryHandler) CreateHousehold(w http.ResponseWriter, r *http.Request) {
+	user := services.CurrentUser(r.Context())
+
+	profile, err := h.directory.GetProfile(user.UserID)
+	if err != nil {
+		slog.Error("failed to get profile", "user_id", user.UserID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	in := services.ProfileInputFrom(*profile, nil)
+	household, err := h.households.Create(services.HouseholdInput{
+		Name:         in.LastName,
+		Phone:        in.Phone,
+		AddressInput: in.AddressInput,
+	}, []uint{user.UserID})
+	if err != nil {
+		if errors.Is(err, services.ErrAlreadyInHousehold) {
+			redirect(w, r, "/member/profile")
+			return
+		}
+		slog.Error("failed to create household", "user_id", user.UserID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("household created", "household_id", household.ID, "user_id", user.UserID)
+	redirect(w, r, "/member/profile?status=household-created")
+}
+
+// AddChild adds a child without a login to the logged-in member's household.
+func (h *DirectoryHandler) AddChild(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	household, err := h.households.GetForUser(user.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Household not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to get household", "user_id", user.UserID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	form := pages.ChildForm{
+		Input: services.ChildInput{
+			FirstName: r.PostFormValue("child_first_name"),
+			LastName:  r.PostFormValue("child_last_name"),
+			BirthDate: r.PostFormValue("child_birth_date"),
+		},
+	}
+
+	child, err := h.households.AddChild(household.ID, form.Input)
+	if err != nil {
+		var verrs services.ValidationErrors
+		if errors.As(err, &verrs) {
+			form.Errors = verrs
+			h.renderChildForm(w, r, user.UserID, form)
+			return
+		}
+		slog.Error("failed to add child", "household_id", household.ID, "user_id", user.UserID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("child added to household", "household_id", household.ID, "member_id", child.ID, "user_id", user.UserID)
+	redirect(w, r, "/member/profile?status=child-added")
+}
+
+// RemoveChild removes a child from the logged-in member's household. HTMX
+// requests get an empty response so the list item is removed in place.
+func (h *DirectoryHandler) RemoveChild(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Household member not found", http.StatusNotFound)
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	household, err := h.households.GetForUser(user.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Household member not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to get household", "user_id", user.UserID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	isChild := false
+	for _, m := range household.Members {
+		if m.ID == uint(id) && m.IsChild() {
+			isChild = true
+		}
+	}
+	if !isChild {
+		http.Error(w, "Household member not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.households.RemoveMember(household.ID, uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Household member not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to remove child", "household_id", household.ID, "member_id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("child removed from household", "household_id", household.ID, "member_id", id, "user_id", user.UserID)
+
+	if r.Header.Get("HX-Request") == "true" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(w, r, "/member/profile", http.StatusSeeOther)
+}
+
+// renderChildForm re-renders the add-child form with validation errors: just
+// the form for HTMX submissions, otherwise the whole profile page.
+func (h *DirectoryHandler) renderChildForm(w http.ResponseWriter, r *http.Request, userID uint, child pages.ChildForm) {
+	if r.Header.Get("HX-Request") == "true" {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		if err := pages.ChildFormFragment(child).Render(r.Context(), w); err != nil {
+			slog.Error("failed to render child form", "error", err)
+		}
+		return
+	}
+
+	form, err := h.profileForm(userID)
+	if err != nil {
+		slog.Error("failed to load profile", "user_id", userID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	form.Child = child
+	h.renderProfile(w, r, form, http.StatusUnprocessableEntity)
+}
+
+// profileForm loads the member's saved profile and household into a form.
+func (h *DirectoryHandler) profileForm(userID uint) (pages.ProfileForm, error) {
+	form := pages.ProfileForm{UserID: userID, MaxUploadSize: h.directory.MaxUploadSize()}
+
+	profile, err := h.directory.GetProfile(userID)
+	if err != nil {
+		return form, err
+	}
+	if form.Household, err = h.householdFor(userID); err != nil {
+		return form, err
+	}
+
+	form.Email = profile.User.Email
+	form.PhotoURL = profile.PhotoURL
+	form.Input = services.ProfileInputFrom(*profile, form.Household)
+	return form, nil
+}
+
+// renderProfile renders just the form for HTMX submissions and the full page
+// otherwise.
+func (h *DirectoryHandler) renderProfile(w http.ResponseWriter, r *http.Request, form pages.ProfileForm, status int) {
+	component := pages.Profile(form)
+	if r.Header.Get("HX-Request") == "true" {
+		component = pages.ProfileFormFragment(form)
+	}
+
+	w.WriteHeader(status)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render profile page", "error", err)
+	}
+}
+
+// householdFor returns the member's household, or nil if they are not in one.
+func (h *DirectoryHandler) householdFor(userID uint) (*models.Household, error) {
+	household, err := h.households.GetForUser(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return household, err
+}
+
+// addressInputFrom reads the mailing address fields from a submitted form.
+func addressInputFrom(r *http.Request) services.AddressInput {
+	return services.AddressInput{
+		AddressLine1: r.PostFormValue("address_line1"),
+		AddressLine2: r.PostFormValue("address_line2"),
+		City:         r.PostFormValue("city"),
+		State:        r.PostFormValue("state"),
+		ZipCode:      r.PostFormValue("zip_code"),
+	}
+}
+
+// directoryFilters reads the search query and initial letter from the URL.
+// The letter is ignored unless it is a single character.
+func directoryFilters(r *http.Request) (string, string) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if len(query) > maxDirectoryQuery {
+		query = query[:maxDirectoryQuery]
+	}
+
+	letter := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("letter")))
+	if utf8.RuneCountInString(letter) != 1 {
+		letter = ""
+	}
+
+	return query, letter
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sfdeloach/churchsite/internal/middleware"
+	"github.com/sfdeloach/churchsite/internal/services"
+	"github.com/sfdeloach/churchsite/internal/storage"
+	"gorm.io/gorm"
+)
+
+// FileHandler serves uploaded files under services.FileURLPrefix to those
+// the record owning each file allows. Files are found through the owner of
+// the storage directory they are kept in.
+//
+// With local storage in production nginx sends the file: the response
+// carries only headers and an X-Accel-Redirect to the internal location
+// given by accelPrefix, which serves the storage directory. Without one, as
+// in development or with S3, the file is streamed from store, with support
+// for range requests.
+type FileHandler struct {
+	store       storage.Storage
+	accelPrefix string
+	owners      map[string]services.FileOwner
+}
+
+// NewFileHandler creates a new FileHandler serving files from store.
+// owners maps each top-level directory in it to the service owning its
+// files.
+func NewFileHandler(store storage.Storage, accelPrefix string, owners map[string]services.FileOwner) *FileHandler {
+	return &FileHandler{store: store, accelPrefix: accelPrefix, owners: owners}
+}
+
+// Serve sends the file at the rest of the path. Anonymous visitors are asked
+// to log in for files they may not see, and others are refused.
+func (h *FileHandler) Serve(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "*")
+	dir, rest, ok := strings.Cut(name, "/")
+	owner := h.owners[dir]
+	if !ok || owner == nil || !fs.ValidPath(name) {
+		http.NotFound(w, r)
+		return
+	}
+
+	file, err := owner.OwnedFile(rest)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		slog.Error("failed to find uploaded file", "name", name, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	if file.CanView != nil && !file.CanView(user) {
+		if user == nil {
+			middleware.Unauthorized(w, r)
+			return
+		}
+		middleware.Forbidden(w, r)
+		return
+	}
+
+	if h.accelPrefix != "" {
+		// nginx keeps these headers and sets the rest, including
+		// Content-Type, itself.
+		setFileHeaders(w, file)
+		w.Header().Set("X-Accel-Redirect", h.accelPrefix+file.Name)
+		return
+	}
+	h.stream(w, r, file)
+}
+
+// stream serves file from storage, answering 404 if it is missing.
+func (h *FileHandler) stream(w http.ResponseWriter, r *http.Request, file *services.StoredFile) {
+	obj, err := h.store.Open(file.Name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("uploaded file missing", "key", file.Name)
+			http.NotFound(w, r)
+			return
+		}
+		slog.Error("failed to open uploaded file", "key", file.Name, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer obj.Close()
+
+	info := obj.Info()
+	if info.ContentType != "" {
+		w.Header().Set("Content-Type", info.ContentType)
+	}
+	setFileHeaders(w, file)
+	http.ServeContent(w, r, path.Base(file.Name), info.ModTime, obj)
+}
+
+func setFileHeaders(w http.ResponseWriter, file *services.StoredFile) {
+	w.Header().Set("Cache-Control", file.CacheControl)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// Filenames come from uploads, so they are quoted, or RFC 2231 encoded
+	// when not plain ASCII, rather than written into the header as they are.
+	if file.Filename == "" {
+		return
+	}
+	if disposition := mime.FormatMediaType("inline", map[string]string{"filename": file.Filename}); disposition != "" {
+		w.Header().Set("Content-Disposition", disposition)
+	}
+}
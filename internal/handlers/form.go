@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sfdeloach/churchsite/internal/services"
+	"github.com/sfdeloach/churchsite/templates/pages"
+	"gorm.io/gorm"
+)
+
+// maxFormBody caps the request body of a form submission, far above what
+// any schema's answers need.
+const maxFormBody = 1 << 20
+
+// FormHandler renders developer-defined forms and accepts their
+// submissions, from the page or as JSON. Submissions are limited per IP
+// address across all forms.
+type FormHandler struct {
+	forms   *services.FormService
+	limiter *services.RateLimiter
+}
+
+// NewFormHandler creates a new FormHandler.
+func NewFormHandler(forms *services.FormService, limiter *services.RateLimiter) *FormHandler {
+	return &FormHandler{forms: forms, limiter: limiter}
+}
+
+// Show renders an active form from its schema.
+func (h *FormHandler) Show(w http.ResponseWriter, r *http.Request) {
+	page, _, ok := h.loadPage(w, r)
+	if !ok {
+		return
+	}
+	page.Submitted = r.URL.Query().Get("status") == "submitted"
+	h.renderPage(w, r, page, http.StatusOK)
+}
+
+// Submit stores the answers posted from a form's page.
+func (h *FormHandler) Submit(w http.ResponseWriter, r *http.Request) {
+	// Check the limit before reading the body or loading the form, so
+	// refused clients cost nothing more.
+	allowed, err := h.allow(r)
+	if err != nil {
+		slog.Error("failed to check form submission rate limit", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !allowed {
+		slog.Warn("form submission rate limit exceeded", "form_id", chi.URLParam(r, "id"))
+		http.Error(w, "Too many forms have been submitted from your network. Please try again later.", http.StatusTooManyRequests)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBody)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	page, schema, ok := h.loadPage(w, r)
+	if !ok {
+		return
+	}
+	page.Values = r.PostForm
+
+	submission, err := h.forms.Submit(page.Form.ID, h.submissionInput(r, schema.FormValues(r.PostForm)))
+	if err != nil {
+		var verrs services.ValidationErrors
+		switch {
+		case errors.As(err, &verrs):
+			page.Errors = verrs
+			h.renderPage(w, r, page, http.StatusUnprocessableEntity)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "Form not found", http.StatusNotFound)
+		default:
+			slog.Error("failed to submit form", "form_id", page.Form.ID, "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	slog.Info("form submitted", "form_id", submission.FormID, "submission_id", submission.ID)
+	redirect(w, r, pages.FormURL(page.Form.ID)+"?status=submitted")
+}
+
+// APISubmit stores answers to an active form from a JSON object keyed by
+// the schema's property names.
+func (h *FormHandler) APISubmit(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "form not found"})
+		return
+	}
+
+	// Check the limit before reading the body, so refused clients cost
+	// nothing more.
+	allowed, err := h.allow(r)
+	if err != nil {
+		slog.Error("failed to check form submission rate limit", "error", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to submit form"})
+		return
+	}
+	if !allowed {
+		slog.Warn("form submission rate limit exceeded", "form_id", id)
+		writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": "too many submissions"})
+		return
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxFormBody))
+	dec.UseNumber()
+	var values map[string]any
+	if err := dec.Decode(&values); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": "request body too large"})
+			return
+		}
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+		return
+	}
+
+	submission, err := h.forms.Submit(uint(id), h.submissionInput(r, values))
+	if err != nil {
+		var verrs services.ValidationErrors
+		switch {
+		case errors.As(err, &verrs):
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"error": "validation failed", "fields": verrs})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "form not found"})
+		default:
+			slog.Error("failed to submit form", "form_id", id, "error", err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to submit form"})
+		}
+		return
+	}
+
+	slog.Info("form submitted via API", "form_id", submission.FormID, "submission_id", submission.ID)
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"id":           submission.ID,
+		"form_id":      submission.FormID,
+		"submitted_at": submission.SubmittedAt,
+	})
+}
+
+// allow records a submission attempt from the request's IP address and
+// reports whether it is within the limit.
+func (h *FormHandler) allow(r *http.Request) (bool, error) {
+	return h.limiter.Allow(r.Context(), "rate:form:"+clientIP(r), services.FormSubmissionRateLimit, services.FormSubmissionRateWindow)
+}
+
+func (h *FormHandler) submissionInput(r *http.Request, values map[string]any) services.FormSubmissionInput {
+	in := services.FormSubmissionInput{
+		Values:    values,
+		IPAddress: clientIP(r),
+		UserAgent: r.UserAgent(),
+	}
+	if user := services.CurrentUser(r.Context()); user != nil {
+		in.UserID = &user.UserID
+	}
+	return in
+}
+
+// loadPage loads an active form and its schema for its page, writing an
+// error response and returning false on failure.
+func (h *FormHandler) loadPage(w http.ResponseWriter, r *http.Request) (pages.FormPage, *services.FormSchema, bool) {
+	var page pages.FormPage
+
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Form not found", http.StatusNotFound)
+		return page, nil, false
+	}
+
+	form, schema, err := h.forms.GetActive(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Form not found", http.StatusNotFound)
+			return page, nil, false
+		}
+		slog.Error("failed to load form", "id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return page, nil, false
+	}
+
+	page.Form = *form
+	page.Fields = schema.Fields
+	return page, schema, true
+}
+
+func (h *FormHandler) renderPage(w http.ResponseWriter, r *http.Request, page pages.FormPage, status int) {
+	component := pages.FormShow(page)
+	if r.Header.Get("HX-Request") == "true" {
+		component = pages.FormShowFragment(page)
+	}
+
+	w.WriteHeader(status)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render form", "form_id", page.Form.ID, "error", err)
+	}
+}
+
+// clientIP returns the address of the client, as set by the RealIP
+// middleware from the header nginx sets, without a port.
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sfdeloach/churchsite/internal/middleware"
+	"github.com/sfdeloach/churchsite/internal/services"
+	"github.com/sfdeloach/churchsite/templates/pages"
+	"gorm.io/gorm"
+)
+
+// GalleryHandler handles the public photo gallery.
+type GalleryHandler struct {
+	photos *services.PhotoService
+}
+
+// NewGalleryHandler creates a new GalleryHandler.
+func NewGalleryHandler(photos *services.PhotoService) *GalleryHandler {
+	return &GalleryHandler{photos: photos}
+}
+
+// Index lists the albums with published photos. Members-only albums are
+// listed for members.
+func (h *GalleryHandler) Index(w http.ResponseWriter, r *http.Request) {
+	user := services.CurrentUser(r.Context())
+	albums, err := h.photos.GalleryAlbums(services.SeesMembersContent(user))
+	if err != nil {
+		slog.Error("failed to list gallery albums", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	component := pages.Gallery(albums)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render gallery page", "error", err)
+	}
+}
+
+// Album renders the published photos of an album. Anonymous visitors to a
+// members-only album are asked to log in.
+func (h *GalleryHandler) Album(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+	album, err := h.photos.GetAlbumBySlug(slug)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Album not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to get photo album", "slug", slug, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	if !services.CanViewAlbum(user, *album) {
+		if user == nil {
+			middleware.Unauthorized(w, r)
+			return
+		}
+		middleware.Forbidden(w, r)
+		return
+	}
+
+	photos, err := h.photos.AlbumPhotos(album.ID, true)
+	if err != nil {
+		slog.Error("failed to list album photos", "album_id", album.ID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if len(photos) == 0 {
+		http.Error(w, "Album not found", http.StatusNotFound)
+		return
+	}
+
+	component := pages.GalleryAlbum(*album, photos)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render gallery album page", "album_id", album.ID, "error", err)
+	}
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/sfdeloach/churchsite/internal/services"
 	"github.com/sfdeloach/churchsite/templates/pages"
@@ -10,17 +11,20 @@ import (
 
 // HomeHandler handles the homepage.
 type HomeHandler struct {
-	events *services.EventService
+	events        *services.EventService
+	announcements *services.AnnouncementService
 }
 
 // NewHomeHandler creates a new HomeHandler.
-func NewHomeHandler(events *services.EventService) *HomeHandler {
+func NewHomeHandler(events *services.EventService, announcements *services.AnnouncementService) *HomeHandler {
 	return &HomeHandler{
-		events: events,
+		events:        events,
+		announcements: announcements,
 	}
 }
 
-// Index renders the homepage with service times and upcoming events.
+// Index renders the homepage with service times, announcements, and upcoming
+// events.
 func (h *HomeHandler) Index(w http.ResponseWriter, r *http.Request) {
 	events, err := h.events.GetUpcoming(6)
 	if err != nil {
@@ -29,7 +33,15 @@ func (h *HomeHandler) Index(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	component := pages.Home(events)
+	members := services.SeesMembersContent(services.CurrentUser(r.Context()))
+	announcements, err := h.announcements.GetPublished(time.Now(), members, 4)
+	if err != nil {
+		slog.Error("failed to load announcements", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	component := pages.Home(events, announcements)
 	if err := component.Render(r.Context(), w); err != nil {
 		slog.Error("failed to render homepage", "error", err)
 	}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/sfdeloach/churchsite/internal/services"
@@ -14,11 +15,12 @@ import (
 // MinistryHandler handles ministry pages.
 type MinistryHandler struct {
 	ministries *services.MinistryService
+	signer     *services.URLSigner
 }
 
 // NewMinistryHandler creates a new MinistryHandler.
-func NewMinistryHandler(ministries *services.MinistryService) *MinistryHandler {
-	return &MinistryHandler{ministries: ministries}
+func NewMinistryHandler(ministries *services.MinistryService, signer *services.URLSigner) *MinistryHandler {
+	return &MinistryHandler{ministries: ministries, signer: signer}
 }
 
 // Index renders the ministries overview page.
@@ -51,8 +53,46 @@ func (h *MinistryHandler) Show(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	component := pages.MinistryShow(*ministry)
+	canEdit, err := h.ministries.CanEdit(services.CurrentUser(r.Context()), ministry.ID)
+	if err != nil {
+		slog.Error("failed to check ministry assignment", "ministry_id", ministry.ID, "error", err)
+	}
+
+	component := pages.MinistryShow(*ministry, canEdit)
 	if err := component.Render(r.Context(), w); err != nil {
 		slog.Error("failed to render ministry show page", "slug", slug, "error", err)
 	}
 }
+
+// Preview renders a ministry page with its unpublished draft. The link is
+// signed and expires, so it can be shared with people who cannot log in.
+func (h *MinistryHandler) Preview(w http.ResponseWriter, r *http.Request) {
+	if !h.signer.Verify(r.URL, time.Now()) {
+		http.Error(w, "This preview link is invalid or has expired.", http.StatusForbidden)
+		return
+	}
+
+	slug := chi.URLParam(r, "slug")
+	ministry, err := h.ministries.GetBySlugForEdit(slug)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Ministry not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to load ministry", "slug", slug, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !ministry.HasDraft() {
+		http.Error(w, "This draft has been published or discarded.", http.StatusNotFound)
+		return
+	}
+	ministry.PageContent = *ministry.DraftContent
+
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	component := pages.MinistryShow(*ministry, false)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render ministry preview page", "slug", slug, "error", err)
+	}
+}
@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sfdeloach/churchsite/internal/mail"
+	"github.com/sfdeloach/churchsite/internal/models"
+	"github.com/sfdeloach/churchsite/internal/services"
+	"github.com/sfdeloach/churchsite/templates/emails"
+	"github.com/sfdeloach/churchsite/templates/pages"
+	"gorm.io/gorm"
+)
+
+// RegistrationHandler handles event registration, cancellation, and the
+// related confirmation emails.
+type RegistrationHandler struct {
+	events        *services.EventService
+	registrations *services.RegistrationService
+	households    *services.HouseholdService
+	outbox        *mail.Outbox
+	appURL        string
+}
+
+// NewRegistrationHandler creates a new RegistrationHandler.
+func NewRegistrationHandler(events *services.EventService, registrations *services.RegistrationService, households *services.HouseholdService, outbox *mail.Outbox, appURL string) *RegistrationHandler {
+	return &RegistrationHandler{events: events, registrations: registrations, households: households, outbox: outbox, appURL: appURL}
+}
+
+// registerRequest is the JSON body accepted by APIRegister.
+type registerRequest struct {
+	GuestName          string `json:"guest_name"`
+	GuestEmail         string `json:"guest_email"`
+	GuestPhone         string `json:"guest_phone"`
+	NumberAttending    int    `json:"number_attending"`
+	SpecialNeeds       string `json:"special_needs"`
+	HouseholdMemberIDs []uint `json:"household_member_ids"` // members only
+}
+
+// Page renders the member registration page for an event.
+func (h *RegistrationHandler) Page(w http.ResponseWriter, r *http.Request) {
+	form, ok := h.loadForm(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.URL.Query().Get("status") {
+	case "registered":
+		form.Success = "You are registered. A confirmation email is on its way."
+	case "cancelled":
+		form.Success = "Your registration has been cancelled."
+	}
+	form.NumberAttending = "1"
+
+	h.renderRegister(w, r, form, http.StatusOK)
+}
+
+// Register signs the logged-in member up for an event.
+func (h *RegistrationHandler) Register(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	form, ok := h.loadForm(w, r)
+	if !ok {
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	form.NumberAttending = r.PostFormValue("number_attending")
+	form.SpecialNeeds = r.PostFormValue("special_needs")
+	number, _ := strconv.Atoi(form.NumberAttending)
+	for _, v := range r.PostForm["attendees"] {
+		if id, err := strconv.ParseUint(v, 10, 64); err == nil {
+			form.Attendees = append(form.Attendees, uint(id))
+		}
+	}
+
+	reg, token, err := h.registrations.Register(form.Event.ID, services.RegistrationInput{
+		UserID:             &user.UserID,
+		HouseholdMemberIDs: form.Attendees,
+		NumberAttending:    number,
+		SpecialNeeds:       form.SpecialNeeds,
+	})
+	if err != nil {
+		var verrs services.ValidationErrors
+		switch {
+		case errors.As(err, &verrs):
+			form.Errors = verrs
+		case errors.Is(err, services.ErrRegistrationClosed):
+			form.Error = "Registration for this event is closed."
+			form.Open = false
+		case errors.Is(err, services.ErrAlreadyRegistered):
+			form.Error = "You are already registered for this event."
+		default:
+			slog.Error("failed to register for event", "event_id", form.Event.ID, "user_id", user.UserID, "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		h.renderRegister(w, r, form, http.StatusUnprocessableEntity)
+		return
+	}
+
+	slog.Info("member registered for event", "event_id", reg.EventID, "user_id", user.UserID, "status", reg.Status)
+	h.sendConfirmation(r.Context(), *reg, token)
+	http.Redirect(w, r, fmt.Sprintf("/member/events/register/%d?status=registered", reg.EventID), http.StatusSeeOther)
+}
+
+// Cancel cancels the logged-in member's registration for an event.
+func (h *RegistrationHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	_, promotions, err := h.registrations.CancelForUser(uint(id), user.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Registration not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to cancel registration", "event_id", id, "user_id", user.UserID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("member cancelled event registration", "event_id", id, "user_id", user.UserID)
+	sendPromotions(r.Context(), h.outbox, h.appURL, promotions)
+	http.Redirect(w, r, fmt.Sprintf("/member/events/register/%d?status=cancelled", id), http.StatusSeeOther)
+}
+
+// CancelPage asks a registrant arriving from an emailed link to confirm.
+func (h *RegistrationHandler) CancelPage(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+
+	reg, err := h.registrations.GetByCancelToken(token)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidToken) {
+			h.renderInvalidCancelLink(w, r)
+			return
+		}
+		slog.Error("failed to load registration for cancel link", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	component := pages.RegistrationCancel(*reg, token)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render cancel registration page", "error", err)
+	}
+}
+
+// CancelByToken cancels the registration owning an emailed cancel link.
+func (h *RegistrationHandler) CancelByToken(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+
+	reg, promotions, err := h.registrations.CancelByToken(token)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidToken) {
+			h.renderInvalidCancelLink(w, r)
+			return
+		}
+		slog.Error("failed to cancel registration by token", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("registration cancelled via email link", "registration_id", reg.ID, "event_id", reg.EventID)
+	sendPromotions(r.Context(), h.outbox, h.appURL, promotions)
+
+	component := pages.AuthMessage(
+		"Registration Cancelled",
+		"Your registration for "+reg.Event.Title+" has been cancelled. Thank you for letting us know.",
+		"/calendar/events",
+		"View Upcoming Events",
+	)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render registration cancelled page", "error", err)
+	}
+}
+
+// APIRegister registers for an event from a JSON body. Logged-in members are
+// registered under their account; anyone else must supply guest_name and
+// guest_email.
+func (h *RegistrationHandler) APIRegister(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "event not found"})
+		return
+	}
+
+	var req registerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+		return
+	}
+
+	in := services.RegistrationInput{
+		GuestName:       req.GuestName,
+		GuestEmail:      req.GuestEmail,
+		GuestPhone:      req.GuestPhone,
+		NumberAttending: req.NumberAttending,
+		SpecialNeeds:    req.SpecialNeeds,
+	}
+	if user := services.CurrentUser(r.Context()); user != nil {
+		in = services.RegistrationInput{
+			UserID:             &user.UserID,
+			HouseholdMemberIDs: req.HouseholdMemberIDs,
+			NumberAttending:    req.NumberAttending,
+			SpecialNeeds:       req.SpecialNeeds,
+		}
+	}
+
+	reg, token, err := h.registrations.Register(uint(id), in)
+	if err != nil {
+		var verrs services.ValidationErrors
+		switch {
+		case errors.As(err, &verrs):
+			writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"error": "validation failed", "fields": verrs})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "event not found"})
+		case errors.Is(err, services.ErrRegistrationClosed):
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "registration is closed"})
+		case errors.Is(err, services.ErrAlreadyRegistered):
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "already registered"})
+		default:
+			slog.Error("failed to register for event", "event_id", id, "error", err)
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to register"})
+		}
+		return
+	}
+
+	slog.Info("registered for event via API", "event_id", reg.EventID, "registration_id", reg.ID, "status", reg.Status)
+	h.sendConfirmation(r.Context(), *reg, token)
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"id":               reg.ID,
+		"event_id":         reg.EventID,
+		"status":           reg.Status,
+		"number_attending": reg.NumberAttending,
+	})
+}
+
+// loadForm loads the event and the member's current registration state,
+// writing an error response and returning false on failure.
+func (h *RegistrationHandler) loadForm(w http.ResponseWriter, r *http.Request) (pages.EventRegisterForm, bool) {
+	var form pages.EventRegisterForm
+
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return form, false
+	}
+
+	event, err := h.events.GetPublicByID(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Event not found", http.StatusNotFound)
+			return form, false
+		}
+		slog.Error("failed to load event", "id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return form, false
+	}
+	if !event.RegistrationEnabled {
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return form, false
+	}
+	form.Event = *event
+
+	if form.Availability, err = h.registrations.GetAvailability(*event); err != nil {
+		slog.Error("failed to load event availability", "id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return form, false
+	}
+
+	user := services.CurrentUser(r.Context())
+	reg, err := h.registrations.GetForUser(event.ID, user.UserID)
+	switch {
+	case err == nil && reg.IsActive():
+		form.Registration = reg
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		slog.Error("failed to load registration", "event_id", id, "user_id", user.UserID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return form, false
+	}
+
+	household, err := h.households.GetForUser(user.UserID)
+	switch {
+	case err == nil:
+		for _, m := range household.Members {
+			if m.UserID == nil || *m.UserID != user.UserID {
+				form.Household = append(form.Household, m)
+			}
+		}
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		slog.Error("failed to load household", "user_id", user.UserID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return form, false
+	}
+
+	form.Open = event.RegistrationOpen(time.Now())
+	return form, true
+}
+
+// sendConfirmation queues the registration confirmation email.
+func (h *RegistrationHandler) sendConfirmation(ctx context.Context, reg models.EventRegistration, token string) {
+	to, name := registrantAddress(reg)
+	subject := "Registration confirmed: " + reg.Event.Title
+	if reg.Status == models.RegistrationWaitlisted {
+		subject = "You're on the waitlist: " + reg.Event.Title
+	}
+
+	body := emails.RegistrationConfirmation(name, reg, eventLink(h.appURL, reg), cancelLink(h.appURL, token))
+	if err := h.outbox.Queue(ctx, to, subject, body); err != nil {
+		slog.Error("failed to queue registration confirmation", "registration_id", reg.ID, "error", err)
+	}
+}
+
+// sendPromotions notifies registrants moved off the waitlist, whether by a
+// cancellation or by staff raising an event's capacity.
+func sendPromotions(ctx context.Context, outbox *mail.Outbox, appURL string, promotions []services.Promotion) {
+	for _, p := range promotions {
+		reg := p.Registration
+		to, name := registrantAddress(reg)
+		body := emails.WaitlistPromoted(name, reg, eventLink(appURL, reg), cancelLink(appURL, p.CancelToken))
+		if err := outbox.Queue(ctx, to, "A place has opened: "+reg.Event.Title, body); err != nil {
+			slog.Error("failed to queue waitlist promotion", "registration_id", reg.ID, "error", err)
+		}
+		slog.Info("promoted registration from waitlist", "registration_id", reg.ID, "event_id", reg.EventID)
+	}
+}
+
+func eventLink(appURL string, reg models.EventRegistration) string {
+	return fmt.Sprintf("%s/calendar/events/%d", appURL, reg.EventID)
+}
+
+func cancelLink(appURL, token string) string {
+	return appURL + "/calendar/registrations/" + token + "/cancel"
+}
+
+// registrantAddress returns the mail recipient and salutation name for a
+// member or guest registration.
+func registrantAddress(reg models.EventRegistration) (mail.Address, string) {
+	if reg.User != nil {
+		return userAddress(reg.User), reg.User.FirstName
+	}
+	return mail.Address{Name: reg.GuestName, Email: reg.GuestEmail}, reg.GuestName
+}
+
+func (h *RegistrationHandler) renderRegister(w http.ResponseWriter, r *http.Request, form pages.EventRegisterForm, status int) {
+	w.WriteHeader(status)
+	component := pages.EventRegister(form)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render event registration page", "event_id", form.Event.ID, "error", err)
+	}
+}
+
+func (h *RegistrationHandler) renderInvalidCancelLink(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNotFound)
+	component := pages.AuthMessage(
+		"Link No Longer Valid",
+		"This cancellation link is invalid or the registration has already been cancelled.",
+		"/calendar/events",
+		"View Upcoming Events",
+	)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render invalid cancel link page", "error", err)
+	}
+}
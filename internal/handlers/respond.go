@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/sfdeloach/churchsite/templates/pages"
+)
+
+// writeJSON encodes v as the JSON response body with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("failed to encode JSON response", "error", err)
+	}
+}
+
+// redirect sends HTMX requests to url with HX-Redirect, so the browser
+// performs a full navigation, and everything else with a 303.
+func redirect(w http.ResponseWriter, r *http.Request, url string) {
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", url)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(w, r, url, http.StatusSeeOther)
+}
+
+// parseOrderIDs reads the ids posted by a sortable list, in their new order,
+// writing a 400 if any is invalid.
+func parseOrderIDs(w http.ResponseWriter, r *http.Request) ([]uint, bool) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return nil, false
+	}
+
+	var ids []uint
+	for _, v := range r.PostForm["id"] {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return nil, false
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, true
+}
+
+// renderOrderStatus writes the message shown beside a sortable list after
+// its new order is saved or rejected.
+func renderOrderStatus(w http.ResponseWriter, r *http.Request, status int, kind, message string) {
+	w.WriteHeader(status)
+	if err := pages.OrderStatus(kind, message).Render(r.Context(), w); err != nil {
+		slog.Error("failed to render order status", "error", err)
+	}
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sfdeloach/churchsite/internal/models"
+	"github.com/sfdeloach/churchsite/internal/services"
+	"github.com/sfdeloach/churchsite/templates/pages"
+	"gorm.io/gorm"
+)
+
+// StaffAnnouncementHandler handles announcement management for staff.
+type StaffAnnouncementHandler struct {
+	announcements *services.AnnouncementService
+}
+
+// NewStaffAnnouncementHandler creates a new StaffAnnouncementHandler.
+func NewStaffAnnouncementHandler(announcements *services.AnnouncementService) *StaffAnnouncementHandler {
+	return &StaffAnnouncementHandler{announcements: announcements}
+}
+
+// Index lists every announcement for editing.
+func (h *StaffAnnouncementHandler) Index(w http.ResponseWriter, r *http.Request) {
+	announcements, err := h.announcements.ListAll()
+	if err != nil {
+		slog.Error("failed to list announcements", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var notice string
+	switch r.URL.Query().Get("status") {
+	case "saved":
+		notice = "The announcement has been saved."
+	case "deleted":
+		notice = "The announcement has been deleted."
+	}
+
+	component := pages.StaffAnnouncements(announcements, notice)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render staff announcements page", "error", err)
+	}
+}
+
+// New renders an empty announcement form.
+func (h *StaffAnnouncementHandler) New(w http.ResponseWriter, r *http.Request) {
+	form := pages.AnnouncementForm{Input: services.AnnouncementInput{
+		Audience: models.AudiencePublic,
+		Priority: models.PriorityNormal,
+		IsActive: true,
+	}}
+	h.renderForm(w, r, form, http.StatusOK)
+}
+
+// Create stores a new announcement written by the logged-in staff member.
+func (h *StaffAnnouncementHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	form := pages.AnnouncementForm{Input: parseAnnouncementInput(r)}
+
+	user := services.CurrentUser(r.Context())
+	announcement, err := h.announcements.Create(form.Input, user.UserID)
+	if err != nil {
+		var verrs services.ValidationErrors
+		if errors.As(err, &verrs) {
+			form.Errors = verrs
+			h.renderForm(w, r, form, http.StatusUnprocessableEntity)
+			return
+		}
+		slog.Error("failed to create announcement", "user_id", user.UserID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("announcement created", "announcement_id", announcement.ID, "user_id", user.UserID)
+	redirect(w, r, "/staff/announcements?status=saved")
+}
+
+// Edit renders the form for an existing announcement.
+func (h *StaffAnnouncementHandler) Edit(w http.ResponseWriter, r *http.Request) {
+	id, ok := announcementIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	announcement, err := h.announcements.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Announcement not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to get announcement", "id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	form := pages.AnnouncementForm{ID: id, Input: services.AnnouncementInputFrom(*announcement)}
+	h.renderForm(w, r, form, http.StatusOK)
+}
+
+// Update saves changes to an existing announcement.
+func (h *StaffAnnouncementHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, ok := announcementIDParam(w, r)
+	if !ok {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	form := pages.AnnouncementForm{ID: id, Input: parseAnnouncementInput(r)}
+
+	user := services.CurrentUser(r.Context())
+	if _, err := h.announcements.Update(id, form.Input, user.UserID); err != nil {
+		var verrs services.ValidationErrors
+		switch {
+		case errors.As(err, &verrs):
+			form.Errors = verrs
+			h.renderForm(w, r, form, http.StatusUnprocessableEntity)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "Announcement not found", http.StatusNotFound)
+		default:
+			slog.Error("failed to update announcement", "id", id, "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	slog.Info("announcement updated", "announcement_id", id, "user_id", user.UserID)
+	redirect(w, r, "/staff/announcements?status=saved")
+}
+
+// Delete soft-deletes an announcement. HTMX requests get an empty response
+// so the table row is removed in place.
+func (h *StaffAnnouncementHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := announcementIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.announcements.Delete(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Announcement not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to delete announcement", "id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	slog.Info("announcement deleted", "announcement_id", id, "user_id", user.UserID)
+
+	if r.Header.Get("HX-Request") == "true" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(w, r, "/staff/announcements?status=deleted", http.StatusSeeOther)
+}
+
+// renderForm renders just the form for HTMX submissions and the full page
+// otherwise.
+func (h *StaffAnnouncementHandler) renderForm(w http.ResponseWriter, r *http.Request, form pages.AnnouncementForm, status int) {
+	component := pages.StaffAnnouncementForm(form)
+	if r.Header.Get("HX-Request") == "true" {
+		component = pages.AnnouncementFormFragment(form)
+	}
+
+	w.WriteHeader(status)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render announcement form", "id", form.ID, "error", err)
+	}
+}
+
+// parseAnnouncementInput reads the announcement form fields from a parsed request.
+func parseAnnouncementInput(r *http.Request) services.AnnouncementInput {
+	return services.AnnouncementInput{
+		Title:        r.PostFormValue("title"),
+		Content:      r.PostFormValue("content"),
+		Audience:     r.PostFormValue("audience"),
+		Priority:     r.PostFormValue("priority"),
+		VisibleFrom:  r.PostFormValue("visible_from"),
+		VisibleUntil: r.PostFormValue("visible_until"),
+		IsActive:     r.PostFormValue("is_active") == "1",
+	}
+}
+
+// announcementIDParam parses the {id} URL parameter, writing a 404 if it is invalid.
+func announcementIDParam(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Announcement not found", http.StatusNotFound)
+		return 0, false
+	}
+	return uint(id), true
+}
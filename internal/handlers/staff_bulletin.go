@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sfdeloach/churchsite/internal/services"
+	"github.com/sfdeloach/churchsite/templates/pages"
+	"gorm.io/gorm"
+)
+
+// multipartOverhead allows for the form fields and part headers that
+// accompany an uploaded file in the request body.
+const multipartOverhead = 1 << 20
+
+// StaffBulletinHandler handles bulletin uploads and removal for staff.
+type StaffBulletinHandler struct {
+	bulletins *services.BulletinService
+}
+
+// NewStaffBulletinHandler creates a new StaffBulletinHandler.
+func NewStaffBulletinHandler(bulletins *services.BulletinService) *StaffBulletinHandler {
+	return &StaffBulletinHandler{bulletins: bulletins}
+}
+
+// Index lists every published bulletin.
+func (h *StaffBulletinHandler) Index(w http.ResponseWriter, r *http.Request) {
+	bulletins, err := h.bulletins.ListAll()
+	if err != nil {
+		slog.Error("failed to list bulletins", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var notice string
+	switch r.URL.Query().Get("status") {
+	case "uploaded":
+		notice = "The bulletin has been uploaded."
+	case "deleted":
+		notice = "The bulletin has been deleted."
+	}
+
+	component := pages.StaffBulletins(bulletins, notice)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render staff bulletins page", "error", err)
+	}
+}
+
+// UploadPage renders the upload form.
+func (h *StaffBulletinHandler) UploadPage(w http.ResponseWriter, r *http.Request) {
+	form := pages.BulletinUploadForm{
+		ServiceType:   r.URL.Query().Get("service"),
+		MaxUploadSize: h.bulletins.MaxUploadSize(),
+	}
+	h.renderForm(w, r, form, http.StatusOK)
+}
+
+// Upload stores a bulletin PDF. The request body is capped just above the
+// configured upload limit so oversized files are rejected while streaming.
+func (h *StaffBulletinHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	form := pages.BulletinUploadForm{MaxUploadSize: h.bulletins.MaxUploadSize()}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.bulletins.MaxUploadSize()+multipartOverhead)
+	if err := r.ParseMultipartForm(multipartOverhead); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			form.Errors = map[string]string{"file": "The file must be " + services.FormatBytes(h.bulletins.MaxUploadSize()) + " or smaller."}
+			h.renderForm(w, r, form, http.StatusUnprocessableEntity)
+			return
+		}
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	form.BulletinDate = r.PostFormValue("bulletin_date")
+	form.ServiceType = r.PostFormValue("service_type")
+	form.MembersOnly = r.PostFormValue("members_only") == "1"
+	in := services.BulletinUpload{BulletinDate: form.BulletinDate, ServiceType: form.ServiceType, MembersOnly: form.MembersOnly}
+
+	file, header, err := r.FormFile("file")
+	switch {
+	case err == nil:
+		defer file.Close()
+		in.File = file
+		in.Size = header.Size
+	case !errors.Is(err, http.ErrMissingFile):
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	bulletin, err := h.bulletins.Upload(in, user.UserID)
+	if err != nil {
+		var verrs services.ValidationErrors
+		if errors.As(err, &verrs) {
+			form.Errors = verrs
+			h.renderForm(w, r, form, http.StatusUnprocessableEntity)
+			return
+		}
+		slog.Error("failed to upload bulletin", "user_id", user.UserID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("bulletin uploaded", "bulletin_id", bulletin.ID, "date", form.BulletinDate, "service", bulletin.ServiceType, "members_only", bulletin.MembersOnly, "size", bulletin.FileSize, "user_id", user.UserID)
+	redirect(w, r, "/staff/bulletins?status=uploaded")
+}
+
+// Delete unpublishes a bulletin. HTMX requests get an empty response so the
+// table row is removed in place.
+func (h *StaffBulletinHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Bulletin not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.bulletins.Delete(uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Bulletin not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to delete bulletin", "id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	slog.Info("bulletin deleted", "bulletin_id", id, "user_id", user.UserID)
+
+	if r.Header.Get("HX-Request") == "true" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(w, r, "/staff/bulletins?status=deleted", http.StatusSeeOther)
+}
+
+// renderForm renders just the form for HTMX submissions and the full page
+// otherwise.
+func (h *StaffBulletinHandler) renderForm(w http.ResponseWriter, r *http.Request, form pages.BulletinUploadForm, status int) {
+	component := pages.StaffBulletinUpload(form)
+	if r.Header.Get("HX-Request") == "true" {
+		component = pages.BulletinUploadFormFragment(form)
+	}
+
+	w.WriteHeader(status)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render bulletin upload form", "error", err)
+	}
+}
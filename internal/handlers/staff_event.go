@@ -0,0 +1,409 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sfdeloach/churchsite/internal/mail"
+	"github.com/sfdeloach/churchsite/internal/services"
+	"github.com/sfdeloach/churchsite/templates/pages"
+	"gorm.io/gorm"
+)
+
+// StaffEventHandler handles event management for staff.
+type StaffEventHandler struct {
+	events     *services.EventService
+	ministries *services.MinistryService
+	outbox     *mail.Outbox
+	appURL     string
+}
+
+// NewStaffEventHandler creates a new StaffEventHandler. The outbox and app
+// URL are used to notify registrants promoted off the waitlist when an
+// event's capacity is raised.
+func NewStaffEventHandler(events *services.EventService, ministries *services.MinistryService, outbox *mail.Outbox, appURL string) *StaffEventHandler {
+	return &StaffEventHandler{events: events, ministries: ministries, outbox: outbox, appURL: appURL}
+}
+
+// Index lists every event for editing.
+func (h *StaffEventHandler) Index(w http.ResponseWriter, r *http.Request) {
+	events, err := h.events.ListAll()
+	if err != nil {
+		slog.Error("failed to list events", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var notice string
+	switch r.URL.Query().Get("status") {
+	case "saved":
+		notice = "The event has been saved."
+	case "deleted":
+		notice = "The event has been deleted."
+	}
+
+	component := pages.StaffEvents(events, notice)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render staff events page", "error", err)
+	}
+}
+
+// New renders an empty event form.
+func (h *StaffEventHandler) New(w http.ResponseWriter, r *http.Request) {
+	form, ok := h.newForm(w, 0, services.EventInput{IsPublic: true})
+	if !ok {
+		return
+	}
+	h.renderForm(w, r, form, http.StatusOK)
+}
+
+// Create stores a new event owned by the logged-in staff member.
+func (h *StaffEventHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	form, ok := h.newForm(w, 0, parseEventInput(r))
+	if !ok {
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	event, err := h.events.Create(form.Input, user.UserID)
+	if err != nil {
+		var verrs services.ValidationErrors
+		if errors.As(err, &verrs) {
+			form.Errors = verrs
+			h.renderForm(w, r, form, http.StatusUnprocessableEntity)
+			return
+		}
+		slog.Error("failed to create event", "user_id", user.UserID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("event created", "event_id", event.ID, "user_id", user.UserID)
+	redirect(w, r, "/staff/events?status=saved")
+}
+
+// Edit renders the form for an existing event.
+func (h *StaffEventHandler) Edit(w http.ResponseWriter, r *http.Request) {
+	id, ok := eventIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	event, err := h.events.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Event not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to get event", "id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	form, ok := h.newForm(w, id, services.EventInputFrom(*event))
+	if !ok {
+		return
+	}
+	h.renderForm(w, r, form, http.StatusOK)
+}
+
+// Update saves changes to an existing event.
+func (h *StaffEventHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, ok := eventIDParam(w, r)
+	if !ok {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	form, ok := h.newForm(w, id, parseEventInput(r))
+	if !ok {
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	_, promotions, err := h.events.Update(id, form.Input)
+	if err != nil {
+		var verrs services.ValidationErrors
+		switch {
+		case errors.As(err, &verrs):
+			form.Errors = verrs
+			h.renderForm(w, r, form, http.StatusUnprocessableEntity)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "Event not found", http.StatusNotFound)
+		default:
+			slog.Error("failed to update event", "id", id, "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	slog.Info("event updated", "event_id", id, "user_id", user.UserID)
+	sendPromotions(r.Context(), h.outbox, h.appURL, promotions)
+	redirect(w, r, "/staff/events?status=saved")
+}
+
+// Delete soft-deletes an event. HTMX requests get an empty response so the
+// table row is removed in place.
+func (h *StaffEventHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, ok := eventIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.events.Delete(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Event not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to delete event", "id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	slog.Info("event deleted", "event_id", id, "user_id", user.UserID)
+
+	if r.Header.Get("HX-Request") == "true" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(w, r, "/staff/events?status=deleted", http.StatusSeeOther)
+}
+
+// Occurrences lists a recurring event's upcoming occurrences so single dates
+// can be cancelled or moved.
+func (h *StaffEventHandler) Occurrences(w http.ResponseWriter, r *http.Request) {
+	id, ok := eventIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	event, schedule, err := h.events.GetSchedule(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Event not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to get event schedule", "id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var notice string
+	switch r.URL.Query().Get("status") {
+	case "cancelled":
+		notice = "The occurrence has been cancelled."
+	case "moved":
+		notice = "The occurrence has been moved."
+	case "restored":
+		notice = "The occurrence follows the series again."
+	}
+
+	component := pages.StaffEventOccurrences(*event, schedule, notice)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render event occurrences page", "id", id, "error", err)
+	}
+}
+
+// MovePage renders the form that moves one occurrence.
+func (h *StaffEventHandler) MovePage(w http.ResponseWriter, r *http.Request) {
+	form, ok := h.occurrenceForm(w, r)
+	if !ok {
+		return
+	}
+	form.Input = services.OccurrenceMoveInputFrom(form.Event, form.Occurrence)
+	h.renderMoveForm(w, r, form, http.StatusOK)
+}
+
+// MoveOccurrence moves one occurrence to a new time.
+func (h *StaffEventHandler) MoveOccurrence(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	form, ok := h.occurrenceForm(w, r)
+	if !ok {
+		return
+	}
+	form.Input = services.OccurrenceMoveInput{
+		Start: r.PostFormValue("start"),
+		End:   r.PostFormValue("end"),
+	}
+
+	if err := h.events.MoveOccurrence(form.Event.ID, form.Occurrence.Start, form.Input); err != nil {
+		var verrs services.ValidationErrors
+		if errors.As(err, &verrs) {
+			form.Errors = verrs
+			h.renderMoveForm(w, r, form, http.StatusUnprocessableEntity)
+			return
+		}
+		h.occurrenceError(w, form.Event.ID, "move", err)
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	slog.Info("event occurrence moved", "event_id", form.Event.ID, "date", form.Occurrence.Date(), "user_id", user.UserID)
+	redirect(w, r, staffOccurrencesPath(form.Event.ID, "moved"))
+}
+
+// CancelOccurrence cancels one occurrence, leaving the rest of the series.
+func (h *StaffEventHandler) CancelOccurrence(w http.ResponseWriter, r *http.Request) {
+	id, date, ok := occurrenceParams(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.events.CancelOccurrence(id, date); err != nil {
+		h.occurrenceError(w, id, "cancel", err)
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	slog.Info("event occurrence cancelled", "event_id", id, "date", date.Format(services.DateInputLayout), "user_id", user.UserID)
+	redirect(w, r, staffOccurrencesPath(id, "cancelled"))
+}
+
+// RestoreOccurrence removes the exception for one occurrence, so it follows
+// the series again.
+func (h *StaffEventHandler) RestoreOccurrence(w http.ResponseWriter, r *http.Request) {
+	id, date, ok := occurrenceParams(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.events.RestoreOccurrence(id, date); err != nil {
+		h.occurrenceError(w, id, "restore", err)
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	slog.Info("event occurrence restored", "event_id", id, "date", date.Format(services.DateInputLayout), "user_id", user.UserID)
+	redirect(w, r, staffOccurrencesPath(id, "restored"))
+}
+
+// occurrenceForm builds an OccurrenceMoveForm for the occurrence named in the
+// URL, writing an error response and returning false on failure.
+func (h *StaffEventHandler) occurrenceForm(w http.ResponseWriter, r *http.Request) (pages.OccurrenceMoveForm, bool) {
+	id, date, ok := occurrenceParams(w, r)
+	if !ok {
+		return pages.OccurrenceMoveForm{}, false
+	}
+
+	event, occurrence, err := h.events.GetOccurrence(id, date)
+	if err != nil {
+		h.occurrenceError(w, id, "load", err)
+		return pages.OccurrenceMoveForm{}, false
+	}
+	return pages.OccurrenceMoveForm{Event: *event, Occurrence: occurrence}, true
+}
+
+// occurrenceError writes the response for a failed occurrence action: 404
+// for unknown events and dates the series does not generate.
+func (h *StaffEventHandler) occurrenceError(w http.ResponseWriter, id uint, action string, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, services.ErrNotAnOccurrence) {
+		http.Error(w, "Occurrence not found", http.StatusNotFound)
+		return
+	}
+	slog.Error("failed to "+action+" event occurrence", "id", id, "error", err)
+	http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+}
+
+func (h *StaffEventHandler) renderMoveForm(w http.ResponseWriter, r *http.Request, form pages.OccurrenceMoveForm, status int) {
+	component := pages.StaffOccurrenceMove(form)
+	if r.Header.Get("HX-Request") == "true" {
+		component = pages.OccurrenceMoveFragment(form)
+	}
+
+	w.WriteHeader(status)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render occurrence move form", "id", form.Event.ID, "error", err)
+	}
+}
+
+// newForm builds an EventForm with the ministry choices loaded.
+func (h *StaffEventHandler) newForm(w http.ResponseWriter, id uint, in services.EventInput) (pages.EventForm, bool) {
+	ministries, err := h.ministries.GetActive()
+	if err != nil {
+		slog.Error("failed to get ministries", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return pages.EventForm{}, false
+	}
+	return pages.EventForm{ID: id, Input: in, Ministries: ministries}, true
+}
+
+// renderForm renders just the form for HTMX submissions and the full page
+// otherwise.
+func (h *StaffEventHandler) renderForm(w http.ResponseWriter, r *http.Request, form pages.EventForm, status int) {
+	component := pages.StaffEventForm(form)
+	if r.Header.Get("HX-Request") == "true" {
+		component = pages.EventFormFragment(form)
+	}
+
+	w.WriteHeader(status)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render event form", "id", form.ID, "error", err)
+	}
+}
+
+// parseEventInput reads the event form fields from a parsed request.
+func parseEventInput(r *http.Request) services.EventInput {
+	return services.EventInput{
+		Title:                r.PostFormValue("title"),
+		Description:          r.PostFormValue("description"),
+		EventDate:            r.PostFormValue("event_date"),
+		EndDate:              r.PostFormValue("end_date"),
+		Location:             r.PostFormValue("location"),
+		LocationDetails:      r.PostFormValue("location_details"),
+		RecurrenceRule:       r.PostFormValue("recurrence_rule"),
+		RecurrenceEnd:        r.PostFormValue("recurrence_end"),
+		RegistrationEnabled:  r.PostFormValue("registration_enabled") == "1",
+		CapacityLimit:        r.PostFormValue("capacity_limit"),
+		RegistrationDeadline: r.PostFormValue("registration_deadline"),
+		VisibleFrom:          r.PostFormValue("visible_from"),
+		VisibleUntil:         r.PostFormValue("visible_until"),
+		IsPublic:             r.PostFormValue("is_public") == "1",
+		MinistryID:           r.PostFormValue("ministry_id"),
+	}
+}
+
+// eventIDParam parses the {id} URL parameter, writing a 404 if it is invalid.
+func eventIDParam(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// occurrenceParams parses the {id} and {date} URL parameters of an occurrence,
+// writing a 404 if either is invalid.
+func occurrenceParams(w http.ResponseWriter, r *http.Request) (uint, time.Time, bool) {
+	id, ok := eventIDParam(w, r)
+	if !ok {
+		return 0, time.Time{}, false
+	}
+	date, err := services.ParseOccurrenceDate(chi.URLParam(r, "date"))
+	if err != nil {
+		http.Error(w, "Occurrence not found", http.StatusNotFound)
+		return 0, time.Time{}, false
+	}
+	return id, date, true
+}
+
+func staffOccurrencesPath(id uint, status string) string {
+	return fmt.Sprintf("/staff/events/%d/occurrences?status=%s", id, status)
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sfdeloach/churchsite/internal/models"
+	"github.com/sfdeloach/churchsite/internal/services"
+	"github.com/sfdeloach/churchsite/templates/pages"
+	"gorm.io/gorm"
+)
+
+// StaffFormHandler handles the staff pages for reviewing and exporting form
+// submissions.
+type StaffFormHandler struct {
+	forms *services.FormService
+}
+
+// NewStaffFormHandler creates a new StaffFormHandler.
+func NewStaffFormHandler(forms *services.FormService) *StaffFormHandler {
+	return &StaffFormHandler{forms: forms}
+}
+
+// Index lists every form with its number of submissions.
+func (h *StaffFormHandler) Index(w http.ResponseWriter, r *http.Request) {
+	forms, err := h.forms.ListAll()
+	if err != nil {
+		slog.Error("failed to list forms", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	component := pages.StaffForms(forms)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render staff forms page", "error", err)
+	}
+}
+
+// Submissions lists a form's submissions, newest first.
+func (h *StaffFormHandler) Submissions(w http.ResponseWriter, r *http.Request) {
+	form, schema, rows, ok := h.loadSubmissions(w, r)
+	if !ok {
+		return
+	}
+
+	component := pages.StaffFormSubmissions(*form, schema.Fields, rows)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render form submissions page", "form_id", form.ID, "error", err)
+	}
+}
+
+// Export downloads a form's submissions as CSV.
+func (h *StaffFormHandler) Export(w http.ResponseWriter, r *http.Request) {
+	form, schema, rows, ok := h.loadSubmissions(w, r)
+	if !ok {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := services.WriteSubmissionsCSV(&buf, schema, rows); err != nil {
+		slog.Error("failed to write form submissions CSV", "form_id", form.ID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	slog.Info("form submissions exported", "form_id", form.ID, "user_id", user.UserID, "submissions", len(rows))
+
+	filename := fmt.Sprintf("form-%d-submissions-%s.csv", form.ID, time.Now().Format("2006-01-02"))
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	if _, err := buf.WriteTo(w); err != nil {
+		slog.Error("failed to write form submissions CSV", "form_id", form.ID, "error", err)
+	}
+}
+
+// loadSubmissions loads a form, active or not, and its submissions, writing
+// an error response and returning false on failure.
+func (h *StaffFormHandler) loadSubmissions(w http.ResponseWriter, r *http.Request) (*models.Form, *services.FormSchema, []services.SubmissionRow, bool) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Form not found", http.StatusNotFound)
+		return nil, nil, nil, false
+	}
+
+	form, schema, err := h.forms.GetByID(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Form not found", http.StatusNotFound)
+			return nil, nil, nil, false
+		}
+		slog.Error("failed to load form", "id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, nil, nil, false
+	}
+
+	rows, err := h.forms.ListSubmissions(form.ID, schema)
+	if err != nil {
+		slog.Error("failed to list form submissions", "form_id", form.ID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, nil, nil, false
+	}
+	return form, schema, rows, true
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sfdeloach/churchsite/internal/services"
+	"github.com/sfdeloach/churchsite/templates/pages"
+	"gorm.io/gorm"
+)
+
+// StaffHouseholdHandler lets staff manage households: adding adults, editing
+// the shared address, and merging or splitting families. Members manage
+// their own household's children from their profile.
+type StaffHouseholdHandler struct {
+	households *services.HouseholdService
+}
+
+// NewStaffHouseholdHandler creates a new StaffHouseholdHandler.
+func NewStaffHouseholdHandler(households *services.HouseholdService) *StaffHouseholdHandler {
+	return &StaffHouseholdHandler{households: households}
+}
+
+// Index lists every household.
+func (h *StaffHouseholdHandler) Index(w http.ResponseWriter, r *http.Request) {
+	households, err := h.households.ListAll()
+	if err != nil {
+		slog.Error("failed to list households", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var notice string
+	if r.URL.Query().Get("status") == "merged" {
+		notice = "The households have been merged."
+	}
+
+	component := pages.StaffHouseholds(households, notice)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render staff households page", "error", err)
+	}
+}
+
+// Show renders a household with its forms.
+func (h *StaffHouseholdHandler) Show(w http.ResponseWriter, r *http.Request) {
+	page, ok := h.loadPage(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.URL.Query().Get("status") {
+	case "saved":
+		page.Notice = "The household has been saved."
+	case "adult-added":
+		page.Notice = "The adult has been added to the household."
+	case "merged":
+		page.Notice = "The households have been merged."
+	case "split":
+		page.Notice = "The new household has been created."
+	}
+	h.renderPage(w, r, page, http.StatusOK)
+}
+
+// Update saves a household's name, address, and phone.
+func (h *StaffHouseholdHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	page, ok := h.loadPage(w, r)
+	if !ok {
+		return
+	}
+	page.Input = services.HouseholdInput{
+		Name:         r.PostFormValue("name"),
+		Phone:        r.PostFormValue("phone"),
+		AddressInput: addressInputFrom(r),
+	}
+
+	if _, err := h.households.Update(page.Household.ID, page.Input); err != nil {
+		h.formError(w, r, &page, &page.Errors, "update", err)
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	slog.Info("household updated", "household_id", page.Household.ID, "user_id", user.UserID)
+	redirect(w, r, staffHouseholdURL(page.Household.ID, "saved"))
+}
+
+// AddAdult adds a member with an account to the household by email.
+func (h *StaffHouseholdHandler) AddAdult(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	page, ok := h.loadPage(w, r)
+	if !ok {
+		return
+	}
+	page.AdultEmail = r.PostFormValue("email")
+
+	member, err := h.households.AddAdult(page.Household.ID, page.AdultEmail)
+	if err != nil {
+		h.formError(w, r, &page, &page.AdultErrors, "add adult to", err)
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	slog.Info("adult added to household", "household_id", page.Household.ID, "member_id", member.ID, "user_id", user.UserID)
+	redirect(w, r, staffHouseholdURL(page.Household.ID, "adult-added"))
+}
+
+// Merge moves everyone in the chosen household into this one and deletes
+// the chosen household.
+func (h *StaffHouseholdHandler) Merge(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	page, ok := h.loadPage(w, r)
+	if !ok {
+		return
+	}
+	page.MergeID = r.PostFormValue("source_id")
+
+	sourceID, err := strconv.ParseUint(page.MergeID, 10, 64)
+	if err != nil {
+		page.MergeErrors = services.ValidationErrors{"source_id": "Choose a household to merge."}
+		h.renderPage(w, r, page, http.StatusUnprocessableEntity)
+		return
+	}
+
+	if _, err := h.households.Merge(page.Household.ID, uint(sourceID)); err != nil {
+		switch {
+		case errors.Is(err, services.ErrSameHousehold):
+			page.MergeErrors = services.ValidationErrors{"source_id": "Choose a different household."}
+			h.renderPage(w, r, page, http.StatusUnprocessableEntity)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			page.MergeErrors = services.ValidationErrors{"source_id": "That household no longer exists."}
+			h.renderPage(w, r, page, http.StatusUnprocessableEntity)
+		default:
+			slog.Error("failed to merge households", "household_id", page.Household.ID, "source_id", sourceID, "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	slog.Info("households merged", "household_id", page.Household.ID, "source_id", sourceID, "user_id", user.UserID)
+	redirect(w, r, staffHouseholdURL(page.Household.ID, "merged"))
+}
+
+// Split moves the ticked members into a new household.
+func (h *StaffHouseholdHandler) Split(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	page, ok := h.loadPage(w, r)
+	if !ok {
+		return
+	}
+	page.SplitName = r.PostFormValue("new_name")
+	for _, v := range r.PostForm["member_id"] {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+		page.SplitIDs = append(page.SplitIDs, uint(id))
+	}
+
+	created, err := h.households.Split(page.Household.ID, page.SplitIDs, page.SplitName)
+	if err != nil {
+		h.formError(w, r, &page, &page.SplitErrors, "split", err)
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	slog.Info("household split", "household_id", page.Household.ID, "new_household_id", created.ID, "members", len(page.SplitIDs), "user_id", user.UserID)
+	redirect(w, r, staffHouseholdURL(created.ID, "split"))
+}
+
+// formError shows validation errors in the form they belong to, or writes
+// the error response for anything else.
+func (h *StaffHouseholdHandler) formError(w http.ResponseWriter, r *http.Request, page *pages.HouseholdPage, errs *map[string]string, action string, err error) {
+	var verrs services.ValidationErrors
+	switch {
+	case errors.As(err, &verrs):
+		*errs = verrs
+		h.renderPage(w, r, *page, http.StatusUnprocessableEntity)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "Household not found", http.StatusNotFound)
+	default:
+		slog.Error("failed to "+action+" household", "household_id", page.Household.ID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// loadPage loads the household named by the {id} URL parameter into a page,
+// writing an error response and returning false on failure.
+func (h *StaffHouseholdHandler) loadPage(w http.ResponseWriter, r *http.Request) (pages.HouseholdPage, bool) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Household not found", http.StatusNotFound)
+		return pages.HouseholdPage{}, false
+	}
+
+	household, err := h.households.GetByID(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Household not found", http.StatusNotFound)
+			return pages.HouseholdPage{}, false
+		}
+		slog.Error("failed to get household", "id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return pages.HouseholdPage{}, false
+	}
+
+	return pages.HouseholdPage{
+		Household: *household,
+		Input:     services.HouseholdInputFrom(*household),
+	}, true
+}
+
+// renderPage renders the household page with the other households that can
+// be merged into it.
+func (h *StaffHouseholdHandler) renderPage(w http.ResponseWriter, r *http.Request, page pages.HouseholdPage, status int) {
+	all, err := h.households.ListAll()
+	if err != nil {
+		slog.Error("failed to list households", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	page.Others = nil
+	for _, other := range all {
+		if other.ID != page.Household.ID {
+			page.Others = append(page.Others, other)
+		}
+	}
+
+	w.WriteHeader(status)
+	if err := pages.StaffHousehold(page).Render(r.Context(), w); err != nil {
+		slog.Error("failed to render household page", "household_id", page.Household.ID, "error", err)
+	}
+}
+
+func staffHouseholdURL(id uint, status string) string {
+	return fmt.Sprintf("/staff/households/%d?status=%s", id, status)
+}
@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sfdeloach/churchsite/internal/models"
+	"github.com/sfdeloach/churchsite/internal/services"
+	"github.com/sfdeloach/churchsite/templates/pages"
+	"gorm.io/gorm"
+)
+
+// StaffMemberHandler handles management of the pastors and staff listed on
+// the About pages.
+type StaffMemberHandler struct {
+	staffMembers *services.StaffMemberService
+	categories   *services.StaffCategoryService
+}
+
+// NewStaffMemberHandler creates a new StaffMemberHandler.
+func NewStaffMemberHandler(staffMembers *services.StaffMemberService, categories *services.StaffCategoryService) *StaffMemberHandler {
+	return &StaffMemberHandler{staffMembers: staffMembers, categories: categories}
+}
+
+// Index lists every staff member in display order.
+func (h *StaffMemberHandler) Index(w http.ResponseWriter, r *http.Request) {
+	members, err := h.staffMembers.ListAll()
+	if err != nil {
+		slog.Error("failed to list staff members", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	categories, err := h.categories.Ordered()
+	if err != nil {
+		slog.Error("failed to list staff categories", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var notice string
+	switch r.URL.Query().Get("status") {
+	case "saved":
+		notice = "The staff member has been saved."
+	case "reordered":
+		notice = "The new order has been saved."
+	}
+
+	component := pages.StaffMembers(members, categories, notice)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render staff members page", "error", err)
+	}
+}
+
+// New renders an empty staff member form.
+func (h *StaffMemberHandler) New(w http.ResponseWriter, r *http.Request) {
+	form := pages.StaffMemberForm{
+		Input:         services.StaffMemberInput{Category: string(models.CategoryStaff), IsActive: true},
+		MaxUploadSize: h.staffMembers.MaxUploadSize(),
+	}
+	h.renderForm(w, r, form, http.StatusOK)
+}
+
+// Create stores a new staff member at the end of the display order.
+func (h *StaffMemberHandler) Create(w http.ResponseWriter, r *http.Request) {
+	form := pages.StaffMemberForm{MaxUploadSize: h.staffMembers.MaxUploadSize()}
+	in, ok := h.parseInput(w, r, &form)
+	if !ok {
+		return
+	}
+	defer closeUpload(r, in)
+
+	user := services.CurrentUser(r.Context())
+	member, err := h.staffMembers.Create(in)
+	if err != nil {
+		var verrs services.ValidationErrors
+		if errors.As(err, &verrs) {
+			form.Errors = verrs
+			h.renderForm(w, r, form, http.StatusUnprocessableEntity)
+			return
+		}
+		slog.Error("failed to create staff member", "user_id", user.UserID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("staff member created", "staff_member_id", member.ID, "user_id", user.UserID)
+	redirect(w, r, "/staff/about/staff?status=saved")
+}
+
+// Edit renders the form for an existing staff member.
+func (h *StaffMemberHandler) Edit(w http.ResponseWriter, r *http.Request) {
+	id, ok := staffMemberIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	member, err := h.staffMembers.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Staff member not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to get staff member", "id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	form := pages.StaffMemberForm{
+		ID:            id,
+		PhotoURL:      member.ThumbnailURL(),
+		Input:         services.StaffMemberInputFrom(*member),
+		MaxUploadSize: h.staffMembers.MaxUploadSize(),
+	}
+	h.renderForm(w, r, form, http.StatusOK)
+}
+
+// Update saves changes to an existing staff member, replacing the headshot
+// if a new one was uploaded.
+func (h *StaffMemberHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, ok := staffMemberIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	member, err := h.staffMembers.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Staff member not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to get staff member", "id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	form := pages.StaffMemberForm{ID: id, PhotoURL: member.ThumbnailURL(), MaxUploadSize: h.staffMembers.MaxUploadSize()}
+	in, ok := h.parseInput(w, r, &form)
+	if !ok {
+		return
+	}
+	defer closeUpload(r, in)
+
+	user := services.CurrentUser(r.Context())
+	if _, err := h.staffMembers.Update(id, in, user.UserID); err != nil {
+		var verrs services.ValidationErrors
+		switch {
+		case errors.As(err, &verrs):
+			form.Errors = verrs
+			h.renderForm(w, r, form, http.StatusUnprocessableEntity)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "Staff member not found", http.StatusNotFound)
+		default:
+			slog.Error("failed to update staff member", "id", id, "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	slog.Info("staff member updated", "staff_member_id", id, "photo_uploaded", in.Photo != nil, "user_id", user.UserID)
+	redirect(w, r, "/staff/about/staff?status=saved")
+}
+
+// Reorder saves the display order posted by the drag-and-drop list, given
+// as the staff member ids in their new order. HTMX requests get a status
+// message to show in place.
+func (h *StaffMemberHandler) Reorder(w http.ResponseWriter, r *http.Request) {
+	ids, ok := parseOrderIDs(w, r)
+	if !ok {
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	if err := h.staffMembers.Reorder(ids); err != nil {
+		var verrs services.ValidationErrors
+		if errors.As(err, &verrs) {
+			renderOrderStatus(w, r, http.StatusUnprocessableEntity, "error", verrs["order"])
+			return
+		}
+		slog.Error("failed to reorder staff members", "user_id", user.UserID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("staff members reordered", "count", len(ids), "user_id", user.UserID)
+
+	if r.Header.Get("HX-Request") == "true" {
+		renderOrderStatus(w, r, http.StatusOK, "success", "The new order has been saved.")
+		return
+	}
+	http.Redirect(w, r, "/staff/about/staff?status=reordered", http.StatusSeeOther)
+}
+
+// parseInput reads the multipart staff member form into form and returns the
+// service input, writing an error response and returning false if the
+// request cannot be read. The request body is capped just above the
+// configured upload limit so oversized photos are rejected while streaming.
+func (h *StaffMemberHandler) parseInput(w http.ResponseWriter, r *http.Request, form *pages.StaffMemberForm) (services.StaffMemberInput, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, h.staffMembers.MaxUploadSize()+multipartOverhead)
+	if err := r.ParseMultipartForm(multipartOverhead); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			form.Error = "The photo must be " + services.FormatBytes(h.staffMembers.MaxUploadSize()) + " or smaller."
+			h.renderForm(w, r, *form, http.StatusUnprocessableEntity)
+			return services.StaffMemberInput{}, false
+		}
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return services.StaffMemberInput{}, false
+	}
+
+	form.Input = services.StaffMemberInput{
+		Name:     r.PostFormValue("name"),
+		Title:    r.PostFormValue("title"),
+		Bio:      r.PostFormValue("bio"),
+		Email:    r.PostFormValue("email"),
+		Phone:    r.PostFormValue("phone"),
+		Category: r.PostFormValue("category"),
+		IsActive: r.PostFormValue("is_active") == "1",
+	}
+
+	in := form.Input
+	file, header, err := r.FormFile("photo")
+	switch {
+	case err == nil:
+		in.Photo = file
+		in.PhotoSize = header.Size
+	case !errors.Is(err, http.ErrMissingFile):
+		r.MultipartForm.RemoveAll()
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return services.StaffMemberInput{}, false
+	}
+
+	return in, true
+}
+
+// closeUpload closes the uploaded photo, if any, and removes the request's
+// temporary upload files.
+func closeUpload(r *http.Request, in services.StaffMemberInput) {
+	if c, ok := in.Photo.(io.Closer); ok {
+		c.Close()
+	}
+	r.MultipartForm.RemoveAll()
+}
+
+// renderForm renders just the form for HTMX submissions and the full page
+// otherwise.
+func (h *StaffMemberHandler) renderForm(w http.ResponseWriter, r *http.Request, form pages.StaffMemberForm, status int) {
+	categories, err := h.categories.Ordered()
+	if err != nil {
+		slog.Error("failed to list staff categories", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	form.Categories = categories
+
+	component := pages.StaffMemberFormPage(form)
+	if r.Header.Get("HX-Request") == "true" {
+		component = pages.StaffMemberFormFragment(form)
+	}
+
+	w.WriteHeader(status)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render staff member form", "id", form.ID, "error", err)
+	}
+}
+
+// staffMemberIDParam parses the {id} URL parameter, writing a 404 if it is invalid.
+func staffMemberIDParam(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Staff member not found", http.StatusNotFound)
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sfdeloach/churchsite/internal/middleware"
+	"github.com/sfdeloach/churchsite/internal/models"
+	"github.com/sfdeloach/churchsite/internal/sanitize"
+	"github.com/sfdeloach/churchsite/internal/services"
+	"github.com/sfdeloach/churchsite/templates/pages"
+	"gorm.io/gorm"
+)
+
+// StaffMinistryHandler handles ministry page editing. Staff can edit every
+// ministry and manage who is assigned to it; members assigned with can_edit
+// can edit that ministry's page.
+type StaffMinistryHandler struct {
+	ministries *services.MinistryService
+	signer     *services.URLSigner
+}
+
+// previewLifetime is how long a draft preview link stays valid.
+const previewLifetime = 72 * time.Hour
+
+// NewStaffMinistryHandler creates a new StaffMinistryHandler.
+func NewStaffMinistryHandler(ministries *services.MinistryService, signer *services.URLSigner) *StaffMinistryHandler {
+	return &StaffMinistryHandler{ministries: ministries, signer: signer}
+}
+
+// Index lists every ministry, active or not, for staff.
+func (h *StaffMinistryHandler) Index(w http.ResponseWriter, r *http.Request) {
+	ministries, err := h.ministries.ListAll()
+	if err != nil {
+		slog.Error("failed to list ministries", "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	component := pages.StaffMinistries(ministries)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render staff ministries page", "error", err)
+	}
+}
+
+// Edit renders the page editor for a ministry, starting from the draft if
+// there is one.
+func (h *StaffMinistryHandler) Edit(w http.ResponseWriter, r *http.Request) {
+	ministry, ok := h.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	form := newMinistryPageForm(ministry)
+	switch r.URL.Query().Get("status") {
+	case "draft":
+		form.Notice = "The draft has been saved. The public page has not changed."
+	case "scheduled":
+		form.Notice = "The draft has been saved and will be published at the scheduled time."
+	case "published":
+		form.Notice = "The page has been published."
+	case "discarded":
+		form.Notice = "The draft has been discarded."
+	case "assigned":
+		form.Notice = "The assignment has been saved."
+	case "unassigned":
+		form.Notice = "The assignment has been removed."
+	}
+
+	h.renderForm(w, r, form, http.StatusOK)
+}
+
+// Update sanitizes the submitted page content and either publishes it or
+// saves it as a draft, optionally scheduled to publish later.
+func (h *StaffMinistryHandler) Update(w http.ResponseWriter, r *http.Request) {
+	ministry, ok := h.authorize(w, r)
+	if !ok {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	form := pages.MinistryPageForm{
+		Ministry:      *ministry,
+		Content:       r.PostFormValue("page_content"),
+		PublishAt:     r.PostFormValue("publish_at"),
+		AssignCanEdit: true,
+	}
+
+	user := services.CurrentUser(r.Context())
+	status := "published"
+	var err error
+	if r.PostFormValue("action") == "publish" {
+		_, err = h.ministries.Publish(ministry.ID, form.Content, user.UserID)
+	} else {
+		var saved *models.Ministry
+		saved, err = h.ministries.SaveDraft(ministry.ID, services.DraftInput{Content: form.Content, PublishAt: form.PublishAt}, user.UserID, time.Now())
+		status = "draft"
+		if err == nil && saved.DraftPublishAt != nil {
+			status = "scheduled"
+		}
+	}
+	if err != nil {
+		var verrs services.ValidationErrors
+		switch {
+		case errors.As(err, &verrs):
+			// The editor loads the content as HTML, so only echo back what
+			// would have been saved.
+			form.Errors = verrs
+			form.Content = sanitize.HTML(form.Content)
+			h.renderForm(w, r, form, http.StatusUnprocessableEntity)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "Ministry not found", http.StatusNotFound)
+		default:
+			slog.Error("failed to update ministry page", "ministry_id", ministry.ID, "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	slog.Info("ministry page updated", "ministry_id", ministry.ID, "status", status, "user_id", user.UserID)
+	redirect(w, r, "/staff/ministry/"+ministry.Slug+"?status="+status)
+}
+
+// DiscardDraft removes the ministry's draft, leaving the published page as
+// it is.
+func (h *StaffMinistryHandler) DiscardDraft(w http.ResponseWriter, r *http.Request) {
+	ministry, ok := h.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.ministries.DiscardDraft(ministry.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Ministry not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to discard ministry page draft", "ministry_id", ministry.ID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	slog.Info("ministry page draft discarded", "ministry_id", ministry.ID, "user_id", user.UserID)
+	redirect(w, r, "/staff/ministry/"+ministry.Slug+"?status=discarded")
+}
+
+// Assign adds a user to the ministry by email, or changes whether an
+// existing assignment can edit the page. Staff only.
+func (h *StaffMinistryHandler) Assign(w http.ResponseWriter, r *http.Request) {
+	ministry, ok := h.authorize(w, r)
+	if !ok {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	form := newMinistryPageForm(ministry)
+	form.AssignEmail = r.PostFormValue("email")
+	form.AssignCanEdit = r.PostFormValue("can_edit") == "1"
+
+	user := services.CurrentUser(r.Context())
+	assignment, err := h.ministries.Assign(ministry.ID, form.AssignEmail, form.AssignCanEdit, user.UserID)
+	if err != nil {
+		var verrs services.ValidationErrors
+		if errors.As(err, &verrs) {
+			form.AssignErrors = verrs
+			h.renderForm(w, r, form, http.StatusUnprocessableEntity)
+			return
+		}
+		slog.Error("failed to assign ministry member", "ministry_id", ministry.ID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	slog.Info("ministry member assigned", "ministry_id", ministry.ID, "assigned_user_id", assignment.UserID, "can_edit", assignment.CanEdit, "user_id", user.UserID)
+	redirect(w, r, "/staff/ministry/"+ministry.Slug+"?status=assigned")
+}
+
+// Unassign removes a user from the ministry. Staff only. HTMX requests get an
+// empty response so the table row is removed in place.
+func (h *StaffMinistryHandler) Unassign(w http.ResponseWriter, r *http.Request) {
+	ministry, ok := h.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Assignment not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.ministries.Unassign(ministry.ID, uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Assignment not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to remove ministry assignment", "ministry_id", ministry.ID, "assignment_id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	slog.Info("ministry assignment removed", "ministry_id", ministry.ID, "assignment_id", id, "user_id", user.UserID)
+
+	if r.Header.Get("HX-Request") == "true" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(w, r, "/staff/ministry/"+ministry.Slug+"?status=unassigned", http.StatusSeeOther)
+}
+
+// authorize loads the {slug} ministry and checks that the current user may
+// edit it, writing a 404 or 403 if not.
+func (h *StaffMinistryHandler) authorize(w http.ResponseWriter, r *http.Request) (*models.Ministry, bool) {
+	slug := chi.URLParam(r, "slug")
+
+	ministry, err := h.ministries.GetBySlugForEdit(slug)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Ministry not found", http.StatusNotFound)
+			return nil, false
+		}
+		slog.Error("failed to load ministry", "slug", slug, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	user := services.CurrentUser(r.Context())
+	canEdit, err := h.ministries.CanEdit(user, ministry.ID)
+	if err != nil {
+		slog.Error("failed to check ministry assignment", "ministry_id", ministry.ID, "user_id", user.UserID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if !canEdit {
+		middleware.Forbidden(w, r)
+		return nil, false
+	}
+
+	return ministry, true
+}
+
+// newMinistryPageForm returns the editor form for a ministry, filled with its
+// draft if there is one and its published page otherwise.
+func newMinistryPageForm(ministry *models.Ministry) pages.MinistryPageForm {
+	form := pages.MinistryPageForm{Ministry: *ministry, Content: ministry.PageContent, AssignCanEdit: true}
+	if ministry.HasDraft() {
+		form.Content = *ministry.DraftContent
+		if ministry.DraftPublishAt != nil {
+			form.PublishAt = ministry.DraftPublishAt.In(time.Local).Format(services.DateTimeInputLayout)
+		}
+	}
+	return form
+}
+
+// renderForm renders just the page form for HTMX submissions and the full
+// page otherwise. Staff also see the ministry's assignments.
+func (h *StaffMinistryHandler) renderForm(w http.ResponseWriter, r *http.Request, form pages.MinistryPageForm, status int) {
+	component := pages.MinistryPageFormFragment(form)
+	if r.Header.Get("HX-Request") != "true" {
+		user := services.CurrentUser(r.Context())
+		if user.HasRole(models.RoleStaff) {
+			assignments, err := h.ministries.ListAssignments(form.Ministry.ID)
+			if err != nil {
+				slog.Error("failed to list ministry assignments", "ministry_id", form.Ministry.ID, "error", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			form.CanManage = true
+			form.Assignments = assignments
+		}
+		if form.Ministry.HasDraft() {
+			form.PreviewExpires = time.Now().Add(previewLifetime)
+			form.PreviewURL = h.signer.Sign("/ministries/"+form.Ministry.Slug+"/preview", form.PreviewExpires)
+		}
+		component = pages.StaffMinistryForm(form)
+	}
+
+	w.WriteHeader(status)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render ministry page form", "ministry_id", form.Ministry.ID, "error", err)
+	}
+}
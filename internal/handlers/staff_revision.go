@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sfdeloach/churchsite/internal/services"
+	"github.com/sfdeloach/churchsite/templates/pages"
+	"gorm.io/gorm"
+)
+
+// StaffRevisionHandler shows the revision history of editable content and
+// restores earlier versions.
+type StaffRevisionHandler struct {
+	revisions *services.RevisionService
+}
+
+// NewStaffRevisionHandler creates a new StaffRevisionHandler.
+func NewStaffRevisionHandler(revisions *services.RevisionService) *StaffRevisionHandler {
+	return &StaffRevisionHandler{revisions: revisions}
+}
+
+// History lists the changes made to one piece of content.
+func (h *StaffRevisionHandler) History(w http.ResponseWriter, r *http.Request) {
+	entityType := chi.URLParam(r, "type")
+	entityID, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Content not found", http.StatusNotFound)
+		return
+	}
+
+	history, err := h.revisions.History(entityType, uint(entityID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, services.ErrUnknownRevisionType) {
+			http.Error(w, "Content not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to load revision history", "entity_type", entityType, "entity_id", entityID, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var notice string
+	if r.URL.Query().Get("status") == "restored" {
+		notice = "The earlier version has been restored."
+	}
+
+	component := pages.RevisionHistory(*history, notice)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render revision history page", "entity_type", entityType, "entity_id", entityID, "error", err)
+	}
+}
+
+// Show compares the content before and after one change.
+func (h *StaffRevisionHandler) Show(w http.ResponseWriter, r *http.Request) {
+	id, ok := revisionIDParam(w, r)
+	if !ok {
+		return
+	}
+	h.renderComparison(w, r, id, "", http.StatusOK)
+}
+
+// Restore puts back the content as it was before a change.
+func (h *StaffRevisionHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	id, ok := revisionIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	user := services.CurrentUser(r.Context())
+	revision, err := h.revisions.Restore(id, user.UserID)
+	if err != nil {
+		var verrs services.ValidationErrors
+		switch {
+		case errors.As(err, &verrs):
+			h.renderComparison(w, r, id, "This version can no longer be restored as it is. Edit the content directly instead.", http.StatusUnprocessableEntity)
+		case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, services.ErrUnknownRevisionType):
+			http.Error(w, "Revision not found", http.StatusNotFound)
+		default:
+			slog.Error("failed to restore revision", "revision_id", id, "error", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	slog.Info("revision restored", "revision_id", id, "entity_type", revision.EntityType, "entity_id", revision.EntityID, "user_id", user.UserID)
+	redirect(w, r, fmt.Sprintf("/staff/history/%s/%d?status=restored", revision.EntityType, revision.EntityID))
+}
+
+func (h *StaffRevisionHandler) renderComparison(w http.ResponseWriter, r *http.Request, id uint, errMsg string, status int) {
+	comparison, err := h.revisions.Compare(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Revision not found", http.StatusNotFound)
+			return
+		}
+		slog.Error("failed to compare revision", "revision_id", id, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(status)
+	component := pages.RevisionCompare(*comparison, errMsg)
+	if err := component.Render(r.Context(), w); err != nil {
+		slog.Error("failed to render revision page", "revision_id", id, "error", err)
+	}
+}
+
+// revisionIDParam parses the {id} URL parameter, writing a 404 if it is invalid.
+func revisionIDParam(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Revision not found", http.StatusNotFound)
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,213 @@
+// Package ical encodes events as iCalendar (RFC 5545) documents for calendar
+// subscriptions and single-event downloads.
+//
+// Event times are stored as wall-clock TIMESTAMP values without a zone, so
+// they are written as floating local times: a 10:30 AM service stays at
+// 10:30 AM in subscribers' calendars across daylight saving changes.
+package ical
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sfdeloach/churchsite/internal/models"
+)
+
+// ContentType is the media type for iCalendar responses.
+const ContentType = "text/calendar; charset=utf-8"
+
+const (
+	floatingLayout = "20060102T150405"
+	utcLayout      = "20060102T150405Z"
+	maxLineOctets  = 75
+)
+
+// Calendar is a VCALENDAR document to encode.
+type Calendar struct {
+	Name       string // shown by clients as the subscription name
+	BaseURL    string // site root used for event links and UIDs
+	Events     []models.Event
+	Exceptions []models.EventException
+}
+
+// Encode renders the calendar as an iCalendar document with CRLF line endings.
+// Recurring events carry an RRULE; cancelled occurrences become EXDATEs and
+// moved occurrences are written as overriding VEVENTs with a RECURRENCE-ID.
+func (c Calendar) Encode() []byte {
+	host := "localhost"
+	if u, err := url.Parse(c.BaseURL); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+
+	exceptions := make(map[uint][]models.EventException)
+	for _, x := range c.Exceptions {
+		exceptions[x.EventID] = append(exceptions[x.EventID], x)
+	}
+
+	var w writer
+	w.line("BEGIN", "VCALENDAR")
+	w.line("VERSION", "2.0")
+	w.line("PRODID", "-//Saint Andrew's Chapel//Church Site//EN")
+	w.line("CALSCALE", "GREGORIAN")
+	w.line("METHOD", "PUBLISH")
+	if c.Name != "" {
+		w.line("X-WR-CALNAME", escapeText(c.Name))
+	}
+
+	for _, e := range c.Events {
+		uid := fmt.Sprintf("event-%d@%s", e.ID, host)
+		c.writeEvent(&w, e, uid, exceptions[e.ID])
+	}
+
+	w.line("END", "VCALENDAR")
+	return []byte(w.String())
+}
+
+func (c Calendar) writeEvent(w *writer, e models.Event, uid string, exceptions []models.EventException) {
+	w.line("BEGIN", "VEVENT")
+	c.writeCommon(w, e, uid)
+
+	if rule := RRule(e); rule != "" {
+		w.line("RRULE", rule)
+		for _, x := range exceptions {
+			if x.IsCancelled {
+				w.line("EXDATE", originalStart(e, x).Format(floatingLayout))
+			}
+		}
+	}
+	w.line("END", "VEVENT")
+
+	if !e.Recurs() {
+		return
+	}
+
+	// A moved occurrence overrides the instance the series generated, tied to
+	// it by UID and RECURRENCE-ID.
+	for _, x := range exceptions {
+		if x.IsCancelled || x.NewEventDate == nil {
+			continue
+		}
+		moved := e
+		moved.EventDate = *x.NewEventDate
+		moved.EndDate = x.NewEndDate
+		if moved.EndDate == nil && e.EndDate != nil {
+			end := moved.EventDate.Add(e.EndDate.Sub(e.EventDate))
+			moved.EndDate = &end
+		}
+
+		w.line("BEGIN", "VEVENT")
+		c.writeCommon(w, moved, uid)
+		w.line("RECURRENCE-ID", originalStart(e, x).Format(floatingLayout))
+		w.line("END", "VEVENT")
+	}
+}
+
+func (c Calendar) writeCommon(w *writer, e models.Event, uid string) {
+	w.line("UID", uid)
+	w.line("DTSTAMP", e.UpdatedAt.UTC().Format(utcLayout))
+	w.line("DTSTART", e.EventDate.Format(floatingLayout))
+	if e.EndDate != nil {
+		w.line("DTEND", e.EndDate.Format(floatingLayout))
+	}
+	w.line("SUMMARY", escapeText(e.Title))
+	if e.Description != "" {
+		w.line("DESCRIPTION", escapeText(e.Description))
+	}
+	if location := eventLocation(e); location != "" {
+		w.line("LOCATION", escapeText(location))
+	}
+	if c.BaseURL != "" {
+		w.line("URL", fmt.Sprintf("%s/calendar/events/%d", strings.TrimRight(c.BaseURL, "/"), e.ID))
+	}
+}
+
+// RRule returns the RRULE value for a recurring event, or an empty string if
+// the event does not recur. Monthly and yearly rules use BYSETPOS to pick the
+// earlier of the original day and the month's last day, matching how
+// occurrences are expanded for the site calendar.
+func RRule(e models.Event) string {
+	if !e.Recurs() {
+		return ""
+	}
+
+	var rule string
+	day := e.EventDate.Day()
+	switch e.RecurrenceRule {
+	case models.RecurrenceDaily:
+		rule = "FREQ=DAILY"
+	case models.RecurrenceWeekly:
+		rule = "FREQ=WEEKLY"
+	case models.RecurrenceBiweekly:
+		rule = "FREQ=WEEKLY;INTERVAL=2"
+	case models.RecurrenceMonthly:
+		rule = "FREQ=MONTHLY"
+		if day > 28 {
+			rule += fmt.Sprintf(";BYMONTHDAY=%d,-1;BYSETPOS=1", day)
+		}
+	case models.RecurrenceYearly:
+		rule = "FREQ=YEARLY"
+		if e.EventDate.Month() == time.February && day == 29 {
+			rule += ";BYMONTH=2;BYMONTHDAY=29,-1;BYSETPOS=1"
+		}
+	}
+
+	if e.RecurrenceEnd != nil {
+		y, m, d := e.RecurrenceEnd.Date()
+		until := time.Date(y, m, d, 23, 59, 59, 0, e.EventDate.Location())
+		rule += ";UNTIL=" + until.Format(floatingLayout)
+	}
+
+	return rule
+}
+
+// originalStart returns the start time the series generated for an exception.
+func originalStart(e models.Event, x models.EventException) time.Time {
+	y, m, d := x.OccurrenceDate.Date()
+	return time.Date(y, m, d, e.EventDate.Hour(), e.EventDate.Minute(), e.EventDate.Second(), 0, e.EventDate.Location())
+}
+
+func eventLocation(e models.Event) string {
+	if e.Location != "" && e.LocationDetails != "" {
+		return e.Location + " — " + e.LocationDetails
+	}
+	return e.Location
+}
+
+var textEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+	"\r", `\n`,
+)
+
+// escapeText escapes a TEXT property value.
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
+
+// writer accumulates content lines, folding them at 75 octets.
+type writer struct {
+	strings.Builder
+}
+
+func (w *writer) line(name, value string) {
+	line := name + ":" + value
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		// Never split a multi-byte UTF-8 sequence.
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		w.WriteString(line[:cut])
+		w.WriteString("\r\n ")
+		line = line[cut:]
+		limit = maxLineOctets - 1 // continuation lines start with a space
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
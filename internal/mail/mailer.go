@@ -0,0 +1,74 @@
+// Package mail renders, queues, and delivers transactional email.
+//
+// Messages are rendered from templ components, written to a Postgres outbox,
+// and delivered by a background worker through a Mailer implementation:
+// SMTP in production, or a filesystem/in-memory sink for development and tests.
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Mailer delivers a single rendered message.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// FileMailer writes each message as an .eml file in Dir. Useful in development
+// to inspect outgoing mail without an SMTP server.
+type FileMailer struct {
+	Dir  string
+	From Address
+}
+
+// NewFileMailer creates a FileMailer, creating dir if needed.
+func NewFileMailer(dir string, from Address) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileMailer{Dir: dir, From: from}, nil
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// Send writes the message to a timestamped .eml file.
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	raw, err := msg.Bytes(m.From)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102T150405.000000000"), unsafeFileChars.ReplaceAllString(msg.To.Email, "_"))
+	return os.WriteFile(filepath.Join(m.Dir, name), raw, 0o644)
+}
+
+// MemoryMailer keeps sent messages in memory. Intended for tests.
+type MemoryMailer struct {
+	mu   sync.Mutex
+	sent []Message
+}
+
+// NewMemoryMailer creates an empty MemoryMailer.
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+// Send records the message.
+func (m *MemoryMailer) Send(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// Messages returns a copy of all messages sent so far.
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.sent...)
+}
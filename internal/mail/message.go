@@ -0,0 +1,199 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/a-h/templ"
+	"golang.org/x/net/html"
+)
+
+// Address is an email address with an optional display name.
+type Address struct {
+	Name  string
+	Email string
+}
+
+// String formats the address for a message header.
+func (a Address) String() string {
+	return (&mail.Address{Name: a.Name, Address: a.Email}).String()
+}
+
+// Message is a rendered email with HTML and plain text bodies.
+type Message struct {
+	To      Address
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// Render builds a Message by rendering body to HTML and deriving the plain
+// text alternative from that HTML.
+func Render(ctx context.Context, to Address, subject string, body templ.Component) (Message, error) {
+	var buf bytes.Buffer
+	if err := body.Render(ctx, &buf); err != nil {
+		return Message{}, err
+	}
+
+	text, err := HTMLToText(buf.String())
+	if err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		To:      to,
+		Subject: subject,
+		HTML:    buf.String(),
+		Text:    text,
+	}, nil
+}
+
+// Bytes encodes the message as a multipart/alternative MIME document.
+func (m Message) Bytes(from Address) ([]byte, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+
+	domain := "localhost"
+	if i := strings.LastIndex(from.Email, "@"); i >= 0 {
+		domain = from.Email[i+1:]
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	headers := []struct{ key, value string }{
+		{"From", from.String()},
+		{"To", m.To.String()},
+		{"Subject", mime.QEncoding.Encode("utf-8", m.Subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", fmt.Sprintf("<%s@%s>", hex.EncodeToString(id), domain)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "multipart/alternative; boundary=" + w.Boundary()},
+	}
+	var out bytes.Buffer
+	for _, h := range headers {
+		fmt.Fprintf(&out, "%s: %s\r\n", h.key, h.value)
+	}
+	out.WriteString("\r\n")
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		pw, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(pw)
+		if _, err := qp.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	out.Write(buf.Bytes())
+	return out.Bytes(), nil
+}
+
+// blockElements end the current line of plain text.
+var blockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "h1": true, "h2": true, "h3": true,
+	"h4": true, "li": true, "tr": true, "table": true, "hr": true,
+}
+
+// HTMLToText converts rendered email HTML to a readable plain text body.
+// Block elements become line breaks and links are written as "text (url)".
+func HTMLToText(s string) (string, error) {
+	z := html.NewTokenizer(strings.NewReader(s))
+
+	var out strings.Builder
+	var hrefs []string
+	skip := 0
+
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if err := z.Err(); !errors.Is(err, io.EOF) {
+				return "", err
+			}
+			return tidyText(out.String()), nil
+		case html.TextToken:
+			if skip == 0 {
+				// Whitespace is collapsed per line by tidyText.
+				out.WriteString(strings.ReplaceAll(string(z.Text()), "\n", " "))
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			switch {
+			case tok.Data == "style" || tok.Data == "head" || tok.Data == "title":
+				skip++
+			case tok.Data == "a":
+				href := ""
+				for _, attr := range tok.Attr {
+					if attr.Key == "href" {
+						href = attr.Val
+					}
+				}
+				hrefs = append(hrefs, href)
+			case tok.Data == "li":
+				out.WriteString("\n- ")
+			case blockElements[tok.Data]:
+				out.WriteString("\n")
+			}
+		case html.EndTagToken:
+			tok := z.Token()
+			switch {
+			case tok.Data == "style" || tok.Data == "head" || tok.Data == "title":
+				skip--
+			case tok.Data == "a" && len(hrefs) > 0:
+				href := hrefs[len(hrefs)-1]
+				hrefs = hrefs[:len(hrefs)-1]
+				if href != "" && !strings.HasPrefix(href, "mailto:") {
+					fmt.Fprintf(&out, " (%s)", href)
+				}
+			case blockElements[tok.Data]:
+				out.WriteString("\n\n")
+			}
+		}
+	}
+}
+
+// tidyText collapses whitespace within each line and runs of blank lines.
+func tidyText(s string) string {
+	var lines []string
+	blank := false
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" {
+			if !blank && len(lines) > 0 {
+				lines = append(lines, "")
+			}
+			blank = true
+			continue
+		}
+		lines = append(lines, line)
+		blank = false
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n")) + "\n"
+}
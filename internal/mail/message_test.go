@@ -0,0 +1,149 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/sfdeloach/churchsite/templates/emails"
+)
+
+func TestHTMLToText(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "paragraphs",
+			html: "<p>Dear Ann,</p><p>Welcome   to\n the chapel.</p>",
+			want: "Dear Ann,\n\nWelcome to the chapel.\n",
+		},
+		{
+			name: "link",
+			html: `<p>Please <a href="https://example.com/verify?t=abc">verify</a>.</p>`,
+			want: "Please verify (https://example.com/verify?t=abc).\n",
+		},
+		{
+			name: "mailto link",
+			html: `<p>Write to <a href="mailto:office@example.com">the office</a>.</p>`,
+			want: "Write to the office.\n",
+		},
+		{
+			name: "list",
+			html: "<ul><li>One</li><li>Two</li></ul>",
+			want: "- One\n\n- Two\n",
+		},
+		{
+			name: "head and style skipped",
+			html: "<html><head><title>T</title><style>p{color:red}</style></head><body><p>Body</p></body></html>",
+			want: "Body\n",
+		},
+		{
+			name: "entities",
+			html: "<p>Saint Andrew&rsquo;s &amp; friends</p>",
+			want: "Saint Andrew’s & friends\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HTMLToText(tt.html)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("HTMLToText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	to := Address{Name: "Ann Member", Email: "ann@example.com"}
+	link := "https://example.com/reset-password?token=abc&x=1"
+
+	msg, err := Render(context.Background(), to, "Reset your password", emails.PasswordReset("Ann <b>", link))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.To != to || msg.Subject != "Reset your password" {
+		t.Errorf("To, Subject = %v, %q", msg.To, msg.Subject)
+	}
+	if !strings.Contains(msg.HTML, "Dear Ann &lt;b&gt;,") {
+		t.Error("HTML body does not contain the escaped first name")
+	}
+	if !strings.Contains(msg.HTML, `href="https://example.com/reset-password?token=abc&amp;x=1"`) {
+		t.Error("HTML body does not contain the reset link")
+	}
+	for _, want := range []string{
+		"Dear Ann <b>,",
+		"Reset Password (" + link + ")",
+		"This link expires in one hour.",
+	} {
+		if !strings.Contains(msg.Text, want) {
+			t.Errorf("text body does not contain %q:\n%s", want, msg.Text)
+		}
+	}
+}
+
+func TestMessageBytes(t *testing.T) {
+	msg := Message{
+		To:      Address{Name: "Ann Member", Email: "ann@example.com"},
+		Subject: "Welcome to Saint Andrew’s",
+		HTML:    "<p>Hello</p>",
+		Text:    "Hello\n",
+	}
+	from := Address{Name: "Saint Andrew's Chapel", Email: "office@example.org"}
+
+	raw, err := msg.Bytes(from)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parsed.Header.Get("To"); got != `"Ann Member" <ann@example.com>` {
+		t.Errorf("To = %q", got)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil || subject != msg.Subject {
+		t.Errorf("Subject = %q, %v; want %q", subject, err, msg.Subject)
+	}
+	if id := parsed.Header.Get("Message-ID"); !strings.HasSuffix(id, "@example.org>") {
+		t.Errorf("Message-ID = %q", id)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, %v", mediaType, err)
+	}
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	for _, want := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		part, err := parts.NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ct := part.Header.Get("Content-Type"); ct != want.contentType {
+			t.Errorf("part Content-Type = %q, want %q", ct, want.contentType)
+		}
+		// Quoted-printable bodies use CRLF line endings.
+		if wantBody := strings.ReplaceAll(want.body, "\n", "\r\n"); string(body) != wantBody {
+			t.Errorf("part body = %q, want %q", body, wantBody)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package mail
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/a-h/templ"
+	"github.com/sfdeloach/churchsite/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxAttempts is how many deliveries are tried before a message is marked failed.
+	maxAttempts = 8
+	// baseBackoff is the delay after the first failure; it doubles on each retry.
+	baseBackoff = time.Minute
+	// batchSize is the number of messages claimed per poll.
+	batchSize = 20
+	// staleSending is how long a claimed message may sit in "sending" before it
+	// is considered abandoned (e.g. the process crashed) and retried. It must
+	// exceed batchSize deliveries of sendTimeout each.
+	staleSending = 10 * time.Minute
+)
+
+// Outbox persists outgoing messages in Postgres and delivers them with retries.
+// Enqueueing never talks to the mail server, so request handlers stay fast and
+// messages survive restarts and SMTP outages.
+type Outbox struct {
+	db     *gorm.DB
+	mailer Mailer
+}
+
+// NewOutbox creates a new Outbox delivering through mailer.
+func NewOutbox(db *gorm.DB, mailer Mailer) *Outbox {
+	return &Outbox{db: db, mailer: mailer}
+}
+
+// Enqueue stores a message for delivery by the worker.
+func (o *Outbox) Enqueue(ctx context.Context, msg Message) error {
+	row := models.OutboxEmail{
+		ToEmail:       msg.To.Email,
+		ToName:        msg.To.Name,
+		Subject:       msg.Subject,
+		HTMLBody:      msg.HTML,
+		TextBody:      msg.Text,
+		Status:        models.OutboxPending,
+		NextAttemptAt: time.Now(),
+	}
+	return o.db.WithContext(ctx).Create(&row).Error
+}
+
+// Queue renders body and enqueues the resulting message.
+func (o *Outbox) Queue(ctx context.Context, to Address, subject string, body templ.Component) error {
+	msg, err := Render(ctx, to, subject, body)
+	if err != nil {
+		return err
+	}
+	return o.Enqueue(ctx, msg)
+}
+
+// Run polls the outbox every interval until ctx is cancelled.
+func (o *Outbox) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := o.ProcessBatch(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("failed to process email outbox", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessBatch claims due messages and attempts delivery, returning how many
+// were sent. Claiming uses FOR UPDATE SKIP LOCKED so several workers can run
+// safely side by side.
+func (o *Outbox) ProcessBatch(ctx context.Context) (int, error) {
+	var batch []models.OutboxEmail
+	now := time.Now()
+
+	err := o.db.WithContext(ctx).Raw(`
+		UPDATE email_outbox SET status = ?, next_attempt_at = ?
+		WHERE id IN (
+			SELECT id FROM email_outbox
+			WHERE (status = ? AND next_attempt_at <= ?)
+			   OR (status = ? AND next_attempt_at <= ?)
+			ORDER BY next_attempt_at
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		models.OutboxSending, now,
+		models.OutboxPending, now,
+		models.OutboxSending, now.Add(-staleSending),
+		batchSize,
+	).Scan(&batch).Error
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, row := range batch {
+		updates := o.deliver(ctx, row, time.Now())
+		if err := o.db.WithContext(ctx).Model(&row).Updates(updates).Error; err != nil {
+			return sent, err
+		}
+		if updates["status"] == models.OutboxSent {
+			sent++
+		}
+	}
+
+	return sent, nil
+}
+
+// deliver attempts delivery of a claimed message and returns the column
+// updates recording the outcome. A failed attempt is scheduled for retry with
+// exponential backoff, or marked failed after maxAttempts.
+func (o *Outbox) deliver(ctx context.Context, row models.OutboxEmail, now time.Time) map[string]any {
+	msg := Message{
+		To:      Address{Name: row.ToName, Email: row.ToEmail},
+		Subject: row.Subject,
+		HTML:    row.HTMLBody,
+		Text:    row.TextBody,
+	}
+	attempts := row.Attempts + 1
+
+	sendErr := o.mailer.Send(ctx, msg)
+	if sendErr == nil {
+		return map[string]any{
+			"status":     models.OutboxSent,
+			"attempts":   attempts,
+			"sent_at":    now,
+			"last_error": "",
+		}
+	}
+
+	status := models.OutboxPending
+	if attempts >= maxAttempts {
+		status = models.OutboxFailed
+	}
+
+	slog.Warn("email delivery failed", "outbox_id", row.ID, "attempt", attempts, "status", status, "error", sendErr)
+
+	return map[string]any{
+		"status":          status,
+		"attempts":        attempts,
+		"next_attempt_at": now.Add(Backoff(attempts)),
+		"last_error":      sendErr.Error(),
+	}
+}
+
+// Backoff returns the delay before the next delivery attempt after the given
+// number of failed attempts: 1m, 2m, 4m, ... capped at 6h.
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	d := baseBackoff << (attempts - 1)
+	if limit := 6 * time.Hour; d > limit || d <= 0 {
+		return limit
+	}
+	return d
+}
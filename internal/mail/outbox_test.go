@@ -0,0 +1,118 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sfdeloach/churchsite/internal/models"
+)
+
+type failingMailer struct{ err error }
+
+func (m failingMailer) Send(ctx context.Context, msg Message) error {
+	return m.err
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, time.Minute},
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{9, 256 * time.Minute},
+		{10, 6 * time.Hour},
+		{64, 6 * time.Hour},
+		{1000, 6 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestOutboxDeliverSent(t *testing.T) {
+	mailer := NewMemoryMailer()
+	o := NewOutbox(nil, mailer)
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	row := models.OutboxEmail{
+		ID:       7,
+		ToEmail:  "member@example.com",
+		ToName:   "Ann Member",
+		Subject:  "Hello",
+		HTMLBody: "<p>Hi</p>",
+		TextBody: "Hi\n",
+		Attempts: 2,
+		Status:   models.OutboxSending,
+	}
+
+	updates := o.deliver(context.Background(), row, now)
+
+	want := map[string]any{
+		"status":     models.OutboxSent,
+		"attempts":   3,
+		"sent_at":    now,
+		"last_error": "",
+	}
+	assertUpdates(t, updates, want)
+
+	sent := mailer.Messages()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d messages, want 1", len(sent))
+	}
+	wantMsg := Message{
+		To:      Address{Name: "Ann Member", Email: "member@example.com"},
+		Subject: "Hello",
+		HTML:    "<p>Hi</p>",
+		Text:    "Hi\n",
+	}
+	if sent[0] != wantMsg {
+		t.Errorf("sent %+v, want %+v", sent[0], wantMsg)
+	}
+}
+
+func TestOutboxDeliverRetry(t *testing.T) {
+	o := NewOutbox(nil, failingMailer{err: errors.New("connection refused")})
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		attempts   int
+		wantStatus string
+		wantNext   time.Time
+	}{
+		{"first failure", 0, models.OutboxPending, now.Add(time.Minute)},
+		{"third failure", 2, models.OutboxPending, now.Add(4 * time.Minute)},
+		{"last retry", maxAttempts - 2, models.OutboxPending, now.Add(Backoff(maxAttempts - 1))},
+		{"gives up", maxAttempts - 1, models.OutboxFailed, now.Add(Backoff(maxAttempts))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := models.OutboxEmail{ID: 1, ToEmail: "member@example.com", Attempts: tt.attempts}
+			updates := o.deliver(context.Background(), row, now)
+			assertUpdates(t, updates, map[string]any{
+				"status":          tt.wantStatus,
+				"attempts":        tt.attempts + 1,
+				"next_attempt_at": tt.wantNext,
+				"last_error":      "connection refused",
+			})
+		})
+	}
+}
+
+func assertUpdates(t *testing.T, got, want map[string]any) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("updates = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("updates[%q] = %v, want %v", k, got[k], v)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// sendTimeout bounds a single delivery, from dialing to QUIT, so a relay that
+// stops answering cannot hold up the worker. A whole batch must finish well
+// within staleSending, or another worker would reclaim and resend its
+// messages.
+const sendTimeout = 20 * time.Second
+
+// SMTPMailer delivers messages through an SMTP relay (Microsoft 365 in
+// production). STARTTLS is used automatically when the server offers it.
+type SMTPMailer struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     Address
+}
+
+// NewSMTPMailer creates an SMTPMailer. Authentication is skipped when
+// username is empty, e.g. for a local development relay.
+func NewSMTPMailer(host, port, username, password string, from Address) *SMTPMailer {
+	return &SMTPMailer{
+		host:     host,
+		port:     port,
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+// Send delivers the message to its recipient. It gives up when ctx is done
+// or after sendTimeout, whichever comes first.
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	raw, err := msg.Bytes(m.from)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(m.host, m.port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// The deadline ends reads and writes when the timeout passes; closing
+	// the connection ends them early if ctx is cancelled first.
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	err = m.send(conn, msg.To.Email, raw)
+	if ctx.Err() != nil {
+		return errors.Join(ctx.Err(), err)
+	}
+	return err
+}
+
+// send runs the SMTP conversation over conn as smtp.SendMail does.
+func (m *SMTPMailer) send(conn net.Conn, to string, raw []byte) error {
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if err := c.Hello("localhost"); err != nil {
+		return err
+	}
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+	if m.username != "" {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := c.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(m.from.Email); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(raw); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
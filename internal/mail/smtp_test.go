@@ -0,0 +1,119 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRelay accepts SMTP connections on a loopback port and answers each with
+// serve. It returns the relay's host and port.
+func fakeRelay(t *testing.T, serve func(net.Conn)) (string, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				serve(conn)
+			}()
+		}
+	}()
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	return host, port
+}
+
+func TestSMTPMailerSend(t *testing.T) {
+	received := make(chan string, 1)
+	host, port := fakeRelay(t, func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 relay ready")
+		var data strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"):
+				reply("250 relay")
+			case strings.HasPrefix(cmd, "MAIL FROM:"), strings.HasPrefix(cmd, "RCPT TO:"):
+				data.WriteString(strings.TrimSpace(line) + "\n")
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				received <- data.String()
+				return
+			default:
+				reply("502 unknown command")
+			}
+		}
+	})
+
+	m := NewSMTPMailer(host, port, "", "", Address{Name: "Chapel", Email: "office@example.com"})
+	msg := Message{To: Address{Email: "member@example.com"}, Subject: "Hello", Text: "Hi\n"}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	got := <-received
+	for _, want := range []string{"MAIL FROM:<office@example.com>", "RCPT TO:<member@example.com>", "Subject: Hello"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("relay received %q, want it to contain %q", got, want)
+		}
+	}
+}
+
+func TestSMTPMailerSendStuckRelay(t *testing.T) {
+	// The relay accepts the connection and never answers.
+	host, port := fakeRelay(t, func(conn net.Conn) {
+		conn.Read(make([]byte, 1))
+	})
+	m := NewSMTPMailer(host, port, "", "", Address{Email: "office@example.com"})
+	msg := Message{To: Address{Email: "member@example.com"}, Subject: "Hello", Text: "Hi\n"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := m.Send(ctx, msg); err == nil {
+		t.Fatal("Send to a stuck relay succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Send returned after %v, want it to stop at the context deadline", elapsed)
+	}
+}
+
+func TestSendTimeoutWithinStaleSending(t *testing.T) {
+	// Messages in a batch are sent one after another; if a batch of stuck
+	// deliveries outlasted staleSending, another worker would claim and send
+	// the same messages again.
+	if batch := batchSize * sendTimeout; batch >= staleSending {
+		t.Errorf("a batch can take %v, want less than staleSending (%v)", batch, staleSending)
+	}
+}
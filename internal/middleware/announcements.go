@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sfdeloach/churchsite/internal/models"
+	"github.com/sfdeloach/churchsite/internal/services"
+)
+
+// Banners attaches a lazy loader for high-priority announcements to the
+// request context. The query only runs if a page layout renders the banner,
+// so static files and API requests cost nothing. Must run after Authenticate
+// so members-only banners reach signed-in members.
+func Banners(announcements *services.AnnouncementService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			members := services.SeesMembersContent(services.CurrentUser(r.Context()))
+			load := sync.OnceValue(func() []models.Announcement {
+				banners, err := announcements.GetBanners(time.Now(), members)
+				if err != nil {
+					slog.Error("failed to load banner announcements", "error", err)
+					return nil
+				}
+				return banners
+			})
+
+			next.ServeHTTP(w, r.WithContext(services.WithBanners(r.Context(), load)))
+		})
+	}
+}
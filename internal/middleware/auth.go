@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sfdeloach/churchsite/internal/services"
+	errorpages "github.com/sfdeloach/churchsite/templates/errors"
+)
+
+// SessionCookieName is the name of the HTTP-only cookie carrying the session JWT.
+const SessionCookieName = "session"
+
+// SessionToken returns the session JWT from the cookie, falling back to an
+// Authorization: Bearer header for API clients.
+func SessionToken(r *http.Request) string {
+	if c, err := r.Cookie(SessionCookieName); err == nil && c.Value != "" {
+		return c.Value
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	return ""
+}
+
+// Authenticate parses the session token, if any, and stores its claims in the
+// request context. Requests without a valid token continue anonymously.
+func Authenticate(sessions *services.SessionService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := SessionToken(r)
+			if token == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			claims, err := sessions.Parse(r.Context(), token)
+			if err != nil {
+				if !errors.Is(err, services.ErrInvalidSession) {
+					slog.Error("failed to check session", "error", err)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(services.WithCurrentUser(r.Context(), claims)))
+		})
+	}
+}
+
+// RequireAuth rejects anonymous requests. Page requests are redirected to
+// /login?next=<original path>; API requests receive 401.
+func RequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if services.CurrentUser(r.Context()) == nil {
+			Unauthorized(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireAnyRole allows the request if the user has at least one of roles.
+// Anonymous users are treated as in RequireAuth; others receive 403.
+func RequireAnyRole(roles ...string) func(http.Handler) http.Handler {
+	return requireRoles(func(c *services.SessionClaims) bool {
+		return c.HasAnyRole(roles...)
+	})
+}
+
+// RequireAllRoles allows the request only if the user has every one of roles.
+// Anonymous users are treated as in RequireAuth; others receive 403.
+func RequireAllRoles(roles ...string) func(http.Handler) http.Handler {
+	return requireRoles(func(c *services.SessionClaims) bool {
+		return c.HasAllRoles(roles...)
+	})
+}
+
+func requireRoles(allowed func(*services.SessionClaims) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := services.CurrentUser(r.Context())
+			if claims == nil {
+				Unauthorized(w, r)
+				return
+			}
+			if !allowed(claims) {
+				Forbidden(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Forbidden responds with 403, rendering the access denied page for browsers
+// and a JSON error for API requests.
+func Forbidden(w http.ResponseWriter, r *http.Request) {
+	if isAPIRequest(r) {
+		writeJSONError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+
+	w.WriteHeader(http.StatusForbidden)
+	if err := errorpages.Forbidden().Render(r.Context(), w); err != nil {
+		slog.Error("failed to render forbidden page", "error", err)
+	}
+}
+
+// Unauthorized sends anonymous browsers to the login page, returning them
+// to the current URL afterwards, and answers API requests with 401.
+func Unauthorized(w http.ResponseWriter, r *http.Request) {
+	if isAPIRequest(r) {
+		writeJSONError(w, http.StatusUnauthorized, "authentication required")
+		return
+	}
+	loginURL := "/login?next=" + url.QueryEscape(r.URL.RequestURI())
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", loginURL)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	http.Redirect(w, r, loginURL, http.StatusSeeOther)
+}
+
+func isAPIRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/")
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// SafeRedirect returns target if it is a local path on this site, otherwise
+// fallback. Used to validate ?next= values and avoid open redirects.
+func SafeRedirect(target, fallback string) string {
+	if target == "" || !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		return fallback
+	}
+	return target
+}
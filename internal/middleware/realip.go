@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// RealIP sets RemoteAddr to the client address nginx passes in X-Real-IP,
+// but only for requests that come from nginx itself: a loopback or private
+// address, such as the proxy's container on the Docker network. nginx
+// always overwrites X-Real-IP with the address it was connected from, so
+// the value cannot be chosen by the client. Other forwarding headers, such
+// as True-Client-IP and X-Forwarded-For, are ignored, since nginx passes
+// them on from the client. Requests reaching the app directly keep their
+// own address.
+func RealIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip, ok := forwardedIP(r); ok {
+			r.RemoteAddr = ip.String()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// forwardedIP returns the address in X-Real-IP if the request came from a
+// trusted proxy.
+func forwardedIP(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	peer, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	if peer = peer.Unmap(); !peer.IsLoopback() && !peer.IsPrivate() {
+		return netip.Addr{}, false
+	}
+
+	ip, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP")))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return ip.Unmap(), true
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     http.Header
+		want       string
+	}{
+		{"from nginx", "172.18.0.5:41234", http.Header{"X-Real-Ip": {"203.0.113.7"}}, "203.0.113.7"},
+		{"from loopback", "127.0.0.1:41234", http.Header{"X-Real-Ip": {"203.0.113.7"}}, "203.0.113.7"},
+		{"from mapped private address", "[::ffff:10.0.0.2]:41234", http.Header{"X-Real-Ip": {"203.0.113.7"}}, "203.0.113.7"},
+		{"IPv6 client", "[::1]:41234", http.Header{"X-Real-Ip": {"2001:db8::1"}}, "2001:db8::1"},
+		{"no header", "172.18.0.5:41234", nil, "172.18.0.5:41234"},
+		{"malformed header", "172.18.0.5:41234", http.Header{"X-Real-Ip": {"203.0.113.7, 10.0.0.1"}}, "172.18.0.5:41234"},
+		{"direct client", "198.51.100.9:5555", http.Header{"X-Real-Ip": {"203.0.113.7"}}, "198.51.100.9:5555"},
+		{"True-Client-IP ignored", "172.18.0.5:41234", http.Header{"X-Real-Ip": {"203.0.113.7"}, "True-Client-Ip": {"192.0.2.1"}}, "203.0.113.7"},
+		{"X-Forwarded-For ignored", "172.18.0.5:41234", http.Header{"X-Forwarded-For": {"192.0.2.1"}}, "172.18.0.5:41234"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := RealIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header = tt.header
+			if req.Header == nil {
+				req.Header = http.Header{}
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("RemoteAddr = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Audiences accepted in Announcement.Audience and PhotoAlbum.Visibility.
+const (
+	AudiencePublic  = "public"
+	AudienceMembers = "members"
+)
+
+// Priorities accepted in Announcement.Priority. High-priority announcements
+// are shown as a site-wide banner.
+const (
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+)
+
+// Announcement is a notice published on the site for a limited time.
+// Soft-delete model (embeds gorm.Model).
+type Announcement struct {
+	gorm.Model
+	Title        string     `gorm:"column:title;type:varchar(255);not null" json:"title"`
+	Content      string     `gorm:"column:content;type:text;not null" json:"content"`
+	AuthorID     *uint      `gorm:"column:author_id" json:"author_id"`
+	Audience     string     `gorm:"column:audience;type:varchar(20);not null;default:'public'" json:"audience"`
+	Priority     string     `gorm:"column:priority;type:varchar(20);not null;default:'normal'" json:"priority"`
+	VisibleFrom  *time.Time `gorm:"column:visible_from" json:"visible_from"`
+	VisibleUntil *time.Time `gorm:"column:visible_until" json:"visible_until"`
+	IsActive     bool       `gorm:"column:is_active;default:true" json:"is_active"`
+}
+
+func (Announcement) TableName() string {
+	return "announcements"
+}
+
+// IsHighPriority reports whether the announcement is shown as a banner.
+func (a Announcement) IsHighPriority() bool {
+	return a.Priority == PriorityHigh
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Bulletin service types.
+const (
+	ServiceMorning = "morning"
+	ServiceEvening = "evening"
+)
+
+// BulletinServiceTypes lists the service types in display order.
+var BulletinServiceTypes = []string{ServiceMorning, ServiceEvening}
+
+// Bulletin is the PDF order of worship for one Lord's Day service.
+// Soft-delete model (embeds gorm.Model). FilePath is relative to the storage
+// directory. A MembersOnly bulletin is listed publicly, but only members may
+// download it.
+type Bulletin struct {
+	gorm.Model
+	BulletinDate time.Time `gorm:"column:bulletin_date;type:date;not null" json:"bulletin_date"`
+	ServiceType  string    `gorm:"column:service_type;type:varchar(20);not null" json:"service_type"`
+	FilePath     string    `gorm:"column:file_path;type:varchar(500);not null" json:"-"`
+	FileSize     int64     `gorm:"column:file_size" json:"file_size"`
+	MembersOnly  bool      `gorm:"column:members_only;default:false" json:"members_only"`
+	UploadedBy   *uint     `gorm:"column:uploaded_by" json:"uploaded_by"`
+	UploadedAt   time.Time `gorm:"column:uploaded_at" json:"uploaded_at"`
+}
+
+func (Bulletin) TableName() string {
+	return "bulletins"
+}
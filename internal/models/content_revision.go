@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Entity types recorded in ContentRevision.EntityType.
+const (
+	RevisionMinistryPage = "ministry_page"
+	RevisionAnnouncement = "announcement"
+	RevisionStaffMember  = "staff_member"
+)
+
+// ContentRevision keeps the editable fields of a piece of content as they
+// were before a change, as JSON, with who made the change and when. Hard-delete
+// model (manual fields).
+type ContentRevision struct {
+	ID         uint      `gorm:"column:id;primaryKey" json:"id"`
+	EntityType string    `gorm:"column:entity_type;type:varchar(50);not null" json:"entity_type"`
+	EntityID   uint      `gorm:"column:entity_id;not null" json:"entity_id"`
+	Content    string    `gorm:"column:content;type:jsonb;not null" json:"content"`
+	AuthorID   *uint     `gorm:"column:author_id" json:"author_id"`
+	CreatedAt  time.Time `gorm:"column:created_at" json:"created_at"`
+	Author     *User     `gorm:"foreignKey:AuthorID" json:"author,omitempty"`
+}
+
+func (ContentRevision) TableName() string {
+	return "content_revisions"
+}
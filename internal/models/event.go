@@ -6,6 +6,16 @@ import (
 	"gorm.io/gorm"
 )
 
+// Recurrence rules accepted in Event.RecurrenceRule.
+const (
+	RecurrenceNone     = "none"
+	RecurrenceDaily    = "daily"
+	RecurrenceWeekly   = "weekly"
+	RecurrenceBiweekly = "biweekly"
+	RecurrenceMonthly  = "monthly"
+	RecurrenceYearly   = "yearly"
+)
+
 // Event represents a church event. Soft-delete model (embeds gorm.Model).
 type Event struct {
 	gorm.Model
@@ -26,8 +36,35 @@ type Event struct {
 	IsPublic             bool       `gorm:"column:is_public;default:true" json:"is_public"`
 	MinistryID           *uint      `gorm:"column:ministry_id" json:"ministry_id"`
 	CreatedBy            *uint      `gorm:"column:created_by" json:"created_by"`
+	Ministry             *Ministry  `gorm:"foreignKey:MinistryID" json:"ministry,omitempty"`
 }
 
 func (Event) TableName() string {
 	return "events"
 }
+
+// RegistrationOpen reports whether sign-ups are accepted at now: registration
+// must be enabled, the deadline (if any) not yet passed, and a one-off event
+// not yet started.
+func (e Event) RegistrationOpen(now time.Time) bool {
+	if !e.RegistrationEnabled {
+		return false
+	}
+	if e.RegistrationDeadline != nil && now.After(*e.RegistrationDeadline) {
+		return false
+	}
+	return e.Recurs() || now.Before(e.EventDate)
+}
+
+// Recurs reports whether the event repeats under a known recurrence rule.
+func (e Event) Recurs() bool {
+	if !e.IsRecurring {
+		return false
+	}
+	switch e.RecurrenceRule {
+	case RecurrenceDaily, RecurrenceWeekly, RecurrenceBiweekly, RecurrenceMonthly, RecurrenceYearly:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// EventException overrides a single occurrence of a recurring event, either
+// cancelling it or moving it to a new time. Hard-delete model (manual fields).
+type EventException struct {
+	ID             uint       `gorm:"column:id;primaryKey" json:"id"`
+	EventID        uint       `gorm:"column:event_id;not null" json:"event_id"`
+	OccurrenceDate time.Time  `gorm:"column:occurrence_date;type:date;not null" json:"occurrence_date"`
+	IsCancelled    bool       `gorm:"column:is_cancelled;default:false" json:"is_cancelled"`
+	NewEventDate   *time.Time `gorm:"column:new_event_date" json:"new_event_date"`
+	NewEndDate     *time.Time `gorm:"column:new_end_date" json:"new_end_date"`
+	CreatedAt      time.Time  `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt      time.Time  `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (EventException) TableName() string {
+	return "event_exceptions"
+}
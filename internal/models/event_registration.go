@@ -0,0 +1,44 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event registration states.
+const (
+	RegistrationConfirmed  = "confirmed"
+	RegistrationWaitlisted = "waitlisted"
+	RegistrationCancelled  = "cancelled"
+)
+
+// EventRegistration records a member or guest signing up for an event.
+// Hard-delete model (manual fields). The cancel token is stored as a SHA-256
+// hash, never in plain text.
+type EventRegistration struct {
+	ID              uint                        `gorm:"column:id;primaryKey" json:"id"`
+	EventID         uint                        `gorm:"column:event_id;not null" json:"event_id"`
+	UserID          *uint                       `gorm:"column:user_id" json:"user_id"`
+	GuestName       string                      `gorm:"column:guest_name;type:varchar(255)" json:"guest_name"`
+	GuestEmail      string                      `gorm:"column:guest_email;type:varchar(255)" json:"guest_email"`
+	GuestPhone      string                      `gorm:"column:guest_phone;type:varchar(20)" json:"guest_phone"`
+	NumberAttending int                         `gorm:"column:number_attending;default:1" json:"number_attending"`
+	SpecialNeeds    string                      `gorm:"column:special_needs;type:text" json:"special_needs"`
+	FormData        json.RawMessage             `gorm:"column:form_data;type:jsonb" json:"form_data,omitempty"`
+	Status          string                      `gorm:"column:status;type:varchar(20);not null;default:'confirmed'" json:"status"`
+	CancelToken     *string                     `gorm:"column:cancel_token;type:varchar(255)" json:"-"`
+	RegisteredAt    time.Time                   `gorm:"column:registered_at;autoCreateTime" json:"registered_at"`
+	CancelledAt     *time.Time                  `gorm:"column:cancelled_at" json:"cancelled_at"`
+	Event           *Event                      `gorm:"foreignKey:EventID" json:"event,omitempty"`
+	User            *User                       `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Attendees       []EventRegistrationAttendee `gorm:"foreignKey:RegistrationID" json:"attendees,omitempty"`
+}
+
+func (EventRegistration) TableName() string {
+	return "event_registrations"
+}
+
+// IsActive reports whether the registration holds a seat or a waitlist place.
+func (r EventRegistration) IsActive() bool {
+	return r.Status == RegistrationConfirmed || r.Status == RegistrationWaitlisted
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Form is a developer-defined form. Schema is the JSON schema its fields are
+// rendered from and submissions are validated against.
+// Soft-delete model (embeds gorm.Model).
+type Form struct {
+	gorm.Model
+	Title       string `gorm:"column:title;type:varchar(255);not null" json:"title"`
+	Description string `gorm:"column:description;type:text" json:"description"`
+	Schema      string `gorm:"column:schema;type:jsonb;not null" json:"schema"`
+	IsActive    bool   `gorm:"column:is_active;default:true" json:"is_active"`
+	CreatedBy   *uint  `gorm:"column:created_by" json:"created_by"`
+}
+
+func (Form) TableName() string {
+	return "forms"
+}
+
+// FormSubmission is one set of answers to a form, as a JSON object keyed by
+// the schema's property names. UserID is set when the submitter was logged
+// in. Hard-delete model (manual fields).
+type FormSubmission struct {
+	ID          uint      `gorm:"column:id;primaryKey" json:"id"`
+	FormID      uint      `gorm:"column:form_id;not null" json:"form_id"`
+	UserID      *uint     `gorm:"column:user_id" json:"user_id"`
+	Data        string    `gorm:"column:data;type:jsonb;not null" json:"data"`
+	IPAddress   string    `gorm:"column:ip_address;type:varchar(45)" json:"ip_address"`
+	UserAgent   string    `gorm:"column:user_agent;type:text" json:"user_agent"`
+	SubmittedAt time.Time `gorm:"column:submitted_at;autoCreateTime" json:"submitted_at"`
+	User        *User     `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+func (FormSubmission) TableName() string {
+	return "form_submissions"
+}
@@ -0,0 +1,93 @@
+package models
+
+import "time"
+
+// Relationships accepted in HouseholdMember.Relationship.
+const (
+	RelationshipAdult = "adult"
+	RelationshipChild = "child"
+)
+
+// Household groups the people who live together and share an address and
+// phone number, so the directory can list a family once. Hard-delete model
+// (manual fields).
+type Household struct {
+	ID           uint              `gorm:"column:id;primaryKey" json:"id"`
+	Name         string            `gorm:"column:name;type:varchar(100);not null" json:"name"`
+	AddressLine1 string            `gorm:"column:address_line1;type:varchar(255)" json:"address_line1"`
+	AddressLine2 string            `gorm:"column:address_line2;type:varchar(255)" json:"address_line2"`
+	City         string            `gorm:"column:city;type:varchar(100)" json:"city"`
+	State        string            `gorm:"column:state;type:varchar(2)" json:"state"`
+	ZipCode      string            `gorm:"column:zip_code;type:varchar(10)" json:"zip_code"`
+	Phone        string            `gorm:"column:phone;type:varchar(20)" json:"phone"`
+	CreatedAt    time.Time         `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt    time.Time         `gorm:"column:updated_at" json:"updated_at"`
+	Members      []HouseholdMember `gorm:"foreignKey:HouseholdID" json:"members,omitempty"`
+}
+
+func (Household) TableName() string {
+	return "households"
+}
+
+// DisplayName returns the name the directory lists the household under,
+// e.g. "The Smith Family".
+func (h Household) DisplayName() string {
+	return "The " + h.Name + " Family"
+}
+
+// HasAddress reports whether any part of the street address is filled in.
+func (h Household) HasAddress() bool {
+	return h.AddressLine1 != "" || h.City != "" || h.State != "" || h.ZipCode != ""
+}
+
+// HouseholdMember is one person in a household. Members with a login link to
+// their user, whose name is used; children without logins carry their own
+// name. Hard-delete model (manual fields).
+type HouseholdMember struct {
+	ID           uint       `gorm:"column:id;primaryKey" json:"id"`
+	HouseholdID  uint       `gorm:"column:household_id;not null" json:"household_id"`
+	UserID       *uint      `gorm:"column:user_id" json:"user_id"`
+	FirstName    string     `gorm:"column:first_name;type:varchar(100)" json:"first_name"`
+	LastName     string     `gorm:"column:last_name;type:varchar(100)" json:"last_name"`
+	Relationship string     `gorm:"column:relationship;type:varchar(20);not null" json:"relationship"`
+	BirthDate    *time.Time `gorm:"column:birth_date;type:date" json:"birth_date,omitempty"`
+	CreatedAt    time.Time  `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"column:updated_at" json:"updated_at"`
+	User         *User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+func (HouseholdMember) TableName() string {
+	return "household_members"
+}
+
+// IsChild reports whether the member is a child of the household.
+func (m HouseholdMember) IsChild() bool {
+	return m.Relationship == RelationshipChild
+}
+
+// Names returns the member's first and last name, taken from their user when
+// they have a login and it is loaded.
+func (m HouseholdMember) Names() (first, last string) {
+	if m.User != nil {
+		return m.User.FirstName, m.User.LastName
+	}
+	return m.FirstName, m.LastName
+}
+
+// FullName returns the member's first and last name joined by a space.
+func (m HouseholdMember) FullName() string {
+	first, last := m.Names()
+	return first + " " + last
+}
+
+// EventRegistrationAttendee records a household member included in an event
+// registration. Hard-delete model (manual fields).
+type EventRegistrationAttendee struct {
+	RegistrationID    uint             `gorm:"column:registration_id;primaryKey;autoIncrement:false" json:"registration_id"`
+	HouseholdMemberID uint             `gorm:"column:household_member_id;primaryKey;autoIncrement:false" json:"household_member_id"`
+	HouseholdMember   *HouseholdMember `gorm:"foreignKey:HouseholdMemberID" json:"household_member,omitempty"`
+}
+
+func (EventRegistrationAttendee) TableName() string {
+	return "event_registration_attendees"
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// MemberProfile holds a member's contact details and directory privacy
+// settings. Hard-delete model (manual fields), keyed by user. Members are
+// hidden from the directory until they opt in, and each contact field is
+// shared only when its toggle is on.
+type MemberProfile struct {
+	UserID           uint       `gorm:"column:user_id;primaryKey;autoIncrement:false" json:"user_id"`
+	PhotoURL         string     `gorm:"column:photo_url;type:varchar(500)" json:"photo_url"`
+	AddressLine1     string     `gorm:"column:address_line1;type:varchar(255)" json:"address_line1"`
+	AddressLine2     string     `gorm:"column:address_line2;type:varchar(255)" json:"address_line2"`
+	City             string     `gorm:"column:city;type:varchar(100)" json:"city"`
+	State            string     `gorm:"column:state;type:varchar(2)" json:"state"`
+	ZipCode          string     `gorm:"column:zip_code;type:varchar(10)" json:"zip_code"`
+	MembershipDate   *time.Time `gorm:"column:membership_date;type:date" json:"membership_date"`
+	DirectoryOptIn   bool       `gorm:"column:directory_opt_in;default:false" json:"directory_opt_in"`
+	ShowEmail        bool       `gorm:"column:show_email;default:false" json:"show_email"`
+	ShowPhone        bool       `gorm:"column:show_phone;default:false" json:"show_phone"`
+	ShowAddress      bool       `gorm:"column:show_address;default:false" json:"show_address"`
+	EmergencyContact string     `gorm:"column:emergency_contact;type:varchar(255)" json:"-"`
+	EmergencyPhone   string     `gorm:"column:emergency_phone;type:varchar(20)" json:"-"`
+	CreatedAt        time.Time  `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt        time.Time  `gorm:"column:updated_at" json:"updated_at"`
+	User             *User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+func (MemberProfile) TableName() string {
+	return "member_profiles"
+}
+
+// HasAddress reports whether any part of the street address is filled in.
+func (p MemberProfile) HasAddress() bool {
+	return p.AddressLine1 != "" || p.City != "" || p.State != "" || p.ZipCode != ""
+}
@@ -1,20 +1,53 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 // Ministry represents a church ministry. Soft-delete model (embeds gorm.Model).
 type Ministry struct {
 	gorm.Model
-	Name         string  `gorm:"column:name;type:varchar(255);not null" json:"name"`
-	Slug         string  `gorm:"column:slug;type:varchar(255);uniqueIndex;not null" json:"slug"`
-	Description  string  `gorm:"column:description;type:text" json:"description"`
-	LeaderID     *uint   `gorm:"column:leader_id" json:"leader_id"`
-	ContactEmail string  `gorm:"column:contact_email;type:varchar(255)" json:"contact_email"`
-	MeetingTime  string  `gorm:"column:meeting_time;type:varchar(255)" json:"meeting_time"`
-	Location     string  `gorm:"column:location;type:varchar(255)" json:"location"`
-	IsActive     bool    `gorm:"column:is_active;default:true" json:"is_active"`
-	SortOrder    int     `gorm:"column:sort_order;default:0" json:"sort_order"`
-	PageContent  string  `gorm:"column:page_content;type:text" json:"page_content"`
+	Name         string `gorm:"column:name;type:varchar(255);not null" json:"name"`
+	Slug         string `gorm:"column:slug;type:varchar(255);uniqueIndex;not null" json:"slug"`
+	Description  string `gorm:"column:description;type:text" json:"description"`
+	LeaderID     *uint  `gorm:"column:leader_id" json:"leader_id"`
+	ContactEmail string `gorm:"column:contact_email;type:varchar(255)" json:"contact_email"`
+	MeetingTime  string `gorm:"column:meeting_time;type:varchar(255)" json:"meeting_time"`
+	Location     string `gorm:"column:location;type:varchar(255)" json:"location"`
+	IsActive     bool   `gorm:"column:is_active;default:true" json:"is_active"`
+	SortOrder    int    `gorm:"column:sort_order;default:0" json:"sort_order"`
+	PageContent  string `gorm:"column:page_content;type:text" json:"page_content"`
+
+	// Unpublished changes to PageContent. DraftContent is nil when there is
+	// no draft; DraftPublishAt, if set, publishes it automatically.
+	DraftContent   *string    `gorm:"column:draft_content;type:text" json:"-"`
+	DraftPublishAt *time.Time `gorm:"column:draft_publish_at" json:"-"`
+	DraftUpdatedAt *time.Time `gorm:"column:draft_updated_at" json:"-"`
+	DraftUpdatedBy *uint      `gorm:"column:draft_updated_by" json:"-"`
 }
 
 func (Ministry) TableName() string { return "ministries" }
+
+// HasDraft reports whether the ministry has unpublished page changes.
+func (m Ministry) HasDraft() bool {
+	return m.DraftContent != nil
+}
+
+// MinistryAssignment links a user to a ministry they serve in. CanEdit lets
+// members who are not staff maintain the ministry's page. Hard-delete model
+// (manual fields).
+type MinistryAssignment struct {
+	ID         uint      `gorm:"column:id;primaryKey" json:"id"`
+	UserID     uint      `gorm:"column:user_id;not null" json:"user_id"`
+	MinistryID uint      `gorm:"column:ministry_id;not null" json:"ministry_id"`
+	CanEdit    bool      `gorm:"column:can_edit" json:"can_edit"`
+	AssignedAt time.Time `gorm:"column:assigned_at;autoCreateTime" json:"assigned_at"`
+	AssignedBy *uint     `gorm:"column:assigned_by" json:"assigned_by"`
+	User       *User     `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+func (MinistryAssignment) TableName() string {
+	return "ministry_assignments"
+}
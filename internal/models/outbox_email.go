@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Outbox email delivery states.
+const (
+	OutboxPending = "pending"
+	OutboxSending = "sending"
+	OutboxSent    = "sent"
+	OutboxFailed  = "failed"
+)
+
+// OutboxEmail is a queued transactional email awaiting delivery.
+// Hard-delete model (manual fields).
+type OutboxEmail struct {
+	ID            uint       `gorm:"column:id;primaryKey" json:"id"`
+	ToEmail       string     `gorm:"column:to_email;type:varchar(255);not null" json:"to_email"`
+	ToName        string     `gorm:"column:to_name;type:varchar(255)" json:"to_name"`
+	Subject       string     `gorm:"column:subject;type:varchar(255);not null" json:"subject"`
+	HTMLBody      string     `gorm:"column:html_body;type:text;not null" json:"-"`
+	TextBody      string     `gorm:"column:text_body;type:text;not null" json:"-"`
+	Status        string     `gorm:"column:status;type:varchar(20);not null;default:'pending'" json:"status"`
+	Attempts      int        `gorm:"column:attempts;not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"column:next_attempt_at;not null" json:"next_attempt_at"`
+	LastError     string     `gorm:"column:last_error;type:text" json:"last_error"`
+	CreatedAt     time.Time  `gorm:"column:created_at" json:"created_at"`
+	SentAt        *time.Time `gorm:"column:sent_at" json:"sent_at"`
+}
+
+func (OutboxEmail) TableName() string {
+	return "email_outbox"
+}
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// PhotoAlbum groups photos in the gallery. Visibility is AudiencePublic or
+// AudienceMembers. Hard-delete model (manual fields).
+type PhotoAlbum struct {
+	ID           uint      `gorm:"column:id;primaryKey" json:"id"`
+	Title        string    `gorm:"column:title;type:varchar(255);not null" json:"title"`
+	Slug         string    `gorm:"column:slug;type:varchar(100);uniqueIndex;not null" json:"slug"`
+	Description  string    `gorm:"column:description;type:text" json:"description"`
+	Visibility   string    `gorm:"column:visibility;type:varchar(20);not null;default:'public'" json:"visibility"`
+	CoverPhotoID *uint     `gorm:"column:cover_photo_id" json:"cover_photo_id"`
+	CreatedBy    *uint     `gorm:"column:created_by" json:"created_by"`
+	CreatedAt    time.Time `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt    time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (PhotoAlbum) TableName() string {
+	return "photo_albums"
+}
+
+// MembersOnly reports whether only members may see the album.
+func (a PhotoAlbum) MembersOnly() bool {
+	return a.Visibility == AudienceMembers
+}
+
+// Photo is an uploaded gallery photo, stored as responsive WebP variants.
+// Photos are drafts until published, which requires alt text.
+// Hard-delete model (manual fields).
+type Photo struct {
+	ID           uint      `gorm:"column:id;primaryKey" json:"id"`
+	AlbumID      uint      `gorm:"column:album_id;not null" json:"album_id"`
+	Caption      string    `gorm:"column:caption;type:text" json:"caption"`
+	AltText      string    `gorm:"column:alt_text;type:varchar(500)" json:"alt_text"`
+	PhotoURL     string    `gorm:"column:photo_url;type:varchar(500);not null" json:"photo_url"`
+	Srcset       string    `gorm:"column:srcset;type:text" json:"srcset"`
+	ThumbnailURL string    `gorm:"column:thumbnail_url;type:varchar(500)" json:"thumbnail_url"`
+	Width        int       `gorm:"column:width" json:"width"`
+	Height       int       `gorm:"column:height" json:"height"`
+	FileSize     int64     `gorm:"column:file_size" json:"file_size"`
+	IsPublished  bool      `gorm:"column:is_published;not null;default:false" json:"is_published"`
+	UploadedBy   *uint     `gorm:"column:uploaded_by" json:"uploaded_by"`
+	UploadedAt   time.Time `gorm:"column:uploaded_at;autoCreateTime" json:"uploaded_at"`
+	UpdatedAt    time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+func (Photo) TableName() string {
+	return "photos"
+}
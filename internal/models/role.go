@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Role names seeded by the create_roles migration.
+const (
+	RolePublic    = "public"
+	RoleMember    = "member"
+	RoleDeacon    = "deacon"
+	RoleElder     = "elder"
+	RoleStaff     = "staff"
+	RoleMusician  = "musician"
+	RolePastor    = "pastor"
+	RoleVolunteer = "volunteer"
+	RoleAdmin     = "admin"
+)
+
+// Role represents a named permission set. Hard-delete model (manual fields).
+type Role struct {
+	ID          uint      `gorm:"column:id;primaryKey" json:"id"`
+	Name        string    `gorm:"column:name;type:varchar(50);uniqueIndex;not null" json:"name"`
+	Description string    `gorm:"column:description;type:text" json:"description"`
+	CreatedAt   time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+func (Role) TableName() string {
+	return "roles"
+}
+
+// UserRole links a user to a role. Hard-delete model (manual fields).
+type UserRole struct {
+	UserID     uint      `gorm:"column:user_id;primaryKey" json:"user_id"`
+	RoleID     uint      `gorm:"column:role_id;primaryKey" json:"role_id"`
+	AssignedAt time.Time `gorm:"column:assigned_at;autoCreateTime" json:"assigned_at"`
+	AssignedBy *uint     `gorm:"column:assigned_by" json:"assigned_by"`
+}
+
+func (UserRole) TableName() string {
+	return "user_roles"
+}
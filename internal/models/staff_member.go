@@ -1,58 +1,63 @@
 package models
 
 import (
-	"sort"
+	"time"
 
 	"gorm.io/gorm"
 )
 
-// StaffCategory is a typed string for staff member categories.
+// StaffCategory is the slug of a staff category, stored in
+// StaffMember.Category.
 type StaffCategory string
 
+// Categories seeded by migration. Admins can add, rename, and reorder
+// categories, so these are only defaults.
 const (
 	CategoryPastor StaffCategory = "pastor"
 	CategoryStaff  StaffCategory = "staff"
 )
 
-// StaffCategoryInfo holds the display label and sort order for a category.
+// StaffCategoryInfo is a staff category with its display label and sort
+// order, e.g. "Teaching Elders". Hard-delete model (manual fields).
 type StaffCategoryInfo struct {
-	Label        string
-	DisplayOrder int
+	ID           uint          `gorm:"column:id;primaryKey" json:"id"`
+	Slug         StaffCategory `gorm:"column:slug;type:varchar(50);uniqueIndex;not null" json:"slug"`
+	Label        string        `gorm:"column:label;type:varchar(255);not null" json:"label"`
+	DisplayOrder int           `gorm:"column:display_order" json:"display_order"`
+	CreatedAt    time.Time     `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt    time.Time     `gorm:"column:updated_at" json:"updated_at"`
 }
 
-// StaffCategories maps each category to its display metadata.
-var StaffCategories = map[StaffCategory]StaffCategoryInfo{
-	CategoryPastor: {Label: "Teaching Elders", DisplayOrder: 1},
-	CategoryStaff:  {Label: "Staff", DisplayOrder: 2},
-}
-
-// OrderedStaffCategories returns categories sorted by DisplayOrder.
-func OrderedStaffCategories() []StaffCategory {
-	cats := make([]StaffCategory, 0, len(StaffCategories))
-	for c := range StaffCategories {
-		cats = append(cats, c)
-	}
-	sort.Slice(cats, func(i, j int) bool {
-		return StaffCategories[cats[i]].DisplayOrder < StaffCategories[cats[j]].DisplayOrder
-	})
-	return cats
+func (StaffCategoryInfo) TableName() string {
+	return "staff_categories"
 }
 
 // StaffMember represents a church staff member. Soft-delete model (embeds gorm.Model).
 type StaffMember struct {
 	gorm.Model
-	UserID       *uint         `gorm:"column:user_id" json:"user_id"`
-	Name         string        `gorm:"column:name;type:varchar(255);not null" json:"name"`
-	Title        string        `gorm:"column:title;type:varchar(255);not null" json:"title"`
-	Bio          string        `gorm:"column:bio;type:text" json:"bio"`
-	Email        string        `gorm:"column:email;type:varchar(255)" json:"email"`
-	Phone        string        `gorm:"column:phone;type:varchar(20)" json:"phone"`
-	PhotoURL     string        `gorm:"column:photo_url;type:varchar(500)" json:"photo_url"`
-	DisplayOrder int           `gorm:"column:display_order;default:0" json:"display_order"`
-	IsActive     bool          `gorm:"column:is_active;default:true" json:"is_active"`
-	Category     StaffCategory `gorm:"column:category;type:varchar(50);not null;default:'staff'" json:"category"`
+	UserID            *uint         `gorm:"column:user_id" json:"user_id"`
+	Name              string        `gorm:"column:name;type:varchar(255);not null" json:"name"`
+	Title             string        `gorm:"column:title;type:varchar(255);not null" json:"title"`
+	Bio               string        `gorm:"column:bio;type:text" json:"bio"`
+	Email             string        `gorm:"column:email;type:varchar(255)" json:"email"`
+	Phone             string        `gorm:"column:phone;type:varchar(20)" json:"phone"`
+	PhotoURL          string        `gorm:"column:photo_url;type:varchar(500)" json:"photo_url"`
+	PhotoSrcset       string        `gorm:"column:photo_srcset;type:text" json:"photo_srcset"`
+	PhotoThumbnailURL string        `gorm:"column:photo_thumbnail_url;type:varchar(500)" json:"photo_thumbnail_url"`
+	DisplayOrder      int           `gorm:"column:display_order;default:0" json:"display_order"`
+	IsActive          bool          `gorm:"column:is_active;default:true" json:"is_active"`
+	Category          StaffCategory `gorm:"column:category;type:varchar(50);not null;default:'staff'" json:"category"`
 }
 
 func (StaffMember) TableName() string {
 	return "staff_members"
 }
+
+// ThumbnailURL returns the square thumbnail of an uploaded headshot, or the
+// photo itself for photos shipped with the site.
+func (m StaffMember) ThumbnailURL() string {
+	if m.PhotoThumbnailURL != "" {
+		return m.PhotoThumbnailURL
+	}
+	return m.PhotoURL
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// User represents a registered site account. Soft-delete model (embeds gorm.Model).
+// Verification and reset tokens are stored as SHA-256 hashes, never in plain text.
+type User struct {
+	gorm.Model
+	Email             string     `gorm:"column:email;type:varchar(255);uniqueIndex;not null" json:"email"`
+	PasswordHash      string     `gorm:"column:password_hash;type:varchar(255);not null" json:"-"`
+	FirstName         string     `gorm:"column:first_name;type:varchar(100);not null" json:"first_name"`
+	LastName          string     `gorm:"column:last_name;type:varchar(100);not null" json:"last_name"`
+	Phone             string     `gorm:"column:phone;type:varchar(20)" json:"phone"`
+	IsVerified        bool       `gorm:"column:is_verified;default:false" json:"is_verified"`
+	VerificationToken *string    `gorm:"column:verification_token;type:varchar(255)" json:"-"`
+	ResetToken        *string    `gorm:"column:reset_token;type:varchar(255)" json:"-"`
+	ResetTokenExpires *time.Time `gorm:"column:reset_token_expires" json:"-"`
+	LastLogin         *time.Time `gorm:"column:last_login" json:"last_login"`
+	FailedLoginCount  int        `gorm:"column:failed_login_count;default:0" json:"-"`
+	LockedUntil       *time.Time `gorm:"column:locked_until" json:"-"`
+}
+
+func (User) TableName() string {
+	return "users"
+}
+
+// FullName returns the user's first and last name joined by a space.
+func (u User) FullName() string {
+	return u.FirstName + " " + u.LastName
+}
@@ -0,0 +1,351 @@
+// Package pdf writes simple PDF 1.4 documents: text in the standard Helvetica
+// fonts, filled rectangles, lines, and JPEG images on US Letter pages. It
+// covers what the printed member directory needs without pulling in a
+// layout engine.
+//
+// Coordinates are in points measured from the top-left corner of the page;
+// text is positioned by its baseline.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // register decoder for LoadImage
+	"image/jpeg"
+	_ "image/png" // register decoder for LoadImage
+	"io"
+	"strings"
+	"time"
+
+	_ "golang.org/x/image/webp" // register decoder for LoadImage
+)
+
+// US Letter page size in points.
+const (
+	PageWidth  = 612.0
+	PageHeight = 792.0
+)
+
+// Font is one of the standard Type 1 fonts every PDF reader provides.
+type Font int
+
+const (
+	Helvetica Font = iota
+	HelveticaBold
+)
+
+var fontNames = [...]string{Helvetica: "Helvetica", HelveticaBold: "Helvetica-Bold"}
+
+// Image is a picture prepared for embedding, stored as a JPEG.
+type Image struct {
+	data          []byte
+	width, height int
+}
+
+// LoadImage decodes a JPEG, PNG, GIF, or WebP image and re-encodes it as a
+// JPEG for embedding. Transparent areas are flattened onto white.
+func LoadImage(r io.Reader) (*Image, error) {
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+
+	bounds := src.Bounds()
+	flat := image.NewRGBA(bounds)
+	draw.Draw(flat, bounds, image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, bounds, src, bounds.Min, draw.Over)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, fmt.Errorf("encode image: %w", err)
+	}
+
+	return &Image{data: buf.Bytes(), width: bounds.Dx(), height: bounds.Dy()}, nil
+}
+
+// Size returns the image's width and height in pixels.
+func (img *Image) Size() (int, int) {
+	return img.width, img.height
+}
+
+// Document is a PDF being built page by page.
+type Document struct {
+	title   string
+	created time.Time
+	pages   []*bytes.Buffer
+	images  []*Image
+	imageID map[*Image]int // 1-based index into images
+	font    Font
+	size    float64
+}
+
+// New starts an empty document with the given title in its metadata.
+func New(title string, created time.Time) *Document {
+	return &Document{title: title, created: created, imageID: make(map[*Image]int), font: Helvetica, size: 12}
+}
+
+// PageCount returns the number of pages added so far.
+func (d *Document) PageCount() int {
+	return len(d.pages)
+}
+
+// AddPage starts a new page; later drawing goes onto it.
+func (d *Document) AddPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+}
+
+func (d *Document) page() *bytes.Buffer {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+	return d.pages[len(d.pages)-1]
+}
+
+// SetFont selects the font and size in points for Text and TextWidth.
+func (d *Document) SetFont(font Font, size float64) {
+	d.font = font
+	d.size = size
+}
+
+// SetColor sets the color used for text, rectangles, and lines on the
+// current page. Components range from 0 to 1.
+func (d *Document) SetColor(r, g, b float64) {
+	c := num(r) + " " + num(g) + " " + num(b)
+	fmt.Fprintf(d.page(), "%s rg %s RG\n", c, c)
+}
+
+// Text draws s with its baseline starting at (x, y).
+func (d *Document) Text(x, y float64, s string) {
+	fmt.Fprintf(d.page(), "BT /F%d %s Tf %s %s Td (%s) Tj ET\n",
+		d.font+1, num(d.size), num(x), num(PageHeight-y), escape(encode(s)))
+}
+
+// TextWidth returns the width of s in points in the current font and size.
+func (d *Document) TextWidth(s string) float64 {
+	widths := &helveticaWidths
+	if d.font == HelveticaBold {
+		widths = &helveticaBoldWidths
+	}
+
+	total := 0
+	for _, c := range encode(s) {
+		if c >= 32 && c <= 126 {
+			total += widths[c-32]
+		} else {
+			total += 556 // close enough for accented letters and punctuation
+		}
+	}
+	return float64(total) * d.size / 1000
+}
+
+// Wrap splits s into lines no wider than width in the current font, breaking
+// at spaces. A single word wider than width is left on its own line.
+func (d *Document) Wrap(s string, width float64) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if line != "" && d.TextWidth(candidate) > width {
+			lines = append(lines, line)
+			line = word
+			continue
+		}
+		line = candidate
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Line draws a line of the given stroke width in the current color.
+func (d *Document) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(d.page(), "%s w %s %s m %s %s l S\n",
+		num(width), num(x1), num(PageHeight-y1), num(x2), num(PageHeight-y2))
+}
+
+// Rect fills a rectangle whose top-left corner is at (x, y).
+func (d *Document) Rect(x, y, w, h float64) {
+	fmt.Fprintf(d.page(), "%s %s %s %s re f\n", num(x), num(PageHeight-y-h), num(w), num(h))
+}
+
+// Image draws img scaled to w by h with its top-left corner at (x, y). An
+// image drawn more than once is embedded once.
+func (d *Document) Image(img *Image, x, y, w, h float64) {
+	id, ok := d.imageID[img]
+	if !ok {
+		d.images = append(d.images, img)
+		id = len(d.images)
+		d.imageID[img] = id
+	}
+	fmt.Fprintf(d.page(), "q %s 0 0 %s %s %s cm /Im%d Do Q\n",
+		num(w), num(h), num(x), num(PageHeight-y-h), id)
+}
+
+// WriteTo writes the finished document to w.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	var out bytes.Buffer
+	var offsets []int
+
+	// Objects are numbered in the order they are written: catalog, page tree,
+	// shared resources, fonts, info, images, then each page and its content.
+	const (
+		catalogID   = 1
+		pagesID     = 2
+		resourcesID = 3
+		firstFontID = 4
+	)
+	infoID := firstFontID + len(fontNames)
+	firstImageID := infoID + 1
+	firstPageID := firstImageID + len(d.images)
+
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+	stream := func(dict string, data []byte) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n<< %s /Length %d >>\nstream\n", len(offsets), dict, len(data))
+		out.Write(data)
+		out.WriteString("\nendstream\nendobj\n")
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	object(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesID))
+
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPageID+2*i)
+	}
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+
+	var resources strings.Builder
+	resources.WriteString("<< /ProcSet [/PDF /Text /ImageC] /Font <<")
+	for i := range fontNames {
+		fmt.Fprintf(&resources, " /F%d %d 0 R", i+1, firstFontID+i)
+	}
+	resources.WriteString(" >> /XObject <<")
+	for i := range d.images {
+		fmt.Fprintf(&resources, " /Im%d %d 0 R", i+1, firstImageID+i)
+	}
+	resources.WriteString(" >> >>")
+	object(resources.String())
+
+	for _, name := range fontNames {
+		object(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", name))
+	}
+
+	object(fmt.Sprintf("<< /Title (%s) /Producer (Saint Andrew's Chapel) /CreationDate (D:%s) >>",
+		escape(encode(d.title)), d.created.UTC().Format("20060102150405Z")))
+
+	for _, img := range d.images {
+		stream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode",
+			img.width, img.height), img.data)
+	}
+
+	for i, content := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources %d 0 R /Contents %d 0 R >>",
+			pagesID, num(PageWidth), num(PageHeight), resourcesID, firstPageID+2*i+1))
+
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		if _, err := zw.Write(content.Bytes()); err != nil {
+			return 0, err
+		}
+		if err := zw.Close(); err != nil {
+			return 0, err
+		}
+		stream("/Filter /FlateDecode", compressed.Bytes())
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(offsets)+1, catalogID, infoID, xref)
+
+	return out.WriteTo(w)
+}
+
+// num formats a coordinate compactly, e.g. 72 or 72.5.
+func num(f float64) string {
+	s := fmt.Sprintf("%.2f", f)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// escape backslash-escapes the characters that delimit PDF literal strings.
+func escape(b []byte) string {
+	var sb strings.Builder
+	for _, c := range b {
+		switch c {
+		case '\\', '(', ')':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		case '\r', '\n':
+			sb.WriteByte(' ')
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// winAnsiExtras maps the Windows-1252 characters outside Latin-1.
+var winAnsiExtras = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9A, '›': 0x9B, 'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+// encode converts s to WinAnsiEncoding, replacing characters the standard
+// fonts cannot show with '?'.
+func encode(s string) []byte {
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r < 0x80 || (r >= 0xA0 && r <= 0xFF):
+			b = append(b, byte(r))
+		case winAnsiExtras[r] != 0:
+			b = append(b, winAnsiExtras[r])
+		default:
+			b = append(b, '?')
+		}
+	}
+	return b
+}
+
+// Advance widths in thousandths of an em for characters 32 to 126, from the
+// Adobe font metrics for the standard fonts.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBoldWidths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
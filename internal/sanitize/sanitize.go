@@ -0,0 +1,167 @@
+// Package sanitize cleans user-edited rich text before it is stored and later
+// rendered unescaped. Only a small allowlist of formatting elements survives;
+// everything else is unwrapped to its text or, for scripts and embedded
+// content, removed entirely.
+package sanitize
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// allowedElements are kept, along with the attributes listed for each.
+var allowedElements = map[string][]string{
+	"p":          nil,
+	"br":         nil,
+	"h2":         nil,
+	"h3":         nil,
+	"h4":         nil,
+	"strong":     nil,
+	"b":          nil,
+	"em":         nil,
+	"i":          nil,
+	"u":          nil,
+	"s":          nil,
+	"blockquote": nil,
+	"ul":         nil,
+	"ol":         nil,
+	"li":         nil,
+	"hr":         nil,
+	"a":          {"href", "title"},
+}
+
+// droppedElements are removed together with their content.
+var droppedElements = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"iframe":   true,
+	"frame":    true,
+	"frameset": true,
+	"object":   true,
+	"embed":    true,
+	"applet":   true,
+	"template": true,
+	"noscript": true,
+	"textarea": true,
+	"select":   true,
+	"svg":      true,
+	"math":     true,
+	"head":     true,
+	"title":    true,
+}
+
+// allowedSchemes are the link targets permitted in href. Relative links have
+// no scheme and are always allowed.
+var allowedSchemes = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"mailto": true,
+	"tel":    true,
+}
+
+// HTML returns s with everything outside the allowlist removed. The result
+// is well-formed and safe to render with templ.Raw.
+func HTML(s string) string {
+	context := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	nodes, err := html.ParseFragment(strings.NewReader(s), context)
+	if err != nil {
+		// The parser only fails on reader errors, which a strings.Reader
+		// never returns; fall back to plain text just in case.
+		return html.EscapeString(s)
+	}
+
+	var out strings.Builder
+	for _, n := range nodes {
+		for _, kept := range clean(n) {
+			if err := html.Render(&out, kept); err != nil {
+				return html.EscapeString(s)
+			}
+		}
+	}
+	return strings.TrimSpace(out.String())
+}
+
+// clean returns the nodes that replace n: n itself with its attributes and
+// children cleaned, n's cleaned children when the element is unwrapped, or
+// nothing.
+func clean(n *html.Node) []*html.Node {
+	switch n.Type {
+	case html.TextNode:
+		return []*html.Node{n}
+	case html.ElementNode:
+		// handled below
+	default:
+		return nil
+	}
+
+	if droppedElements[n.Data] {
+		return nil
+	}
+
+	var children []*html.Node
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		n.RemoveChild(c)
+		children = append(children, clean(c)...)
+		c = next
+	}
+
+	attrs, ok := allowedElements[n.Data]
+	if !ok {
+		return children
+	}
+
+	n.Attr = cleanAttrs(n.Data, n.Attr, attrs)
+	if n.Data == "a" && len(n.Attr) == 0 {
+		return children
+	}
+	for _, c := range children {
+		n.AppendChild(c)
+	}
+	return []*html.Node{n}
+}
+
+// cleanAttrs keeps the allowed attributes, dropping links with unsafe URLs.
+func cleanAttrs(tag string, attrs []html.Attribute, allowed []string) []html.Attribute {
+	var kept []html.Attribute
+	for _, a := range attrs {
+		if a.Namespace != "" || !slices.Contains(allowed, a.Key) {
+			continue
+		}
+		if a.Key == "href" {
+			href, ok := safeURL(a.Val)
+			if !ok {
+				continue
+			}
+			a.Val = href
+		}
+		kept = append(kept, a)
+	}
+
+	// A link is only kept when it still has somewhere to go.
+	if tag == "a" && !slices.ContainsFunc(kept, func(a html.Attribute) bool { return a.Key == "href" }) {
+		return nil
+	}
+	return kept
+}
+
+// safeURL reports whether raw is a relative URL or uses an allowed scheme,
+// returning it trimmed.
+func safeURL(raw string) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", false
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+	if u.Scheme != "" && !allowedSchemes[u.Scheme] {
+		return "", false
+	}
+	return raw, true
+}
@@ -0,0 +1,63 @@
+package sanitize
+
+import "testing"
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"empty", "", ""},
+		{"plain text", "Hello", "Hello"},
+		{"text is escaped", "1 < 2 & 3 > 2", "1 &lt; 2 &amp; 3 &gt; 2"},
+		{"allowed formatting", "<p>A <strong>bold</strong> and <em>calm</em> word</p>", "<p>A <strong>bold</strong> and <em>calm</em> word</p>"},
+		{"lists", "<ul><li>One</li><li>Two</li></ul>", "<ul><li>One</li><li>Two</li></ul>"},
+		{"void elements", "<p>a<br>b</p><hr>", "<p>a<br/>b</p><hr/>"},
+		{"unknown element unwrapped", "<div><span>kept</span></div>", "kept"},
+		{"h1 unwrapped", "<h1>Title</h1>", "Title"},
+		{"unclosed tags closed", "<p><strong>open", "<p><strong>open</strong></p>"},
+
+		{"script removed", "<p>a</p><script>alert(1)</script><p>b</p>", "<p>a</p><p>b</p>"},
+		{"style removed", "<style>p{}</style>text", "text"},
+		{"iframe removed", `<iframe src="https://evil.example"></iframe>x`, "x"},
+		{"svg removed", `<svg><script>alert(1)</script></svg>x`, "x"},
+		{"math removed", `<math><mtext><img src=x onerror=alert(1)></mtext></math>x`, "x"},
+		{"nested script in unwrapped element", `<div><script>alert(1)</script>ok</div>`, "ok"},
+		{"comment removed", "a<!-- secret -->b", "ab"},
+		{"img removed", `<img src="x" onerror="alert(1)">`, ""},
+
+		{"event handler dropped", `<p onclick="alert(1)">x</p>`, "<p>x</p>"},
+		{"style attribute dropped", `<p style="color:red">x</p>`, "<p>x</p>"},
+		{"class attribute dropped", `<strong class="x" id="y">x</strong>`, "<strong>x</strong>"},
+
+		{"https link", `<a href="https://example.com/a?b=1&amp;c=2" title="T">x</a>`, `<a href="https://example.com/a?b=1&amp;c=2" title="T">x</a>`},
+		{"relative link", `<a href="/events">x</a>`, `<a href="/events">x</a>`},
+		{"mailto link", `<a href="mailto:office@example.com">x</a>`, `<a href="mailto:office@example.com">x</a>`},
+		{"tel link", `<a href="tel:+14075551234">x</a>`, `<a href="tel:+14075551234">x</a>`},
+		{"href trimmed", `<a href=" https://example.com ">x</a>`, `<a href="https://example.com">x</a>`},
+		{"link without href unwrapped", `<a name="top">x</a>`, "x"},
+		{"link target dropped", `<a href="/a" target="_blank" rel="opener">x</a>`, `<a href="/a">x</a>`},
+
+		{"javascript link", `<a href="javascript:alert(1)">x</a>`, "x"},
+		{"javascript link uppercase", `<a href="JavaScript:alert(1)">x</a>`, "x"},
+		{"javascript link padded", `<a href="  javascript:alert(1)">x</a>`, "x"},
+		{"javascript link entity", `<a href="javascript&#58;alert(1)">x</a>`, "x"},
+		{"javascript link encoded letters", `<a href="&#x6A;avascript:alert(1)">x</a>`, "x"},
+		{"javascript link with tab", "<a href=\"java\tscript:alert(1)\">x</a>", "x"},
+		{"javascript link with newline", "<a href=\"java\nscript:alert(1)\">x</a>", "x"},
+		{"data link", `<a href="data:text/html;base64,PHNjcmlwdD4=">x</a>`, "x"},
+		{"vbscript link", `<a href="vbscript:msgbox(1)">x</a>`, "x"},
+		{"empty href", `<a href="">x</a>`, "x"},
+
+		{"attribute breakout", `<p title="a"><a href="/x" title='"><script>alert(1)</script>'>y</a></p>`, `<p><a href="/x" title="&#34;&gt;&lt;script&gt;alert(1)&lt;/script&gt;">y</a></p>`},
+		{"unterminated tag", `<p>a</p><scr<script>ipt>alert(1)</script>`, "<p>a</p>ipt&gt;alert(1)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTML(tt.in); got != tt.want {
+				t.Errorf("HTML(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
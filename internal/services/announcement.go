@@ -0,0 +1,268 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sfdeloach/churchsite/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AnnouncementInput holds the fields submitted on the staff announcement
+// form. Dates use DateTimeInputLayout.
+type AnnouncementInput struct {
+	Title        string
+	Content      string
+	Audience     string
+	Priority     string
+	VisibleFrom  string
+	VisibleUntil string
+	IsActive     bool
+}
+
+// AnnouncementService handles announcement queries and staff edits.
+type AnnouncementService struct {
+	db *gorm.DB
+}
+
+// NewAnnouncementService creates a new AnnouncementService.
+func NewAnnouncementService(db *gorm.DB) *AnnouncementService {
+	return &AnnouncementService{db: db}
+}
+
+// publishedAnnouncements restricts a query to active announcements within
+// their publish window at now. Members-only announcements are included only
+// when members is true.
+func publishedAnnouncements(now time.Time, members bool) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.
+			Where("is_active = ?", true).
+			Where("(visible_from IS NULL OR visible_from <= ?)", now).
+			Where("(visible_until IS NULL OR visible_until >= ?)", now)
+		if !members {
+			db = db.Where("audience = ?", models.AudiencePublic)
+		}
+		return db.Order("COALESCE(visible_from, created_at) DESC")
+	}
+}
+
+// GetBanners returns the published high-priority announcements.
+func (s *AnnouncementService) GetBanners(now time.Time, members bool) ([]models.Announcement, error) {
+	var announcements []models.Announcement
+
+	err := s.db.
+		Scopes(publishedAnnouncements(now, members)).
+		Where("priority = ?", models.PriorityHigh).
+		Find(&announcements).Error
+
+	return announcements, err
+}
+
+// GetPublished returns up to limit published normal-priority announcements,
+// newest first.
+func (s *AnnouncementService) GetPublished(now time.Time, members bool, limit int) ([]models.Announcement, error) {
+	var announcements []models.Announcement
+
+	err := s.db.
+		Scopes(publishedAnnouncements(now, members)).
+		Where("priority = ?", models.PriorityNormal).
+		Limit(limit).
+		Find(&announcements).Error
+
+	return announcements, err
+}
+
+// ListAll returns every non-deleted announcement for staff, newest first.
+func (s *AnnouncementService) ListAll() ([]models.Announcement, error) {
+	var announcements []models.Announcement
+
+	err := s.db.
+		Order("created_at DESC").
+		Find(&announcements).Error
+
+	return announcements, err
+}
+
+// GetByID returns a non-deleted announcement regardless of its publish window.
+// Returns gorm.ErrRecordNotFound if it does not exist.
+func (s *AnnouncementService) GetByID(id uint) (*models.Announcement, error) {
+	var announcement models.Announcement
+
+	if err := s.db.First(&announcement, id).Error; err != nil {
+		return nil, err
+	}
+
+	return &announcement, nil
+}
+
+// AnnouncementInputFrom returns the form values for an existing announcement.
+func AnnouncementInputFrom(a models.Announcement) AnnouncementInput {
+	return AnnouncementInput{
+		Title:        a.Title,
+		Content:      a.Content,
+		Audience:     a.Audience,
+		Priority:     a.Priority,
+		VisibleFrom:  formatOptional(a.VisibleFrom, DateTimeInputLayout),
+		VisibleUntil: formatOptional(a.VisibleUntil, DateTimeInputLayout),
+		IsActive:     a.IsActive,
+	}
+}
+
+// Create validates in and stores a new announcement written by authorID.
+// Returns ValidationErrors for bad input.
+func (s *AnnouncementService) Create(in AnnouncementInput, authorID uint) (*models.Announcement, error) {
+	var announcement models.Announcement
+	if err := applyAnnouncementInput(&announcement, in); err != nil {
+		return nil, err
+	}
+	announcement.AuthorID = &authorID
+
+	if err := s.db.Create(&announcement).Error; err != nil {
+		return nil, err
+	}
+
+	return &announcement, nil
+}
+
+// announcementRevision is the part of an announcement kept in its revisions.
+type announcementRevision struct {
+	Title        string     `json:"title"`
+	Content      string     `json:"content"`
+	Audience     string     `json:"audience"`
+	Priority     string     `json:"priority"`
+	VisibleFrom  *time.Time `json:"visible_from"`
+	VisibleUntil *time.Time `json:"visible_until"`
+	IsActive     bool       `json:"is_active"`
+}
+
+func announcementRevisionOf(a models.Announcement) announcementRevision {
+	return announcementRevision{
+		Title:        a.Title,
+		Content:      a.Content,
+		Audience:     a.Audience,
+		Priority:     a.Priority,
+		VisibleFrom:  a.VisibleFrom,
+		VisibleUntil: a.VisibleUntil,
+		IsActive:     a.IsActive,
+	}
+}
+
+// Update validates in and saves it over an existing announcement, recording
+// the previous version as a revision by editorID.
+// Returns gorm.ErrRecordNotFound for unknown announcements and
+// ValidationErrors for bad input.
+func (s *AnnouncementService) Update(id uint, in AnnouncementInput, editorID uint) (*models.Announcement, error) {
+	var announcement models.Announcement
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&announcement, id).Error; err != nil {
+			return err
+		}
+
+		before := announcementRevisionOf(announcement)
+		if err := applyAnnouncementInput(&announcement, in); err != nil {
+			return err
+		}
+		if err := recordRevision(tx, models.RevisionAnnouncement, id, before, announcementRevisionOf(announcement), editorID); err != nil {
+			return err
+		}
+
+		// Select("*") so cleared optional fields are written as NULL.
+		return tx.Select("*").Omit("created_at", "author_id").Updates(&announcement).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &announcement, nil
+}
+
+// Delete soft-deletes an announcement.
+// Returns gorm.ErrRecordNotFound if it does not exist.
+func (s *AnnouncementService) Delete(id uint) error {
+	result := s.db.Delete(&models.Announcement{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// applyAnnouncementInput parses and validates in, writing the result into a.
+// Returns ValidationErrors keyed by form field name.
+func applyAnnouncementInput(a *models.Announcement, in AnnouncementInput) error {
+	errs := ValidationErrors{}
+
+	title := strings.TrimSpace(in.Title)
+	switch {
+	case title == "":
+		errs["title"] = "Title is required."
+	case len(title) > 255:
+		errs["title"] = "Title must be 255 characters or fewer."
+	}
+
+	content := strings.TrimSpace(in.Content)
+	if content == "" {
+		errs["content"] = "Content is required."
+	}
+
+	if in.Audience != models.AudiencePublic && in.Audience != models.AudienceMembers {
+		errs["audience"] = "Choose who can see this announcement."
+	}
+	if in.Priority != models.PriorityNormal && in.Priority != models.PriorityHigh {
+		errs["priority"] = "Choose a valid priority."
+	}
+
+	visibleFrom, err := parseOptional(in.VisibleFrom, DateTimeInputLayout)
+	if err != nil {
+		errs["visible_from"] = "Enter a valid date and time."
+	}
+	visibleUntil, err := parseOptional(in.VisibleUntil, DateTimeInputLayout)
+	switch {
+	case err != nil:
+		errs["visible_until"] = "Enter a valid date and time."
+	case visibleUntil != nil && visibleFrom != nil && !visibleUntil.After(*visibleFrom):
+		errs["visible_until"] = "Expiry must be after the publish date."
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	a.Title = title
+	a.Content = content
+	a.Audience = in.Audience
+	a.Priority = in.Priority
+	a.VisibleFrom = visibleFrom
+	a.VisibleUntil = visibleUntil
+	a.IsActive = in.IsActive
+	return nil
+}
+
+type bannersContextKey struct{}
+
+// WithBanners returns a copy of ctx carrying a loader for the banner
+// announcements, so layouts can render them without every handler fetching
+// them. The loader runs at most once per request.
+func WithBanners(ctx context.Context, load func() []models.Announcement) context.Context {
+	return context.WithValue(ctx, bannersContextKey{}, load)
+}
+
+// Banners returns the banner announcements for the request in ctx, or nil if
+// none were attached. Templ components can call this with their implicit ctx.
+func Banners(ctx context.Context) []models.Announcement {
+	load, _ := ctx.Value(bannersContextKey{}).(func() []models.Announcement)
+	if load == nil {
+		return nil
+	}
+	return load()
+}
+
+// SeesMembersContent reports whether the user may see members-only content.
+func SeesMembersContent(claims *SessionClaims) bool {
+	return claims != nil && claims.HasAnyRole(models.RoleMember, models.RoleStaff, models.RoleElder, models.RolePastor)
+}
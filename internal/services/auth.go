@@ -0,0 +1,380 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/sfdeloach/churchsite/internal/models"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	bcryptCost       = 12
+	maxFailedLogins  = 5
+	lockoutDuration  = 15 * time.Minute
+	minPasswordChars = 8
+	resetTokenTTL    = time.Hour
+)
+
+var (
+	ErrEmailTaken         = errors.New("an account with that email already exists")
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrAccountLocked      = errors.New("account is temporarily locked")
+	ErrEmailNotVerified   = errors.New("email address has not been verified")
+	ErrInvalidToken       = errors.New("invalid or expired token")
+	ErrWeakPassword       = errors.New("password does not meet complexity requirements")
+)
+
+// PasswordPolicy describes the password rules in user-facing terms.
+const PasswordPolicy = "Password must be at least 8 characters and include an uppercase letter, a lowercase letter, a number, and a special character."
+
+// dummyHash is compared against when a login email is unknown so that the
+// response time does not reveal whether an account exists.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not-a-real-password"), bcryptCost)
+
+// RegisterInput holds the fields submitted on the registration form.
+type RegisterInput struct {
+	Email     string
+	Password  string
+	FirstName string
+	LastName  string
+	Phone     string
+}
+
+// ValidationErrors maps form field names to human-readable messages.
+type ValidationErrors map[string]string
+
+func (v ValidationErrors) Error() string {
+	return fmt.Sprintf("%d field(s) failed validation", len(v))
+}
+
+// AuthService handles account registration, verification, and credential checks.
+type AuthService struct {
+	db *gorm.DB
+}
+
+// NewAuthService creates a new AuthService.
+func NewAuthService(db *gorm.DB) *AuthService {
+	return &AuthService{db: db}
+}
+
+// Register creates an unverified user and returns it along with the plain
+// verification token to be emailed. Only the token's SHA-256 hash is stored.
+// Returns ValidationErrors for bad input and ErrEmailTaken for duplicate emails.
+func (s *AuthService) Register(in RegisterInput) (*models.User, string, error) {
+	in.Email = NormalizeEmail(in.Email)
+	in.FirstName = strings.TrimSpace(in.FirstName)
+	in.LastName = strings.TrimSpace(in.LastName)
+	in.Phone = strings.TrimSpace(in.Phone)
+
+	errs := ValidationErrors{}
+	if _, err := mail.ParseAddress(in.Email); err != nil || in.Email == "" {
+		errs["email"] = "Enter a valid email address."
+	}
+	if in.FirstName == "" {
+		errs["first_name"] = "First name is required."
+	}
+	if in.LastName == "" {
+		errs["last_name"] = "Last name is required."
+	}
+	if err := ValidatePassword(in.Password); err != nil {
+		errs["password"] = PasswordPolicy
+	}
+	if len(errs) > 0 {
+		return nil, "", errs
+	}
+
+	var count int64
+	if err := s.db.Unscoped().Model(&models.User{}).Where("email = ?", in.Email).Count(&count).Error; err != nil {
+		return nil, "", err
+	}
+	if count > 0 {
+		return nil, "", ErrEmailTaken
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(in.Password), bcryptCost)
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, err := GenerateToken()
+	if err != nil {
+		return nil, "", err
+	}
+	tokenHash := HashToken(token)
+
+	user := models.User{
+		Email:             in.Email,
+		PasswordHash:      string(hash),
+		FirstName:         in.FirstName,
+		LastName:          in.LastName,
+		Phone:             in.Phone,
+		VerificationToken: &tokenHash,
+	}
+	if err := s.db.Create(&user).Error; err != nil {
+		return nil, "", err
+	}
+
+	return &user, token, nil
+}
+
+// VerifyEmail marks the user owning the given plain token as verified and
+// clears the stored hash so the link cannot be reused.
+// Returns ErrInvalidToken if no user matches.
+func (s *AuthService) VerifyEmail(token string) (*models.User, error) {
+	if token == "" {
+		return nil, ErrInvalidToken
+	}
+
+	var user models.User
+	err := s.db.Where("verification_token = ?", HashToken(token)).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Model(&user).Updates(map[string]any{
+		"is_verified":        true,
+		"verification_token": nil,
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// Authenticate checks an email/password pair. After maxFailedLogins
+// consecutive failures the account is locked for lockoutDuration.
+// Returns ErrInvalidCredentials, ErrAccountLocked, or ErrEmailNotVerified on failure.
+func (s *AuthService) Authenticate(email, password string) (*models.User, error) {
+	var user models.User
+	err := s.db.Where("email = ?", NormalizeEmail(email)).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if user.LockedUntil != nil && user.LockedUntil.After(now) {
+		return nil, ErrAccountLocked
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		if err := s.recordFailedLogin(&user, now); err != nil {
+			return nil, err
+		}
+		if user.LockedUntil != nil && user.LockedUntil.After(now) {
+			return nil, ErrAccountLocked
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	if !user.IsVerified {
+		return nil, ErrEmailNotVerified
+	}
+
+	err = s.db.Model(&user).Updates(map[string]any{
+		"failed_login_count": 0,
+		"locked_until":       nil,
+		"last_login":         now,
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// recordFailedLogin increments the failure counter and locks the account
+// once it reaches maxFailedLogins. The counter resets when the lock is applied.
+// Both happen in one UPDATE, which Postgres evaluates against the row as it
+// stands, so concurrent failures cannot lose a count; user gets the values
+// the update left.
+func (s *AuthService) recordFailedLogin(user *models.User, now time.Time) error {
+	var result struct {
+		FailedLoginCount int
+		LockedUntil      *time.Time
+	}
+
+	err := s.db.Raw(`
+		UPDATE users SET
+			failed_login_count = CASE WHEN failed_login_count + 1 >= ? THEN 0 ELSE failed_login_count + 1 END,
+			locked_until = CASE WHEN failed_login_count + 1 >= ? THEN ? ELSE locked_until END,
+			updated_at = ?
+		WHERE id = ?
+		RETURNING failed_login_count, locked_until`,
+		maxFailedLogins, maxFailedLogins, now.Add(lockoutDuration), now, user.ID,
+	).Scan(&result).Error
+	if err != nil {
+		return err
+	}
+
+	user.FailedLoginCount = result.FailedLoginCount
+	user.LockedUntil = result.LockedUntil
+	return nil
+}
+
+// RequestPasswordReset stores a hashed reset token valid for one hour and
+// returns the user and plain token to be emailed. If no account matches the
+// email, it returns a nil user and no error so callers cannot reveal whether
+// an address is registered.
+func (s *AuthService) RequestPasswordReset(email string) (*models.User, string, error) {
+	var user models.User
+	err := s.db.Where("email = ?", NormalizeEmail(email)).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, err := GenerateToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = s.db.Model(&user).Updates(map[string]any{
+		"reset_token":         HashToken(token),
+		"reset_token_expires": time.Now().Add(resetTokenTTL),
+	}).Error
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &user, token, nil
+}
+
+// CheckResetToken returns the user owning an unexpired reset token.
+// Returns ErrInvalidToken if the token is unknown or expired.
+func (s *AuthService) CheckResetToken(token string) (*models.User, error) {
+	if token == "" {
+		return nil, ErrInvalidToken
+	}
+
+	var user models.User
+	err := s.db.
+		Where("reset_token = ? AND reset_token_expires > ?", HashToken(token), time.Now()).
+		First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// ResetPassword sets a new password for the owner of a valid reset token and
+// clears the token. Any lockout is lifted, and since the user proved control
+// of their inbox, the address is marked verified.
+// Returns ErrInvalidToken or ErrWeakPassword on failure.
+func (s *AuthService) ResetPassword(token, password string) (*models.User, error) {
+	user, err := s.CheckResetToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidatePassword(password); err != nil {
+		return nil, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Model(user).Updates(map[string]any{
+		"password_hash":       string(hash),
+		"reset_token":         nil,
+		"reset_token_expires": nil,
+		"failed_login_count":  0,
+		"locked_until":        nil,
+		"is_verified":         true,
+		"verification_token":  nil,
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// GetByID returns a single user by ID.
+// Returns gorm.ErrRecordNotFound if no user with that ID exists.
+func (s *AuthService) GetByID(id uint) (*models.User, error) {
+	var user models.User
+	if err := s.db.First(&user, id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// RoleNames returns the names of all roles assigned to a user, sorted alphabetically.
+func (s *AuthService) RoleNames(userID uint) ([]string, error) {
+	var names []string
+
+	err := s.db.
+		Table("roles").
+		Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ?", userID).
+		Order("roles.name ASC").
+		Pluck("roles.name", &names).Error
+
+	return names, err
+}
+
+// ValidatePassword enforces PasswordPolicy. Returns ErrWeakPassword on failure.
+func ValidatePassword(password string) error {
+	var hasUpper, hasLower, hasDigit, hasSpecial bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSpecial = true
+		}
+	}
+
+	if len([]rune(password)) < minPasswordChars || !hasUpper || !hasLower || !hasDigit || !hasSpecial {
+		return ErrWeakPassword
+	}
+	return nil
+}
+
+// NormalizeEmail trims whitespace and lowercases an email address.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// GenerateToken returns a random 32-byte token encoded as hex.
+func GenerateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// HashToken returns the hex-encoded SHA-256 hash of a plain token.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
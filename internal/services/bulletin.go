@@ -0,0 +1,326 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sfdeloach/churchsite/internal/models"
+	"github.com/sfdeloach/churchsite/internal/storage"
+	"gorm.io/gorm"
+)
+
+// BulletinRetentionDays is how long bulletins stay published before
+// cleanup-bulletins removes them.
+const BulletinRetentionDays = 14
+
+// pdfMagic is the signature every PDF file starts with.
+var pdfMagic = []byte("%PDF-")
+
+// BulletinUpload holds a submitted bulletin file and its form fields.
+// BulletinDate uses DateInputLayout.
+type BulletinUpload struct {
+	BulletinDate string
+	ServiceType  string
+	MembersOnly  bool
+	File         io.Reader
+	Size         int64
+}
+
+// BulletinDay groups the bulletins for one Lord's Day.
+type BulletinDay struct {
+	Date    time.Time
+	Morning *models.Bulletin
+	Evening *models.Bulletin
+}
+
+// BulletinService handles bulletin uploads, listing, and retention. Files are
+// stored under bulletins/{morning|evening}/{date}.pdf.
+type BulletinService struct {
+	db            *gorm.DB
+	store         storage.Storage
+	maxUploadSize int64
+}
+
+// NewBulletinService creates a new BulletinService storing files in store
+// and rejecting files larger than maxUploadSize bytes.
+func NewBulletinService(db *gorm.DB, store storage.Storage, maxUploadSize int64) *BulletinService {
+	return &BulletinService{db: db, store: store, maxUploadSize: maxUploadSize}
+}
+
+// MaxUploadSize returns the largest accepted file size in bytes.
+func (s *BulletinService) MaxUploadSize() int64 {
+	return s.maxUploadSize
+}
+
+// GetRecent returns the bulletins still within the retention window, grouped
+// by Lord's Day, most recent first.
+func (s *BulletinService) GetRecent(now time.Time) ([]BulletinDay, error) {
+	var bulletins []models.Bulletin
+
+	err := s.db.
+		Where("bulletin_date >= ?", bulletinCutoff(now, BulletinRetentionDays)).
+		Order("bulletin_date DESC").
+		Find(&bulletins).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return groupBulletins(bulletins), nil
+}
+
+// GetCurrent returns the bulletins for the most recent Lord's Day on or
+// before the coming one, so a bulletin uploaded during the week is current
+// ahead of Sunday. Returns gorm.ErrRecordNotFound if none are published.
+func (s *BulletinService) GetCurrent(now time.Time) (*BulletinDay, error) {
+	today := StartOfDay(now)
+	coming := today.AddDate(0, 0, (7-int(today.Weekday()))%7)
+
+	var latest models.Bulletin
+	err := s.db.
+		Where("bulletin_date >= ? AND bulletin_date <= ?", bulletinCutoff(now, BulletinRetentionDays), coming.Format(DateInputLayout)).
+		Order("bulletin_date DESC").
+		First(&latest).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var bulletins []models.Bulletin
+	err = s.db.
+		Where("bulletin_date = ?", latest.BulletinDate.Format(DateInputLayout)).
+		Find(&bulletins).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &groupBulletins(bulletins)[0], nil
+}
+
+// ListAll returns every bulletin for staff, most recent first.
+func (s *BulletinService) ListAll() ([]models.Bulletin, error) {
+	var bulletins []models.Bulletin
+
+	err := s.db.
+		Order("bulletin_date DESC, service_type DESC").
+		Find(&bulletins).Error
+
+	return bulletins, err
+}
+
+// GetByID returns a published bulletin.
+// Returns gorm.ErrRecordNotFound if it does not exist.
+func (s *BulletinService) GetByID(id uint) (*models.Bulletin, error) {
+	var bulletin models.Bulletin
+
+	if err := s.db.First(&bulletin, id).Error; err != nil {
+		return nil, err
+	}
+
+	return &bulletin, nil
+}
+
+// OwnedFile finds the published bulletin stored at name, relative to the
+// bulletins directory. Anyone may download it unless it is members-only. A
+// bulletin replaced for the same service keeps its file name, so it is only
+// cached briefly.
+// Returns gorm.ErrRecordNotFound if no published bulletin is stored there.
+func (s *BulletinService) OwnedFile(name string) (*StoredFile, error) {
+	var bulletin models.Bulletin
+
+	err := s.db.
+		Where("file_path = ?", "bulletins/"+name).
+		First(&bulletin).Error
+	if err != nil {
+		return nil, err
+	}
+
+	file := &StoredFile{
+		Name:         bulletin.FilePath,
+		Filename:     bulletinFilename(bulletin),
+		CacheControl: "public, max-age=300",
+	}
+	if bulletin.MembersOnly {
+		file.CacheControl = "private, max-age=300"
+		file.CanView = SeesMembersContent
+	}
+	return file, nil
+}
+
+// Upload validates and stores a bulletin PDF, replacing any bulletin already
+// published for the same date and service.
+// Returns ValidationErrors for bad input.
+func (s *BulletinService) Upload(in BulletinUpload, uploadedBy uint) (*models.Bulletin, error) {
+	errs := ValidationErrors{}
+
+	date, err := time.Parse(DateInputLayout, in.BulletinDate)
+	switch {
+	case in.BulletinDate == "":
+		errs["bulletin_date"] = "Date is required."
+	case err != nil:
+		errs["bulletin_date"] = "Enter a valid date."
+	case date.Weekday() != time.Sunday:
+		errs["bulletin_date"] = "Bulletins are published for the Lord's Day. Choose a Sunday."
+	}
+	if !slices.Contains(models.BulletinServiceTypes, in.ServiceType) {
+		errs["service_type"] = "Choose the morning or evening service."
+	}
+
+	var head []byte
+	switch {
+	case in.File == nil || in.Size == 0:
+		errs["file"] = "Choose a PDF file to upload."
+	case in.Size > s.maxUploadSize:
+		errs["file"] = fmt.Sprintf("The file must be %s or smaller.", FormatBytes(s.maxUploadSize))
+	default:
+		head = make([]byte, len(pdfMagic))
+		if _, err := io.ReadFull(in.File, head); err != nil || !bytes.Equal(head, pdfMagic) {
+			errs["file"] = "The file must be a PDF."
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	relPath := fmt.Sprintf("bulletins/%s/%s.pdf", in.ServiceType, date.Format(DateInputLayout))
+	size, err := s.store.Put(relPath, io.MultiReader(bytes.NewReader(head), in.File), "application/pdf")
+	if err != nil {
+		return nil, err
+	}
+
+	var bulletin models.Bulletin
+	err = s.db.
+		Where("bulletin_date = ? AND service_type = ?", date.Format(DateInputLayout), in.ServiceType).
+		First(&bulletin).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	bulletin.BulletinDate = date
+	bulletin.ServiceType = in.ServiceType
+	bulletin.FilePath = relPath
+	bulletin.FileSize = size
+	bulletin.MembersOnly = in.MembersOnly
+	bulletin.UploadedBy = &uploadedBy
+	bulletin.UploadedAt = time.Now()
+	if err := s.db.Save(&bulletin).Error; err != nil {
+		return nil, err
+	}
+
+	return &bulletin, nil
+}
+
+// Delete unpublishes a bulletin. Its file is removed by the next cleanup
+// once the bulletin ages out.
+// Returns gorm.ErrRecordNotFound if it does not exist.
+func (s *BulletinService) Delete(id uint) error {
+	result := s.db.Delete(&models.Bulletin{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Cleanup permanently removes bulletins dated more than days before now,
+// including deleted ones, along with their files. Expired files left without
+// a bulletin, such as by an upload that failed partway, are removed too.
+// Rows whose file cannot be removed are kept so the next run can retry.
+// Returns the number of bulletins removed.
+func (s *BulletinService) Cleanup(now time.Time, days int) (int, error) {
+	cutoff := bulletinCutoff(now, days)
+
+	files, err := s.store.List("bulletins/")
+	if err != nil {
+		return 0, err
+	}
+	var fileErrs []error
+	failed := make(map[string]bool)
+	for _, f := range files {
+		date := strings.TrimSuffix(path.Base(f.Key), ".pdf")
+		if _, err := time.Parse(DateInputLayout, date); err != nil || date >= cutoff {
+			continue
+		}
+		if err := s.store.Delete(f.Key); err != nil {
+			fileErrs = append(fileErrs, err)
+			failed[f.Key] = true
+		}
+	}
+
+	var removed int
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var expired []models.Bulletin
+		err := tx.Unscoped().
+			Where("bulletin_date < ?", cutoff).
+			Find(&expired).Error
+		if err != nil {
+			return err
+		}
+
+		var ids []uint
+		for _, b := range expired {
+			if !failed[b.FilePath] {
+				ids = append(ids, b.ID)
+			}
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		if err := tx.Unscoped().Delete(&models.Bulletin{}, ids).Error; err != nil {
+			return err
+		}
+		removed = len(ids)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return removed, errors.Join(fileErrs...)
+}
+
+// bulletinCutoff returns the earliest bulletin date still retained, formatted
+// for comparison with the DATE column.
+func bulletinCutoff(now time.Time, days int) string {
+	return StartOfDay(now).AddDate(0, 0, -days).Format(DateInputLayout)
+}
+
+// groupBulletins groups bulletins sorted by date into Lord's Days.
+func groupBulletins(bulletins []models.Bulletin) []BulletinDay {
+	var days []BulletinDay
+	for i := range bulletins {
+		b := &bulletins[i]
+		if len(days) == 0 || !days[len(days)-1].Date.Equal(b.BulletinDate) {
+			days = append(days, BulletinDay{Date: b.BulletinDate})
+		}
+		day := &days[len(days)-1]
+		switch b.ServiceType {
+		case models.ServiceMorning:
+			day.Morning = b
+		case models.ServiceEvening:
+			day.Evening = b
+		}
+	}
+	return days
+}
+
+// bulletinFilename names a bulletin download, e.g. bulletin-2025-01-05-morning.pdf.
+func bulletinFilename(b models.Bulletin) string {
+	return fmt.Sprintf("bulletin-%s-%s.pdf", b.BulletinDate.Format(DateInputLayout), b.ServiceType)
+}
+
+// FormatBytes renders a byte count in whole megabytes or kilobytes.
+func FormatBytes(n int64) string {
+	if n >= 1<<20 {
+		return fmt.Sprintf("%d MB", n>>20)
+	}
+	return fmt.Sprintf("%d KB", max(n>>10, 1))
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"time"
+
+	"github.com/sfdeloach/churchsite/internal/models"
+)
+
+// CalendarDay is a single day cell in a calendar view.
+type CalendarDay struct {
+	Date    time.Time
+	InMonth bool
+	IsToday bool
+	Events  []models.Event
+}
+
+// StartOfDay returns midnight at the start of t's day in t's location.
+func StartOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// StartOfMonth returns midnight on the first day of t's month.
+func StartOfMonth(t time.Time) time.Time {
+	y, m, _ := t.Date()
+	return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+}
+
+// StartOfWeek returns midnight on the Sunday on or before t.
+func StartOfWeek(t time.Time) time.Time {
+	day := StartOfDay(t)
+	return day.AddDate(0, 0, -int(day.Weekday()))
+}
+
+// MonthGridRange returns the [start, end) range covered by a month grid: from
+// the Sunday on or before the first of the month through the Saturday on or
+// after the last day.
+func MonthGridRange(month time.Time) (time.Time, time.Time) {
+	first := StartOfMonth(month)
+	start := StartOfWeek(first)
+	end := StartOfWeek(first.AddDate(0, 1, -1)).AddDate(0, 0, 7)
+	return start, end
+}
+
+// BuildMonthGrid lays out events in weeks of seven days for month. Events
+// must fall within MonthGridRange(month).
+func BuildMonthGrid(month time.Time, events []models.Event, now time.Time) [][]CalendarDay {
+	start, end := MonthGridRange(month)
+	days := buildDays(start, end, events, now)
+	for i := range days {
+		days[i].InMonth = days[i].Date.Month() == month.Month()
+	}
+
+	weeks := make([][]CalendarDay, 0, len(days)/7)
+	for i := 0; i < len(days); i += 7 {
+		weeks = append(weeks, days[i:i+7])
+	}
+	return weeks
+}
+
+// BuildWeek returns the seven days starting at weekStart with their events.
+func BuildWeek(weekStart time.Time, events []models.Event, now time.Time) []CalendarDay {
+	days := buildDays(weekStart, weekStart.AddDate(0, 0, 7), events, now)
+	for i := range days {
+		days[i].InMonth = true
+	}
+	return days
+}
+
+// BuildAgenda groups events by day, omitting days without events.
+func BuildAgenda(events []models.Event, now time.Time) []CalendarDay {
+	var days []CalendarDay
+	for _, e := range events {
+		day := StartOfDay(e.EventDate)
+		if n := len(days); n > 0 && days[n-1].Date.Equal(day) {
+			days[n-1].Events = append(days[n-1].Events, e)
+			continue
+		}
+		days = append(days, CalendarDay{
+			Date:    day,
+			InMonth: true,
+			IsToday: day.Equal(StartOfDay(now)),
+			Events:  []models.Event{e},
+		})
+	}
+	return days
+}
+
+// buildDays returns one CalendarDay per day in [start, end), assigning each
+// event to the day it starts on.
+func buildDays(start, end time.Time, events []models.Event, now time.Time) []CalendarDay {
+	today := StartOfDay(now)
+
+	var days []CalendarDay
+	index := make(map[time.Time]int)
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		index[d] = len(days)
+		days = append(days, CalendarDay{Date: d, IsToday: d.Equal(today)})
+	}
+
+	for _, e := range events {
+		if i, ok := index[StartOfDay(e.EventDate.In(start.Location()))]; ok {
+			days[i].Events = append(days[i].Events, e)
+		}
+	}
+	return days
+}
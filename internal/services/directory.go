@@ -0,0 +1,617 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sfdeloach/churchsite/internal/models"
+	"github.com/sfdeloach/churchsite/internal/storage"
+	"github.com/sfdeloach/churchsite/internal/utils/imaging"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MemberPhotoURLPrefix is the URL path members' directory photos are served
+// under.
+const MemberPhotoURLPrefix = FileURLPrefix + "directory/"
+
+var zipCodePattern = regexp.MustCompile(`^\d{5}(-\d{4})?$`)
+
+// likeEscaper escapes LIKE wildcards in user-supplied search terms.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// AddressInput holds the mailing address fields shared by the profile and
+// household forms.
+type AddressInput struct {
+	AddressLine1 string
+	AddressLine2 string
+	City         string
+	State        string
+	ZipCode      string
+}
+
+// normalize trims the fields and upper-cases the state.
+func (a *AddressInput) normalize() {
+	a.AddressLine1 = strings.TrimSpace(a.AddressLine1)
+	a.AddressLine2 = strings.TrimSpace(a.AddressLine2)
+	a.City = strings.TrimSpace(a.City)
+	a.State = strings.ToUpper(strings.TrimSpace(a.State))
+	a.ZipCode = strings.TrimSpace(a.ZipCode)
+}
+
+// validate records problems with a normalized address in errs, keyed by form
+// field name.
+func (a AddressInput) validate(errs ValidationErrors) {
+	if len(a.AddressLine1) > 255 {
+		errs["address_line1"] = "Address must be 255 characters or fewer."
+	}
+	if len(a.AddressLine2) > 255 {
+		errs["address_line2"] = "Address must be 255 characters or fewer."
+	}
+	if len(a.City) > 100 {
+		errs["city"] = "City must be 100 characters or fewer."
+	}
+	if a.State != "" && (len(a.State) != 2 || strings.Trim(a.State, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "") {
+		errs["state"] = "Use the two-letter state abbreviation."
+	}
+	if a.ZipCode != "" && !zipCodePattern.MatchString(a.ZipCode) {
+		errs["zip_code"] = "Enter a five-digit ZIP code."
+	}
+}
+
+// ProfileInput holds the fields a member submits on their profile page.
+// Members of a household edit the household's shared address and phone
+// instead of their own.
+type ProfileInput struct {
+	FirstName string
+	LastName  string
+	Phone     string
+	AddressInput
+	HouseholdPhone   string
+	EmergencyContact string
+	EmergencyPhone   string
+	DirectoryOptIn   bool
+	ShowEmail        bool
+	ShowPhone        bool
+	ShowAddress      bool
+}
+
+// DirectoryAddress is a shared mailing address.
+type DirectoryAddress struct {
+	Line1   string `json:"line1"`
+	Line2   string `json:"line2,omitempty"`
+	City    string `json:"city"`
+	State   string `json:"state"`
+	ZipCode string `json:"zip_code"`
+}
+
+// DirectoryPerson is one person named in a directory entry. Contact fields
+// the person has not chosen to share are left empty.
+type DirectoryPerson struct {
+	UserID    uint   `json:"user_id,omitempty"` // zero for children without logins
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	IsChild   bool   `json:"is_child,omitempty"`
+	Email     string `json:"email,omitempty"`
+	Phone     string `json:"phone,omitempty"`
+}
+
+// DirectoryEntry is one listing as other members see it: a single member, or
+// a household listed once with its adults and children. The shared phone and
+// address are included only when someone listed has chosen to share them.
+type DirectoryEntry struct {
+	HouseholdID uint              `json:"household_id,omitempty"`
+	Name        string            `json:"name"`
+	SortName    string            `json:"-"` // family or last name the entry is filed under
+	PhotoURL    string            `json:"photo_url,omitempty"`
+	Phone       string            `json:"phone,omitempty"`
+	Address     *DirectoryAddress `json:"address,omitempty"`
+	People      []DirectoryPerson `json:"people"`
+}
+
+// DirectoryService handles member profiles and the opt-in member directory.
+// Uploaded profile photos are stored under directory/ as WebP thumbnails.
+type DirectoryService struct {
+	db            *gorm.DB
+	store         storage.Storage
+	maxUploadSize int64
+}
+
+// NewDirectoryService creates a new DirectoryService storing profile photos
+// in store and rejecting files larger than maxUploadSize bytes.
+func NewDirectoryService(db *gorm.DB, store storage.Storage, maxUploadSize int64) *DirectoryService {
+	return &DirectoryService{db: db, store: store, maxUploadSize: maxUploadSize}
+}
+
+// MaxUploadSize returns the largest accepted profile photo size in bytes.
+func (s *DirectoryService) MaxUploadSize() int64 {
+	return s.maxUploadSize
+}
+
+// listedInDirectory restricts a member_profiles query to verified,
+// non-deleted users who have opted in to the directory.
+func listedInDirectory(db *gorm.DB) *gorm.DB {
+	return db.
+		Joins("JOIN users ON users.id = member_profiles.user_id AND users.deleted_at IS NULL").
+		Where("member_profiles.directory_opt_in = ? AND users.is_verified = ?", true, true)
+}
+
+// Search returns directory entries for listed members whose names, or whose
+// household's name, match every word of query and, when letter is set, whose
+// last name starts with it. Members of a household are listed once, together.
+// Results are ordered by last name, then first name.
+func (s *DirectoryService) Search(query, letter string) ([]DirectoryEntry, error) {
+	q := s.db.Model(&models.MemberProfile{}).Scopes(listedInDirectory)
+
+	for _, word := range strings.Fields(query) {
+		pattern := "%" + likeEscaper.Replace(strings.ToLower(word)) + "%"
+		q = q.Where(`(LOWER(users.first_name) LIKE ? OR LOWER(users.last_name) LIKE ? OR EXISTS (
+			SELECT 1 FROM household_members hm JOIN households h ON h.id = hm.household_id
+			WHERE hm.user_id = users.id AND LOWER(h.name) LIKE ?))`, pattern, pattern, pattern)
+	}
+	if letter != "" {
+		q = q.Where("LOWER(users.last_name) LIKE ?", likeEscaper.Replace(strings.ToLower(letter))+"%")
+	}
+
+	var profiles []models.MemberProfile
+	err := q.
+		Preload("User").
+		Order("LOWER(users.last_name), LOWER(users.first_name)").
+		Find(&profiles).Error
+	if err != nil {
+		return nil, err
+	}
+
+	households, listed, err := s.householdsFor(profiles)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]DirectoryEntry, 0, len(profiles))
+	seen := make(map[uint]bool)
+	for _, p := range profiles {
+		if p.User == nil {
+			continue
+		}
+		household, ok := households[p.UserID]
+		if !ok {
+			entries = append(entries, memberEntry(*p.User, p))
+			continue
+		}
+		if !seen[household.ID] {
+			seen[household.ID] = true
+			entries = append(entries, householdEntry(*household, listed))
+		}
+	}
+	return entries, nil
+}
+
+// householdsFor loads the households of the given members, keyed by user ID,
+// along with the listed profiles of everyone in those households keyed the
+// same way, so a household entry can include adults the search did not match.
+func (s *DirectoryService) householdsFor(profiles []models.MemberProfile) (map[uint]*models.Household, map[uint]models.MemberProfile, error) {
+	userIDs := make([]uint, 0, len(profiles))
+	for _, p := range profiles {
+		userIDs = append(userIDs, p.UserID)
+	}
+
+	byUser := make(map[uint]*models.Household)
+	listed := make(map[uint]models.MemberProfile)
+	if len(userIDs) == 0 {
+		return byUser, listed, nil
+	}
+
+	var households []models.Household
+	err := s.db.Scopes(withMembers).
+		Where("id IN (SELECT household_id FROM household_members WHERE user_id IN ?)", userIDs).
+		Find(&households).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var adultIDs []uint
+	for i := range households {
+		for _, m := range households[i].Members {
+			if m.UserID != nil {
+				byUser[*m.UserID] = &households[i]
+				adultIDs = append(adultIDs, *m.UserID)
+			}
+		}
+	}
+	if len(adultIDs) == 0 {
+		return byUser, listed, nil
+	}
+
+	var adults []models.MemberProfile
+	err = s.db.Model(&models.MemberProfile{}).
+		Scopes(listedInDirectory).
+		Where("member_profiles.user_id IN ?", adultIDs).
+		Find(&adults).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, p := range adults {
+		listed[p.UserID] = p
+	}
+
+	return byUser, listed, nil
+}
+
+// Letters returns the distinct initials of listed members' last names, in
+// alphabetical order, for letter navigation.
+func (s *DirectoryService) Letters() ([]string, error) {
+	var letters []string
+
+	err := s.db.Model(&models.MemberProfile{}).
+		Scopes(listedInDirectory).
+		Select("DISTINCT UPPER(LEFT(users.last_name, 1)) AS letter").
+		Order("letter").
+		Pluck("letter", &letters).Error
+
+	return letters, err
+}
+
+// GetProfile returns a member's profile with their user loaded. Members who
+// have never saved a profile get an empty one with the directory defaults.
+func (s *DirectoryService) GetProfile(userID uint) (*models.MemberProfile, error) {
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	profile := models.MemberProfile{UserID: userID}
+	err := s.db.First(&profile, "user_id = ?", userID).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	profile.User = &user
+
+	return &profile, nil
+}
+
+// ProfileInputFrom returns the form values for a member's profile. Members of
+// a household see the household's shared address and phone.
+func ProfileInputFrom(p models.MemberProfile, household *models.Household) ProfileInput {
+	in := ProfileInput{
+		AddressInput: AddressInput{
+			AddressLine1: p.AddressLine1,
+			AddressLine2: p.AddressLine2,
+			City:         p.City,
+			State:        p.State,
+			ZipCode:      p.ZipCode,
+		},
+		EmergencyContact: p.EmergencyContact,
+		EmergencyPhone:   p.EmergencyPhone,
+		DirectoryOptIn:   p.DirectoryOptIn,
+		ShowEmail:        p.ShowEmail,
+		ShowPhone:        p.ShowPhone,
+		ShowAddress:      p.ShowAddress,
+	}
+	if p.User != nil {
+		in.FirstName = p.User.FirstName
+		in.LastName = p.User.LastName
+		in.Phone = p.User.Phone
+	}
+	if household != nil {
+		in.AddressInput = HouseholdInputFrom(*household).AddressInput
+		in.HouseholdPhone = household.Phone
+	}
+	return in
+}
+
+// SaveProfile validates in and saves the member's name, phone, profile, and
+// privacy settings. For members of a household, the address and household
+// phone are saved to the household, so the change applies to everyone in it.
+// Returns ValidationErrors for bad input.
+func (s *DirectoryService) SaveProfile(userID uint, in ProfileInput) (*models.MemberProfile, error) {
+	in.FirstName = strings.TrimSpace(in.FirstName)
+	in.LastName = strings.TrimSpace(in.LastName)
+	in.Phone = strings.TrimSpace(in.Phone)
+	in.AddressInput.normalize()
+	in.HouseholdPhone = strings.TrimSpace(in.HouseholdPhone)
+	in.EmergencyContact = strings.TrimSpace(in.EmergencyContact)
+	in.EmergencyPhone = strings.TrimSpace(in.EmergencyPhone)
+
+	errs := ValidationErrors{}
+	if in.FirstName == "" {
+		errs["first_name"] = "First name is required."
+	} else if len(in.FirstName) > 100 {
+		errs["first_name"] = "First name must be 100 characters or fewer."
+	}
+	if in.LastName == "" {
+		errs["last_name"] = "Last name is required."
+	} else if len(in.LastName) > 100 {
+		errs["last_name"] = "Last name must be 100 characters or fewer."
+	}
+	if len(in.Phone) > 20 {
+		errs["phone"] = "Phone must be 20 characters or fewer."
+	}
+	in.AddressInput.validate(errs)
+	if len(in.HouseholdPhone) > 20 {
+		errs["household_phone"] = "Phone must be 20 characters or fewer."
+	}
+	if len(in.EmergencyContact) > 255 {
+		errs["emergency_contact"] = "Emergency contact must be 255 characters or fewer."
+	}
+	if len(in.EmergencyPhone) > 20 {
+		errs["emergency_phone"] = "Phone must be 20 characters or fewer."
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	profile := models.MemberProfile{
+		UserID:           userID,
+		AddressLine1:     in.AddressLine1,
+		AddressLine2:     in.AddressLine2,
+		City:             in.City,
+		State:            in.State,
+		ZipCode:          in.ZipCode,
+		EmergencyContact: in.EmergencyContact,
+		EmergencyPhone:   in.EmergencyPhone,
+		DirectoryOptIn:   in.DirectoryOptIn,
+		ShowEmail:        in.ShowEmail,
+		ShowPhone:        in.ShowPhone,
+		ShowAddress:      in.ShowAddress,
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).
+			Where("id = ?", userID).
+			Updates(map[string]any{"first_name": in.FirstName, "last_name": in.LastName, "phone": in.Phone})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		// Photo and membership date are managed elsewhere and left untouched.
+		columns := []string{
+			"emergency_contact", "emergency_phone",
+			"directory_opt_in", "show_email", "show_phone", "show_address", "updated_at",
+		}
+
+		var householdIDs []uint
+		err := tx.Model(&models.HouseholdMember{}).Where("user_id = ?", userID).Pluck("household_id", &householdIDs).Error
+		if err != nil {
+			return err
+		}
+		if len(householdIDs) > 0 {
+			err := tx.Model(&models.Household{}).
+				Where("id = ?", householdIDs[0]).
+				Updates(map[string]any{
+					"address_line1": in.AddressLine1,
+					"address_line2": in.AddressLine2,
+					"city":          in.City,
+					"state":         in.State,
+					"zip_code":      in.ZipCode,
+					"phone":         in.HouseholdPhone,
+				}).Error
+			if err != nil {
+				return err
+			}
+			profile.AddressLine1, profile.AddressLine2, profile.City, profile.State, profile.ZipCode = "", "", "", "", ""
+		} else {
+			columns = append(columns, "address_line1", "address_line2", "city", "state", "zip_code")
+		}
+
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns(columns),
+		}).Create(&profile).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetProfile(userID)
+}
+
+// SavePhoto replaces the member's profile photo with the uploaded file of the
+// given size. Only the square thumbnail is kept, which is all the directory
+// shows; it is stored under a new name so cached copies of the old photo are
+// never served in its place, and the old file is removed.
+// Returns ValidationErrors keyed "photo" for a missing or unacceptable file.
+func (s *DirectoryService) SavePhoto(userID uint, file io.Reader, size int64) (*models.MemberProfile, error) {
+	photo, msg := readPhoto(file, size, s.maxUploadSize)
+	if msg != "" {
+		return nil, ValidationErrors{"photo": msg}
+	}
+	if photo == nil {
+		return nil, ValidationErrors{"photo": "Choose a photo to upload."}
+	}
+
+	base := fmt.Sprintf("%d-%d", userID, time.Now().UnixNano())
+	set, err := (&imaging.Processed{Thumbnail: photo.Thumbnail}).Save(s.store, "directory", base)
+	if err != nil {
+		return nil, err
+	}
+	url := MemberPhotoURLPrefix + set.Thumbnail
+
+	old, err := s.setPhotoURL(userID, url)
+	if err != nil {
+		removeUploads(s.store, []string{url}, MemberPhotoURLPrefix)
+		return nil, err
+	}
+	removeUploads(s.store, []string{old}, MemberPhotoURLPrefix)
+
+	return s.GetProfile(userID)
+}
+
+// RemovePhoto removes the member's profile photo, if any.
+func (s *DirectoryService) RemovePhoto(userID uint) error {
+	old, err := s.setPhotoURL(userID, "")
+	if err != nil {
+		return err
+	}
+	removeUploads(s.store, []string{old}, MemberPhotoURLPrefix)
+	return nil
+}
+
+// setPhotoURL points the member's profile at url, creating the profile if
+// they have never saved one, and returns the URL it replaced.
+func (s *DirectoryService) setPhotoURL(userID uint, url string) (string, error) {
+	var old string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Select("id").First(&user, userID).Error; err != nil {
+			return err
+		}
+
+		var profile models.MemberProfile
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&profile, "user_id = ?", userID).Error
+		switch {
+		case err == nil:
+			old = profile.PhotoURL
+			return tx.Model(&profile).Updates(map[string]any{"photo_url": url, "updated_at": time.Now()}).Error
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			// A concurrent first save may create the profile; keep its
+			// settings and just set the photo.
+			return tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "user_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"photo_url", "updated_at"}),
+			}).Create(&models.MemberProfile{UserID: userID, PhotoURL: url}).Error
+		default:
+			return err
+		}
+	})
+	return old, err
+}
+
+// OpenPhoto opens an uploaded profile photo named in a URL under
+// MemberPhotoURLPrefix.
+// Returns an fs.ErrNotExist error if there is no such file.
+func (s *DirectoryService) OpenPhoto(name string) (io.ReadCloser, error) {
+	if name == "" || path.Base(name) != name || !filepath.IsLocal(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return s.store.Open("directory/" + name)
+}
+
+// OwnedFile finds the member whose current profile photo is the file name.
+// Profile photos are shown to the same people as the directory entry (see
+// canViewMemberPhoto) and are never replaced in place.
+// Returns gorm.ErrRecordNotFound if no member's photo is that file.
+func (s *DirectoryService) OwnedFile(name string) (*StoredFile, error) {
+	userID, ok := fileOwnerID(name)
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	var profile models.MemberProfile
+	if err := s.db.First(&profile, "user_id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	if profile.PhotoURL != MemberPhotoURLPrefix+name {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &StoredFile{
+		Name:         "directory/" + name,
+		CacheControl: "private, max-age=31536000, immutable",
+		CanView: func(claims *SessionClaims) bool {
+			return canViewMemberPhoto(claims, profile)
+		},
+	}, nil
+}
+
+// canViewMemberPhoto reports whether claims may see the profile's photo: its
+// owner and staff always may, other members only while the owner is listed
+// in the directory.
+func canViewMemberPhoto(claims *SessionClaims, profile models.MemberProfile) bool {
+	switch {
+	case claims == nil:
+		return false
+	case claims.UserID == profile.UserID, claims.HasRole(models.RoleStaff):
+		return true
+	default:
+		return profile.DirectoryOptIn && SeesMembersContent(claims)
+	}
+}
+
+// memberEntry applies a member's privacy settings to their details.
+func memberEntry(user models.User, p models.MemberProfile) DirectoryEntry {
+	entry := DirectoryEntry{
+		Name:     user.LastName + ", " + user.FirstName,
+		SortName: user.LastName,
+		PhotoURL: p.PhotoURL,
+		People:   []DirectoryPerson{directoryPerson(user, p)},
+	}
+	if p.ShowAddress && p.HasAddress() {
+		entry.Address = &DirectoryAddress{
+			Line1:   p.AddressLine1,
+			Line2:   p.AddressLine2,
+			City:    p.City,
+			State:   p.State,
+			ZipCode: p.ZipCode,
+		}
+	}
+	return entry
+}
+
+// householdEntry lists a household once. Adults appear only if they have
+// opted in themselves, and the shared phone and address only if one of them
+// has chosen to share theirs. Children are listed by name with their
+// household.
+func householdEntry(h models.Household, listed map[uint]models.MemberProfile) DirectoryEntry {
+	entry := DirectoryEntry{HouseholdID: h.ID, Name: h.DisplayName(), SortName: h.Name}
+
+	var showPhone, showAddress bool
+	for _, m := range h.Members {
+		if m.IsChild() {
+			first, last := m.Names()
+			entry.People = append(entry.People, DirectoryPerson{FirstName: first, LastName: last, IsChild: true})
+			continue
+		}
+		if m.UserID == nil || m.User == nil {
+			continue
+		}
+		p, ok := listed[*m.UserID]
+		if !ok {
+			continue
+		}
+		entry.People = append(entry.People, directoryPerson(*m.User, p))
+		if entry.PhotoURL == "" {
+			entry.PhotoURL = p.PhotoURL
+		}
+		showPhone = showPhone || p.ShowPhone
+		showAddress = showAddress || p.ShowAddress
+	}
+
+	if showPhone {
+		entry.Phone = h.Phone
+	}
+	if showAddress && h.HasAddress() {
+		entry.Address = &DirectoryAddress{
+			Line1:   h.AddressLine1,
+			Line2:   h.AddressLine2,
+			City:    h.City,
+			State:   h.State,
+			ZipCode: h.ZipCode,
+		}
+	}
+	return entry
+}
+
+// directoryPerson applies a member's email and phone settings.
+func directoryPerson(user models.User, p models.MemberProfile) DirectoryPerson {
+	person := DirectoryPerson{
+		UserID:    user.ID,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+	}
+	if p.ShowEmail {
+		person.Email = user.Email
+	}
+	if p.ShowPhone {
+		person.Phone = user.Phone
+	}
+	return person
+}
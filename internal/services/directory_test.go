@@ -0,0 +1,37 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/sfdeloach/churchsite/internal/models"
+)
+
+func TestCanViewMemberPhoto(t *testing.T) {
+	listed := models.MemberProfile{UserID: 7, DirectoryOptIn: true, PhotoURL: MemberPhotoURLPrefix + "7-1-thumb.webp"}
+	unlisted := models.MemberProfile{UserID: 7, DirectoryOptIn: false, PhotoURL: MemberPhotoURLPrefix + "7-1-thumb.webp"}
+	owner := &SessionClaims{UserID: 7, Roles: []string{models.RoleMember}}
+	member := &SessionClaims{UserID: 8, Roles: []string{models.RoleMember}}
+	staff := &SessionClaims{UserID: 9, Roles: []string{models.RoleStaff}}
+	visitor := &SessionClaims{UserID: 10}
+
+	tests := []struct {
+		name    string
+		claims  *SessionClaims
+		profile models.MemberProfile
+		want    bool
+	}{
+		{"anonymous, listed", nil, listed, false},
+		{"logged in without a role, listed", visitor, listed, false},
+		{"member, listed", member, listed, true},
+		{"member, opted out", member, unlisted, false},
+		{"owner, opted out", owner, unlisted, true},
+		{"staff, opted out", staff, unlisted, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canViewMemberPhoto(tt.claims, tt.profile); got != tt.want {
+				t.Errorf("canViewMemberPhoto() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
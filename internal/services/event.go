@@ -1,12 +1,32 @@
 package services
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/sfdeloach/churchsite/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+const (
+	// upcomingHorizon is how far ahead GetUpcoming looks for occurrences.
+	upcomingHorizon = 365 * 24 * time.Hour
+
+	// feedLookback is how far into the past calendar feeds include events, so
+	// subscribers keep recent history without the feed growing forever.
+	feedLookback = 90 * 24 * time.Hour
+)
+
+// ErrNotAnOccurrence is returned when an exception targets a date that the
+// event's recurrence rule does not generate.
+var ErrNotAnOccurrence = errors.New("date is not an occurrence of this event")
+
 // EventService handles event queries.
 type EventService struct {
 	db *gorm.DB
@@ -17,19 +37,694 @@ func NewEventService(db *gorm.DB) *EventService {
 	return &EventService{db: db}
 }
 
-// GetUpcoming returns upcoming public events ordered by date, limited to `limit` results.
-// Only returns events that are public, not soft-deleted, and within their visibility window.
+// publiclyVisible restricts a query to events that are public, not soft-deleted,
+// and within their visibility window at the given time.
+func publiclyVisible(now time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.
+			Where("is_public = ?", true).
+			Where("(visible_from IS NULL OR visible_from <= ?)", now).
+			Where("(visible_until IS NULL OR visible_until >= ?)", now)
+	}
+}
+
+// GetUpcoming returns the next occurrence of each upcoming public event ordered
+// by date, limited to `limit` results. Only returns events that are public, not
+// soft-deleted, and within their visibility window.
 func (s *EventService) GetUpcoming(limit int) ([]models.Event, error) {
-	var events []models.Event
 	now := time.Now()
 
+	occurrences, err := s.GetPublicInRange(now, now.Add(upcomingHorizon))
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]models.Event, 0, limit)
+	seen := make(map[uint]bool)
+	for _, o := range occurrences {
+		if len(events) == limit {
+			break
+		}
+		if seen[o.ID] {
+			continue
+		}
+		seen[o.ID] = true
+		events = append(events, o)
+	}
+
+	return events, nil
+}
+
+// GetPublicInRange returns occurrences of publicly visible events starting in
+// [start, end), ordered by date. Recurring events are expanded into one entry
+// per occurrence.
+func (s *EventService) GetPublicInRange(start, end time.Time) ([]models.Event, error) {
+	var events []models.Event
+
+	moved := s.db.Model(&models.EventException{}).
+		Select("event_id").
+		Where("new_event_date >= ? AND new_event_date < ?", start, end)
+
+	err := s.db.
+		Scopes(publiclyVisible(time.Now())).
+		Where(s.db.
+			Where("is_recurring = ? AND event_date >= ? AND event_date < ?", false, start, end).
+			Or("is_recurring = ? AND event_date < ? AND (recurrence_end IS NULL OR recurrence_end >= ?)", true, end, StartOfDay(start)).
+			Or("id IN (?)", moved)).
+		Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+
+	exceptions, err := s.GetExceptions(events)
+	if err != nil {
+		return nil, err
+	}
+
+	return ExpandOccurrences(events, exceptions, start, end), nil
+}
+
+// publicFeed restricts a query to publicly visible events that belong in a
+// calendar feed: one-off events within the lookback window and recurring
+// series that have not ended before it. A non-nil ministryID narrows the
+// feed to that ministry's events.
+func publicFeed(now time.Time, ministryID *uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		since := now.Add(-feedLookback)
+		db = db.
+			Scopes(publiclyVisible(now)).
+			Where("(event_date >= ? OR (is_recurring = ? AND (recurrence_end IS NULL OR recurrence_end >= ?)))", since, true, StartOfDay(since))
+		if ministryID != nil {
+			db = db.Where("ministry_id = ?", *ministryID)
+		}
+		return db
+	}
+}
+
+// GetPublicFeed returns the series and exceptions for a public calendar feed,
+// optionally limited to one ministry. Recurring events are not expanded.
+func (s *EventService) GetPublicFeed(ministryID *uint) ([]models.Event, []models.EventException, error) {
+	var events []models.Event
+
 	err := s.db.
-		Where("is_public = ? AND event_date >= ?", true, now).
-		Where("(visible_from IS NULL OR visible_from <= ?)", now).
-		Where("(visible_until IS NULL OR visible_until >= ?)", now).
+		Scopes(publicFeed(time.Now(), ministryID)).
 		Order("event_date ASC").
-		Limit(limit).
+		Find(&events).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	exceptions, err := s.GetExceptions(events)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return events, exceptions, nil
+}
+
+// PublicFeedVersion returns a short fingerprint of everything a feed shows,
+// computed from counts and last-modified times alone: its events, their
+// exceptions (cancelled or moved occurrences), and the ministries the feed
+// names. It lets feed handlers answer conditional requests without loading
+// any events.
+func (s *EventService) PublicFeedVersion(ministryID *uint) (string, error) {
+	now := time.Now()
+	feed := func() *gorm.DB {
+		return s.db.Model(&models.Event{}).Scopes(publicFeed(now, ministryID))
+	}
+
+	// stamp counts the rows of q and finds when the latest was changed.
+	// Counting catches rows that are deleted, which leave no time behind.
+	stamp := func(q *gorm.DB) (string, error) {
+		var st struct {
+			Count   int64
+			Updated time.Time
+		}
+		err := q.Select("COUNT(*) AS count, COALESCE(MAX(updated_at), 'epoch') AS updated").Scan(&st).Error
+		return fmt.Sprintf("%d|%d", st.Count, st.Updated.UnixNano()), err
+	}
+
+	events, err := stamp(feed())
+	if err != nil {
+		return "", err
+	}
+	exceptions, err := stamp(s.db.Model(&models.EventException{}).
+		Where("event_id IN (?)", feed().Select("id")))
+	if err != nil {
+		return "", err
+	}
+	ministries, err := stamp(s.db.Model(&models.Ministry{}).
+		Where("id IN (?) OR id = ?", feed().Select("ministry_id"), ministryID))
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(events + "|" + exceptions + "|" + ministries))
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// GetPublicByID returns a single publicly visible event with its ministry loaded.
+// Returns gorm.ErrRecordNotFound if the event does not exist or is not visible.
+func (s *EventService) GetPublicByID(id uint) (*models.Event, error) {
+	var event models.Event
+
+	err := s.db.
+		Scopes(publiclyVisible(time.Now())).
+		Preload("Ministry").
+		First(&event, id).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+// GetPublicOccurrence returns the occurrence of a publicly visible event that
+// starts on the given day, with its ministry loaded. Returns
+// gorm.ErrRecordNotFound if the event is not visible or has no occurrence
+// that day.
+func (s *EventService) GetPublicOccurrence(id uint, day time.Time) (*models.Event, error) {
+	event, err := s.GetPublicByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	exceptions, err := s.GetExceptions([]models.Event{*event})
+	if err != nil {
+		return nil, err
+	}
+
+	from := StartOfDay(day)
+	occurrences := ExpandOccurrences([]models.Event{*event}, exceptions, from, from.AddDate(0, 0, 1))
+	if len(occurrences) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &occurrences[0], nil
+}
+
+// occurrenceScheduleLimit caps how many upcoming occurrences of a recurring
+// event staff see when managing its exceptions.
+const occurrenceScheduleLimit = 60
+
+// ScheduledOccurrence is an occurrence as a recurring event's rule generates
+// it, with the exception that cancels or moves it, if any.
+type ScheduledOccurrence struct {
+	Start     time.Time
+	Exception *models.EventException
+}
+
+// Date returns the date the occurrence was generated on, in DateInputLayout.
+// It identifies the occurrence even after it has been moved.
+func (o ScheduledOccurrence) Date() string {
+	return o.Start.Format(DateInputLayout)
+}
+
+// OccurrenceMoveInput holds the fields submitted to move one occurrence, in
+// DateTimeInputLayout. An empty End keeps the series' duration.
+type OccurrenceMoveInput struct {
+	Start string
+	End   string
+}
+
+// OccurrenceMoveInputFrom returns the move form values for an occurrence:
+// its current start and end, moved or not.
+func OccurrenceMoveInputFrom(e models.Event, o ScheduledOccurrence) OccurrenceMoveInput {
+	start, end := o.Start, (*time.Time)(nil)
+	if x := o.Exception; x != nil && x.NewEventDate != nil {
+		start, end = *x.NewEventDate, x.NewEndDate
+	}
+	current := occurrenceAt(e, start, end)
+	return OccurrenceMoveInput{
+		Start: current.EventDate.Format(DateTimeInputLayout),
+		End:   formatOptional(current.EndDate, DateTimeInputLayout),
+	}
+}
+
+// ParseOccurrenceDate parses an occurrence's date as given by
+// ScheduledOccurrence.Date.
+func ParseOccurrenceDate(s string) (time.Time, error) {
+	return time.ParseInLocation(DateInputLayout, s, time.Local)
+}
+
+// GetSchedule returns an event's occurrences from the start of today, as its
+// rule generates them, each with its exception. A non-recurring event has no
+// schedule.
+// Returns gorm.ErrRecordNotFound if the event does not exist.
+func (s *EventService) GetSchedule(id uint) (*models.Event, []ScheduledOccurrence, error) {
+	event, err := s.GetByID(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !event.Recurs() {
+		return event, nil, nil
+	}
+
+	exceptions, err := s.GetExceptions([]models.Event{*event})
+	if err != nil {
+		return nil, nil, err
+	}
+	byDate := make(map[occurrenceKey]*models.EventException, len(exceptions))
+	for i, x := range exceptions {
+		byDate[newOccurrenceKey(x.EventID, x.OccurrenceDate)] = &exceptions[i]
+	}
+
+	from := StartOfDay(time.Now())
+	generated := ExpandOccurrences([]models.Event{*event}, nil, from, from.Add(upcomingHorizon))
+	if len(generated) > occurrenceScheduleLimit {
+		generated = generated[:occurrenceScheduleLimit]
+	}
+
+	schedule := make([]ScheduledOccurrence, len(generated))
+	for i, o := range generated {
+		schedule[i] = ScheduledOccurrence{Start: o.EventDate, Exception: byDate[newOccurrenceKey(event.ID, o.EventDate)]}
+	}
+	return event, schedule, nil
+}
+
+// GetOccurrence returns an event and the occurrence its rule generates on the
+// given date, with its exception.
+// Returns gorm.ErrRecordNotFound if the event does not exist, or
+// ErrNotAnOccurrence if the rule does not generate that date.
+func (s *EventService) GetOccurrence(id uint, date time.Time) (*models.Event, ScheduledOccurrence, error) {
+	event, err := s.GetByID(id)
+	if err != nil {
+		return nil, ScheduledOccurrence{}, err
+	}
+
+	from := StartOfDay(date.In(event.EventDate.Location()))
+	generated := ExpandOccurrences([]models.Event{*event}, nil, from, from.AddDate(0, 0, 1))
+	if !event.Recurs() || len(generated) == 0 {
+		return nil, ScheduledOccurrence{}, ErrNotAnOccurrence
+	}
+
+	occurrence := ScheduledOccurrence{Start: generated[0].EventDate}
+	var exception models.EventException
+	err = s.db.
+		Where("event_id = ? AND occurrence_date = ?", event.ID, occurrence.Date()).
+		First(&exception).Error
+	switch {
+	case err == nil:
+		occurrence.Exception = &exception
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, ScheduledOccurrence{}, err
+	}
+	return event, occurrence, nil
+}
+
+// CancelOccurrence cancels the occurrence of a recurring event generated on
+// the given date, leaving the rest of the series intact.
+// Returns ErrNotAnOccurrence if the rule does not generate that date.
+func (s *EventService) CancelOccurrence(eventID uint, date time.Time) error {
+	return s.saveException(eventID, date, models.EventException{IsCancelled: true})
+}
+
+// MoveOccurrence validates in and moves the occurrence of a recurring event
+// generated on the given date to the new time.
+// Returns ValidationErrors keyed by form field name for bad input, or
+// ErrNotAnOccurrence if the rule does not generate that date.
+func (s *EventService) MoveOccurrence(eventID uint, date time.Time, in OccurrenceMoveInput) error {
+	errs := ValidationErrors{}
+
+	start, err := parseRequired(in.Start, DateTimeInputLayout)
+	if err != nil {
+		errs["start"] = "Enter a valid start date and time."
+	}
+	end, err := parseOptional(in.End, DateTimeInputLayout)
+	if err != nil {
+		errs["end"] = "Enter a valid end date and time."
+	} else if end != nil && !start.IsZero() && !end.After(start) {
+		errs["end"] = "End must be after the start."
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return s.saveException(eventID, date, models.EventException{NewEventDate: &start, NewEndDate: end})
+}
+
+// RestoreOccurrence removes any exception for the occurrence generated on the
+// given date, so it follows the series again.
+func (s *EventService) RestoreOccurrence(eventID uint, date time.Time) error {
+	return s.db.
+		Where("event_id = ? AND occurrence_date = ?", eventID, date.Format(DateInputLayout)).
+		Delete(&models.EventException{}).Error
+}
+
+// saveException creates or replaces the exception for one occurrence after
+// checking that the series actually generates that date.
+func (s *EventService) saveException(eventID uint, date time.Time, exception models.EventException) error {
+	var event models.Event
+	if err := s.db.First(&event, eventID).Error; err != nil {
+		return err
+	}
+	if !event.Recurs() || !IsOccurrenceDate(event, date) {
+		return ErrNotAnOccurrence
+	}
+
+	exception.EventID = eventID
+	y, m, d := date.Date()
+	exception.OccurrenceDate = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "event_id"}, {Name: "occurrence_date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"is_cancelled", "new_event_date", "new_end_date", "updated_at"}),
+	}).Create(&exception).Error
+}
+
+// GetExceptions loads the exceptions belonging to the recurring events in events.
+func (s *EventService) GetExceptions(events []models.Event) ([]models.EventException, error) {
+	var ids []uint
+	for _, e := range events {
+		if e.Recurs() {
+			ids = append(ids, e.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var exceptions []models.EventException
+	err := s.db.Where("event_id IN ?", ids).Find(&exceptions).Error
+	return exceptions, err
+}
+
+// Layouts for the event form's datetime-local and date inputs.
+const (
+	DateTimeInputLayout = "2006-01-02T15:04"
+	DateInputLayout     = "2006-01-02"
+)
+
+// maxCapacity bounds the capacity accepted on the event form.
+const maxCapacity = 100000
+
+// recurrenceIntervals is the shortest gap between occurrences for each rule.
+// A recurring event must end before its next occurrence begins.
+var recurrenceIntervals = map[string]time.Duration{
+	models.RecurrenceDaily:    24 * time.Hour,
+	models.RecurrenceWeekly:   7 * 24 * time.Hour,
+	models.RecurrenceBiweekly: 14 * 24 * time.Hour,
+	models.RecurrenceMonthly:  28 * 24 * time.Hour,
+	models.RecurrenceYearly:   365 * 24 * time.Hour,
+}
+
+// EventInput holds the fields submitted on the staff event form. Dates use
+// DateTimeInputLayout, except RecurrenceEnd which uses DateInputLayout.
+type EventInput struct {
+	Title                string
+	Description          string
+	EventDate            string
+	EndDate              string
+	Location             string
+	LocationDetails      string
+	RecurrenceRule       string
+	RecurrenceEnd        string
+	RegistrationEnabled  bool
+	CapacityLimit        string
+	RegistrationDeadline string
+	VisibleFrom          string
+	VisibleUntil         string
+	IsPublic             bool
+	MinistryID           string
+}
+
+// EventInputFrom returns the form values for an existing event.
+func EventInputFrom(e models.Event) EventInput {
+	in := EventInput{
+		Title:               e.Title,
+		Description:         e.Description,
+		EventDate:           e.EventDate.Format(DateTimeInputLayout),
+		EndDate:             formatOptional(e.EndDate, DateTimeInputLayout),
+		Location:            e.Location,
+		LocationDetails:     e.LocationDetails,
+		RecurrenceRule:      models.RecurrenceNone,
+		RegistrationEnabled: e.RegistrationEnabled,
+		VisibleFrom:         formatOptional(e.VisibleFrom, DateTimeInputLayout),
+		VisibleUntil:        formatOptional(e.VisibleUntil, DateTimeInputLayout),
+		IsPublic:            e.IsPublic,
+	}
+	if e.Recurs() {
+		in.RecurrenceRule = e.RecurrenceRule
+		in.RecurrenceEnd = formatOptional(e.RecurrenceEnd, DateInputLayout)
+	}
+	if e.CapacityLimit != nil {
+		in.CapacityLimit = strconv.Itoa(*e.CapacityLimit)
+	}
+	in.RegistrationDeadline = formatOptional(e.RegistrationDeadline, DateTimeInputLayout)
+	if e.MinistryID != nil {
+		in.MinistryID = strconv.FormatUint(uint64(*e.MinistryID), 10)
+	}
+	return in
+}
+
+// ListAll returns every non-deleted event for staff, most recent first.
+func (s *EventService) ListAll() ([]models.Event, error) {
+	var events []models.Event
+
+	err := s.db.
+		Preload("Ministry").
+		Order("event_date DESC").
 		Find(&events).Error
 
 	return events, err
 }
+
+// GetByID returns a non-deleted event regardless of visibility.
+// Returns gorm.ErrRecordNotFound if it does not exist.
+func (s *EventService) GetByID(id uint) (*models.Event, error) {
+	var event models.Event
+
+	if err := s.db.First(&event, id).Error; err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+// Create validates in and stores a new event created by the given user.
+// Returns ValidationErrors for bad input.
+func (s *EventService) Create(in EventInput, createdBy uint) (*models.Event, error) {
+	var event models.Event
+	if err := s.applyInput(&event, in); err != nil {
+		return nil, err
+	}
+	event.CreatedBy = &createdBy
+
+	if err := s.db.Create(&event).Error; err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+// Update validates in and saves it over an existing event. Capacity may not
+// drop below the seats already confirmed; when it rises, waitlisted
+// registrations are promoted into the new seats and returned with their
+// cancel tokens so they can be notified.
+// Returns gorm.ErrRecordNotFound for unknown events and ValidationErrors for bad input.
+func (s *EventService) Update(id uint, in EventInput) (*models.Event, []Promotion, error) {
+	var event models.Event
+	var promotions []Promotion
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Lock the row, as registration does, so seats are not taken
+		// while the capacity changes.
+		err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&event, id).Error
+		if err != nil {
+			return err
+		}
+
+		if err := s.applyInput(&event, in); err != nil {
+			return err
+		}
+
+		if event.CapacityLimit != nil {
+			confirmed, err := confirmedSeats(tx, event.ID)
+			if err != nil {
+				return err
+			}
+			if *event.CapacityLimit < confirmed {
+				return ValidationErrors{"capacity_limit": fmt.Sprintf("Capacity cannot be less than the %d places already confirmed.", confirmed)}
+			}
+		}
+
+		// Select("*") so cleared optional fields are written as NULL.
+		if err := tx.Select("*").Omit("created_at", "created_by").Updates(&event).Error; err != nil {
+			return err
+		}
+
+		promotions, err = promoteWaitlist(tx, event)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &event, promotions, nil
+}
+
+// Delete soft-deletes an event. It disappears from the calendar and feeds
+// but its registrations are kept.
+// Returns gorm.ErrRecordNotFound if the event does not exist.
+func (s *EventService) Delete(id uint) error {
+	result := s.db.Delete(&models.Event{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// applyInput parses and validates in, writing the result into event.
+// Returns ValidationErrors keyed by form field name.
+func (s *EventService) applyInput(event *models.Event, in EventInput) error {
+	errs := ValidationErrors{}
+
+	title := strings.TrimSpace(in.Title)
+	switch {
+	case title == "":
+		errs["title"] = "Title is required."
+	case len(title) > 255:
+		errs["title"] = "Title must be 255 characters or fewer."
+	}
+
+	location := strings.TrimSpace(in.Location)
+	if len(location) > 255 {
+		errs["location"] = "Location must be 255 characters or fewer."
+	}
+
+	start, err := parseRequired(in.EventDate, DateTimeInputLayout)
+	if err != nil {
+		errs["event_date"] = "Enter a valid start date and time."
+	}
+	end, err := parseOptional(in.EndDate, DateTimeInputLayout)
+	if err != nil {
+		errs["end_date"] = "Enter a valid end date and time."
+	} else if end != nil && !start.IsZero() && !end.After(start) {
+		errs["end_date"] = "End must be after the start."
+	}
+
+	rule := strings.TrimSpace(in.RecurrenceRule)
+	if rule == "" {
+		rule = models.RecurrenceNone
+	}
+	interval, recurring := recurrenceIntervals[rule]
+	if !recurring && rule != models.RecurrenceNone {
+		errs["recurrence_rule"] = "Choose a valid repeat option."
+	}
+	recurrenceEnd, err := parseOptional(in.RecurrenceEnd, DateInputLayout)
+	switch {
+	case err != nil:
+		errs["recurrence_end"] = "Enter a valid date."
+	case recurrenceEnd != nil && !recurring:
+		errs["recurrence_end"] = "Only repeating events can have a last date."
+	case recurrenceEnd != nil && !start.IsZero() && recurrenceEnd.Before(StartOfDay(start)):
+		errs["recurrence_end"] = "The last date cannot be before the first occurrence."
+	}
+	if recurring && end != nil && !start.IsZero() && end.Sub(start) >= interval {
+		errs["end_date"] = "A repeating event must end before its next occurrence begins."
+	}
+
+	var capacity *int
+	if c := strings.TrimSpace(in.CapacityLimit); c != "" {
+		n, err := strconv.Atoi(c)
+		if err != nil || n < 1 || n > maxCapacity {
+			errs["capacity_limit"] = "Capacity must be a whole number of at least 1."
+		} else {
+			capacity = &n
+		}
+	}
+	deadline, err := parseOptional(in.RegistrationDeadline, DateTimeInputLayout)
+	switch {
+	case err != nil:
+		errs["registration_deadline"] = "Enter a valid date and time."
+	case deadline != nil && !recurring && !start.IsZero() && deadline.After(start):
+		errs["registration_deadline"] = "The deadline must be before the event starts."
+	}
+	if !in.RegistrationEnabled && (capacity != nil || deadline != nil) {
+		errs["registration_enabled"] = "Enable registration to set a capacity or deadline."
+	}
+
+	visibleFrom, err := parseOptional(in.VisibleFrom, DateTimeInputLayout)
+	if err != nil {
+		errs["visible_from"] = "Enter a valid date and time."
+	}
+	visibleUntil, err := parseOptional(in.VisibleUntil, DateTimeInputLayout)
+	switch {
+	case err != nil:
+		errs["visible_until"] = "Enter a valid date and time."
+	case visibleUntil != nil && visibleFrom != nil && !visibleUntil.After(*visibleFrom):
+		errs["visible_until"] = "Hide date must be after the show date."
+	}
+
+	var ministryID *uint
+	if m := strings.TrimSpace(in.MinistryID); m != "" {
+		id, err := strconv.ParseUint(m, 10, 64)
+		if err == nil {
+			err = s.db.Select("id").First(&models.Ministry{}, id).Error
+		}
+		switch {
+		case err == nil:
+			mid := uint(id)
+			ministryID = &mid
+		case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, strconv.ErrSyntax), errors.Is(err, strconv.ErrRange):
+			errs["ministry_id"] = "Choose a valid ministry."
+		default:
+			return err
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	event.Title = title
+	event.Description = strings.TrimSpace(in.Description)
+	event.EventDate = start
+	event.EndDate = end
+	event.Location = location
+	event.LocationDetails = strings.TrimSpace(in.LocationDetails)
+	event.IsRecurring = recurring
+	event.RecurrenceRule = rule
+	event.RecurrenceEnd = recurrenceEnd
+	event.RegistrationEnabled = in.RegistrationEnabled
+	event.CapacityLimit = capacity
+	event.RegistrationDeadline = deadline
+	event.VisibleFrom = visibleFrom
+	event.VisibleUntil = visibleUntil
+	event.IsPublic = in.IsPublic
+	event.MinistryID = ministryID
+	event.Ministry = nil
+	return nil
+}
+
+// parseRequired parses a local date or time input value.
+func parseRequired(value, layout string) (time.Time, error) {
+	return time.ParseInLocation(layout, strings.TrimSpace(value), time.Local)
+}
+
+// parseOptional parses a local date or time input value, returning nil for
+// an empty value.
+func parseOptional(value, layout string) (*time.Time, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	t, err := parseRequired(value, layout)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// formatOptional formats t for a form input, or returns "" when t is nil.
+func formatOptional(t *time.Time, layout string) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(layout)
+}
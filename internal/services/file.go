@@ -0,0 +1,44 @@
+package services
+
+import (
+	"strconv"
+	"strings"
+)
+
+// FileURLPrefix is the URL path uploaded files are served under. The rest of
+// the path is the file's storage key, which begins with the directory of the
+// service that owns it, e.g.
+// /files/photos/12-1736035200000000000-800w.webp.
+const FileURLPrefix = "/files/"
+
+// StoredFile is an uploaded file found by a FileOwner, with who may
+// download it and how long it may be cached.
+type StoredFile struct {
+	Name         string                           // storage key
+	Filename     string                           // suggested download name; optional
+	CacheControl string                           // Cache-Control header for the download
+	CanView      func(claims *SessionClaims) bool // nil if anyone may download the file
+}
+
+// FileOwner is implemented by services that keep uploads under their own
+// directory in storage.
+type FileOwner interface {
+	// OwnedFile finds the record that the file name, relative to the
+	// service's directory, belongs to.
+	// Returns gorm.ErrRecordNotFound if no record owns it.
+	OwnedFile(name string) (*StoredFile, error)
+}
+
+// fileOwnerID parses the ID of the record an uploaded file belongs to from
+// its name, which begins with the ID followed by a hyphen.
+func fileOwnerID(name string) (uint, bool) {
+	prefix, _, ok := strings.Cut(name, "-")
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(prefix, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,237 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sfdeloach/churchsite/internal/models"
+	"gorm.io/gorm"
+)
+
+// FormSubmissionRateLimit is how many submissions one IP address may make
+// per FormSubmissionRateWindow, across all forms.
+const (
+	FormSubmissionRateLimit  = 10
+	FormSubmissionRateWindow = time.Hour
+)
+
+// maxUserAgentLength caps the User-Agent kept with a submission.
+const maxUserAgentLength = 1000
+
+// FormSubmissionInput holds a submission's answers and where it came from.
+type FormSubmissionInput struct {
+	Values    map[string]any // from FormSchema.FormValues or a JSON object
+	UserID    *uint          // nil for anonymous submissions
+	IPAddress string
+	UserAgent string
+}
+
+// FormSummary is a form with how often it has been submitted, for the
+// staff forms list.
+type FormSummary struct {
+	Form          models.Form
+	Submissions   int
+	LastSubmitted *time.Time
+}
+
+// SubmissionRow is a submission with its answers formatted in the order of
+// the form's fields.
+type SubmissionRow struct {
+	Submission models.FormSubmission
+	Values     []string
+}
+
+// FormService handles developer-defined forms and their submissions.
+type FormService struct {
+	db *gorm.DB
+}
+
+// NewFormService creates a new FormService.
+func NewFormService(db *gorm.DB) *FormService {
+	return &FormService{db: db}
+}
+
+// ListAll returns every non-deleted form with its submission count, by
+// title.
+func (s *FormService) ListAll() ([]FormSummary, error) {
+	var forms []models.Form
+	if err := s.db.Order("title, id").Find(&forms).Error; err != nil {
+		return nil, err
+	}
+
+	var counts []struct {
+		FormID        uint
+		Submissions   int
+		LastSubmitted time.Time
+	}
+	err := s.db.Model(&models.FormSubmission{}).
+		Select("form_id, COUNT(*) AS submissions, MAX(submitted_at) AS last_submitted").
+		Group("form_id").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]FormSummary, len(forms))
+	for i, f := range forms {
+		summaries[i].Form = f
+		for _, c := range counts {
+			if c.FormID == f.ID {
+				summaries[i].Submissions = c.Submissions
+				summaries[i].LastSubmitted = &c.LastSubmitted
+			}
+		}
+	}
+	return summaries, nil
+}
+
+// GetByID returns a form, active or not, with its parsed schema.
+// Returns gorm.ErrRecordNotFound if it does not exist.
+func (s *FormService) GetByID(id uint) (*models.Form, *FormSchema, error) {
+	var form models.Form
+	if err := s.db.First(&form, id).Error; err != nil {
+		return nil, nil, err
+	}
+	schema, err := ParseFormSchema(form.Schema)
+	if err != nil {
+		return nil, nil, fmt.Errorf("form %d: %w", form.ID, err)
+	}
+	return &form, schema, nil
+}
+
+// GetActive returns a form that is accepting submissions, with its parsed
+// schema.
+// Returns gorm.ErrRecordNotFound if it does not exist or is inactive.
+func (s *FormService) GetActive(id uint) (*models.Form, *FormSchema, error) {
+	form, schema, err := s.GetByID(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !form.IsActive {
+		return nil, nil, gorm.ErrRecordNotFound
+	}
+	return form, schema, nil
+}
+
+// Submit validates answers against an active form's schema and stores them.
+// Returns gorm.ErrRecordNotFound if the form is missing or inactive, or
+// ValidationErrors keyed by property name for bad answers.
+func (s *FormService) Submit(formID uint, in FormSubmissionInput) (*models.FormSubmission, error) {
+	form, schema, err := s.GetActive(formID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := schema.Validate(in.Values)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	submission := models.FormSubmission{
+		FormID:    form.ID,
+		UserID:    in.UserID,
+		Data:      string(encoded),
+		IPAddress: truncateRunes(in.IPAddress, 45),
+		UserAgent: truncateRunes(in.UserAgent, maxUserAgentLength),
+	}
+	if err := s.db.Create(&submission).Error; err != nil {
+		return nil, err
+	}
+	return &submission, nil
+}
+
+// ListSubmissions returns a form's submissions, newest first, with their
+// submitters and answers formatted for schema's fields.
+func (s *FormService) ListSubmissions(formID uint, schema *FormSchema) ([]SubmissionRow, error) {
+	var submissions []models.FormSubmission
+	err := s.db.
+		Preload("User").
+		Where("form_id = ?", formID).
+		Order("submitted_at DESC, id DESC").
+		Find(&submissions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]SubmissionRow, len(submissions))
+	for i, sub := range submissions {
+		data, err := decodeFormData(sub.Data)
+		if err != nil {
+			return nil, fmt.Errorf("submission %d: %w", sub.ID, err)
+		}
+		rows[i].Submission = sub
+		for _, f := range schema.Fields {
+			rows[i].Values = append(rows[i].Values, f.Display(data[f.Name]))
+		}
+	}
+	return rows, nil
+}
+
+// SubmitterName returns who made a submission: the member's name and email,
+// or empty for anonymous submissions.
+func SubmitterName(sub models.FormSubmission) string {
+	if sub.User == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s %s <%s>", sub.User.FirstName, sub.User.LastName, sub.User.Email)
+}
+
+// WriteSubmissionsCSV writes submissions as CSV with a header row: the
+// submission time, one column per field, then the submitter, IP address,
+// and user agent.
+func WriteSubmissionsCSV(w io.Writer, schema *FormSchema, rows []SubmissionRow) error {
+	out := csv.NewWriter(w)
+
+	header := []string{"Submitted At"}
+	for _, f := range schema.Fields {
+		header = append(header, f.Label())
+	}
+	header = append(header, "Submitted By", "IP Address", "User Agent")
+	if err := out.Write(header); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		record := []string{row.Submission.SubmittedAt.Format("2006-01-02 15:04:05")}
+		record = append(record, row.Values...)
+		record = append(record, SubmitterName(row.Submission), row.Submission.IPAddress, row.Submission.UserAgent)
+		for i := range record {
+			record[i] = csvSafe(record[i])
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// csvSafe keeps spreadsheets from running answers as formulas by prefixing
+// those that start like one, other than plain numbers, with an apostrophe.
+func csvSafe(s string) string {
+	if s == "" || !strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return s
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return s
+	}
+	return "'" + s
+}
+
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
@@ -0,0 +1,453 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Property types and string formats accepted in a form schema.
+const (
+	FieldString  = "string"
+	FieldInteger = "integer"
+	FieldNumber  = "number"
+	FieldBoolean = "boolean"
+	FieldArray   = "array"
+
+	FormatEmail    = "email"
+	FormatDate     = "date"
+	FormatTel      = "tel"
+	FormatTextarea = "textarea"
+)
+
+// formFieldMaxLength caps string answers whose property sets no maxLength.
+const formFieldMaxLength = 5000
+
+var (
+	formFieldName = regexp.MustCompile(`^[a-z][a-z0-9_]{0,62}$`)
+	phonePattern  = regexp.MustCompile(`^\+?[0-9 ().-]{7,25}$`)
+)
+
+// formSchemaJSON is the JSON form of a schema. Unknown keywords are rejected
+// rather than silently ignored, so a schema never promises validation the
+// server does not perform.
+type formSchemaJSON struct {
+	Schema      string                  `json:"$schema"`
+	Title       string                  `json:"title"`
+	Description string                  `json:"description"`
+	Type        string                  `json:"type"`
+	Properties  map[string]FormProperty `json:"properties"`
+	Required    []string                `json:"required"`
+}
+
+// FormProperty is one property of a form schema, using JSON Schema keywords.
+// Pattern uses Go regular expression syntax and, as in JSON Schema, matches
+// anywhere in the answer unless anchored.
+type FormProperty struct {
+	Type          string        `json:"type"`
+	Title         string        `json:"title"`
+	Description   string        `json:"description"`
+	Format        string        `json:"format"`
+	Enum          []string      `json:"enum"`
+	MinLength     *int          `json:"minLength"`
+	MaxLength     *int          `json:"maxLength"`
+	Pattern       string        `json:"pattern"`
+	Minimum       *float64      `json:"minimum"`
+	Maximum       *float64      `json:"maximum"`
+	Items         *FormProperty `json:"items"`
+	MinItems      *int          `json:"minItems"`
+	MaxItems      *int          `json:"maxItems"`
+	PropertyOrder int           `json:"propertyOrder"`
+}
+
+// FormField is a property of a form schema with its name, as shown on the
+// form.
+type FormField struct {
+	FormProperty
+	Name     string
+	Required bool
+	pattern  *regexp.Regexp
+}
+
+// Label returns the field's title, or its name if it has none.
+func (f FormField) Label() string {
+	if f.Title != "" {
+		return f.Title
+	}
+	return f.Name
+}
+
+// Options returns the choices for a field answered from a list: the enum of
+// a string, or of an array's items.
+func (f FormField) Options() []string {
+	if f.Type == FieldArray {
+		return f.Items.Enum
+	}
+	return f.Enum
+}
+
+// FormSchema is a parsed form schema: a JSON Schema object whose properties
+// are the form's fields. The supported property types are
+//
+//   - string, with minLength, maxLength, pattern, enum (shown as a select),
+//     and format email, date (YYYY-MM-DD), tel, or textarea
+//   - integer and number, with minimum and maximum
+//   - boolean, shown as a checkbox, which must be checked if required
+//   - array with string enum items, shown as checkboxes, with minItems and
+//     maxItems
+//
+// Postgres does not keep the order of JSONB keys, so fields are shown in
+// order of propertyOrder, then name.
+type FormSchema struct {
+	Fields []FormField
+}
+
+// ParseFormSchema parses and checks a form schema. Errors describe the
+// mistake in the schema, for the developer defining the form.
+func ParseFormSchema(raw string) (*FormSchema, error) {
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.DisallowUnknownFields()
+	var doc formSchemaJSON
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("form schema: %w", err)
+	}
+	if doc.Type != "object" {
+		return nil, errors.New(`form schema: type must be "object"`)
+	}
+	if len(doc.Properties) == 0 {
+		return nil, errors.New("form schema: no properties")
+	}
+
+	schema := &FormSchema{}
+	for name, prop := range doc.Properties {
+		field := FormField{FormProperty: prop, Name: name, Required: slices.Contains(doc.Required, name)}
+		if err := field.check(); err != nil {
+			return nil, fmt.Errorf("form schema: property %q: %w", name, err)
+		}
+		schema.Fields = append(schema.Fields, field)
+	}
+	for _, name := range doc.Required {
+		if _, ok := doc.Properties[name]; !ok {
+			return nil, fmt.Errorf("form schema: required property %q is not defined", name)
+		}
+	}
+
+	slices.SortFunc(schema.Fields, func(a, b FormField) int {
+		if a.PropertyOrder != b.PropertyOrder {
+			return a.PropertyOrder - b.PropertyOrder
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return schema, nil
+}
+
+// check reports keywords the field's type does not support.
+func (f *FormField) check() error {
+	if !formFieldName.MatchString(f.Name) {
+		return errors.New("name must be lowercase letters, digits, and underscores, starting with a letter")
+	}
+
+	switch f.Type {
+	case FieldString:
+		switch f.Format {
+		case "", FormatEmail, FormatDate, FormatTel, FormatTextarea:
+		default:
+			return fmt.Errorf("unsupported format %q", f.Format)
+		}
+		if f.Pattern != "" {
+			re, err := regexp.Compile(f.Pattern)
+			if err != nil {
+				return fmt.Errorf("pattern: %w", err)
+			}
+			f.pattern = re
+		}
+	case FieldInteger, FieldNumber, FieldBoolean:
+		if f.Format != "" || f.Pattern != "" || len(f.Enum) > 0 {
+			return fmt.Errorf("format, pattern, and enum are not supported for type %s", f.Type)
+		}
+	case FieldArray:
+		if f.Items == nil || f.Items.Type != FieldString || len(f.Items.Enum) == 0 {
+			return errors.New("items must be a string enum")
+		}
+	default:
+		return fmt.Errorf("unsupported type %q", f.Type)
+	}
+
+	if f.Type != FieldString && (f.MinLength != nil || f.MaxLength != nil) {
+		return errors.New("minLength and maxLength are only supported for strings")
+	}
+	if f.Type != FieldInteger && f.Type != FieldNumber && (f.Minimum != nil || f.Maximum != nil) {
+		return errors.New("minimum and maximum are only supported for numbers")
+	}
+	if f.Type != FieldArray && (f.Items != nil || f.MinItems != nil || f.MaxItems != nil) {
+		return errors.New("items, minItems, and maxItems are only supported for arrays")
+	}
+	return nil
+}
+
+// FormValues converts a posted HTML form into the values Validate expects:
+// a list for array fields and a string for the rest. Unknown names are
+// dropped.
+func (s *FormSchema) FormValues(form url.Values) map[string]any {
+	values := map[string]any{}
+	for _, f := range s.Fields {
+		if f.Type == FieldArray {
+			var list []any
+			for _, v := range form[f.Name] {
+				list = append(list, v)
+			}
+			values[f.Name] = list
+		} else if form.Has(f.Name) {
+			values[f.Name] = form.Get(f.Name)
+		}
+	}
+	return values
+}
+
+// Validate checks answers, from FormValues or a JSON object decoded with
+// UseNumber, against the schema. Returns the answers to store, converted to
+// their property's type, with empty optional answers and unknown names
+// dropped and booleans always present.
+// Returns ValidationErrors keyed by property name for bad answers.
+func (s *FormSchema) Validate(values map[string]any) (map[string]any, error) {
+	data := map[string]any{}
+	errs := ValidationErrors{}
+	for _, f := range s.Fields {
+		v, msg := f.validate(values[f.Name])
+		switch {
+		case msg != "":
+			errs[f.Name] = msg
+		case v != nil:
+			data[f.Name] = v
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return data, nil
+}
+
+// validate converts an answer to the field's type, returning nil for an
+// empty optional answer, or a message explaining why it is invalid.
+func (f FormField) validate(v any) (any, string) {
+	switch f.Type {
+	case FieldBoolean:
+		checked, ok := parseFormBool(v)
+		if !ok {
+			return nil, "Must be true or false."
+		}
+		if f.Required && !checked {
+			return nil, "This field is required."
+		}
+		return checked, ""
+	case FieldArray:
+		return f.validateChoices(v)
+	}
+
+	text, ok := formText(v)
+	if !ok {
+		return nil, "This value is not valid."
+	}
+	if text == "" {
+		if f.Required {
+			return nil, "This field is required."
+		}
+		return nil, ""
+	}
+
+	switch f.Type {
+	case FieldInteger:
+		n, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return nil, "Enter a whole number."
+		}
+		if msg := f.checkRange(float64(n)); msg != "" {
+			return nil, msg
+		}
+		return n, ""
+	case FieldNumber:
+		n, err := strconv.ParseFloat(text, 64)
+		if err != nil || math.IsInf(n, 0) || math.IsNaN(n) {
+			return nil, "Enter a number."
+		}
+		if msg := f.checkRange(n); msg != "" {
+			return nil, msg
+		}
+		return n, ""
+	default:
+		return f.validateString(text)
+	}
+}
+
+func (f FormField) validateString(text string) (any, string) {
+	length := utf8.RuneCountInString(text)
+	maxLength := formFieldMaxLength
+	if f.MaxLength != nil {
+		maxLength = *f.MaxLength
+	}
+	switch {
+	case length > maxLength:
+		return nil, fmt.Sprintf("Must be %d characters or fewer.", maxLength)
+	case f.MinLength != nil && length < *f.MinLength:
+		return nil, fmt.Sprintf("Must be at least %d characters.", *f.MinLength)
+	case len(f.Enum) > 0 && !slices.Contains(f.Enum, text):
+		return nil, "Choose one of the listed options."
+	case f.pattern != nil && !f.pattern.MatchString(text):
+		return nil, "This is not in the expected format."
+	}
+
+	switch f.Format {
+	case FormatEmail:
+		if addr, err := mail.ParseAddress(text); err != nil || addr.Address != text {
+			return nil, "Enter a valid email address."
+		}
+	case FormatDate:
+		if _, err := time.Parse(DateInputLayout, text); err != nil {
+			return nil, "Enter a date."
+		}
+	case FormatTel:
+		if !phonePattern.MatchString(text) {
+			return nil, "Enter a phone number."
+		}
+	}
+	return text, ""
+}
+
+func (f FormField) checkRange(n float64) string {
+	switch {
+	case f.Minimum != nil && n < *f.Minimum:
+		return fmt.Sprintf("Must be at least %s.", formatFormNumber(*f.Minimum))
+	case f.Maximum != nil && n > *f.Maximum:
+		return fmt.Sprintf("Must be at most %s.", formatFormNumber(*f.Maximum))
+	}
+	return ""
+}
+
+// validateChoices checks the options chosen for an array field, returning
+// them in the order they are listed in the schema.
+func (f FormField) validateChoices(v any) (any, string) {
+	var chosen []string
+	switch list := v.(type) {
+	case nil:
+	case []any:
+		for _, item := range list {
+			s, ok := item.(string)
+			if !ok {
+				return nil, "This value is not valid."
+			}
+			chosen = append(chosen, s)
+		}
+	case []string:
+		chosen = list
+	default:
+		return nil, "This value is not valid."
+	}
+
+	var choices []string
+	for _, s := range chosen {
+		if !slices.Contains(f.Items.Enum, s) {
+			return nil, "Choose from the listed options."
+		}
+	}
+	for _, option := range f.Items.Enum {
+		if slices.Contains(chosen, option) {
+			choices = append(choices, option)
+		}
+	}
+
+	switch {
+	case len(choices) == 0 && f.Required:
+		return nil, "This field is required."
+	case f.MinItems != nil && len(choices) < *f.MinItems:
+		return nil, fmt.Sprintf("Choose at least %d.", *f.MinItems)
+	case f.MaxItems != nil && len(choices) > *f.MaxItems:
+		return nil, fmt.Sprintf("Choose at most %d.", *f.MaxItems)
+	case len(choices) == 0:
+		return nil, ""
+	}
+	return choices, ""
+}
+
+// Display formats a stored answer for the submissions table and CSV export.
+func (f FormField) Display(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		if v {
+			return "Yes"
+		}
+		return "No"
+	case json.Number:
+		return v.String()
+	case float64:
+		return formatFormNumber(v)
+	case []any:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = f.Display(item)
+		}
+		return strings.Join(parts, ", ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// decodeFormData decodes stored answers, keeping numbers as written.
+func decodeFormData(raw string) (map[string]any, error) {
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.UseNumber()
+	var data map[string]any
+	err := dec.Decode(&data)
+	return data, err
+}
+
+// formText returns a scalar answer as trimmed text.
+func formText(v any) (string, bool) {
+	switch v := v.(type) {
+	case nil:
+		return "", true
+	case string:
+		return strings.TrimSpace(v), true
+	case json.Number:
+		return v.String(), true
+	case float64:
+		return formatFormNumber(v), true
+	default:
+		return "", false
+	}
+}
+
+// parseFormBool reads a checkbox, which is absent when unchecked, or a JSON
+// boolean.
+func parseFormBool(v any) (bool, bool) {
+	switch v := v.(type) {
+	case nil:
+		return false, true
+	case bool:
+		return v, true
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "", "0", "false", "off":
+			return false, true
+		case "1", "true", "on":
+			return true, true
+		}
+	}
+	return false, false
+}
+
+func formatFormNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
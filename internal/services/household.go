@@ -0,0 +1,470 @@
+package services
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/sfdeloach/churchsite/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrAlreadyInHousehold = errors.New("already belongs to a household")
+	ErrSameHousehold      = errors.New("cannot merge a household into itself")
+)
+
+// HouseholdInput holds a household's name and the address and phone shared
+// by everyone in it.
+type HouseholdInput struct {
+	Name  string
+	Phone string
+	AddressInput
+}
+
+// ChildInput holds the fields submitted when adding a child without a login
+// to a household. BirthDate uses DateInputLayout and is optional.
+type ChildInput struct {
+	FirstName string
+	LastName  string
+	BirthDate string
+}
+
+// HouseholdService manages households: the families whose adults and
+// children share an address and are listed together in the directory.
+type HouseholdService struct {
+	db *gorm.DB
+}
+
+// NewHouseholdService creates a new HouseholdService.
+func NewHouseholdService(db *gorm.DB) *HouseholdService {
+	return &HouseholdService{db: db}
+}
+
+// withMembers preloads a household's members and their users, adults first,
+// then children from oldest to youngest.
+func withMembers(db *gorm.DB) *gorm.DB {
+	return db.Preload("Members", func(db *gorm.DB) *gorm.DB {
+		return db.Order("relationship ASC, birth_date ASC NULLS LAST, id ASC")
+	}).Preload("Members.User")
+}
+
+// GetByID returns a household with its members loaded.
+// Returns gorm.ErrRecordNotFound if it does not exist.
+func (s *HouseholdService) GetByID(id uint) (*models.Household, error) {
+	var household models.Household
+
+	if err := s.db.Scopes(withMembers).First(&household, id).Error; err != nil {
+		return nil, err
+	}
+
+	return &household, nil
+}
+
+// GetForUser returns the household a user belongs to, with its members
+// loaded. Returns gorm.ErrRecordNotFound if they are not in one.
+func (s *HouseholdService) GetForUser(userID uint) (*models.Household, error) {
+	return householdForUser(s.db, userID)
+}
+
+// householdForUser is GetForUser for callers outside HouseholdService, such as
+// profile saves that run in their own transaction.
+func householdForUser(db *gorm.DB, userID uint) (*models.Household, error) {
+	var household models.Household
+
+	err := db.Scopes(withMembers).
+		Where("id = (SELECT household_id FROM household_members WHERE user_id = ?)", userID).
+		First(&household).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &household, nil
+}
+
+// ListAll returns every household ordered by name, with members loaded.
+func (s *HouseholdService) ListAll() ([]models.Household, error) {
+	var households []models.Household
+
+	err := s.db.Scopes(withMembers).
+		Order("LOWER(name), id").
+		Find(&households).Error
+
+	return households, err
+}
+
+// HouseholdInputFrom returns the form values for an existing household.
+func HouseholdInputFrom(h models.Household) HouseholdInput {
+	return HouseholdInput{
+		Name:  h.Name,
+		Phone: h.Phone,
+		AddressInput: AddressInput{
+			AddressLine1: h.AddressLine1,
+			AddressLine2: h.AddressLine2,
+			City:         h.City,
+			State:        h.State,
+			ZipCode:      h.ZipCode,
+		},
+	}
+}
+
+// Create validates in and stores a new household with the given users as its
+// adults. Returns ValidationErrors for bad input, gorm.ErrRecordNotFound for
+// unknown users, and ErrAlreadyInHousehold if any user already has one.
+func (s *HouseholdService) Create(in HouseholdInput, adultIDs []uint) (*models.Household, error) {
+	var household models.Household
+	if err := applyHouseholdInput(&household, in); err != nil {
+		return nil, err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&household).Error; err != nil {
+			return err
+		}
+		for _, userID := range adultIDs {
+			if _, err := addAdult(tx, household.ID, userID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetByID(household.ID)
+}
+
+// Update validates in and saves it over a household. The new address and
+// phone apply to everyone in it.
+// Returns gorm.ErrRecordNotFound for unknown households and ValidationErrors
+// for bad input.
+func (s *HouseholdService) Update(id uint, in HouseholdInput) (*models.Household, error) {
+	var household models.Household
+	if err := s.db.First(&household, id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := applyHouseholdInput(&household, in); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Select("*").Omit("created_at").Updates(&household).Error; err != nil {
+		return nil, err
+	}
+
+	return s.GetByID(id)
+}
+
+// AddAdult adds the user with the given email, who has a login, to a
+// household as an adult.
+// Returns gorm.ErrRecordNotFound for unknown households and ValidationErrors
+// if no account uses the email or its user already has a household.
+func (s *HouseholdService) AddAdult(householdID uint, email string) (*models.HouseholdMember, error) {
+	email = NormalizeEmail(email)
+	if email == "" {
+		return nil, ValidationErrors{"email": "Email is required."}
+	}
+
+	var user models.User
+	if err := s.db.Where("email = ?", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ValidationErrors{"email": "No account uses that email address."}
+		}
+		return nil, err
+	}
+
+	var member *models.HouseholdMember
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockHouseholds(tx, householdID); err != nil {
+			return err
+		}
+		var err error
+		member, err = addAdult(tx, householdID, user.ID)
+		return err
+	})
+	if errors.Is(err, ErrAlreadyInHousehold) {
+		return nil, ValidationErrors{"email": user.FullName() + " already belongs to a household. Merge the households instead."}
+	}
+
+	return member, err
+}
+
+// AddChild validates in and adds a child without a login to a household.
+// Returns gorm.ErrRecordNotFound for unknown households and ValidationErrors
+// for bad input.
+func (s *HouseholdService) AddChild(householdID uint, in ChildInput) (*models.HouseholdMember, error) {
+	errs := ValidationErrors{}
+
+	firstName := strings.TrimSpace(in.FirstName)
+	switch {
+	case firstName == "":
+		errs["first_name"] = "First name is required."
+	case len(firstName) > 100:
+		errs["first_name"] = "First name must be 100 characters or fewer."
+	}
+
+	lastName := strings.TrimSpace(in.LastName)
+	if len(lastName) > 100 {
+		errs["last_name"] = "Last name must be 100 characters or fewer."
+	}
+
+	birthDate, err := parseOptional(in.BirthDate, DateInputLayout)
+	if err != nil {
+		errs["birth_date"] = "Enter a valid date."
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	member := models.HouseholdMember{
+		HouseholdID:  householdID,
+		FirstName:    firstName,
+		LastName:     lastName,
+		Relationship: models.RelationshipChild,
+		BirthDate:    birthDate,
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var household models.Household
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&household, householdID).Error; err != nil {
+			return err
+		}
+		if member.LastName == "" {
+			member.LastName = household.Name
+		}
+		return tx.Create(&member).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &member, nil
+}
+
+// RemoveMember removes a person from a household. A household left with no
+// members is deleted.
+// Returns gorm.ErrRecordNotFound if the member is not in the household.
+func (s *HouseholdService) RemoveMember(householdID, memberID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockHouseholds(tx, householdID); err != nil {
+			return err
+		}
+
+		result := tx.Where("household_id = ?", householdID).Delete(&models.HouseholdMember{}, memberID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return deleteIfEmpty(tx, householdID)
+	})
+}
+
+// Merge moves everyone in the source household into the target and deletes
+// the source. The target keeps its name; its address and phone are filled
+// from the source only where the target has none.
+// Returns gorm.ErrRecordNotFound for unknown households and ErrSameHousehold
+// when both IDs are equal.
+func (s *HouseholdService) Merge(targetID, sourceID uint) (*models.Household, error) {
+	if targetID == sourceID {
+		return nil, ErrSameHousehold
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockHouseholds(tx, targetID, sourceID); err != nil {
+			return err
+		}
+
+		var target, source models.Household
+		if err := tx.First(&target, targetID).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&source, sourceID).Error; err != nil {
+			return err
+		}
+
+		if !target.HasAddress() {
+			target.AddressLine1 = source.AddressLine1
+			target.AddressLine2 = source.AddressLine2
+			target.City = source.City
+			target.State = source.State
+			target.ZipCode = source.ZipCode
+		}
+		if target.Phone == "" {
+			target.Phone = source.Phone
+		}
+		if err := tx.Save(&target).Error; err != nil {
+			return err
+		}
+
+		err := tx.Model(&models.HouseholdMember{}).
+			Where("household_id = ?", sourceID).
+			Updates(map[string]any{"household_id": targetID, "updated_at": gorm.Expr("CURRENT_TIMESTAMP")}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Delete(&models.Household{}, sourceID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetByID(targetID)
+}
+
+// Split moves the given members out of a household into a new one named
+// name, which starts with a copy of the original address and phone. At least
+// one member must move and at least one must stay.
+// Returns gorm.ErrRecordNotFound for unknown households and ValidationErrors
+// for bad input, including members not in the household.
+func (s *HouseholdService) Split(householdID uint, memberIDs []uint, name string) (*models.Household, error) {
+	memberIDs = slices.Compact(slices.Sorted(slices.Values(memberIDs)))
+	var created models.Household
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockHouseholds(tx, householdID); err != nil {
+			return err
+		}
+
+		var original models.Household
+		if err := tx.Scopes(withMembers).First(&original, householdID).Error; err != nil {
+			return err
+		}
+
+		in := HouseholdInputFrom(original)
+		in.Name = name
+		if err := applyHouseholdInput(&created, in); err != nil {
+			return err
+		}
+
+		moving := 0
+		for _, m := range original.Members {
+			if slices.Contains(memberIDs, m.ID) {
+				moving++
+			}
+		}
+		switch {
+		case len(memberIDs) == 0:
+			return ValidationErrors{"members": "Choose who is moving to the new household."}
+		case moving != len(memberIDs):
+			return ValidationErrors{"members": "Everyone moving must belong to this household."}
+		case moving == len(original.Members):
+			return ValidationErrors{"members": "At least one person must stay in the original household."}
+		}
+
+		if err := tx.Create(&created).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.HouseholdMember{}).
+			Where("household_id = ? AND id IN ?", householdID, memberIDs).
+			Updates(map[string]any{"household_id": created.ID, "updated_at": gorm.Expr("CURRENT_TIMESTAMP")}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetByID(created.ID)
+}
+
+// addAdult adds userID to a household whose row the caller has locked or
+// just created.
+func addAdult(tx *gorm.DB, householdID, userID uint) (*models.HouseholdMember, error) {
+	var user models.User
+	if err := tx.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := tx.Model(&models.HouseholdMember{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrAlreadyInHousehold
+	}
+
+	member := models.HouseholdMember{
+		HouseholdID:  householdID,
+		UserID:       &user.ID,
+		Relationship: models.RelationshipAdult,
+		User:         &user,
+	}
+	if err := tx.Omit("User").Create(&member).Error; err != nil {
+		return nil, err
+	}
+
+	return &member, nil
+}
+
+// lockHouseholds locks household rows in ID order, so merges and splits
+// running concurrently cannot interleave or deadlock.
+// Returns gorm.ErrRecordNotFound unless every household exists.
+func lockHouseholds(tx *gorm.DB, ids ...uint) error {
+	var locked []models.Household
+
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", ids).
+		Order("id").
+		Find(&locked).Error
+	if err != nil {
+		return err
+	}
+	if len(locked) != len(ids) {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// deleteIfEmpty removes a household that no longer has any members.
+func deleteIfEmpty(tx *gorm.DB, householdID uint) error {
+	var count int64
+	if err := tx.Model(&models.HouseholdMember{}).Where("household_id = ?", householdID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return tx.Delete(&models.Household{}, householdID).Error
+}
+
+// applyHouseholdInput parses and validates in, writing the result into h.
+// Returns ValidationErrors keyed by form field name.
+func applyHouseholdInput(h *models.Household, in HouseholdInput) error {
+	errs := ValidationErrors{}
+
+	name := strings.TrimSpace(in.Name)
+	switch {
+	case name == "":
+		errs["name"] = "Family name is required."
+	case len(name) > 100:
+		errs["name"] = "Family name must be 100 characters or fewer."
+	}
+
+	phone := strings.TrimSpace(in.Phone)
+	if len(phone) > 20 {
+		errs["phone"] = "Phone must be 20 characters or fewer."
+	}
+
+	in.AddressInput.normalize()
+	in.AddressInput.validate(errs)
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	h.Name = name
+	h.Phone = phone
+	h.AddressLine1 = in.AddressLine1
+	h.AddressLine2 = in.AddressLine2
+	h.City = in.City
+	h.State = in.State
+	h.ZipCode = in.ZipCode
+	return nil
+}
@@ -1,10 +1,21 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"time"
+
 	"github.com/sfdeloach/churchsite/internal/models"
+	"github.com/sfdeloach/churchsite/internal/sanitize"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// MaxPageContentLength caps the size of a ministry page's HTML.
+const MaxPageContentLength = 100_000
+
 // MinistryService handles ministry queries.
 type MinistryService struct {
 	db *gorm.DB
@@ -42,3 +53,313 @@ func (s *MinistryService) GetBySlug(slug string) (*models.Ministry, error) {
 
 	return &ministry, nil
 }
+
+// ListAll returns every non-deleted ministry, active or not, for staff.
+func (s *MinistryService) ListAll() ([]models.Ministry, error) {
+	var ministries []models.Ministry
+
+	err := s.db.
+		Order("sort_order ASC, name ASC").
+		Find(&ministries).Error
+
+	return ministries, err
+}
+
+// GetBySlugForEdit returns a ministry by its slug whether or not it is
+// active, so inactive pages can be prepared before they are listed.
+// Returns gorm.ErrRecordNotFound if no ministry with that slug exists.
+func (s *MinistryService) GetBySlugForEdit(slug string) (*models.Ministry, error) {
+	var ministry models.Ministry
+
+	if err := s.db.Where("slug = ?", slug).First(&ministry).Error; err != nil {
+		return nil, err
+	}
+
+	return &ministry, nil
+}
+
+// CanEdit reports whether the user may edit the ministry's page: staff may
+// edit every ministry, other users only those they are assigned to with
+// can_edit set.
+func (s *MinistryService) CanEdit(user *SessionClaims, ministryID uint) (bool, error) {
+	if user == nil {
+		return false, nil
+	}
+	if user.HasRole(models.RoleStaff) {
+		return true, nil
+	}
+
+	var count int64
+	err := s.db.Model(&models.MinistryAssignment{}).
+		Where("user_id = ? AND ministry_id = ? AND can_edit", user.UserID, ministryID).
+		Count(&count).Error
+
+	return count > 0, err
+}
+
+// ministryPageRevision is the part of a ministry kept in its revisions.
+type ministryPageRevision struct {
+	PageContent string `json:"page_content"`
+}
+
+// UpdatePageContent sanitizes content and saves it as the ministry's page,
+// recording the previous content as a revision by editorID. Any draft is
+// left in place.
+// Returns gorm.ErrRecordNotFound for unknown ministries and ValidationErrors
+// for content that is too long.
+func (s *MinistryService) UpdatePageContent(id uint, content string, editorID uint) (*models.Ministry, error) {
+	return s.updatePage(id, content, editorID, false)
+}
+
+// Publish sanitizes content and publishes it as the ministry's page,
+// discarding any draft.
+// Returns gorm.ErrRecordNotFound for unknown ministries and ValidationErrors
+// for content that is too long.
+func (s *MinistryService) Publish(id uint, content string, editorID uint) (*models.Ministry, error) {
+	return s.updatePage(id, content, editorID, true)
+}
+
+func (s *MinistryService) updatePage(id uint, content string, editorID uint, discardDraft bool) (*models.Ministry, error) {
+	content, err := cleanPageContent(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var ministry models.Ministry
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ministry, id).Error; err != nil {
+			return err
+		}
+		return publishPage(tx, &ministry, content, editorID, discardDraft)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ministry, nil
+}
+
+// DraftInput holds the fields submitted when saving a ministry page draft.
+// PublishAt uses DateTimeInputLayout and may be empty.
+type DraftInput struct {
+	Content   string
+	PublishAt string
+}
+
+// SaveDraft sanitizes in.Content and saves it as the ministry's draft without
+// changing the published page. A publish time schedules the draft to be
+// published by the background publisher.
+// Returns gorm.ErrRecordNotFound for unknown ministries and ValidationErrors
+// for bad input.
+func (s *MinistryService) SaveDraft(id uint, in DraftInput, editorID uint, now time.Time) (*models.Ministry, error) {
+	errs := ValidationErrors{}
+
+	content, err := cleanPageContent(in.Content)
+	var verrs ValidationErrors
+	if errors.As(err, &verrs) {
+		maps.Copy(errs, verrs)
+	}
+
+	publishAt, err := parseOptional(in.PublishAt, DateTimeInputLayout)
+	switch {
+	case err != nil:
+		errs["publish_at"] = "Enter a valid date and time."
+	case publishAt != nil && !publishAt.After(now):
+		errs["publish_at"] = "Choose a time in the future, or publish now."
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	var ministry models.Ministry
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ministry, id).Error; err != nil {
+			return err
+		}
+
+		ministry.DraftContent = &content
+		ministry.DraftPublishAt = publishAt
+		ministry.DraftUpdatedAt = &now
+		ministry.DraftUpdatedBy = &editorID
+		return tx.Model(&ministry).
+			Select("draft_content", "draft_publish_at", "draft_updated_at", "draft_updated_by").
+			Updates(&ministry).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ministry, nil
+}
+
+// DiscardDraft removes the ministry's draft and any scheduled publish.
+// Returns gorm.ErrRecordNotFound for unknown ministries.
+func (s *MinistryService) DiscardDraft(id uint) error {
+	result := s.db.Model(&models.Ministry{}).Where("id = ?", id).Updates(clearDraft())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RunPublisher publishes scheduled drafts every interval until ctx is
+// cancelled.
+func (s *MinistryService) RunPublisher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.PublishDue(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			slog.Error("failed to publish scheduled ministry pages", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PublishDue publishes the drafts scheduled at or before now, returning how
+// many were published. Each is recorded as a change by the draft's last
+// editor. Rows are claimed with FOR UPDATE SKIP LOCKED so several servers
+// can run the publisher.
+func (s *MinistryService) PublishDue(ctx context.Context, now time.Time) (int, error) {
+	var due []models.Ministry
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("draft_content IS NOT NULL AND draft_publish_at <= ?", now).
+			Find(&due).Error
+		if err != nil {
+			return err
+		}
+
+		for i := range due {
+			var editorID uint
+			if due[i].DraftUpdatedBy != nil {
+				editorID = *due[i].DraftUpdatedBy
+			}
+			if err := publishPage(tx, &due[i], *due[i].DraftContent, editorID, true); err != nil {
+				return err
+			}
+			slog.Info("scheduled ministry page published", "ministry_id", due[i].ID, "editor_id", editorID)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(due), nil
+}
+
+// clearDraft returns the column updates that remove a ministry's draft.
+func clearDraft() map[string]any {
+	return map[string]any{
+		"draft_content":    nil,
+		"draft_publish_at": nil,
+		"draft_updated_at": nil,
+		"draft_updated_by": nil,
+	}
+}
+
+// publishPage replaces a locked ministry's page with content, recording the
+// previous content as a revision by editorID, and optionally discards the
+// draft.
+func publishPage(tx *gorm.DB, ministry *models.Ministry, content string, editorID uint, discardDraft bool) error {
+	before := ministryPageRevision{PageContent: ministry.PageContent}
+	after := ministryPageRevision{PageContent: content}
+	if err := recordRevision(tx, models.RevisionMinistryPage, ministry.ID, before, after, editorID); err != nil {
+		return err
+	}
+
+	updates := map[string]any{"page_content": content}
+	if discardDraft {
+		maps.Copy(updates, clearDraft())
+		ministry.DraftContent = nil
+		ministry.DraftPublishAt = nil
+		ministry.DraftUpdatedAt = nil
+		ministry.DraftUpdatedBy = nil
+	}
+	ministry.PageContent = content
+	return tx.Model(ministry).Updates(updates).Error
+}
+
+// cleanPageContent sanitizes page HTML and checks its length.
+func cleanPageContent(content string) (string, error) {
+	content = sanitize.HTML(content)
+	if len(content) > MaxPageContentLength {
+		return "", ValidationErrors{"page_content": "The page is too long. Please shorten it and try again."}
+	}
+	return content, nil
+}
+
+// ListAssignments returns the users assigned to a ministry with their
+// accounts loaded, ordered by name.
+func (s *MinistryService) ListAssignments(ministryID uint) ([]models.MinistryAssignment, error) {
+	var assignments []models.MinistryAssignment
+
+	err := s.db.
+		InnerJoins("User").
+		Where("ministry_assignments.ministry_id = ?", ministryID).
+		Order(`"User".last_name ASC, "User".first_name ASC`).
+		Find(&assignments).Error
+
+	return assignments, err
+}
+
+// Assign adds the user with the given email to a ministry, or updates their
+// can_edit flag if they are already assigned.
+// Returns ValidationErrors if no account uses the email address.
+func (s *MinistryService) Assign(ministryID uint, email string, canEdit bool, assignedBy uint) (*models.MinistryAssignment, error) {
+	email = NormalizeEmail(email)
+	if email == "" {
+		return nil, ValidationErrors{"email": "Email is required."}
+	}
+
+	var user models.User
+	if err := s.db.Where("email = ?", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ValidationErrors{"email": "No account uses that email address."}
+		}
+		return nil, err
+	}
+
+	assignment := models.MinistryAssignment{
+		UserID:     user.ID,
+		MinistryID: ministryID,
+		CanEdit:    canEdit,
+		AssignedBy: &assignedBy,
+	}
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "ministry_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"can_edit"}),
+	}).Create(&assignment).Error
+	if err != nil {
+		return nil, err
+	}
+
+	assignment.User = &user
+	return &assignment, nil
+}
+
+// Unassign removes an assignment from a ministry.
+// Returns gorm.ErrRecordNotFound if it does not belong to the ministry.
+func (s *MinistryService) Unassign(ministryID, assignmentID uint) error {
+	result := s.db.
+		Where("id = ? AND ministry_id = ?", assignmentID, ministryID).
+		Delete(&models.MinistryAssignment{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
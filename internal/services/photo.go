@@ -0,0 +1,617 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sfdeloach/churchsite/internal/models"
+	"github.com/sfdeloach/churchsite/internal/storage"
+	"github.com/sfdeloach/churchsite/internal/utils/imaging"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PhotoURLPrefix is the URL path gallery photos are served under.
+const PhotoURLPrefix = FileURLPrefix + "photos/"
+
+// MaxPhotosPerUpload is the most files accepted in one upload request.
+// Processing takes a few seconds per photo, so larger batches would outlast
+// the server's write timeout; the upload page sends files one at a time.
+const MaxPhotosPerUpload = 10
+
+// ErrAlbumNotEmpty is returned when deleting an album that still has photos.
+var ErrAlbumNotEmpty = errors.New("photo album not empty")
+
+// AlbumInput holds the fields submitted on the admin album form. Slug is
+// only used when creating; it is fixed afterwards.
+type AlbumInput struct {
+	Title       string
+	Slug        string
+	Description string
+	Visibility  string
+}
+
+// PhotoUpload is one submitted photo file.
+type PhotoUpload struct {
+	Name string // the file name on the uploader's device
+	File io.Reader
+	Size int64
+}
+
+// UploadResult reports what became of one PhotoUpload: the stored draft, or
+// the message explaining why it was not accepted.
+type UploadResult struct {
+	Name  string
+	Photo *models.Photo
+	Error string
+}
+
+// PhotoInput holds the fields submitted when moderating a photo. AlbumID
+// is a raw form value.
+type PhotoInput struct {
+	AltText     string
+	Caption     string
+	AlbumID     string
+	IsPublished bool
+}
+
+// AlbumSummary is an album with its cover and photo counts, as listed on
+// the gallery and admin pages.
+type AlbumSummary struct {
+	Album     models.PhotoAlbum
+	Cover     *models.Photo // nil if the album has no photos to show
+	Published int
+	Drafts    int
+}
+
+// PhotoService handles gallery albums and photos. Uploaded photos are
+// stored under photos/ as WebP variants.
+type PhotoService struct {
+	db            *gorm.DB
+	store         storage.Storage
+	maxUploadSize int64
+}
+
+// NewPhotoService creates a new PhotoService storing photos in store and
+// rejecting files larger than maxUploadSize bytes.
+func NewPhotoService(db *gorm.DB, store storage.Storage, maxUploadSize int64) *PhotoService {
+	return &PhotoService{db: db, store: store, maxUploadSize: maxUploadSize}
+}
+
+// MaxUploadSize returns the largest accepted photo size in bytes.
+func (s *PhotoService) MaxUploadSize() int64 {
+	return s.maxUploadSize
+}
+
+// CanViewAlbum reports whether the user may see album's published photos.
+func CanViewAlbum(claims *SessionClaims, album models.PhotoAlbum) bool {
+	return !album.MembersOnly() || SeesMembersContent(claims)
+}
+
+// ListAlbums returns every album, newest first, with its cover and counts
+// of published and draft photos. Albums without a chosen cover show their
+// first photo.
+func (s *PhotoService) ListAlbums() ([]AlbumSummary, error) {
+	var albums []models.PhotoAlbum
+	if err := s.db.Order("created_at DESC, id DESC").Find(&albums).Error; err != nil {
+		return nil, err
+	}
+	return s.summarize(albums, false)
+}
+
+// GalleryAlbums returns the albums shown in the public gallery, newest
+// first: those with published photos, leaving out members-only albums
+// unless members is true. Covers are always published photos.
+func (s *PhotoService) GalleryAlbums(members bool) ([]AlbumSummary, error) {
+	db := s.db.Where("EXISTS (SELECT 1 FROM photos WHERE photos.album_id = photo_albums.id AND photos.is_published)")
+	if !members {
+		db = db.Where("visibility = ?", models.AudiencePublic)
+	}
+
+	var albums []models.PhotoAlbum
+	if err := db.Order("created_at DESC, id DESC").Find(&albums).Error; err != nil {
+		return nil, err
+	}
+	return s.summarize(albums, true)
+}
+
+// summarize loads the covers and photo counts of albums. With
+// publishedOnly, a cover that is still a draft is passed over.
+func (s *PhotoService) summarize(albums []models.PhotoAlbum, publishedOnly bool) ([]AlbumSummary, error) {
+	if len(albums) == 0 {
+		return nil, nil
+	}
+	ids := make([]uint, len(albums))
+	for i, a := range albums {
+		ids[i] = a.ID
+	}
+
+	var counts []struct {
+		AlbumID     uint
+		IsPublished bool
+		Count       int
+	}
+	err := s.db.Model(&models.Photo{}).
+		Select("album_id, is_published, COUNT(*) AS count").
+		Where("album_id IN ?", ids).
+		Group("album_id, is_published").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// The first photo of each album stands in for a missing cover.
+	firsts := s.db.Model(&models.Photo{}).
+		Select("DISTINCT ON (album_id) *").
+		Where("album_id IN ?", ids).
+		Order("album_id, uploaded_at, id")
+	if publishedOnly {
+		firsts = firsts.Where("is_published = ?", true)
+	}
+	var candidates []models.Photo
+	if err := firsts.Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	var coverIDs []uint
+	for _, a := range albums {
+		if a.CoverPhotoID != nil {
+			coverIDs = append(coverIDs, *a.CoverPhotoID)
+		}
+	}
+	if len(coverIDs) > 0 {
+		covers := s.db.Where("id IN ?", coverIDs)
+		if publishedOnly {
+			covers = covers.Where("is_published = ?", true)
+		}
+		var chosen []models.Photo
+		if err := covers.Find(&chosen).Error; err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, chosen...)
+	}
+
+	summaries := make([]AlbumSummary, len(albums))
+	for i, a := range albums {
+		summaries[i].Album = a
+		for _, c := range counts {
+			switch {
+			case c.AlbumID != a.ID:
+			case c.IsPublished:
+				summaries[i].Published = c.Count
+			default:
+				summaries[i].Drafts = c.Count
+			}
+		}
+		for j := range candidates {
+			p := &candidates[j]
+			if p.AlbumID != a.ID {
+				continue
+			}
+			if a.CoverPhotoID != nil && p.ID == *a.CoverPhotoID {
+				summaries[i].Cover = p
+				break
+			}
+			if summaries[i].Cover == nil {
+				summaries[i].Cover = p
+			}
+		}
+	}
+	return summaries, nil
+}
+
+// Albums returns every album ordered by title, for choosing where photos go.
+func (s *PhotoService) Albums() ([]models.PhotoAlbum, error) {
+	var albums []models.PhotoAlbum
+	err := s.db.Order("title ASC, id ASC").Find(&albums).Error
+	return albums, err
+}
+
+// GetAlbum returns an album.
+// Returns gorm.ErrRecordNotFound if it does not exist.
+func (s *PhotoService) GetAlbum(id uint) (*models.PhotoAlbum, error) {
+	var album models.PhotoAlbum
+	if err := s.db.First(&album, id).Error; err != nil {
+		return nil, err
+	}
+	return &album, nil
+}
+
+// GetAlbumBySlug returns an album by its slug.
+// Returns gorm.ErrRecordNotFound if it does not exist.
+func (s *PhotoService) GetAlbumBySlug(slug string) (*models.PhotoAlbum, error) {
+	var album models.PhotoAlbum
+	if err := s.db.Where("slug = ?", slug).First(&album).Error; err != nil {
+		return nil, err
+	}
+	return &album, nil
+}
+
+// AlbumPhotos returns the photos in an album in upload order, leaving out
+// drafts when publishedOnly is true.
+func (s *PhotoService) AlbumPhotos(albumID uint, publishedOnly bool) ([]models.Photo, error) {
+	db := s.db.Where("album_id = ?", albumID)
+	if publishedOnly {
+		db = db.Where("is_published = ?", true)
+	}
+
+	var photos []models.Photo
+	err := db.Order("uploaded_at ASC, id ASC").Find(&photos).Error
+	return photos, err
+}
+
+// CreateAlbum validates in and stores a new album created by userID.
+// Returns ValidationErrors for bad input or a slug that is already used.
+func (s *PhotoService) CreateAlbum(in AlbumInput, userID uint) (*models.PhotoAlbum, error) {
+	var album models.PhotoAlbum
+	errs := applyAlbumInput(&album, in)
+
+	slug := strings.ToLower(strings.TrimSpace(in.Slug))
+	switch {
+	case slug == "":
+		errs["slug"] = "Slug is required."
+	case len(slug) > 100:
+		errs["slug"] = "Slug must be 100 characters or fewer."
+	case !slugPattern.MatchString(slug):
+		errs["slug"] = "Use lowercase letters, numbers, and hyphens, e.g. easter-2025."
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	album.Slug = slug
+	album.CreatedBy = &userID
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var taken int64
+		if err := tx.Model(&models.PhotoAlbum{}).Where("slug = ?", slug).Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			return ValidationErrors{"slug": "Another album already uses this slug."}
+		}
+		return tx.Create(&album).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &album, nil
+}
+
+// UpdateAlbum validates in and saves it over an existing album.
+// Returns gorm.ErrRecordNotFound for unknown albums and ValidationErrors for
+// bad input.
+func (s *PhotoService) UpdateAlbum(id uint, in AlbumInput) (*models.PhotoAlbum, error) {
+	album, err := s.GetAlbum(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if errs := applyAlbumInput(album, in); len(errs) > 0 {
+		return nil, errs
+	}
+
+	err = s.db.Model(album).
+		Select("title", "description", "visibility").
+		Updates(album).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return album, nil
+}
+
+// DeleteAlbum removes an album that has no photos.
+// Returns gorm.ErrRecordNotFound if it does not exist and ErrAlbumNotEmpty
+// if photos belong to it.
+func (s *PhotoService) DeleteAlbum(id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var album models.PhotoAlbum
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&album, id).Error; err != nil {
+			return err
+		}
+
+		var photos int64
+		if err := tx.Model(&models.Photo{}).Where("album_id = ?", id).Count(&photos).Error; err != nil {
+			return err
+		}
+		if photos > 0 {
+			return ErrAlbumNotEmpty
+		}
+
+		return tx.Delete(&album).Error
+	})
+}
+
+// applyAlbumInput validates the editable album fields of in and writes them
+// into a, returning the problems found keyed by form field name.
+func applyAlbumInput(a *models.PhotoAlbum, in AlbumInput) ValidationErrors {
+	errs := ValidationErrors{}
+
+	title := strings.TrimSpace(in.Title)
+	switch {
+	case title == "":
+		errs["title"] = "Title is required."
+	case len(title) > 255:
+		errs["title"] = "Title must be 255 characters or fewer."
+	}
+
+	if in.Visibility != models.AudiencePublic && in.Visibility != models.AudienceMembers {
+		errs["visibility"] = "Choose who can see this album."
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	a.Title = title
+	a.Description = strings.TrimSpace(in.Description)
+	a.Visibility = in.Visibility
+	return errs
+}
+
+// Upload processes each file and stores it as a draft photo in the album,
+// uploaded by userID. Files that are not acceptable are skipped, with the
+// reason in their result.
+// Returns ValidationErrors if the album does not exist or no files were
+// given.
+func (s *PhotoService) Upload(albumID string, files []PhotoUpload, userID uint) ([]UploadResult, error) {
+	errs := ValidationErrors{}
+	album, err := s.albumParam(albumID)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		errs["album_id"] = "Choose an album."
+	case err != nil:
+		return nil, err
+	}
+	switch {
+	case len(files) == 0:
+		errs["photos"] = "Choose at least one photo."
+	case len(files) > MaxPhotosPerUpload:
+		errs["photos"] = fmt.Sprintf("Upload at most %d photos at a time.", MaxPhotosPerUpload)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	results := make([]UploadResult, len(files))
+	for i, f := range files {
+		results[i].Name = f.Name
+		if f.File == nil || f.Size <= 0 {
+			results[i].Error = "The file is empty."
+			continue
+		}
+		processed, msg := readPhoto(f.File, f.Size, s.maxUploadSize)
+		if msg != "" {
+			results[i].Error = msg
+			continue
+		}
+
+		photo := models.Photo{AlbumID: album.ID, FileSize: f.Size, UploadedBy: &userID}
+		if err := s.create(&photo, processed); err != nil {
+			return nil, err
+		}
+		results[i].Photo = &photo
+	}
+	return results, nil
+}
+
+// create stores a new photo row and its files, naming the files after the
+// row so OwnedFile can find it again.
+func (s *PhotoService) create(photo *models.Photo, processed *imaging.Processed) error {
+	saved := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(photo).Error; err != nil {
+			return err
+		}
+
+		base := fmt.Sprintf("%d-%d", photo.ID, time.Now().UnixNano())
+		set, err := processed.Save(s.store, "photos", base)
+		if err != nil {
+			return err
+		}
+		saved = true
+
+		largest := set.Largest()
+		photo.PhotoURL = PhotoURLPrefix + largest.Name
+		photo.Srcset = set.Srcset(PhotoURLPrefix)
+		photo.ThumbnailURL = PhotoURLPrefix + set.Thumbnail
+		photo.Width, photo.Height = largest.Width, largest.Height
+		return tx.Model(photo).
+			Select("photo_url", "srcset", "thumbnail_url", "width", "height").
+			Updates(photo).Error
+	})
+	if err != nil && saved {
+		s.removeFiles(*photo)
+	}
+	return err
+}
+
+// GetPhoto returns a photo.
+// Returns gorm.ErrRecordNotFound if it does not exist.
+func (s *PhotoService) GetPhoto(id uint) (*models.Photo, error) {
+	var photo models.Photo
+	if err := s.db.First(&photo, id).Error; err != nil {
+		return nil, err
+	}
+	return &photo, nil
+}
+
+// PhotoInputFrom returns the moderation form values for an existing photo.
+func PhotoInputFrom(p models.Photo) PhotoInput {
+	return PhotoInput{
+		AltText:     p.AltText,
+		Caption:     p.Caption,
+		AlbumID:     strconv.FormatUint(uint64(p.AlbumID), 10),
+		IsPublished: p.IsPublished,
+	}
+}
+
+// UpdatePhoto validates in and saves it over an existing photo. A photo
+// can only be published once it has alt text. Moving the cover of an album
+// to another album leaves the first album without a chosen cover.
+// Returns gorm.ErrRecordNotFound for unknown photos and ValidationErrors for
+// bad input.
+func (s *PhotoService) UpdatePhoto(id uint, in PhotoInput) (*models.Photo, error) {
+	errs := ValidationErrors{}
+
+	altText := strings.TrimSpace(in.AltText)
+	switch {
+	case len(altText) > 500:
+		errs["alt_text"] = "Alt text must be 500 characters or fewer."
+	case altText == "" && in.IsPublished:
+		errs["alt_text"] = "Describe the photo for people who cannot see it before publishing it."
+	}
+
+	caption := strings.TrimSpace(in.Caption)
+	if len(caption) > 2000 {
+		errs["caption"] = "Caption must be 2,000 characters or fewer."
+	}
+
+	album, err := s.albumParam(in.AlbumID)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		errs["album_id"] = "Choose an album."
+	case err != nil:
+		return nil, err
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	var photo models.Photo
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&photo, id).Error; err != nil {
+			return err
+		}
+
+		if photo.AlbumID != album.ID {
+			err := tx.Model(&models.PhotoAlbum{}).
+				Where("id = ? AND cover_photo_id = ?", photo.AlbumID, photo.ID).
+				Update("cover_photo_id", nil).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		photo.AltText = altText
+		photo.Caption = caption
+		photo.AlbumID = album.ID
+		photo.IsPublished = in.IsPublished
+		return tx.Model(&photo).
+			Select("alt_text", "caption", "album_id", "is_published").
+			Updates(&photo).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &photo, nil
+}
+
+// SetCover makes a published photo the cover of its album.
+// Returns gorm.ErrRecordNotFound for unknown photos and ValidationErrors if
+// the photo is a draft.
+func (s *PhotoService) SetCover(id uint) error {
+	photo, err := s.GetPhoto(id)
+	if err != nil {
+		return err
+	}
+	if !photo.IsPublished {
+		return ValidationErrors{"cover": "Publish the photo before making it the album cover."}
+	}
+
+	return s.db.Model(&models.PhotoAlbum{}).
+		Where("id = ?", photo.AlbumID).
+		Update("cover_photo_id", photo.ID).Error
+}
+
+// DeletePhoto removes a photo and its files. An album it was the cover of
+// goes back to showing its first photo.
+// Returns gorm.ErrRecordNotFound if it does not exist.
+func (s *PhotoService) DeletePhoto(id uint) (*models.Photo, error) {
+	photo, err := s.GetPhoto(id)
+	if err != nil {
+		return nil, err
+	}
+
+	result := s.db.Delete(&models.Photo{}, id)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	s.removeFiles(*photo)
+	return photo, nil
+}
+
+// OwnedFile finds the photo that the file name is one of the variants of.
+// Anyone may see published photos in public albums, members those in
+// members-only albums, and admins drafts. Photos can be unpublished or their
+// album restricted later, so they are only cached for an hour, and privately
+// unless public.
+// Returns gorm.ErrRecordNotFound if name is not one of a photo's files.
+func (s *PhotoService) OwnedFile(name string) (*StoredFile, error) {
+	id, ok := fileOwnerID(name)
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	photo, err := s.GetPhoto(id)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(photoURLs(*photo), PhotoURLPrefix+name) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	album, err := s.GetAlbum(photo.AlbumID)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &StoredFile{
+		Name:         "photos/" + name,
+		CacheControl: "private, max-age=3600",
+	}
+	switch {
+	case photo.IsPublished && !album.MembersOnly():
+		file.CacheControl = "public, max-age=3600"
+	case photo.IsPublished:
+		file.CanView = func(claims *SessionClaims) bool {
+			return CanViewAlbum(claims, *album)
+		}
+	default:
+		file.CanView = func(claims *SessionClaims) bool {
+			return claims != nil && claims.HasRole(models.RoleAdmin)
+		}
+	}
+	return file, nil
+}
+
+// albumParam returns the album whose ID is the form value id.
+// Returns gorm.ErrRecordNotFound if there is none.
+func (s *PhotoService) albumParam(id string) (*models.PhotoAlbum, error) {
+	n, err := strconv.ParseUint(strings.TrimSpace(id), 10, 64)
+	if err != nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return s.GetAlbum(uint(n))
+}
+
+// removeFiles deletes every variant of a photo.
+func (s *PhotoService) removeFiles(p models.Photo) {
+	removeUploads(s.store, photoURLs(p), PhotoURLPrefix)
+}
+
+// photoURLs returns the URLs of every stored variant of a photo.
+func photoURLs(p models.Photo) []string {
+	return append(imaging.SrcsetURLs(p.Srcset), p.PhotoURL, p.ThumbnailURL)
+}
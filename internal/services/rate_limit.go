@@ -0,0 +1,31 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RateLimiter implements fixed-window rate limiting with Redis counters.
+// Keys follow the rate:{scope}:{identifier} convention.
+type RateLimiter struct {
+	redis *redis.Client
+}
+
+// NewRateLimiter creates a new RateLimiter.
+func NewRateLimiter(rdb *redis.Client) *RateLimiter {
+	return &RateLimiter{redis: rdb}
+}
+
+// Allow records an attempt against key and reports whether it is within
+// limit attempts per window. The window starts at the first attempt.
+func (l *RateLimiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (bool, error) {
+	pipe := l.redis.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.ExpireNX(ctx, key, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	return incr.Val() <= int64(limit), nil
+}
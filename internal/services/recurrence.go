@@ -0,0 +1,160 @@
+package services
+
+import (
+	"slices"
+	"time"
+
+	"github.com/sfdeloach/churchsite/internal/models"
+)
+
+// ExpandOccurrences returns the occurrences of events that start in [from, to),
+// ordered by start time. A non-recurring event is its own single occurrence.
+// A recurring event yields one copy per date its rule generates, with
+// EventDate and EndDate shifted to that date; exceptions cancel generated
+// dates or move them to a new time. Occurrences keep their series' ID.
+func ExpandOccurrences(events []models.Event, exceptions []models.EventException, from, to time.Time) []models.Event {
+	overridden := make(map[occurrenceKey]bool, len(exceptions))
+	for _, x := range exceptions {
+		overridden[newOccurrenceKey(x.EventID, x.OccurrenceDate)] = true
+	}
+
+	var occurrences []models.Event
+	series := make(map[uint]models.Event)
+	for _, e := range events {
+		if !e.Recurs() {
+			if inWindow(e.EventDate, from, to) {
+				occurrences = append(occurrences, e)
+			}
+			continue
+		}
+
+		series[e.ID] = e
+		for n := firstCandidate(e.EventDate, e.RecurrenceRule, from); ; n++ {
+			start := nthOccurrence(e.EventDate, e.RecurrenceRule, n)
+			if !start.Before(to) || pastRecurrenceEnd(e, start) {
+				break
+			}
+			if start.Before(from) || overridden[newOccurrenceKey(e.ID, start)] {
+				continue
+			}
+			occurrences = append(occurrences, occurrenceAt(e, start, nil))
+		}
+	}
+
+	// Moved occurrences are placed by their new time, which may fall in the
+	// window even when the date they were generated on does not.
+	for _, x := range exceptions {
+		e, ok := series[x.EventID]
+		if !ok || x.IsCancelled || x.NewEventDate == nil || !inWindow(*x.NewEventDate, from, to) {
+			continue
+		}
+		occurrences = append(occurrences, occurrenceAt(e, *x.NewEventDate, x.NewEndDate))
+	}
+
+	slices.SortStableFunc(occurrences, func(a, b models.Event) int {
+		return a.EventDate.Compare(b.EventDate)
+	})
+	return occurrences
+}
+
+// IsOccurrenceDate reports whether e's recurrence rule generates an occurrence
+// on the given day, ignoring exceptions.
+func IsOccurrenceDate(e models.Event, day time.Time) bool {
+	from := StartOfDay(day.In(e.EventDate.Location()))
+	return len(ExpandOccurrences([]models.Event{e}, nil, from, from.AddDate(0, 0, 1))) > 0
+}
+
+// occurrenceKey identifies the occurrence a series generates on one date.
+type occurrenceKey struct {
+	eventID uint
+	date    string
+}
+
+func newOccurrenceKey(eventID uint, date time.Time) occurrenceKey {
+	return occurrenceKey{eventID: eventID, date: date.Format("2006-01-02")}
+}
+
+func inWindow(t, from, to time.Time) bool {
+	return !t.Before(from) && t.Before(to)
+}
+
+// occurrenceAt returns a copy of e starting at start. When end is nil the
+// series' duration is preserved.
+func occurrenceAt(e models.Event, start time.Time, end *time.Time) models.Event {
+	if end == nil && e.EndDate != nil {
+		shifted := start.Add(e.EndDate.Sub(e.EventDate))
+		end = &shifted
+	}
+	e.EventDate = start
+	e.EndDate = end
+	return e
+}
+
+// pastRecurrenceEnd reports whether start falls after the series' last day.
+// RecurrenceEnd is a date, so an occurrence on that day is still included.
+func pastRecurrenceEnd(e models.Event, start time.Time) bool {
+	if e.RecurrenceEnd == nil {
+		return false
+	}
+	y, m, d := e.RecurrenceEnd.Date()
+	last := time.Date(y, m, d, 0, 0, 0, 0, start.Location())
+	return StartOfDay(start).After(last)
+}
+
+// nthOccurrence returns the start of the n-th (zero-based) occurrence of a
+// series beginning at start. Monthly and yearly rules always count from the
+// original date, so an event on the 31st falls on the last day of shorter
+// months and returns to the 31st afterwards instead of drifting earlier.
+func nthOccurrence(start time.Time, rule string, n int) time.Time {
+	switch rule {
+	case models.RecurrenceDaily:
+		return start.AddDate(0, 0, n)
+	case models.RecurrenceWeekly:
+		return start.AddDate(0, 0, 7*n)
+	case models.RecurrenceBiweekly:
+		return start.AddDate(0, 0, 14*n)
+	case models.RecurrenceMonthly:
+		return addMonthsClamped(start, n)
+	case models.RecurrenceYearly:
+		return addMonthsClamped(start, 12*n)
+	default:
+		return start
+	}
+}
+
+// addMonthsClamped adds months to t, clamping the day to the end of the
+// resulting month rather than overflowing into the next one as AddDate does.
+func addMonthsClamped(t time.Time, months int) time.Time {
+	y, m, d := t.Date()
+	first := time.Date(y, m+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	if last := first.AddDate(0, 1, -1).Day(); d > last {
+		d = last
+	}
+	return first.AddDate(0, 0, d-1)
+}
+
+// firstCandidate returns an occurrence index no later than the first
+// occurrence on or after from, letting expansion skip a long series' past.
+func firstCandidate(start time.Time, rule string, from time.Time) int {
+	if !from.After(start) {
+		return 0
+	}
+
+	var n int
+	days := int(from.Sub(start).Hours() / 24)
+	switch rule {
+	case models.RecurrenceDaily:
+		n = days
+	case models.RecurrenceWeekly:
+		n = days / 7
+	case models.RecurrenceBiweekly:
+		n = days / 14
+	case models.RecurrenceMonthly:
+		n = (from.Year()-start.Year())*12 + int(from.Month()-start.Month())
+	case models.RecurrenceYearly:
+		n = from.Year() - start.Year()
+	}
+
+	// Step back one to absorb daylight saving shifts and partial periods.
+	return max(n-1, 0)
+}
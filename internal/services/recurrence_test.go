@@ -0,0 +1,210 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sfdeloach/churchsite/internal/models"
+	"gorm.io/gorm"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 10, 30, 0, 0, time.UTC)
+}
+
+func recurring(rule string, start time.Time) models.Event {
+	return models.Event{Model: gorm.Model{ID: 1}, EventDate: start, IsRecurring: true, RecurrenceRule: rule}
+}
+
+func TestAddMonthsClamped(t *testing.T) {
+	tests := []struct {
+		name   string
+		start  time.Time
+		months int
+		want   time.Time
+	}{
+		{"same month", date(2026, 1, 31), 0, date(2026, 1, 31)},
+		{"31st into February", date(2026, 1, 31), 1, date(2026, 2, 28)},
+		{"31st into leap February", date(2028, 1, 31), 1, date(2028, 2, 29)},
+		{"31st into April", date(2026, 1, 31), 3, date(2026, 4, 30)},
+		{"31st back to March", date(2026, 1, 31), 2, date(2026, 3, 31)},
+		{"30th into February", date(2026, 1, 30), 1, date(2026, 2, 28)},
+		{"29th into February", date(2026, 1, 29), 1, date(2026, 2, 28)},
+		{"28th unchanged", date(2026, 1, 28), 1, date(2026, 2, 28)},
+		{"across year end", date(2026, 12, 31), 2, date(2027, 2, 28)},
+		{"many years", date(2026, 1, 31), 25, date(2028, 2, 29)},
+		{"leap day a year on", date(2028, 2, 29), 12, date(2029, 2, 28)},
+		{"leap day four years on", date(2028, 2, 29), 48, date(2032, 2, 29)},
+		{"backwards", date(2026, 3, 31), -1, date(2026, 2, 28)},
+		{"keeps time of day", time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC), 1, time.Date(2026, 2, 28, 23, 59, 59, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := addMonthsClamped(tt.start, tt.months); !got.Equal(tt.want) {
+				t.Errorf("addMonthsClamped(%v, %d) = %v, want %v", tt.start, tt.months, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsOccurrenceDate(t *testing.T) {
+	monthEnd := recurring(models.RecurrenceMonthly, date(2026, 1, 31))
+	leapDay := recurring(models.RecurrenceYearly, date(2028, 2, 29))
+	weekly := recurring(models.RecurrenceWeekly, date(2026, 3, 2))
+	biweekly := recurring(models.RecurrenceBiweekly, date(2026, 3, 2))
+	ended := recurring(models.RecurrenceWeekly, date(2026, 3, 2))
+	last := time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC)
+	ended.RecurrenceEnd = &last
+
+	tests := []struct {
+		name  string
+		event models.Event
+		day   time.Time
+		want  bool
+	}{
+		{"monthly first", monthEnd, date(2026, 1, 31), true},
+		{"monthly clamped to February 28", monthEnd, date(2026, 2, 28), true},
+		{"monthly not March 3", monthEnd, date(2026, 3, 3), false},
+		{"monthly not March 1", monthEnd, date(2026, 3, 1), false},
+		{"monthly back to the 31st", monthEnd, date(2026, 3, 31), true},
+		{"monthly not March 28", monthEnd, date(2026, 3, 28), false},
+		{"monthly clamped to April 30", monthEnd, date(2026, 4, 30), true},
+		{"monthly leap February 29", monthEnd, date(2028, 2, 29), true},
+		{"monthly not leap February 28", monthEnd, date(2028, 2, 28), false},
+		{"monthly before start", monthEnd, date(2025, 12, 31), false},
+
+		{"yearly leap day", leapDay, date(2028, 2, 29), true},
+		{"yearly February 28 in common year", leapDay, date(2029, 2, 28), true},
+		{"yearly not March 1 in common year", leapDay, date(2029, 3, 1), false},
+		{"yearly leap day again", leapDay, date(2032, 2, 29), true},
+		{"yearly not February 28 in leap year", leapDay, date(2032, 2, 28), false},
+
+		{"weekly", weekly, date(2026, 3, 23), true},
+		{"weekly other day", weekly, date(2026, 3, 24), false},
+		{"biweekly on", biweekly, date(2026, 3, 16), true},
+		{"biweekly off week", biweekly, date(2026, 3, 9), false},
+
+		{"on recurrence end", ended, date(2026, 3, 16), true},
+		{"after recurrence end", ended, date(2026, 3, 23), false},
+
+		{"any time that day", weekly, time.Date(2026, 3, 9, 23, 0, 0, 0, time.UTC), true},
+		{"non-recurring", models.Event{EventDate: date(2026, 3, 2)}, date(2026, 3, 2), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsOccurrenceDate(tt.event, tt.day); got != tt.want {
+				t.Errorf("IsOccurrenceDate(%s from %v, %v) = %v, want %v", tt.event.RecurrenceRule, tt.event.EventDate, tt.day, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandOccurrencesMonthEnd(t *testing.T) {
+	end := date(2026, 1, 31).Add(90 * time.Minute)
+	event := recurring(models.RecurrenceMonthly, date(2026, 1, 31))
+	event.EndDate = &end
+
+	got := ExpandOccurrences([]models.Event{event}, nil, date(2026, 1, 1), date(2026, 7, 1))
+
+	want := []time.Time{
+		date(2026, 1, 31),
+		date(2026, 2, 28),
+		date(2026, 3, 31),
+		date(2026, 4, 30),
+		date(2026, 5, 31),
+		date(2026, 6, 30),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d occurrences, want %d: %v", len(got), len(want), got)
+	}
+	for i, o := range got {
+		if !o.EventDate.Equal(want[i]) {
+			t.Errorf("occurrence %d starts %v, want %v", i, o.EventDate, want[i])
+		}
+		if o.EndDate == nil || o.EndDate.Sub(o.EventDate) != 90*time.Minute {
+			t.Errorf("occurrence %d ends %v, want 90 minutes after it starts", i, o.EndDate)
+		}
+	}
+}
+
+func TestExpandOccurrencesWindowFarFromStart(t *testing.T) {
+	// firstCandidate skips ahead; the occurrences after a long gap must still
+	// land on the clamped dates.
+	event := recurring(models.RecurrenceMonthly, date(2020, 1, 31))
+
+	got := ExpandOccurrences([]models.Event{event}, nil, date(2028, 2, 1), date(2028, 4, 1))
+
+	want := []time.Time{date(2028, 2, 29), date(2028, 3, 31)}
+	if len(got) != len(want) {
+		t.Fatalf("got %d occurrences, want %d: %v", len(got), len(want), got)
+	}
+	for i, o := range got {
+		if !o.EventDate.Equal(want[i]) {
+			t.Errorf("occurrence %d starts %v, want %v", i, o.EventDate, want[i])
+		}
+	}
+}
+
+func TestExpandOccurrencesExceptions(t *testing.T) {
+	event := recurring(models.RecurrenceWeekly, date(2026, 3, 2))
+	moved := date(2026, 3, 18)
+	movedEnd := moved.Add(time.Hour)
+	farMoved := date(2026, 5, 1)
+	exceptions := []models.EventException{
+		{EventID: 1, OccurrenceDate: time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC), IsCancelled: true},
+		{EventID: 1, OccurrenceDate: time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC), NewEventDate: &moved, NewEndDate: &movedEnd},
+		{EventID: 1, OccurrenceDate: time.Date(2026, 3, 23, 0, 0, 0, 0, time.UTC), NewEventDate: &farMoved},
+	}
+
+	got := ExpandOccurrences([]models.Event{event}, exceptions, date(2026, 3, 1), date(2026, 4, 1))
+
+	want := []time.Time{date(2026, 3, 2), date(2026, 3, 18), date(2026, 3, 30)}
+	if len(got) != len(want) {
+		t.Fatalf("got %d occurrences, want %d: %v", len(got), len(want), got)
+	}
+	for i, o := range got {
+		if !o.EventDate.Equal(want[i]) {
+			t.Errorf("occurrence %d starts %v, want %v", i, o.EventDate, want[i])
+		}
+	}
+	if got[1].EndDate == nil || !got[1].EndDate.Equal(movedEnd) {
+		t.Errorf("moved occurrence ends %v, want %v", got[1].EndDate, movedEnd)
+	}
+
+	// An occurrence moved into a window its original date is outside of
+	// appears there.
+	got = ExpandOccurrences([]models.Event{event}, exceptions, date(2026, 4, 30), date(2026, 5, 2))
+	if len(got) != 1 || !got[0].EventDate.Equal(farMoved) {
+		t.Errorf("got %v, want one occurrence at %v", got, farMoved)
+	}
+}
+
+func TestOccurrenceMoveInputFrom(t *testing.T) {
+	start := time.Date(2026, 3, 2, 10, 30, 0, 0, time.Local)
+	end := start.Add(time.Hour)
+	event := models.Event{Model: gorm.Model{ID: 1}, EventDate: start, EndDate: &end, IsRecurring: true, RecurrenceRule: models.RecurrenceWeekly}
+	generated := start.AddDate(0, 0, 7)
+	moved := time.Date(2026, 3, 10, 19, 0, 0, 0, time.Local)
+	movedEnd := moved.Add(2 * time.Hour)
+
+	tests := []struct {
+		name string
+		o    ScheduledOccurrence
+		want OccurrenceMoveInput
+	}{
+		{"as generated", ScheduledOccurrence{Start: generated}, OccurrenceMoveInput{Start: "2026-03-09T10:30", End: "2026-03-09T11:30"}},
+		{"cancelled", ScheduledOccurrence{Start: generated, Exception: &models.EventException{IsCancelled: true}}, OccurrenceMoveInput{Start: "2026-03-09T10:30", End: "2026-03-09T11:30"}},
+		{"moved keeping duration", ScheduledOccurrence{Start: generated, Exception: &models.EventException{NewEventDate: &moved}}, OccurrenceMoveInput{Start: "2026-03-10T19:00", End: "2026-03-10T20:00"}},
+		{"moved with end", ScheduledOccurrence{Start: generated, Exception: &models.EventException{NewEventDate: &moved, NewEndDate: &movedEnd}}, OccurrenceMoveInput{Start: "2026-03-10T19:00", End: "2026-03-10T21:00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := OccurrenceMoveInputFrom(event, tt.o); got != tt.want {
+				t.Errorf("OccurrenceMoveInputFrom() = %+v, want %+v", got, tt.want)
+			}
+			if tt.o.Date() != "2026-03-09" {
+				t.Errorf("Date() = %q, want 2026-03-09", tt.o.Date())
+			}
+		})
+	}
+}
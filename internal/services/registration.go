@@ -0,0 +1,425 @@
+package services
+
+import (
+	"errors"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sfdeloach/churchsite/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxPartySize caps number_attending on a single registration.
+const maxPartySize = 10
+
+var (
+	ErrRegistrationClosed = errors.New("registration is closed for this event")
+	ErrAlreadyRegistered  = errors.New("already registered for this event")
+)
+
+// RegistrationInput holds the fields submitted when registering for an event.
+// Members set UserID and may bring people from their household, including
+// children without logins; guests provide a name and email instead.
+type RegistrationInput struct {
+	UserID             *uint
+	HouseholdMemberIDs []uint
+	GuestName          string
+	GuestEmail         string
+	GuestPhone         string
+	NumberAttending    int
+	SpecialNeeds       string
+}
+
+// Availability summarizes how many seats an event has left.
+type Availability struct {
+	Capacity  *int // nil when unlimited
+	Confirmed int  // seats taken by confirmed registrations
+	Waitlist  int  // registrations waiting for a seat
+}
+
+// Remaining returns the number of open seats, or -1 when capacity is unlimited.
+func (a Availability) Remaining() int {
+	if a.Capacity == nil {
+		return -1
+	}
+	return max(*a.Capacity-a.Confirmed, 0)
+}
+
+// Promotion is a waitlisted registration that was given a seat, with the new
+// plain cancel token to include in its notification.
+type Promotion struct {
+	Registration models.EventRegistration
+	CancelToken  string
+}
+
+// RegistrationService handles event sign-ups, capacity, and the waitlist.
+type RegistrationService struct {
+	db *gorm.DB
+}
+
+// NewRegistrationService creates a new RegistrationService.
+func NewRegistrationService(db *gorm.DB) *RegistrationService {
+	return &RegistrationService{db: db}
+}
+
+// GetAvailability returns seat counts for an event.
+func (s *RegistrationService) GetAvailability(event models.Event) (Availability, error) {
+	a := Availability{Capacity: event.CapacityLimit}
+
+	confirmed, err := confirmedSeats(s.db, event.ID)
+	if err != nil {
+		return a, err
+	}
+	a.Confirmed = confirmed
+
+	var waitlist int64
+	err = s.db.Model(&models.EventRegistration{}).
+		Where("event_id = ? AND status = ?", event.ID, models.RegistrationWaitlisted).
+		Count(&waitlist).Error
+	a.Waitlist = int(waitlist)
+
+	return a, err
+}
+
+// GetForUser returns the user's registration for an event, including a
+// cancelled one, with the household members it includes loaded.
+// Returns gorm.ErrRecordNotFound if they never registered.
+func (s *RegistrationService) GetForUser(eventID, userID uint) (*models.EventRegistration, error) {
+	var reg models.EventRegistration
+
+	err := s.db.
+		Preload("Attendees.HouseholdMember.User").
+		Where("event_id = ? AND user_id = ?", eventID, userID).
+		First(&reg).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &reg, nil
+}
+
+// GetByCancelToken returns the active registration owning a plain cancel
+// token, with its event and user loaded. Returns ErrInvalidToken if none matches.
+func (s *RegistrationService) GetByCancelToken(token string) (*models.EventRegistration, error) {
+	if token == "" {
+		return nil, ErrInvalidToken
+	}
+
+	var reg models.EventRegistration
+	err := s.db.
+		Preload("Event").
+		Preload("User").
+		Where("cancel_token = ? AND status IN ?", HashToken(token), []string{models.RegistrationConfirmed, models.RegistrationWaitlisted}).
+		First(&reg).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &reg, nil
+}
+
+// Register signs a member or guest up for a publicly visible event and
+// returns the registration with its event and user loaded, plus the plain
+// cancel token to be emailed. The event row is locked while seats are
+// counted, so concurrent registrations cannot oversell it; a party that does
+// not fit, or that arrives while others are waiting, is waitlisted. A member
+// who previously cancelled is re-registered at the back of the queue.
+// Returns ValidationErrors for bad input, gorm.ErrRecordNotFound for unknown
+// events, ErrRegistrationClosed, and ErrAlreadyRegistered.
+func (s *RegistrationService) Register(eventID uint, in RegistrationInput) (*models.EventRegistration, string, error) {
+	in.GuestName = strings.TrimSpace(in.GuestName)
+	in.GuestEmail = NormalizeEmail(in.GuestEmail)
+	in.GuestPhone = strings.TrimSpace(in.GuestPhone)
+	in.SpecialNeeds = strings.TrimSpace(in.SpecialNeeds)
+
+	in.HouseholdMemberIDs = slices.Compact(slices.Sorted(slices.Values(in.HouseholdMemberIDs)))
+
+	errs := ValidationErrors{}
+	switch {
+	case in.NumberAttending < 1 || in.NumberAttending > maxPartySize:
+		errs["number_attending"] = "Enter a number of attendees between 1 and 10."
+	case in.NumberAttending < 1+len(in.HouseholdMemberIDs):
+		errs["number_attending"] = "The number attending must include you and everyone selected from your household."
+	}
+	if in.UserID == nil && len(in.HouseholdMemberIDs) > 0 {
+		errs["attendees"] = "Log in to register members of your household."
+	}
+	if in.UserID == nil {
+		if in.GuestName == "" {
+			errs["guest_name"] = "Name is required."
+		}
+		if _, err := mail.ParseAddress(in.GuestEmail); err != nil || in.GuestEmail == "" {
+			errs["guest_email"] = "Enter a valid email address."
+		}
+	}
+	if len(errs) > 0 {
+		return nil, "", errs
+	}
+
+	token, err := GenerateToken()
+	if err != nil {
+		return nil, "", err
+	}
+	tokenHash := HashToken(token)
+
+	var reg models.EventRegistration
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		var event models.Event
+		err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Scopes(publiclyVisible(now)).
+			First(&event, eventID).Error
+		if err != nil {
+			return err
+		}
+		if !event.RegistrationOpen(now) {
+			return ErrRegistrationClosed
+		}
+
+		existing := tx.Where("event_id = ?", eventID)
+		if in.UserID != nil {
+			existing = existing.Where("user_id = ?", *in.UserID)
+		} else {
+			existing = existing.Where("user_id IS NULL AND guest_email = ?", in.GuestEmail)
+		}
+		err = existing.Order("registered_at DESC").First(&reg).Error
+		switch {
+		case err == nil && reg.IsActive():
+			return ErrAlreadyRegistered
+		case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+
+		status := models.RegistrationConfirmed
+		if event.CapacityLimit != nil {
+			confirmed, err := confirmedSeats(tx, eventID)
+			if err != nil {
+				return err
+			}
+			var waiting int64
+			err = tx.Model(&models.EventRegistration{}).
+				Where("event_id = ? AND status = ?", eventID, models.RegistrationWaitlisted).
+				Count(&waiting).Error
+			if err != nil {
+				return err
+			}
+			if joinsWaitlist(event.CapacityLimit, confirmed, int(waiting), in.NumberAttending) {
+				status = models.RegistrationWaitlisted
+			}
+		}
+
+		reg.EventID = eventID
+		reg.UserID = in.UserID
+		reg.GuestName = in.GuestName
+		reg.GuestEmail = in.GuestEmail
+		reg.GuestPhone = in.GuestPhone
+		reg.NumberAttending = in.NumberAttending
+		reg.SpecialNeeds = in.SpecialNeeds
+		reg.Status = status
+		reg.CancelToken = &tokenHash
+		reg.RegisteredAt = now
+		reg.CancelledAt = nil
+		if err := tx.Omit("Attendees").Save(&reg).Error; err != nil {
+			return err
+		}
+		if in.UserID != nil {
+			if err := saveAttendees(tx, reg.ID, *in.UserID, in.HouseholdMemberIDs); err != nil {
+				return err
+			}
+		}
+
+		reg.Event = &event
+		if in.UserID != nil {
+			var user models.User
+			if err := tx.First(&user, *in.UserID).Error; err != nil {
+				return err
+			}
+			reg.User = &user
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &reg, token, nil
+}
+
+// CancelByToken cancels the registration owning a plain cancel token and
+// promotes waitlisted registrations into the freed seats.
+// Returns ErrInvalidToken if no active registration matches.
+func (s *RegistrationService) CancelByToken(token string) (*models.EventRegistration, []Promotion, error) {
+	reg, err := s.GetByCancelToken(token)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.cancel(reg)
+}
+
+// CancelForUser cancels a member's registration for an event and promotes
+// waitlisted registrations into the freed seats.
+// Returns gorm.ErrRecordNotFound if the member has no active registration.
+func (s *RegistrationService) CancelForUser(eventID, userID uint) (*models.EventRegistration, []Promotion, error) {
+	reg, err := s.GetForUser(eventID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !reg.IsActive() {
+		return nil, nil, gorm.ErrRecordNotFound
+	}
+	return s.cancel(reg)
+}
+
+// cancel marks reg cancelled and, with the event row locked, promotes the
+// waitlist into the freed seats.
+func (s *RegistrationService) cancel(reg *models.EventRegistration) (*models.EventRegistration, []Promotion, error) {
+	var promotions []Promotion
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var event models.Event
+		err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&event, reg.EventID).Error
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		result := tx.Model(&models.EventRegistration{}).
+			Where("id = ? AND status IN ?", reg.ID, []string{models.RegistrationConfirmed, models.RegistrationWaitlisted}).
+			Updates(map[string]any{"status": models.RegistrationCancelled, "cancelled_at": now, "cancel_token": nil})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidToken
+		}
+		reg.Status = models.RegistrationCancelled
+		reg.CancelledAt = &now
+		reg.Event = &event
+
+		promotions, err = promoteWaitlist(tx, event)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return reg, promotions, nil
+}
+
+// promoteWaitlist confirms waitlisted registrations for event in
+// registration order for as long as the next party fits, and returns them
+// with their new cancel tokens. The caller must hold the event row lock.
+func promoteWaitlist(tx *gorm.DB, event models.Event) ([]Promotion, error) {
+	var waitlist []models.EventRegistration
+	err := tx.
+		Preload("User").
+		Where("event_id = ? AND status = ?", event.ID, models.RegistrationWaitlisted).
+		Order("registered_at ASC, id ASC").
+		Find(&waitlist).Error
+	if err != nil {
+		return nil, err
+	}
+
+	confirmed, err := confirmedSeats(tx, event.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var promotions []Promotion
+	for _, next := range waitlist[:promotable(waitlist, event.CapacityLimit, confirmed)] {
+		token, err := GenerateToken()
+		if err != nil {
+			return nil, err
+		}
+		tokenHash := HashToken(token)
+
+		err = tx.Model(&next).Updates(map[string]any{
+			"status":       models.RegistrationConfirmed,
+			"cancel_token": tokenHash,
+		}).Error
+		if err != nil {
+			return nil, err
+		}
+
+		next.Status = models.RegistrationConfirmed
+		next.CancelToken = &tokenHash
+		next.Event = &event
+		promotions = append(promotions, Promotion{Registration: next, CancelToken: token})
+	}
+	return promotions, nil
+}
+
+// promotable returns how many registrations at the front of waitlist fit
+// into capacity alongside the confirmed seats. It stops at the first party
+// that does not fit so that earlier registrants are never skipped.
+func promotable(waitlist []models.EventRegistration, capacity *int, confirmed int) int {
+	for i, next := range waitlist {
+		if capacity != nil && confirmed+next.NumberAttending > *capacity {
+			return i
+		}
+		confirmed += next.NumberAttending
+	}
+	return len(waitlist)
+}
+
+// joinsWaitlist reports whether a new party must wait: when it does not fit,
+// or when earlier parties are already waiting, so it cannot take a seat
+// ahead of them.
+func joinsWaitlist(capacity *int, confirmed, waiting, party int) bool {
+	if capacity == nil {
+		return false
+	}
+	return waiting > 0 || confirmed+party > *capacity
+}
+
+// saveAttendees replaces the household members included in a registration.
+// Every member must belong to the registering user's household and must not
+// be the user themself.
+// Returns ValidationErrors otherwise.
+func saveAttendees(tx *gorm.DB, registrationID, userID uint, memberIDs []uint) error {
+	if err := tx.Where("registration_id = ?", registrationID).Delete(&models.EventRegistrationAttendee{}).Error; err != nil {
+		return err
+	}
+	if len(memberIDs) == 0 {
+		return nil
+	}
+
+	var count int64
+	err := tx.Model(&models.HouseholdMember{}).
+		Where("id IN ?", memberIDs).
+		Where("user_id IS DISTINCT FROM ?", userID).
+		Where("household_id = (SELECT household_id FROM household_members WHERE user_id = ?)", userID).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if int(count) != len(memberIDs) {
+		return ValidationErrors{"attendees": "Choose people from your own household."}
+	}
+
+	attendees := make([]models.EventRegistrationAttendee, 0, len(memberIDs))
+	for _, id := range memberIDs {
+		attendees = append(attendees, models.EventRegistrationAttendee{RegistrationID: registrationID, HouseholdMemberID: id})
+	}
+	return tx.Create(&attendees).Error
+}
+
+// confirmedSeats returns the number of seats held by confirmed registrations.
+func confirmedSeats(db *gorm.DB, eventID uint) (int, error) {
+	var seats int
+	err := db.Model(&models.EventRegistration{}).
+		Where("event_id = ? AND status = ?", eventID, models.RegistrationConfirmed).
+		Select("COALESCE(SUM(number_attending), 0)").
+		Scan(&seats).Error
+	return seats, err
+}
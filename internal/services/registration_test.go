@@ -0,0 +1,70 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/sfdeloach/churchsite/internal/models"
+)
+
+func capacity(n int) *int { return &n }
+
+func parties(sizes ...int) []models.EventRegistration {
+	regs := make([]models.EventRegistration, len(sizes))
+	for i, n := range sizes {
+		regs[i] = models.EventRegistration{NumberAttending: n, Status: models.RegistrationWaitlisted}
+	}
+	return regs
+}
+
+func TestJoinsWaitlist(t *testing.T) {
+	tests := []struct {
+		name      string
+		capacity  *int
+		confirmed int
+		waiting   int
+		party     int
+		want      bool
+	}{
+		{"unlimited", nil, 500, 0, 10, false},
+		{"fits", capacity(10), 6, 0, 4, false},
+		{"does not fit", capacity(10), 6, 0, 5, true},
+		{"full", capacity(10), 10, 0, 1, true},
+		// A small party must not take a seat ahead of a larger party that
+		// is already waiting for one.
+		{"fits behind a waitlist", capacity(10), 6, 1, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := joinsWaitlist(tt.capacity, tt.confirmed, tt.waiting, tt.party); got != tt.want {
+				t.Errorf("joinsWaitlist(%v, %d, %d, %d) = %v, want %v", tt.capacity, tt.confirmed, tt.waiting, tt.party, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPromotable(t *testing.T) {
+	tests := []struct {
+		name      string
+		waitlist  []models.EventRegistration
+		capacity  *int
+		confirmed int
+		want      int
+	}{
+		{"empty waitlist", nil, capacity(10), 4, 0},
+		{"one seat freed", parties(1, 1), capacity(10), 9, 1},
+		{"all fit", parties(2, 3), capacity(10), 5, 2},
+		{"stops at the first that does not fit", parties(4, 1), capacity(10), 7, 0},
+		{"stops partway", parties(2, 4, 1), capacity(10), 5, 1},
+		// Raising capacity from 10 to 15 with 10 confirmed and parties of
+		// 3, 2, and 2 waiting seats the first two.
+		{"capacity raised", parties(3, 2, 2), capacity(15), 10, 2},
+		{"capacity removed", parties(3, 2, 2), nil, 10, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := promotable(tt.waitlist, tt.capacity, tt.confirmed); got != tt.want {
+				t.Errorf("promotable() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,338 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sfdeloach/churchsite/internal/models"
+	"github.com/sfdeloach/churchsite/internal/textdiff"
+	"gorm.io/gorm"
+)
+
+// ErrUnknownRevisionType is returned for entity types that have no revisions.
+var ErrUnknownRevisionType = errors.New("unknown revision type")
+
+// RevisionTypes names the kinds of content that keep revisions.
+var RevisionTypes = map[string]string{
+	models.RevisionMinistryPage: "Ministry page",
+	models.RevisionAnnouncement: "Announcement",
+	models.RevisionStaffMember:  "Staff member",
+}
+
+// RevisionHistory is the list of changes made to one piece of content,
+// newest first.
+type RevisionHistory struct {
+	EntityType string
+	EntityID   uint
+	Title      string // e.g. the ministry name or announcement title
+	EditPath   string // the staff page for editing the content
+	Revisions  []models.ContentRevision
+}
+
+// RevisionField is one field of the content compared before and after a
+// change.
+type RevisionField struct {
+	Label string
+	Rows  []textdiff.Row
+}
+
+// Changed reports whether the field differs before and after the change.
+func (f RevisionField) Changed() bool {
+	return textdiff.Changed(f.Rows)
+}
+
+// RevisionComparison shows what one change did: the content saved in the
+// revision against the content that replaced it, which is the next revision
+// or, for the latest change, the current content.
+type RevisionComparison struct {
+	Revision models.ContentRevision
+	Title    string
+	EditPath string
+	Latest   bool
+	Fields   []RevisionField
+}
+
+// RevisionService lists, compares, and restores earlier versions of
+// editable content. Revisions are recorded by the services that update the
+// content, inside the same transaction.
+type RevisionService struct {
+	db            *gorm.DB
+	ministries    *MinistryService
+	announcements *AnnouncementService
+	staffMembers  *StaffMemberService
+}
+
+// NewRevisionService creates a new RevisionService.
+func NewRevisionService(db *gorm.DB, ministries *MinistryService, announcements *AnnouncementService, staffMembers *StaffMemberService) *RevisionService {
+	return &RevisionService{db: db, ministries: ministries, announcements: announcements, staffMembers: staffMembers}
+}
+
+// recordRevision saves before as a revision of the entity, changed by
+// authorID. Nothing is recorded when after is the same as before.
+func recordRevision(tx *gorm.DB, entityType string, entityID uint, before, after any, authorID uint) error {
+	old, err := json.Marshal(before)
+	if err != nil {
+		return err
+	}
+	updated, err := json.Marshal(after)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(old, updated) {
+		return nil
+	}
+
+	revision := models.ContentRevision{
+		EntityType: entityType,
+		EntityID:   entityID,
+		Content:    string(old),
+	}
+	if authorID != 0 {
+		revision.AuthorID = &authorID
+	}
+	return tx.Create(&revision).Error
+}
+
+// History returns the changes made to an entity, newest first.
+// Returns ErrUnknownRevisionType for unsupported types and
+// gorm.ErrRecordNotFound if the entity does not exist.
+func (s *RevisionService) History(entityType string, entityID uint) (*RevisionHistory, error) {
+	title, editPath, _, err := s.current(entityType, entityID)
+	if err != nil {
+		return nil, err
+	}
+
+	history := RevisionHistory{EntityType: entityType, EntityID: entityID, Title: title, EditPath: editPath}
+	err = s.db.
+		Preload("Author").
+		Where("entity_type = ? AND entity_id = ?", entityType, entityID).
+		Order("created_at DESC, id DESC").
+		Find(&history.Revisions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &history, nil
+}
+
+// Compare returns the content before and after the change recorded in a
+// revision, field by field.
+// Returns gorm.ErrRecordNotFound for unknown revisions or deleted content.
+func (s *RevisionService) Compare(id uint) (*RevisionComparison, error) {
+	var revision models.ContentRevision
+	if err := s.db.Preload("Author").First(&revision, id).Error; err != nil {
+		return nil, err
+	}
+
+	title, editPath, current, err := s.current(revision.EntityType, revision.EntityID)
+	if err != nil {
+		return nil, err
+	}
+
+	comparison := RevisionComparison{Revision: revision, Title: title, EditPath: editPath}
+
+	after := current
+	var next models.ContentRevision
+	err = s.db.
+		Where("entity_type = ? AND entity_id = ? AND id > ?", revision.EntityType, revision.EntityID, revision.ID).
+		Order("id ASC").
+		First(&next).Error
+	switch {
+	case err == nil:
+		after = []byte(next.Content)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		comparison.Latest = true
+	default:
+		return nil, err
+	}
+
+	beforeFields, err := s.revisionFields(revision.EntityType, []byte(revision.Content))
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := s.revisionFields(revision.EntityType, after)
+	if err != nil {
+		return nil, err
+	}
+	for i, field := range beforeFields {
+		comparison.Fields = append(comparison.Fields, RevisionField{
+			Label: field.label,
+			Rows:  textdiff.SideBySide(textdiff.Lines(field.text), textdiff.Lines(afterFields[i].text)),
+		})
+	}
+
+	return &comparison, nil
+}
+
+// Restore puts the content saved in a revision back, through the owning
+// service so it is validated and the replaced content is itself kept as a
+// revision.
+// Returns gorm.ErrRecordNotFound for unknown revisions or deleted content and
+// ValidationErrors if the old content is no longer valid.
+func (s *RevisionService) Restore(id, editorID uint) (*models.ContentRevision, error) {
+	var revision models.ContentRevision
+	if err := s.db.First(&revision, id).Error; err != nil {
+		return nil, err
+	}
+
+	switch revision.EntityType {
+	case models.RevisionMinistryPage:
+		var old ministryPageRevision
+		if err := json.Unmarshal([]byte(revision.Content), &old); err != nil {
+			return nil, err
+		}
+		if _, err := s.ministries.UpdatePageContent(revision.EntityID, old.PageContent, editorID); err != nil {
+			return nil, err
+		}
+	case models.RevisionAnnouncement:
+		var old announcementRevision
+		if err := json.Unmarshal([]byte(revision.Content), &old); err != nil {
+			return nil, err
+		}
+		in := AnnouncementInput{
+			Title:        old.Title,
+			Content:      old.Content,
+			Audience:     old.Audience,
+			Priority:     old.Priority,
+			VisibleFrom:  formatOptional(old.VisibleFrom, DateTimeInputLayout),
+			VisibleUntil: formatOptional(old.VisibleUntil, DateTimeInputLayout),
+			IsActive:     old.IsActive,
+		}
+		if _, err := s.announcements.Update(revision.EntityID, in, editorID); err != nil {
+			return nil, err
+		}
+	case models.RevisionStaffMember:
+		var old staffMemberRevision
+		if err := json.Unmarshal([]byte(revision.Content), &old); err != nil {
+			return nil, err
+		}
+		// Headshots are not kept in revisions, so the current one stays.
+		in := StaffMemberInput{
+			Name:     old.Name,
+			Title:    old.Title,
+			Bio:      old.Bio,
+			Email:    old.Email,
+			Phone:    old.Phone,
+			Category: old.Category,
+			IsActive: old.IsActive,
+		}
+		if _, err := s.staffMembers.Update(revision.EntityID, in, editorID); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrUnknownRevisionType
+	}
+
+	return &revision, nil
+}
+
+// current returns an entity's title, edit page, and current content in the
+// same JSON form its revisions are stored in.
+func (s *RevisionService) current(entityType string, entityID uint) (title, editPath string, content []byte, err error) {
+	switch entityType {
+	case models.RevisionMinistryPage:
+		var ministry models.Ministry
+		if err := s.db.First(&ministry, entityID).Error; err != nil {
+			return "", "", nil, err
+		}
+		content, err = json.Marshal(ministryPageRevision{PageContent: ministry.PageContent})
+		return ministry.Name, "/staff/ministry/" + ministry.Slug, content, err
+	case models.RevisionAnnouncement:
+		var announcement models.Announcement
+		if err := s.db.First(&announcement, entityID).Error; err != nil {
+			return "", "", nil, err
+		}
+		content, err = json.Marshal(announcementRevisionOf(announcement))
+		return announcement.Title, fmt.Sprintf("/staff/announcements/%d/edit", announcement.ID), content, err
+	case models.RevisionStaffMember:
+		var member models.StaffMember
+		if err := s.db.First(&member, entityID).Error; err != nil {
+			return "", "", nil, err
+		}
+		content, err = json.Marshal(staffMemberRevisionOf(member))
+		return member.Name, fmt.Sprintf("/staff/about/staff/%d/edit", member.ID), content, err
+	default:
+		return "", "", nil, ErrUnknownRevisionType
+	}
+}
+
+// revisionField is one field of a stored revision as text to compare.
+type revisionField struct {
+	label string
+	text  string
+}
+
+// revisionFields decodes stored content into the fields shown when comparing
+// revisions. Every revision of a type yields the same fields in the same
+// order.
+func (s *RevisionService) revisionFields(entityType string, content []byte) ([]revisionField, error) {
+	switch entityType {
+	case models.RevisionMinistryPage:
+		var r ministryPageRevision
+		if err := json.Unmarshal(content, &r); err != nil {
+			return nil, err
+		}
+		return []revisionField{{"Page content", htmlLines(r.PageContent)}}, nil
+	case models.RevisionAnnouncement:
+		var r announcementRevision
+		if err := json.Unmarshal(content, &r); err != nil {
+			return nil, err
+		}
+		const layout = "Jan 2, 2006 3:04 PM"
+		return []revisionField{
+			{"Title", r.Title},
+			{"Content", r.Content},
+			{"Audience", r.Audience},
+			{"Priority", r.Priority},
+			{"Publish at", formatOptional(r.VisibleFrom, layout)},
+			{"Expire at", formatOptional(r.VisibleUntil, layout)},
+			{"Active", yesNo(r.IsActive)},
+		}, nil
+	case models.RevisionStaffMember:
+		var r staffMemberRevision
+		if err := json.Unmarshal(content, &r); err != nil {
+			return nil, err
+		}
+		// Show the category's current label; its slug never changes.
+		category := r.Category
+		var info models.StaffCategoryInfo
+		err := s.db.Where("slug = ?", r.Category).First(&info).Error
+		switch {
+		case err == nil:
+			category = info.Label
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, err
+		}
+		return []revisionField{
+			{"Name", r.Name},
+			{"Title", r.Title},
+			{"Bio", r.Bio},
+			{"Email", r.Email},
+			{"Phone", r.Phone},
+			{"Category", category},
+			{"Active", yesNo(r.IsActive)},
+		}, nil
+	default:
+		return nil, ErrUnknownRevisionType
+	}
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "Yes"
+	}
+	return "No"
+}
+
+// blockEnd matches the end of block-level HTML so page content can be
+// compared a paragraph at a time.
+var blockEnd = regexp.MustCompile(`(?i)(</(?:p|h[1-6]|li|ul|ol|blockquote)>|<br\s*/?>|<hr\s*/?>)\s*`)
+
+// htmlLines puts each block of HTML on its own line.
+func htmlLines(s string) string {
+	return strings.TrimSpace(blockEnd.ReplaceAllString(s, "$1\n"))
+}
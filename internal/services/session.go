@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+	"github.com/sfdeloach/churchsite/internal/models"
+)
+
+// ErrInvalidSession is returned when a session token is malformed, expired,
+// forged, or has been revoked.
+var ErrInvalidSession = errors.New("invalid session")
+
+// SessionClaims are the JWT claims carried in the session cookie.
+// The token ID (jti) is used as the key for server-side revocation.
+// IssuedAtNano repeats iat at nanosecond resolution, which iat lacks, so a
+// session issued in the same second as a revocation of all the user's
+// sessions is told apart from the ones it revoked.
+type SessionClaims struct {
+	UserID       uint     `json:"user_id"`
+	Email        string   `json:"email"`
+	Roles        []string `json:"roles"`
+	IssuedAtNano int64    `json:"iat_ns"`
+	jwt.RegisteredClaims
+}
+
+// HasRole reports whether the claims include the named role. The admin role
+// has access to all resources, so it satisfies every check.
+func (c *SessionClaims) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role || r == models.RoleAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAnyRole reports whether the claims include at least one of the named roles.
+func (c *SessionClaims) HasAnyRole(roles ...string) bool {
+	for _, role := range roles {
+		if c.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAllRoles reports whether the claims include every one of the named roles.
+func (c *SessionClaims) HasAllRoles(roles ...string) bool {
+	for _, role := range roles {
+		if !c.HasRole(role) {
+			return false
+		}
+	}
+	return true
+}
+
+// SessionService issues, parses, and revokes signed session tokens.
+// Revoked token IDs are kept in Redis under blacklist:{jti} until the token
+// would have expired anyway. Revoking every session for a user records the
+// revocation time in Unix nanoseconds under revoked:user:{id}; tokens issued
+// before it are rejected.
+type SessionService struct {
+	secret []byte
+	ttl    time.Duration
+	redis  *redis.Client
+}
+
+// NewSessionService creates a new SessionService that signs tokens with secret
+// and issues them with the given lifetime.
+func NewSessionService(secret string, ttl time.Duration, rdb *redis.Client) *SessionService {
+	return &SessionService{
+		secret: []byte(secret),
+		ttl:    ttl,
+		redis:  rdb,
+	}
+}
+
+// Issue returns a signed HS256 token for the user and its expiry time.
+func (s *SessionService) Issue(user *models.User, roles []string) (string, time.Time, error) {
+	jti, err := GenerateToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	expires := now.Add(s.ttl)
+
+	claims := SessionClaims{
+		UserID:       user.ID,
+		Email:        user.Email,
+		Roles:        roles,
+		IssuedAtNano: now.UnixNano(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   strconv.FormatUint(uint64(user.ID), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expires),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secret)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expires, nil
+}
+
+// Parse validates a signed token, checks that it has not been revoked, and
+// returns its claims. Returns ErrInvalidSession if the token cannot be trusted.
+// A Redis failure is returned as-is so callers can distinguish an outage from
+// a bad token.
+func (s *SessionService) Parse(ctx context.Context, token string) (*SessionClaims, error) {
+	claims := &SessionClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		return s.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil || claims.ID == "" {
+		return nil, ErrInvalidSession
+	}
+
+	vals, err := s.redis.MGet(ctx, blacklistKey(claims.ID), userRevokedKey(claims.UserID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if vals[0] != nil {
+		return nil, ErrInvalidSession
+	}
+	if revokedAt, ok := vals[1].(string); ok {
+		if ts, err := strconv.ParseInt(revokedAt, 10, 64); err == nil && claims.IssuedAtNano < ts {
+			return nil, ErrInvalidSession
+		}
+	}
+
+	return claims, nil
+}
+
+// Revoke blacklists the token's jti until its original expiry.
+func (s *SessionService) Revoke(ctx context.Context, claims *SessionClaims) error {
+	ttl := time.Until(claims.ExpiresAt.Time)
+	if ttl <= 0 {
+		return nil
+	}
+	return s.redis.Set(ctx, blacklistKey(claims.ID), 1, ttl).Err()
+}
+
+// RevokeAllForUser invalidates every token issued to the user up to now.
+// The marker lives for one token lifetime, after which all older tokens
+// have expired on their own.
+func (s *SessionService) RevokeAllForUser(ctx context.Context, userID uint) error {
+	return s.redis.Set(ctx, userRevokedKey(userID), time.Now().UnixNano(), s.ttl).Err()
+}
+
+func blacklistKey(jti string) string {
+	return "blacklist:" + jti
+}
+
+func userRevokedKey(userID uint) string {
+	return "revoked:user:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+type sessionContextKey struct{}
+
+// WithCurrentUser returns a copy of ctx carrying the authenticated user's claims.
+func WithCurrentUser(ctx context.Context, claims *SessionClaims) context.Context {
+	return context.WithValue(ctx, sessionContextKey{}, claims)
+}
+
+// CurrentUser returns the authenticated user's claims from ctx, or nil for
+// anonymous requests. Templ components can call this with their implicit ctx.
+func CurrentUser(ctx context.Context) *SessionClaims {
+	claims, _ := ctx.Value(sessionContextKey{}).(*SessionClaims)
+	return claims
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/sfdeloach/churchsite/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrStaffCategoryInUse is returned when deleting a category that staff
+// members still belong to.
+var ErrStaffCategoryInUse = errors.New("staff category in use")
+
+// slugPattern matches lowercase words joined by hyphens.
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// StaffCategoryInput holds the fields submitted on the admin category form.
+// Slug is only used when creating; it is fixed afterwards.
+type StaffCategoryInput struct {
+	Label string
+	Slug  string
+}
+
+// StaffCategoryService handles the categories staff members are grouped
+// under, such as "Teaching Elders" and "Deacons".
+type StaffCategoryService struct {
+	db *gorm.DB
+}
+
+// NewStaffCategoryService creates a new StaffCategoryService.
+func NewStaffCategoryService(db *gorm.DB) *StaffCategoryService {
+	return &StaffCategoryService{db: db}
+}
+
+// Ordered returns every category sorted by display order, then label.
+func (s *StaffCategoryService) Ordered() ([]models.StaffCategoryInfo, error) {
+	var categories []models.StaffCategoryInfo
+
+	err := s.db.
+		Order("display_order ASC, label ASC").
+		Find(&categories).Error
+
+	return categories, err
+}
+
+// MemberCounts returns the number of non-deleted staff members in each
+// category. Categories without members are omitted.
+func (s *StaffCategoryService) MemberCounts() (map[models.StaffCategory]int, error) {
+	var rows []struct {
+		Category models.StaffCategory
+		Count    int
+	}
+	err := s.db.Model(&models.StaffMember{}).
+		Select("category, COUNT(*) AS count").
+		Group("category").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[models.StaffCategory]int, len(rows))
+	for _, r := range rows {
+		counts[r.Category] = r.Count
+	}
+	return counts, nil
+}
+
+// GetByID returns a category.
+// Returns gorm.ErrRecordNotFound if it does not exist.
+func (s *StaffCategoryService) GetByID(id uint) (*models.StaffCategoryInfo, error) {
+	var category models.StaffCategoryInfo
+
+	if err := s.db.First(&category, id).Error; err != nil {
+		return nil, err
+	}
+
+	return &category, nil
+}
+
+// Create validates in and stores a new category at the end of the display
+// order.
+// Returns ValidationErrors for bad input or a slug that is already used.
+func (s *StaffCategoryService) Create(in StaffCategoryInput) (*models.StaffCategoryInfo, error) {
+	errs := ValidationErrors{}
+
+	label, msg := staffCategoryLabel(in.Label)
+	if msg != "" {
+		errs["label"] = msg
+	}
+
+	slug := strings.ToLower(strings.TrimSpace(in.Slug))
+	switch {
+	case slug == "":
+		errs["slug"] = "Slug is required."
+	case len(slug) > 50:
+		errs["slug"] = "Slug must be 50 characters or fewer."
+	case !slugPattern.MatchString(slug):
+		errs["slug"] = "Use lowercase letters, numbers, and hyphens, e.g. ruling-elder."
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	category := models.StaffCategoryInfo{Slug: models.StaffCategory(slug), Label: label}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var taken int64
+		if err := tx.Model(&models.StaffCategoryInfo{}).Where("slug = ?", slug).Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			return ValidationErrors{"slug": "Another category already uses this slug."}
+		}
+
+		var last int
+		err := tx.Model(&models.StaffCategoryInfo{}).Select("COALESCE(MAX(display_order), 0)").Scan(&last).Error
+		if err != nil {
+			return err
+		}
+		category.DisplayOrder = last + 1
+
+		return tx.Create(&category).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &category, nil
+}
+
+// Update renames a category.
+// Returns gorm.ErrRecordNotFound for unknown categories and ValidationErrors
+// for bad input.
+func (s *StaffCategoryService) Update(id uint, in StaffCategoryInput) (*models.StaffCategoryInfo, error) {
+	label, msg := staffCategoryLabel(in.Label)
+	if msg != "" {
+		return nil, ValidationErrors{"label": msg}
+	}
+
+	category, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	category.Label = label
+	if err := s.db.Model(category).Update("label", label).Error; err != nil {
+		return nil, err
+	}
+
+	return category, nil
+}
+
+// Reorder sets the display order to the order of ids, which must list every
+// category exactly once.
+// Returns ValidationErrors if ids does not match the current categories.
+func (s *StaffCategoryService) Reorder(ids []uint) error {
+	return setDisplayOrder(s.db, &models.StaffCategoryInfo{}, ids)
+}
+
+// Delete removes a category that no staff member belongs to, including
+// deleted staff members, whose rows still refer to it.
+// Returns gorm.ErrRecordNotFound if it does not exist and
+// ErrStaffCategoryInUse if staff members belong to it.
+func (s *StaffCategoryService) Delete(id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var category models.StaffCategoryInfo
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&category, id).Error; err != nil {
+			return err
+		}
+
+		var members int64
+		err := tx.Unscoped().Model(&models.StaffMember{}).Where("category = ?", category.Slug).Count(&members).Error
+		if err != nil {
+			return err
+		}
+		if members > 0 {
+			return ErrStaffCategoryInUse
+		}
+
+		return tx.Delete(&category).Error
+	})
+}
+
+// staffCategoryLabel trims and checks a category label, returning a message
+// for the form if it is not acceptable.
+func staffCategoryLabel(s string) (string, string) {
+	label := strings.TrimSpace(s)
+	switch {
+	case label == "":
+		return "", "Label is required."
+	case len(label) > 255:
+		return "", "Label must be 255 characters or fewer."
+	}
+	return label, ""
+}
@@ -1,18 +1,60 @@
 package services
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/mail"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
 	"github.com/sfdeloach/churchsite/internal/models"
+	"github.com/sfdeloach/churchsite/internal/storage"
+	"github.com/sfdeloach/churchsite/internal/utils/imaging"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// StaffMemberService handles staff member queries.
+// StaffPhotoURLPrefix is the URL path uploaded staff headshots are served
+// under.
+const StaffPhotoURLPrefix = FileURLPrefix + "staff/"
+
+// StaffMemberInput holds the fields submitted on the staff member form.
+// Photo is optional; when set it replaces the current headshot.
+type StaffMemberInput struct {
+	Name      string
+	Title     string
+	Bio       string
+	Email     string
+	Phone     string
+	Category  string
+	IsActive  bool
+	Photo     io.Reader
+	PhotoSize int64
+}
+
+// StaffMemberService handles staff member queries and staff edits.
+// Uploaded headshots are stored under staff/ as WebP variants.
 type StaffMemberService struct {
-	db *gorm.DB
+	db            *gorm.DB
+	store         storage.Storage
+	maxUploadSize int64
 }
 
-// NewStaffMemberService creates a new StaffMemberService.
-func NewStaffMemberService(db *gorm.DB) *StaffMemberService {
-	return &StaffMemberService{db: db}
+// NewStaffMemberService creates a new StaffMemberService storing headshots
+// in store and rejecting files larger than maxUploadSize bytes.
+func NewStaffMemberService(db *gorm.DB, store storage.Storage, maxUploadSize int64) *StaffMemberService {
+	return &StaffMemberService{db: db, store: store, maxUploadSize: maxUploadSize}
+}
+
+// MaxUploadSize returns the largest accepted headshot size in bytes.
+func (s *StaffMemberService) MaxUploadSize() int64 {
+	return s.maxUploadSize
 }
 
 // GetActive returns active, non-deleted staff members ordered by display_order then name.
@@ -27,6 +69,376 @@ func (s *StaffMemberService) GetActive() ([]models.StaffMember, error) {
 	return members, err
 }
 
+// ListAll returns every non-deleted staff member, active or not, in display
+// order.
+func (s *StaffMemberService) ListAll() ([]models.StaffMember, error) {
+	var members []models.StaffMember
+
+	err := s.db.
+		Order("display_order ASC, name ASC").
+		Find(&members).Error
+
+	return members, err
+}
+
+// GetByID returns a non-deleted staff member, active or not.
+// Returns gorm.ErrRecordNotFound if it does not exist.
+func (s *StaffMemberService) GetByID(id uint) (*models.StaffMember, error) {
+	var member models.StaffMember
+
+	if err := s.db.First(&member, id).Error; err != nil {
+		return nil, err
+	}
+
+	return &member, nil
+}
+
+// OpenPhoto opens an uploaded headshot named in a URL under
+// StaffPhotoURLPrefix.
+// Returns an fs.ErrNotExist error if there is no such file.
+func (s *StaffMemberService) OpenPhoto(name string) (io.ReadCloser, error) {
+	if name == "" || path.Base(name) != name || !filepath.IsLocal(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return s.store.Open("staff/" + name)
+}
+
+// OwnedFile finds the staff member whose uploaded headshot includes the
+// file name. Anyone may see the headshots of active staff members, which
+// are never replaced in place and so are cached indefinitely; those of
+// inactive members are only shown to staff.
+// Returns gorm.ErrRecordNotFound if no staff member's headshot includes it.
+func (s *StaffMemberService) OwnedFile(name string) (*StoredFile, error) {
+	id, ok := fileOwnerID(name)
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	member, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(staffPhotoURLs(*member), StaffPhotoURLPrefix+name) {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	file := &StoredFile{
+		Name:         "staff/" + name,
+		CacheControl: "public, max-age=31536000, immutable",
+	}
+	if !member.IsActive {
+		file.CacheControl = "private, max-age=3600"
+		file.CanView = func(claims *SessionClaims) bool {
+			return claims != nil && claims.HasRole(models.RoleStaff)
+		}
+	}
+	return file, nil
+}
+
+// StaffMemberInputFrom returns the form values for an existing staff member.
+func StaffMemberInputFrom(m models.StaffMember) StaffMemberInput {
+	return StaffMemberInput{
+		Name:     m.Name,
+		Title:    m.Title,
+		Bio:      m.Bio,
+		Email:    m.Email,
+		Phone:    m.Phone,
+		Category: string(m.Category),
+		IsActive: m.IsActive,
+	}
+}
+
+// Create validates in and stores a new staff member at the end of the
+// display order.
+// Returns ValidationErrors for bad input.
+func (s *StaffMemberService) Create(in StaffMemberInput) (*models.StaffMember, error) {
+	var member models.StaffMember
+	photo, photoMsg := readPhoto(in.Photo, in.PhotoSize, s.maxUploadSize)
+	if err := applyStaffMemberInput(s.db, &member, in, photoMsg); err != nil {
+		return nil, err
+	}
+
+	saved := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var last int
+		err := tx.Model(&models.StaffMember{}).Select("COALESCE(MAX(display_order), 0)").Scan(&last).Error
+		if err != nil {
+			return err
+		}
+		member.DisplayOrder = last + 1
+
+		if err := tx.Create(&member).Error; err != nil {
+			return err
+		}
+		if photo == nil {
+			return nil
+		}
+
+		if err := s.savePhoto(&member, photo); err != nil {
+			return err
+		}
+		saved = true
+		return tx.Model(&member).
+			Select("photo_url", "photo_srcset", "photo_thumbnail_url").
+			Updates(&member).Error
+	})
+	if err != nil {
+		if saved {
+			s.removePhoto(member)
+		}
+		return nil, err
+	}
+
+	return &member, nil
+}
+
+// staffMemberRevision is the part of a staff member kept in its revisions.
+// Headshots are not kept, since replaced files are removed.
+type staffMemberRevision struct {
+	Name     string `json:"name"`
+	Title    string `json:"title"`
+	Bio      string `json:"bio"`
+	Email    string `json:"email"`
+	Phone    string `json:"phone"`
+	Category string `json:"category"`
+	IsActive bool   `json:"is_active"`
+}
+
+func staffMemberRevisionOf(m models.StaffMember) staffMemberRevision {
+	return staffMemberRevision{
+		Name:     m.Name,
+		Title:    m.Title,
+		Bio:      m.Bio,
+		Email:    m.Email,
+		Phone:    m.Phone,
+		Category: string(m.Category),
+		IsActive: m.IsActive,
+	}
+}
+
+// Update validates in and saves it over an existing staff member, recording
+// the previous version as a revision by editorID. A new headshot replaces
+// the old one, whose files are removed if it was uploaded.
+// Returns gorm.ErrRecordNotFound for unknown staff members and
+// ValidationErrors for bad input.
+func (s *StaffMemberService) Update(id uint, in StaffMemberInput, editorID uint) (*models.StaffMember, error) {
+	// Prepare the photo before locking the row; encoding takes a moment.
+	photo, photoMsg := readPhoto(in.Photo, in.PhotoSize, s.maxUploadSize)
+
+	var member, old models.StaffMember
+	saved := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&member, id).Error; err != nil {
+			return err
+		}
+
+		before := staffMemberRevisionOf(member)
+		if err := applyStaffMemberInput(tx, &member, in, photoMsg); err != nil {
+			return err
+		}
+		if err := recordRevision(tx, models.RevisionStaffMember, id, before, staffMemberRevisionOf(member), editorID); err != nil {
+			return err
+		}
+
+		if photo != nil {
+			old = member
+			if err := s.savePhoto(&member, photo); err != nil {
+				return err
+			}
+			saved = true
+		}
+
+		return tx.Model(&member).
+			Select("name", "title", "bio", "email", "phone", "category", "is_active", "photo_url", "photo_srcset", "photo_thumbnail_url").
+			Updates(&member).Error
+	})
+	if err != nil {
+		if saved {
+			s.removePhoto(member)
+		}
+		return nil, err
+	}
+
+	if saved {
+		s.removePhoto(old)
+	}
+	return &member, nil
+}
+
+// Reorder sets the display order to the order of ids, which must list every
+// non-deleted staff member exactly once.
+// Returns ValidationErrors if ids does not match the current staff.
+func (s *StaffMemberService) Reorder(ids []uint) error {
+	return setDisplayOrder(s.db, &models.StaffMember{}, ids)
+}
+
+// setDisplayOrder numbers the rows of model's table in the order of ids,
+// which must list every row exactly once. The rows are locked and updated
+// in one transaction so concurrent reorders cannot interleave.
+// Returns ValidationErrors if ids does not match the current rows.
+func setDisplayOrder(db *gorm.DB, model any, ids []uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var current []uint
+		err := tx.Model(model).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Order("id").
+			Pluck("id", &current).Error
+		if err != nil {
+			return err
+		}
+
+		sorted := slices.Sorted(slices.Values(ids))
+		if !slices.Equal(sorted, current) {
+			return ValidationErrors{"order": "The list has changed. Reload the page and try again."}
+		}
+
+		for i, id := range ids {
+			err := tx.Model(model).Where("id = ?", id).Update("display_order", i+1).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// applyStaffMemberInput validates in and writes the result into m. photoMsg
+// is the problem readPhoto found with the submitted headshot, if any.
+// Returns ValidationErrors keyed by form field name.
+func applyStaffMemberInput(db *gorm.DB, m *models.StaffMember, in StaffMemberInput, photoMsg string) error {
+	errs := ValidationErrors{}
+
+	name := strings.TrimSpace(in.Name)
+	switch {
+	case name == "":
+		errs["name"] = "Name is required."
+	case len(name) > 255:
+		errs["name"] = "Name must be 255 characters or fewer."
+	}
+
+	title := strings.TrimSpace(in.Title)
+	switch {
+	case title == "":
+		errs["title"] = "Title is required."
+	case len(title) > 255:
+		errs["title"] = "Title must be 255 characters or fewer."
+	}
+
+	email := strings.TrimSpace(in.Email)
+	if email != "" {
+		if _, err := mail.ParseAddress(email); err != nil || len(email) > 255 {
+			errs["email"] = "Enter a valid email address."
+		}
+	}
+
+	phone := strings.TrimSpace(in.Phone)
+	if len(phone) > 20 {
+		errs["phone"] = "Phone must be 20 characters or fewer."
+	}
+
+	category := models.StaffCategory(in.Category)
+	var found int64
+	if err := db.Model(&models.StaffCategoryInfo{}).Where("slug = ?", category).Count(&found).Error; err != nil {
+		return err
+	}
+	if found == 0 {
+		errs["category"] = "Choose a category."
+	}
+
+	if photoMsg != "" {
+		errs["photo"] = photoMsg
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	m.Name = name
+	m.Title = title
+	m.Bio = strings.TrimSpace(in.Bio)
+	m.Email = email
+	m.Phone = phone
+	m.Category = category
+	m.IsActive = in.IsActive
+	return nil
+}
+
+// readPhoto reads an uploaded photo of the given size, if any, and prepares
+// its variants, returning a message for the form if it is not acceptable.
+func readPhoto(file io.Reader, size, maxUploadSize int64) (*imaging.Processed, string) {
+	if file == nil || size <= 0 {
+		return nil, ""
+	}
+
+	tooLarge := fmt.Sprintf("The photo must be %s or smaller.", FormatBytes(maxUploadSize))
+	if size > maxUploadSize {
+		return nil, tooLarge
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, maxUploadSize+1))
+	switch {
+	case err != nil:
+		return nil, "The photo could not be read. Please try again."
+	case int64(len(data)) > maxUploadSize:
+		return nil, tooLarge
+	}
+
+	photo, err := imaging.Process(data)
+	switch {
+	case errors.Is(err, imaging.ErrFormat):
+		return nil, "The photo must be a JPEG, PNG, or WebP image."
+	case errors.Is(err, imaging.ErrTooLarge):
+		return nil, fmt.Sprintf("The photo must be at most %d megapixels.", imaging.MaxPixels/1_000_000)
+	case err != nil:
+		slog.Error("failed to process uploaded photo", "error", err)
+		return nil, "The photo could not be processed. Please try another."
+	}
+	return photo, ""
+}
+
+// savePhoto stores a headshot's variants for a staff member under new
+// names, so cached copies of the old photo are never served in its place,
+// and points m at them.
+func (s *StaffMemberService) savePhoto(m *models.StaffMember, photo *imaging.Processed) error {
+	base := fmt.Sprintf("%d-%d", m.ID, time.Now().UnixNano())
+	set, err := photo.Save(s.store, "staff", base)
+	if err != nil {
+		return err
+	}
+
+	m.PhotoURL = StaffPhotoURLPrefix + set.Largest().Name
+	m.PhotoSrcset = set.Srcset(StaffPhotoURLPrefix)
+	m.PhotoThumbnailURL = StaffPhotoURLPrefix + set.Thumbnail
+	return nil
+}
+
+// removePhoto deletes the files of m's headshot if it was uploaded rather
+// than shipped with the site.
+func (s *StaffMemberService) removePhoto(m models.StaffMember) {
+	removeUploads(s.store, staffPhotoURLs(m), StaffPhotoURLPrefix)
+}
+
+// staffPhotoURLs returns the URLs of every variant of a staff member's
+// headshot.
+func staffPhotoURLs(m models.StaffMember) []string {
+	return append(imaging.SrcsetURLs(m.PhotoSrcset), m.PhotoURL, m.PhotoThumbnailURL)
+}
+
+// removeUploads deletes the files in store behind urls that are served
+// under prefix. Other URLs, such as photos shipped with the site, are
+// skipped. Failures only leave unused files behind.
+func removeUploads(store storage.Storage, urls []string, prefix string) {
+	slices.Sort(urls)
+	for _, url := range slices.Compact(urls) {
+		if !strings.HasPrefix(url, prefix) {
+			continue
+		}
+		key := strings.TrimPrefix(url, FileURLPrefix)
+		if err := store.Delete(key); err != nil {
+			slog.Warn("failed to remove uploaded file", "key", key, "error", err)
+		}
+	}
+}
+
 // GroupByCategory groups staff members by their category.
 func GroupByCategory(members []models.StaffMember) map[models.StaffCategory][]models.StaffMember {
 	grouped := make(map[models.StaffCategory][]models.StaffMember)
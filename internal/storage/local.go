@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// tempPrefix begins the names of files Local is still writing, which are
+// left out of listings.
+const tempPrefix = ".upload-"
+
+// fileMode is the permission stored files are given. They must be readable
+// by nginx, which sends them itself through X-Accel-Redirect and usually
+// runs as a different user from the app.
+const fileMode = 0o644
+
+// Local stores objects as files in a directory, each at its key's path.
+// Content types are not recorded; they are derived from the key's extension
+// when an object is opened.
+type Local struct {
+	dir string
+}
+
+// NewLocal creates a Local storing objects under dir.
+func NewLocal(dir string) *Local {
+	return &Local{dir: dir}
+}
+
+func (s *Local) path(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(key))
+}
+
+// Put writes r to a temporary file beside the object and renames it into
+// place. The file is made readable by everyone (fileMode); temporary files
+// are otherwise created readable only by their owner.
+func (s *Local) Put(key string, r io.Reader, contentType string) (int64, error) {
+	if !validKey(key) {
+		return 0, invalidKey("put", key)
+	}
+	dest := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return 0, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), tempPrefix+"*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	size, err := io.Copy(tmp, r)
+	if err == nil {
+		err = tmp.Chmod(fileMode)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// Open opens the object's file.
+func (s *Local) Open(key string) (Object, error) {
+	if !validKey(key) {
+		return nil, invalidKey("open", key)
+	}
+	f, err := os.Open(s.path(key))
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if fi.IsDir() {
+		f.Close()
+		return nil, &fs.PathError{Op: "open", Path: key, Err: fs.ErrNotExist}
+	}
+	return &localObject{File: f, info: localInfo(key, fi)}, nil
+}
+
+// Stat describes the object's file.
+func (s *Local) Stat(key string) (ObjectInfo, error) {
+	if !validKey(key) {
+		return ObjectInfo{}, invalidKey("stat", key)
+	}
+	fi, err := os.Stat(s.path(key))
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	if fi.IsDir() {
+		return ObjectInfo{}, &fs.PathError{Op: "stat", Path: key, Err: fs.ErrNotExist}
+	}
+	return localInfo(key, fi), nil
+}
+
+// Delete removes the object's file. Emptied directories are left in place.
+func (s *Local) Delete(key string) error {
+	if !validKey(key) {
+		return invalidKey("delete", key)
+	}
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// List walks the directory holding the objects under prefix.
+func (s *Local) List(prefix string) ([]ObjectInfo, error) {
+	root := "."
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		root = prefix[:i]
+	}
+	if !validKey(root) && root != "." {
+		return nil, invalidKey("list", prefix)
+	}
+
+	var objects []ObjectInfo
+	err := fs.WalkDir(os.DirFS(s.dir), root, func(key string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if key == root && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), tempPrefix) || !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, localInfo(key, fi))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(objects, func(a, b ObjectInfo) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return objects, nil
+}
+
+func localInfo(key string, fi fs.FileInfo) ObjectInfo {
+	return ObjectInfo{
+		Key:         key,
+		Size:        fi.Size(),
+		ModTime:     fi.ModTime(),
+		ContentType: mime.TypeByExtension(path.Ext(key)),
+	}
+}
+
+type localObject struct {
+	*os.File
+	info ObjectInfo
+}
+
+func (o *localObject) Info() ObjectInfo {
+	return o.info
+}
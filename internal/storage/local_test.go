@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalPutIsReadableByNginx(t *testing.T) {
+	dir := t.TempDir()
+	s := NewLocal(dir)
+
+	if _, err := s.Put("staff/1-2-thumb.webp", strings.NewReader("photo"), "image/webp"); err != nil {
+		t.Fatal(err)
+	}
+
+	// nginx sends files itself through X-Accel-Redirect and runs as another
+	// user, so the file must be readable, and its directories searchable,
+	// by others.
+	fi, err := os.Stat(filepath.Join(dir, "staff", "1-2-thumb.webp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := fi.Mode().Perm(); mode != fileMode {
+		t.Errorf("file mode = %v, want %v", mode, fs.FileMode(fileMode))
+	}
+	di, err := os.Stat(filepath.Join(dir, "staff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := di.Mode().Perm(); mode&0o005 != 0o005 {
+		t.Errorf("directory mode = %v, want it readable and searchable by others", mode)
+	}
+}
+
+func TestLocalPutReplaces(t *testing.T) {
+	s := NewLocal(t.TempDir())
+
+	for _, content := range []string{"first", "second"} {
+		n, err := s.Put("bulletins/a.pdf", strings.NewReader(content), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != int64(len(content)) {
+			t.Errorf("Put stored %d bytes, want %d", n, len(content))
+		}
+	}
+
+	obj, err := s.Open("bulletins/a.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer obj.Close()
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "second" {
+		t.Errorf("content = %q, want %q", data, "second")
+	}
+	if info := obj.Info(); info.ContentType != "application/pdf" || info.Size != 6 {
+		t.Errorf("Info() = %+v, want a 6-byte application/pdf", info)
+	}
+}
+
+func TestLocalList(t *testing.T) {
+	dir := t.TempDir()
+	s := NewLocal(dir)
+	for _, key := range []string{"photos/2.webp", "photos/1.webp", "photos/x/3.webp", "staff/1.webp"} {
+		if _, err := s.Put(key, strings.NewReader(key), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A file still being written is left out.
+	if err := os.WriteFile(filepath.Join(dir, "photos", tempPrefix+"123"), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"photos/", []string{"photos/1.webp", "photos/2.webp", "photos/x/3.webp"}},
+		{"photos/1", []string{"photos/1.webp"}},
+		{"", []string{"photos/1.webp", "photos/2.webp", "photos/x/3.webp", "staff/1.webp"}},
+		{"bulletins/", nil},
+	}
+	for _, tt := range tests {
+		objects, err := s.List(tt.prefix)
+		if err != nil {
+			t.Fatalf("List(%q): %v", tt.prefix, err)
+		}
+		var got []string
+		for _, o := range objects {
+			got = append(got, o.Key)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("List(%q) = %v, want %v", tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestLocalMissingAndInvalidKeys(t *testing.T) {
+	s := NewLocal(t.TempDir())
+
+	if _, err := s.Open("photos/missing.webp"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Open of a missing object: %v, want fs.ErrNotExist", err)
+	}
+	if err := s.Delete("photos/missing.webp"); err != nil {
+		t.Errorf("Delete of a missing object: %v", err)
+	}
+	for _, key := range []string{"../escape", "/abs", "a//b", "a/./b", `a\b`, "."} {
+		if _, err := s.Put(key, strings.NewReader("x"), ""); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("Put(%q): %v, want fs.ErrInvalid", key, err)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Config locates an S3 bucket and the credentials for it.
+type S3Config struct {
+	Endpoint        string // e.g. https://s3.us-east-1.amazonaws.com or http://localhost:9000
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+
+	// PathStyle addresses the bucket in the URL path, as MinIO and most
+	// other S3-compatible servers expect, instead of in the host name.
+	PathStyle bool
+}
+
+// S3 stores objects in an S3 bucket, or one on a compatible server such as
+// MinIO, using the S3 REST API directly.
+type S3 struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// NewS3 creates an S3 storing objects in the configured bucket.
+func NewS3(cfg S3Config) (*S3, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("s3 endpoint must be an http or https URL: %q", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" || cfg.Region == "" || cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("s3 bucket, region, and credentials are required")
+	}
+
+	// Downloads are streamed to browsers, so only waiting for the response
+	// is limited, not reading it.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = 30 * time.Second
+
+	return &S3{cfg: cfg, endpoint: endpoint, client: &http.Client{Transport: transport}}, nil
+}
+
+// objectURL returns the URL of the object stored under key, or of the
+// bucket if key is empty.
+func (s *S3) objectURL(key string, query url.Values) *url.URL {
+	u := &url.URL{Scheme: s.endpoint.Scheme, Host: s.endpoint.Host}
+	p := strings.TrimSuffix(s.endpoint.Path, "/") + "/"
+	if s.cfg.PathStyle {
+		p += s.cfg.Bucket + "/"
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+	}
+	p += key
+
+	u.Path = p
+	u.RawPath = uriEncode(p, false)
+	u.RawQuery = canonicalQuery(query)
+	return u
+}
+
+// do sends a signed request for the object stored under key.
+func (s *S3) do(method, key string, query url.Values, header http.Header, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	req, err := http.NewRequest(method, s.objectURL(key, query).String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	signV4(req, payloadHash, s.cfg.Region, s.cfg.AccessKeyID, s.cfg.SecretAccessKey, time.Now())
+	return s.client.Do(req)
+}
+
+// Put spools r to a temporary file to learn its size and checksum, then
+// uploads it in a single request. S3 never exposes a partly written object.
+func (s *S3) Put(key string, r io.Reader, contentType string) (int64, error) {
+	if !validKey(key) {
+		return 0, invalidKey("put", key)
+	}
+
+	tmp, err := os.CreateTemp("", "s3-put-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if err != nil {
+		return 0, err
+	}
+
+	if contentType == "" {
+		head := make([]byte, min(size, sniffLen))
+		if _, err := tmp.ReadAt(head, 0); err != nil && !errors.Is(err, io.EOF) {
+			return 0, err
+		}
+		contentType = detectContentType(key, head)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	header := http.Header{"Content-Type": {contentType}}
+	resp, err := s.do(http.MethodPut, key, nil, header, tmp, size, hex.EncodeToString(hash.Sum(nil)))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, s3Error("put", key, resp)
+	}
+	return size, nil
+}
+
+// Open describes the object, then downloads its content as it is read,
+// from wherever it was last seeked to.
+func (s *S3) Open(key string) (Object, error) {
+	info, etag, err := s.head("open", key)
+	if err != nil {
+		return nil, err
+	}
+	return &s3Object{s: s, info: info, etag: etag}, nil
+}
+
+// Stat describes the object.
+func (s *S3) Stat(key string) (ObjectInfo, error) {
+	info, _, err := s.head("stat", key)
+	return info, err
+}
+
+func (s *S3) head(op, key string) (ObjectInfo, string, error) {
+	if !validKey(key) {
+		return ObjectInfo{}, "", invalidKey(op, key)
+	}
+	resp, err := s.do(http.MethodHead, key, nil, nil, nil, 0, emptyPayloadHash)
+	if err != nil {
+		return ObjectInfo{}, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ObjectInfo{}, "", s3Error(op, key, resp)
+	}
+
+	info := ObjectInfo{
+		Key:         key,
+		Size:        resp.ContentLength,
+		ContentType: resp.Header.Get("Content-Type"),
+	}
+	info.ModTime, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	return info, resp.Header.Get("ETag"), nil
+}
+
+// Delete removes the object. S3 reports success for missing objects too.
+func (s *S3) Delete(key string) error {
+	if !validKey(key) {
+		return invalidKey("delete", key)
+	}
+	resp, err := s.do(http.MethodDelete, key, nil, nil, nil, 0, emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return s3Error("delete", key, resp)
+	}
+	return nil
+}
+
+// listResult is the response to a ListObjectsV2 request.
+type listResult struct {
+	Contents []struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// List pages through the bucket's objects under prefix.
+func (s *S3) List(prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := s.do(http.MethodGet, "", query, nil, nil, 0, emptyPayloadHash)
+		if err != nil {
+			return nil, err
+		}
+		var result listResult
+		if resp.StatusCode != http.StatusOK {
+			err = s3Error("list", prefix, resp)
+		} else {
+			err = xml.NewDecoder(resp.Body).Decode(&result)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range result.Contents {
+			objects = append(objects, ObjectInfo{Key: c.Key, Size: c.Size, ModTime: c.LastModified})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// s3Error converts an unsuccessful response into an error, reporting
+// missing objects as fs.ErrNotExist.
+func s3Error(op, key string, resp *http.Response) error {
+	if resp.StatusCode == http.StatusNotFound {
+		return &fs.PathError{Op: op, Path: key, Err: fs.ErrNotExist}
+	}
+
+	var body struct {
+		Code    string
+		Message string
+	}
+	// HEAD responses have no body, leaving only the status.
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body); err != nil || body.Code == "" {
+		return &fs.PathError{Op: op, Path: key, Err: fmt.Errorf("s3: %s", resp.Status)}
+	}
+	return &fs.PathError{Op: op, Path: key, Err: fmt.Errorf("s3: %s: %s", body.Code, body.Message)}
+}
+
+// s3Object reads an object with ranged GET requests, starting a new one
+// after each seek. Requests are conditional on the ETag the object had
+// when it was opened, so a replaced object is never read as a mixture.
+type s3Object struct {
+	s      *S3
+	info   ObjectInfo
+	etag   string
+	offset int64
+	body   io.ReadCloser
+}
+
+func (o *s3Object) Info() ObjectInfo {
+	return o.info
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.offset >= o.info.Size {
+		return 0, io.EOF
+	}
+	if o.body == nil {
+		header := http.Header{"Range": {"bytes=" + strconv.FormatInt(o.offset, 10) + "-"}}
+		if o.etag != "" {
+			header.Set("If-Match", o.etag)
+		}
+		resp, err := o.s.do(http.MethodGet, o.info.Key, nil, header, nil, 0, emptyPayloadHash)
+		if err != nil {
+			return 0, err
+		}
+		if resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusOK {
+			defer resp.Body.Close()
+			return 0, s3Error("read", o.info.Key, resp)
+		}
+		o.body = resp.Body
+	}
+
+	n, err := o.body.Read(p)
+	o.offset += int64(n)
+	if errors.Is(err, io.EOF) && o.offset < o.info.Size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += o.offset
+	case io.SeekEnd:
+		offset += o.info.Size
+	default:
+		return 0, errors.New("s3: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("s3: negative position")
+	}
+
+	if offset != o.offset {
+		o.closeBody()
+		o.offset = offset
+	}
+	return offset, nil
+}
+
+func (o *s3Object) Close() error {
+	o.closeBody()
+	return nil
+}
+
+func (o *s3Object) closeBody() {
+	if o.body != nil {
+		o.body.Close()
+		o.body = nil
+	}
+}
@@ -0,0 +1,361 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is a stand-in for an S3-compatible server holding one bucket,
+// addressed path-style. It refuses requests whose signature or payload hash
+// is wrong, as S3 does, and lists at most pageSize keys per ListObjectsV2
+// page.
+type fakeS3 struct {
+	bucket   string
+	pageSize int
+
+	mu      sync.Mutex
+	objects map[string]fakeObject
+	lists   int // ListObjectsV2 requests served
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+	modTime     time.Time
+}
+
+func (o fakeObject) etag() string {
+	sum := sha256.Sum256(o.data)
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+func newFakeS3(t *testing.T) (*S3, *fakeS3) {
+	f := &fakeS3{bucket: "uploads", pageSize: 2, objects: map[string]fakeObject{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	s, err := NewS3(S3Config{
+		Endpoint:        srv.URL,
+		Region:          "us-east-1",
+		Bucket:          f.bucket,
+		AccessKeyID:     "test-key",
+		SecretAccessKey: "test-secret",
+		PathStyle:       true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, f
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !f.verify(r, body) {
+		s3ErrorResponse(w, http.StatusForbidden, "SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided.")
+		return
+	}
+
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+f.bucket+"/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	obj, exists := f.objects[key]
+
+	switch {
+	case key == "" && r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+		f.list(w, r)
+	case r.Method == http.MethodPut:
+		f.objects[key] = fakeObject{data: body, contentType: r.Header.Get("Content-Type"), modTime: time.Now().UTC().Truncate(time.Second)}
+		w.Header().Set("ETag", f.objects[key].etag())
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	case !exists:
+		s3ErrorResponse(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+	case r.Method == http.MethodHead:
+		w.Header().Set("Content-Length", strconv.Itoa(len(obj.data)))
+		w.Header().Set("Content-Type", obj.contentType)
+		w.Header().Set("ETag", obj.etag())
+		w.Header().Set("Last-Modified", obj.modTime.Format(http.TimeFormat))
+	case r.Method == http.MethodGet:
+		if m := r.Header.Get("If-Match"); m != "" && m != obj.etag() {
+			s3ErrorResponse(w, http.StatusPreconditionFailed, "PreconditionFailed", "At least one of the pre-conditions you specified did not hold")
+			return
+		}
+		start := 0
+		if rng := r.Header.Get("Range"); rng != "" {
+			start, _ = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(obj.data)-1, len(obj.data)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(obj.data[start:])
+	default:
+		http.Error(w, "unexpected request", http.StatusMethodNotAllowed)
+	}
+}
+
+// verify re-signs the request as received and compares the result, and
+// checks the declared payload hash against the body.
+func (f *fakeS3) verify(r *http.Request, body []byte) bool {
+	sum := sha256.Sum256(body)
+	if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+		return false
+	}
+	signedAt, err := time.Parse(sigV4Time, r.Header.Get("X-Amz-Date"))
+	if err != nil {
+		return false
+	}
+
+	resigned := r.Clone(r.Context())
+	resigned.URL.Host = r.Host
+	resigned.Header.Del("Authorization")
+	signV4(resigned, r.Header.Get("X-Amz-Content-Sha256"), "us-east-1", "test-key", "test-secret", signedAt)
+	return r.Header.Get("Authorization") == resigned.Header.Get("Authorization")
+}
+
+// list answers a ListObjectsV2 request. Continuation tokens are the last
+// key of the previous page, reversed so clients cannot mistake them for
+// keys.
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	f.lists++
+	prefix := r.URL.Query().Get("prefix")
+	after := ""
+	if token := r.URL.Query().Get("continuation-token"); token != "" {
+		after = reverse(token)
+	}
+
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) && key > after {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	type content struct {
+		Key          string
+		Size         int
+		LastModified string
+	}
+	result := struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Contents              []content
+		IsTruncated           bool
+		NextContinuationToken string `xml:",omitempty"`
+	}{}
+	if len(keys) > f.pageSize {
+		keys = keys[:f.pageSize]
+		result.IsTruncated = true
+		result.NextContinuationToken = reverse(keys[len(keys)-1])
+	}
+	for _, key := range keys {
+		obj := f.objects[key]
+		result.Contents = append(result.Contents, content{Key: key, Size: len(obj.data), LastModified: obj.modTime.Format(time.RFC3339)})
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+func reverse(s string) string {
+	r := []rune(s)
+	slices.Reverse(r)
+	return string(r)
+}
+
+func s3ErrorResponse(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, message)
+}
+
+func TestS3PutOpenDelete(t *testing.T) {
+	s, f := newFakeS3(t)
+
+	content := strings.Repeat("0123456789", 100)
+	n, err := s.Put("bulletins/2026 05 03 am.pdf", strings.NewReader(content), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(content)) {
+		t.Errorf("Put stored %d bytes, want %d", n, len(content))
+	}
+	if got := f.objects["bulletins/2026 05 03 am.pdf"].contentType; got != "application/pdf" {
+		t.Errorf("stored Content-Type = %q, want application/pdf", got)
+	}
+
+	info, err := s.Stat("bulletins/2026 05 03 am.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != int64(len(content)) || info.ContentType != "application/pdf" || info.ModTime.IsZero() {
+		t.Errorf("Stat() = %+v", info)
+	}
+
+	obj, err := s.Open("bulletins/2026 05 03 am.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer obj.Close()
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != content {
+		t.Errorf("read %d bytes, want the %d stored", len(data), len(content))
+	}
+
+	// Seeking starts a ranged request from the new position.
+	if _, err := obj.Seek(995, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	tail, err := io.ReadAll(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(tail) != "56789" {
+		t.Errorf("read %q after seeking, want %q", tail, "56789")
+	}
+
+	if err := s.Delete("bulletins/2026 05 03 am.pdf"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Open("bulletins/2026 05 03 am.pdf"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Open after Delete: %v, want fs.ErrNotExist", err)
+	}
+	if err := s.Delete("bulletins/2026 05 03 am.pdf"); err != nil {
+		t.Errorf("Delete of a missing object: %v", err)
+	}
+}
+
+func TestS3PutDetectsContentType(t *testing.T) {
+	s, f := newFakeS3(t)
+
+	tests := []struct {
+		key, contentType, body, want string
+	}{
+		{"photos/1.webp", "", "RIFF", "image/webp"},
+		{"photos/noext", "", "%PDF-1.7", "application/pdf"},
+		{"photos/given", "image/jpeg", "x", "image/jpeg"},
+	}
+	for _, tt := range tests {
+		if _, err := s.Put(tt.key, strings.NewReader(tt.body), tt.contentType); err != nil {
+			t.Fatal(err)
+		}
+		if got := f.objects[tt.key].contentType; got != tt.want {
+			t.Errorf("Put(%q) stored Content-Type %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestS3OpenReplacedObject(t *testing.T) {
+	s, _ := newFakeS3(t)
+
+	if _, err := s.Put("staff/1.webp", strings.NewReader("first version"), ""); err != nil {
+		t.Fatal(err)
+	}
+	obj, err := s.Open("staff/1.webp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer obj.Close()
+	if _, err := s.Put("staff/1.webp", strings.NewReader("second version"), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// The download is conditional on the ETag seen when it was opened.
+	if _, err := io.ReadAll(obj); err == nil {
+		t.Error("read a replaced object without an error")
+	}
+}
+
+func TestS3List(t *testing.T) {
+	s, f := newFakeS3(t)
+	keys := []string{"photos/1.webp", "photos/2.webp", "photos/3.webp", "photos/4.webp", "photos/5.webp", "staff/1.webp"}
+	for _, key := range keys {
+		if _, err := s.Put(key, strings.NewReader(key), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		prefix    string
+		want      []string
+		wantPages int
+	}{
+		{"photos/", keys[:5], 3},
+		{"photos/4", keys[3:4], 1},
+		{"", keys, 3},
+		{"bulletins/", nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			f.lists = 0
+			objects, err := s.List(tt.prefix)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, o := range objects {
+				got = append(got, o.Key)
+				if o.Size != int64(len(o.Key)) || o.ModTime.IsZero() {
+					t.Errorf("listed %+v, want its size and modification time", o)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("List(%q) = %v, want %v", tt.prefix, got, tt.want)
+			}
+			if f.lists != tt.wantPages {
+				t.Errorf("List(%q) fetched %d pages, want %d", tt.prefix, f.lists, tt.wantPages)
+			}
+		})
+	}
+}
+
+func TestS3Errors(t *testing.T) {
+	s, _ := newFakeS3(t)
+
+	if _, err := s.Stat("photos/missing.webp"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Stat of a missing object: %v, want fs.ErrNotExist", err)
+	}
+	if _, err := s.Put("../escape", strings.NewReader("x"), ""); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("Put of an invalid key: %v, want fs.ErrInvalid", err)
+	}
+
+	wrong, err := NewS3(S3Config{
+		Endpoint:        s.cfg.Endpoint,
+		Region:          "us-east-1",
+		Bucket:          "uploads",
+		AccessKeyID:     "test-key",
+		SecretAccessKey: "wrong-secret",
+		PathStyle:       true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wrong.List("")
+	if err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Errorf("List with the wrong secret: %v, want SignatureDoesNotMatch", err)
+	}
+}
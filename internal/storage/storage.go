@@ -0,0 +1,125 @@
+// Package storage keeps uploaded files, such as bulletins, gallery photos,
+// and staff headshots, as objects named by slash-separated keys like
+// photos/12-1736035200000000000-800w.webp.
+//
+// Two backends implement Storage: Local, a directory on disk (the Docker
+// volume nginx serves from in production), and S3, a bucket on Amazon S3 or
+// a compatible server such as MinIO.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// Storage stores and retrieves objects. Missing objects are reported with
+// errors satisfying errors.Is(err, fs.ErrNotExist).
+type Storage interface {
+	// Put stores the content of r under key, replacing any object already
+	// there only once the new one is completely written. An empty
+	// contentType is detected from the key's extension or the content.
+	// Returns the number of bytes stored.
+	Put(key string, r io.Reader, contentType string) (int64, error)
+
+	// Open returns the content of the object stored under key.
+	Open(key string) (Object, error)
+
+	// Stat describes the object stored under key.
+	Stat(key string) (ObjectInfo, error)
+
+	// Delete removes the object stored under key. Deleting a missing object
+	// is not an error.
+	Delete(key string) error
+
+	// List describes every object whose key begins with prefix, in key
+	// order.
+	List(prefix string) ([]ObjectInfo, error)
+}
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ModTime     time.Time
+	ContentType string // empty if unknown, as in listings from S3
+}
+
+// Object is the content of a stored object. It can be seeked, so it can be
+// served with http.ServeContent, range requests included.
+type Object interface {
+	io.ReadSeekCloser
+	Info() ObjectInfo
+}
+
+// sniffLen is how much content http.DetectContentType considers.
+const sniffLen = 512
+
+// validKey reports whether key names an object: a slash-separated relative
+// path with no empty, "." or ".." elements.
+func validKey(key string) bool {
+	return key != "." && fs.ValidPath(key) && !strings.Contains(key, `\`)
+}
+
+// invalidKey is the error for operations on keys that fail validKey.
+func invalidKey(op, key string) error {
+	return &fs.PathError{Op: op, Path: key, Err: fs.ErrInvalid}
+}
+
+// detectContentType returns the content type for an object stored under
+// key, from its extension or, failing that, its first bytes.
+func detectContentType(key string, head []byte) string {
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+		return t
+	}
+	return http.DetectContentType(head)
+}
+
+// Migrate copies every object in src to dst, skipping those dst already has
+// at the same size, so an interrupted migration can be run again. Objects
+// that cannot be copied are skipped and reported in the returned error.
+// Returns the number of objects copied and skipped.
+func Migrate(dst, src Storage) (copied, skipped int, err error) {
+	objects, err := src.List("")
+	if err != nil {
+		return 0, 0, fmt.Errorf("list source: %w", err)
+	}
+
+	var errs []error
+	for _, obj := range objects {
+		existing, err := dst.Stat(obj.Key)
+		switch {
+		case err == nil && existing.Size == obj.Size:
+			skipped++
+			continue
+		case err != nil && !errors.Is(err, fs.ErrNotExist):
+			errs = append(errs, fmt.Errorf("%s: %w", obj.Key, err))
+			continue
+		}
+
+		if err := copyObject(dst, src, obj.Key); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", obj.Key, err))
+			continue
+		}
+		copied++
+	}
+
+	return copied, skipped, errors.Join(errs...)
+}
+
+func copyObject(dst, src Storage, key string) error {
+	obj, err := src.Open(key)
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+
+	_, err = dst.Put(key, obj, obj.Info().ContentType)
+	return err
+}
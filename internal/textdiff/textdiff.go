@@ -0,0 +1,160 @@
+// Package textdiff compares two texts line by line for side-by-side display.
+package textdiff
+
+import "strings"
+
+// Op describes how a row differs between the two texts.
+type Op int
+
+const (
+	Equal   Op = iota // the line is in both texts
+	Delete            // the line is only in the old text
+	Insert            // the line is only in the new text
+	Replace           // an old line was replaced by a new one
+)
+
+// Row is one line of a side-by-side diff. Left is empty for Insert rows and
+// Right is empty for Delete rows.
+type Row struct {
+	Op    Op
+	Left  string
+	Right string
+}
+
+// maxCells bounds the comparison table so very large, very different texts
+// cannot use unbounded memory; beyond it the differing middle is shown as
+// replaced wholesale.
+const maxCells = 4_000_000
+
+// Lines splits s into lines, ignoring a trailing newline.
+func Lines(s string) []string {
+	s = strings.TrimSuffix(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// SideBySide returns the rows of a line diff from a to b. Runs of deleted
+// lines followed by inserted lines are paired up as Replace rows.
+func SideBySide(a, b []string) []Row {
+	// Lines shared at either end need no comparison table.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var rows []Row
+	for _, line := range a[:prefix] {
+		rows = append(rows, Row{Op: Equal, Left: line, Right: line})
+	}
+	rows = append(rows, pair(middle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]))...)
+	for _, line := range a[len(a)-suffix:] {
+		rows = append(rows, Row{Op: Equal, Left: line, Right: line})
+	}
+	return rows
+}
+
+// Changed reports whether any row differs.
+func Changed(rows []Row) bool {
+	for _, r := range rows {
+		if r.Op != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// middle diffs a and b using a longest common subsequence table, returning
+// Equal, Delete, and Insert rows.
+func middle(a, b []string) []Row {
+	if len(a)*len(b) > maxCells {
+		var rows []Row
+		for _, line := range a {
+			rows = append(rows, Row{Op: Delete, Left: line})
+		}
+		for _, line := range b {
+			rows = append(rows, Row{Op: Insert, Right: line})
+		}
+		return rows
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var rows []Row
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			rows = append(rows, Row{Op: Equal, Left: a[i], Right: b[j]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			rows = append(rows, Row{Op: Delete, Left: a[i]})
+			i++
+		default:
+			rows = append(rows, Row{Op: Insert, Right: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		rows = append(rows, Row{Op: Delete, Left: a[i]})
+	}
+	for ; j < len(b); j++ {
+		rows = append(rows, Row{Op: Insert, Right: b[j]})
+	}
+	return rows
+}
+
+// pair merges each run of Delete rows with the Insert rows that follow it
+// into Replace rows, so changed lines sit side by side.
+func pair(rows []Row) []Row {
+	var out []Row
+	for i := 0; i < len(rows); {
+		if rows[i].Op != Delete {
+			out = append(out, rows[i])
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(rows) && rows[i].Op == Delete {
+			i++
+		}
+		deleted := rows[start:i]
+		insertStart := i
+		for i < len(rows) && rows[i].Op == Insert {
+			i++
+		}
+		inserted := rows[insertStart:i]
+
+		for k := 0; k < max(len(deleted), len(inserted)); k++ {
+			switch {
+			case k < len(deleted) && k < len(inserted):
+				out = append(out, Row{Op: Replace, Left: deleted[k].Left, Right: inserted[k].Right})
+			case k < len(deleted):
+				out = append(out, deleted[k])
+			default:
+				out = append(out, inserted[k])
+			}
+		}
+	}
+	return out
+}
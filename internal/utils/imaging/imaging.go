@@ -0,0 +1,238 @@
+// Package imaging prepares uploaded photos for the web. An upload is checked,
+// turned upright according to its EXIF orientation, scaled down to a set of
+// responsive widths and a square thumbnail, and re-encoded as WebP. Only
+// pixels are carried over, so camera metadata such as GPS coordinates never
+// reaches the site.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/jpeg" // register decoders for Process
+	_ "image/png"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/sfdeloach/churchsite/internal/storage"
+	"github.com/sfdeloach/churchsite/internal/webp"
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // register decoder for Process
+)
+
+const (
+	// MaxDimension is the longest side of the largest variant; bigger
+	// photos are scaled down to fit.
+	MaxDimension = 2000
+
+	// ThumbnailSize is the width and height of the square thumbnail.
+	ThumbnailSize = 300
+
+	// Quality is the WebP quality variants are encoded at.
+	Quality = 85
+
+	// MaxPixels bounds the size of an upload once decoded, so a small file
+	// cannot expand into an enormous image.
+	MaxPixels = 50_000_000
+)
+
+// Widths are the responsive widths generated for each photo. Photos
+// narrower than a width are not scaled up; their own width is used instead.
+var Widths = []int{400, 800, 1200, MaxDimension}
+
+var (
+	// ErrFormat is returned for uploads that are not JPEG, PNG, or WebP
+	// images.
+	ErrFormat = errors.New("imaging: not a JPEG, PNG, or WebP image")
+
+	// ErrTooLarge is returned for images with more than MaxPixels pixels.
+	ErrTooLarge = errors.New("imaging: image has too many pixels")
+)
+
+// contentTypes are the upload types Process accepts.
+var contentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
+// Variant is one encoded rendition of a photo.
+type Variant struct {
+	Name          string // file name suffix, e.g. "800w" or "thumb"
+	Width, Height int
+	Data          []byte
+}
+
+// Processed holds the renditions of an upload, ready to be saved.
+type Processed struct {
+	Variants  []Variant // responsive widths, narrowest first
+	Thumbnail Variant
+}
+
+// Process decodes an uploaded JPEG, PNG, or WebP image and encodes its
+// responsive variants and thumbnail.
+// Returns ErrFormat or ErrTooLarge for unacceptable uploads.
+func Process(data []byte) (*Processed, error) {
+	if !contentTypes[http.DetectContentType(data)] {
+		return nil, ErrFormat
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrFormat
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrFormat
+	}
+
+	// Scale before rotating, so only the smaller copy is rotated.
+	full := orient(fit(src, MaxDimension), jpegOrientation(data))
+
+	var p Processed
+	width := full.Bounds().Dx()
+	for _, w := range Widths {
+		if w >= width {
+			w = width
+		}
+		img := full
+		if w < width {
+			img = scale(full, full.Bounds(), w, max(1, full.Bounds().Dy()*w/width))
+		}
+		v, err := encode(strconv.Itoa(w)+"w", img)
+		if err != nil {
+			return nil, err
+		}
+		p.Variants = append(p.Variants, v)
+		if w == width {
+			break
+		}
+	}
+
+	p.Thumbnail, err = encode("thumb", thumbnail(full, ThumbnailSize))
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// fit scales m down so its width and height are at most size, keeping its
+// aspect ratio. Smaller images are returned as they are.
+func fit(m image.Image, size int) image.Image {
+	b := m.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return m
+	}
+	if w >= h {
+		return scale(m, b, size, max(1, h*size/w))
+	}
+	return scale(m, b, max(1, w*size/h), size)
+}
+
+// thumbnail crops the centre square of m and scales it to size.
+func thumbnail(m image.Image, size int) image.Image {
+	b := m.Bounds()
+	side := min(b.Dx(), b.Dy())
+	crop := image.Rect(0, 0, side, side).Add(b.Min).Add(image.Pt((b.Dx()-side)/2, (b.Dy()-side)/2))
+	return scale(m, crop, size, size)
+}
+
+// scale resamples the part r of m to a w x h image.
+func scale(m image.Image, r image.Rectangle, w, h int) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), m, r, draw.Src, nil)
+	return dst
+}
+
+func encode(name string, m image.Image) (Variant, error) {
+	var buf bytes.Buffer
+	if err := webp.Encode(&buf, m, &webp.Options{Quality: Quality}); err != nil {
+		return Variant{}, fmt.Errorf("encode %s: %w", name, err)
+	}
+	b := m.Bounds()
+	return Variant{Name: name, Width: b.Dx(), Height: b.Dy(), Data: buf.Bytes()}, nil
+}
+
+// Set names the files a processed photo was saved as, relative to the
+// directory it was saved in.
+type Set struct {
+	Files     []File // responsive widths, narrowest first
+	Thumbnail string
+}
+
+// File is one saved responsive variant.
+type File struct {
+	Name          string
+	Width, Height int
+}
+
+// Largest returns the widest variant, which suits the src attribute of an
+// img whose srcset lists the others.
+func (s Set) Largest() File {
+	return s.Files[len(s.Files)-1]
+}
+
+// Srcset returns the variants as the value of an img srcset attribute, with
+// each file name appended to urlPrefix.
+func (s Set) Srcset(urlPrefix string) string {
+	candidates := make([]string, len(s.Files))
+	for i, f := range s.Files {
+		candidates[i] = urlPrefix + f.Name + " " + strconv.Itoa(f.Width) + "w"
+	}
+	return strings.Join(candidates, ", ")
+}
+
+// SrcsetURLs returns the URLs listed in a srcset attribute value.
+func SrcsetURLs(srcset string) []string {
+	var urls []string
+	for _, candidate := range strings.Split(srcset, ",") {
+		if fields := strings.Fields(candidate); len(fields) > 0 {
+			urls = append(urls, fields[0])
+		}
+	}
+	return urls
+}
+
+// Save stores every rendition in store as {dir}/{base}-{name}.webp and
+// returns their names. If any write fails, the renditions already stored
+// are removed.
+func (p *Processed) Save(store storage.Storage, dir, base string) (Set, error) {
+	var set Set
+	var written []string
+	save := func(v Variant) (string, error) {
+		name := base + "-" + v.Name + ".webp"
+		if _, err := store.Put(dir+"/"+name, bytes.NewReader(v.Data), "image/webp"); err != nil {
+			return "", err
+		}
+		written = append(written, dir+"/"+name)
+		return name, nil
+	}
+
+	for _, v := range p.Variants {
+		name, err := save(v)
+		if err != nil {
+			removeAll(store, written)
+			return Set{}, err
+		}
+		set.Files = append(set.Files, File{Name: name, Width: v.Width, Height: v.Height})
+	}
+	name, err := save(p.Thumbnail)
+	if err != nil {
+		removeAll(store, written)
+		return Set{}, err
+	}
+	set.Thumbnail = name
+	return set, nil
+}
+
+func removeAll(store storage.Storage, keys []string) {
+	for _, key := range keys {
+		store.Delete(key)
+	}
+}
@@ -0,0 +1,214 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	xwebp "golang.org/x/image/webp"
+)
+
+// halves returns a w x h image whose left half is left and right half is
+// right.
+func halves(w, h int, left, right color.Color) *image.RGBA {
+	m := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(m, image.Rect(0, 0, w/2, h), image.NewUniform(left), image.Point{}, draw.Src)
+	draw.Draw(m, image.Rect(w/2, 0, w, h), image.NewUniform(right), image.Point{}, draw.Src)
+	return m
+}
+
+func encodePNG(t *testing.T, m image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// encodeJPEG encodes m as a JPEG carrying the EXIF orientation, as a camera
+// would write it.
+func encodeJPEG(t *testing.T, m image.Image, orientation uint16) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, m, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	app1 := exifSegment(tiff(binary.BigEndian, orientationEntry(orientation)))
+	return append(append(append([]byte{}, data[:2]...), app1...), data[2:]...)
+}
+
+func process(t *testing.T, data []byte) *Processed {
+	t.Helper()
+	p, err := Process(data)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	return p
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		w, h         int
+		wantW, wantH int
+	}{
+		{100, 50, 100, 50},
+		{2000, 2000, 2000, 2000},
+		{3000, 1500, 2000, 1000},
+		{1500, 3000, 1000, 2000},
+		{4000, 3000, 2000, 1500},
+		{2001, 2000, 2000, 1999},
+		{4001, 1, 2000, 1},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%dx%d", tt.w, tt.h), func(t *testing.T) {
+			b := fit(image.NewGray(image.Rect(0, 0, tt.w, tt.h)), MaxDimension).Bounds()
+			if b.Dx() != tt.wantW || b.Dy() != tt.wantH {
+				t.Errorf("fit = %dx%d, want %dx%d", b.Dx(), b.Dy(), tt.wantW, tt.wantH)
+			}
+		})
+	}
+}
+
+func TestProcessVariants(t *testing.T) {
+	tests := []struct {
+		name       string
+		w, h       int
+		wantWidths []int
+		largest    image.Point
+	}{
+		{"larger than the limit", 3000, 1500, []int{400, 800, 1200, 2000}, image.Pt(2000, 1000)},
+		{"portrait fit to the limit", 1000, 3000, []int{400, 666}, image.Pt(666, 2000)},
+		{"exactly a width", 800, 600, []int{400, 800}, image.Pt(800, 600)},
+		{"between widths", 1000, 500, []int{400, 800, 1000}, image.Pt(1000, 500)},
+		{"narrower than every width", 300, 200, []int{300}, image.Pt(300, 200)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := process(t, encodePNG(t, halves(tt.w, tt.h, color.White, color.Black)))
+
+			var widths []int
+			for _, v := range p.Variants {
+				widths = append(widths, v.Width)
+				if want := fmt.Sprintf("%dw", v.Width); v.Name != want {
+					t.Errorf("variant %dx%d named %q, want %q", v.Width, v.Height, v.Name, want)
+				}
+				if want := max(1, tt.largest.Y*v.Width/tt.largest.X); v.Height != want {
+					t.Errorf("variant %dw is %d high, want %d", v.Width, v.Height, want)
+				}
+				config, err := xwebp.DecodeConfig(bytes.NewReader(v.Data))
+				if err != nil {
+					t.Fatalf("variant %dw: %v", v.Width, err)
+				}
+				if config.Width != v.Width || config.Height != v.Height {
+					t.Errorf("variant %dw encodes %dx%d, want %dx%d", v.Width, config.Width, config.Height, v.Width, v.Height)
+				}
+			}
+			if fmt.Sprint(widths) != fmt.Sprint(tt.wantWidths) {
+				t.Errorf("widths = %v, want %v", widths, tt.wantWidths)
+			}
+			if largest := p.Variants[len(p.Variants)-1]; largest.Width != tt.largest.X || largest.Height != tt.largest.Y {
+				t.Errorf("largest variant = %dx%d, want %v", largest.Width, largest.Height, tt.largest)
+			}
+			if th := p.Thumbnail; th.Name != "thumb" || th.Width != ThumbnailSize || th.Height != ThumbnailSize {
+				t.Errorf("thumbnail = %q %dx%d, want thumb %dx%d", th.Name, th.Width, th.Height, ThumbnailSize, ThumbnailSize)
+			}
+		})
+	}
+}
+
+func TestThumbnailCropsCentre(t *testing.T) {
+	// Thirds of red, green, and blue: the centre square is all green.
+	m := image.NewRGBA(image.Rect(0, 0, 600, 200))
+	for i, c := range []color.RGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}} {
+		draw.Draw(m, image.Rect(200*i, 0, 200*(i+1), 200), image.NewUniform(c), image.Point{}, draw.Src)
+	}
+
+	for _, src := range []image.Image{m, orient(m, 6)} {
+		got := thumbnail(src, ThumbnailSize)
+		if b := got.Bounds(); b.Dx() != ThumbnailSize || b.Dy() != ThumbnailSize {
+			t.Fatalf("thumbnail of %v = %v, want %dx%d", src.Bounds(), b, ThumbnailSize, ThumbnailSize)
+		}
+		for _, pt := range []image.Point{{0, 0}, {299, 0}, {150, 150}, {0, 299}, {299, 299}} {
+			if c := color.RGBAModel.Convert(got.At(pt.X, pt.Y)).(color.RGBA); c.G < 250 || c.R > 5 || c.B > 5 {
+				t.Errorf("thumbnail of %v at %v = %v, want green", src.Bounds(), pt, c)
+			}
+		}
+	}
+}
+
+func TestProcessOrientsJPEG(t *testing.T) {
+	// Stored sideways as 400x200, red on the left: orientation 6 says to
+	// turn it right, leaving a 200x400 photo red on top.
+	red, blue := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}
+	tests := []struct {
+		orientation uint16
+		w, h        int
+		top         color.RGBA
+	}{
+		{1, 400, 200, red}, // as stored, red on the left
+		{6, 200, 400, red},
+		{8, 200, 400, blue},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.orientation), func(t *testing.T) {
+			p := process(t, encodeJPEG(t, halves(400, 200, red, blue), tt.orientation))
+			v := p.Variants[len(p.Variants)-1]
+			if v.Width != tt.w || v.Height != tt.h {
+				t.Fatalf("largest variant = %dx%d, want %dx%d", v.Width, v.Height, tt.w, tt.h)
+			}
+
+			m, err := xwebp.Decode(bytes.NewReader(v.Data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := color.RGBAModel.Convert(m.At(tt.w/4, tt.h/8)).(color.RGBA)
+			if (c.R > c.B) != (tt.top.R > tt.top.B) {
+				t.Errorf("pixel near the top left = %v, want mostly %v", c, tt.top)
+			}
+		})
+	}
+}
+
+// pngHeader returns the signature and header chunk of a PNG claiming to be
+// w x h, which is all image.DecodeConfig reads.
+func pngHeader(w, h uint32) []byte {
+	ihdr := []byte("IHDR")
+	ihdr = binary.BigEndian.AppendUint32(ihdr, w)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, h)
+	ihdr = append(ihdr, 8, 2, 0, 0, 0) // 8-bit RGB
+	b := []byte("\x89PNG\r\n\x1a\n")
+	b = binary.BigEndian.AppendUint32(b, uint32(len(ihdr)-4))
+	b = append(b, ihdr...)
+	return binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(ihdr))
+}
+
+func TestProcessRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, ErrFormat},
+		{"text", []byte("not an image"), ErrFormat},
+		{"GIF", []byte("GIF89a\x01\x00\x01\x00\x00\x00\x00;"), ErrFormat},
+		{"PNG header only", pngHeader(10, 10), ErrFormat},
+		{"too many pixels", pngHeader(10000, 5001), ErrTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Process(tt.data); !errors.Is(err, tt.want) {
+				t.Errorf("Process: %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// ifdEntry is one tag in an EXIF image file directory, with a SHORT value.
+type ifdEntry struct {
+	tag, typ uint16
+	value    uint16
+}
+
+// byteOrder is binary.LittleEndian or binary.BigEndian.
+type byteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// tiff lays out EXIF data with a single image file directory holding
+// entries, in the given byte order.
+func tiff(order byteOrder, entries ...ifdEntry) []byte {
+	var b []byte
+	if order == binary.LittleEndian {
+		b = append(b, "II"...)
+	} else {
+		b = append(b, "MM"...)
+	}
+	b = order.AppendUint16(b, 42)
+	b = order.AppendUint32(b, 8)
+	b = order.AppendUint16(b, uint16(len(entries)))
+	for _, e := range entries {
+		b = order.AppendUint16(b, e.tag)
+		b = order.AppendUint16(b, e.typ)
+		b = order.AppendUint32(b, 1)
+		b = order.AppendUint16(b, e.value)
+		b = append(b, 0, 0)
+	}
+	return order.AppendUint32(b, 0)
+}
+
+// segment returns a JPEG marker segment.
+func segment(marker byte, payload []byte) []byte {
+	b := []byte{0xff, marker}
+	b = binary.BigEndian.AppendUint16(b, uint16(len(payload)+2))
+	return append(b, payload...)
+}
+
+// exifSegment returns an APP1 segment carrying EXIF data.
+func exifSegment(data []byte) []byte {
+	return segment(0xe1, append([]byte("Exif\x00\x00"), data...))
+}
+
+// jpegHeader joins marker segments between the start of image and a start
+// of scan marker, as at the head of a JPEG file.
+func jpegHeader(segments ...[]byte) []byte {
+	b := []byte{0xff, 0xd8}
+	for _, s := range segments {
+		b = append(b, s...)
+	}
+	return append(b, 0xff, 0xda, 0x00, 0x02)
+}
+
+func orientationEntry(v uint16) ifdEntry {
+	return ifdEntry{tag: 0x0112, typ: 3, value: v}
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	for _, order := range []byteOrder{binary.LittleEndian, binary.BigEndian} {
+		for v := 1; v <= 8; v++ {
+			t.Run(fmt.Sprintf("%v %d", order, v), func(t *testing.T) {
+				data := jpegHeader(exifSegment(tiff(order, ifdEntry{tag: 0x010f, typ: 2}, orientationEntry(uint16(v)))))
+				if got := jpegOrientation(data); got != v {
+					t.Errorf("jpegOrientation = %d, want %d", got, v)
+				}
+			})
+		}
+	}
+
+	valid := exifSegment(tiff(binary.BigEndian, orientationEntry(6)))
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"empty", nil, 1},
+		{"not a JPEG", []byte("\x89PNG\r\n\x1a\n"), 1},
+		{"no metadata", jpegHeader(), 1},
+		{"after JFIF segment", jpegHeader(segment(0xe0, []byte("JFIF\x00\x01\x01")), valid), 6},
+		{"after fill bytes", append([]byte{0xff, 0xd8, 0xff, 0xff}, valid...), 6},
+		{"other APP1 data", jpegHeader(segment(0xe1, []byte("http://ns.adobe.com/xap/1.0/\x00"))), 1},
+		{"after start of scan", append(jpegHeader(), valid...), 1},
+		{"missing marker", append([]byte{0xff, 0xd8, 0x00}, valid...), 1},
+		{"segment length too short", []byte{0xff, 0xd8, 0xff, 0xe1, 0x00, 0x01}, 1},
+		{"segment past the end", append([]byte{0xff, 0xd8}, valid[:len(valid)-1]...), 1},
+		{"EXIF header only", jpegHeader(exifSegment(nil)), 1},
+		{"bad byte order", jpegHeader(exifSegment(append([]byte("XX"), tiff(binary.BigEndian, orientationEntry(6))[2:]...))), 1},
+		{"IFD offset past the end", jpegHeader(exifSegment([]byte("MM\x00\x2a\x7f\xff\xff\xff"))), 1},
+		{"IFD offset into the header", jpegHeader(exifSegment([]byte("MM\x00\x2a\x00\x00\x00\x02\x00\x00"))), 1},
+		{"entry count past the end", jpegHeader(exifSegment([]byte("MM\x00\x2a\x00\x00\x00\x08\xff\xff"))), 1},
+		{"no orientation tag", jpegHeader(exifSegment(tiff(binary.BigEndian, ifdEntry{tag: 0x010f, typ: 2}))), 1},
+		{"orientation not a SHORT", jpegHeader(exifSegment(tiff(binary.BigEndian, ifdEntry{tag: 0x0112, typ: 4, value: 6}))), 1},
+		{"orientation 0", jpegHeader(exifSegment(tiff(binary.BigEndian, orientationEntry(0)))), 1},
+		{"orientation 9", jpegHeader(exifSegment(tiff(binary.BigEndian, orientationEntry(9)))), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jpegOrientation(tt.data); got != tt.want {
+				t.Errorf("jpegOrientation = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJPEGOrientationTruncated(t *testing.T) {
+	// Uploads are untrusted: every truncation of a valid header must be
+	// read without panicking, as either the full answer or upright.
+	data := jpegHeader(exifSegment(tiff(binary.LittleEndian, ifdEntry{tag: 0x010f, typ: 2}, orientationEntry(8))))
+	for n := range len(data) {
+		if got := jpegOrientation(data[:n]); got != 1 && got != 8 {
+			t.Errorf("jpegOrientation of the first %d bytes = %d, want 1 or 8", n, got)
+		}
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// A 3x2 image stored as the camera saw it, with every pixel distinct.
+	stored := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	for y := range 2 {
+		for x := range 3 {
+			stored.SetRGBA(x, y, color.RGBA{uint8(x), uint8(y), 0, 255})
+		}
+	}
+
+	// For each orientation, where the stored pixels (0, 0), (1, 0), and
+	// (0, 1) belong once upright, per the EXIF definition of where the
+	// stored first row and column are to be shown.
+	tests := []struct {
+		orientation          int
+		w, h                 int
+		origin, right, below image.Point
+	}{
+		{1, 3, 2, image.Pt(0, 0), image.Pt(1, 0), image.Pt(0, 1)}, // row 0 top, column 0 left
+		{2, 3, 2, image.Pt(2, 0), image.Pt(1, 0), image.Pt(2, 1)}, // row 0 top, column 0 right
+		{3, 3, 2, image.Pt(2, 1), image.Pt(1, 1), image.Pt(2, 0)}, // row 0 bottom, column 0 right
+		{4, 3, 2, image.Pt(0, 1), image.Pt(1, 1), image.Pt(0, 0)}, // row 0 bottom, column 0 left
+		{5, 2, 3, image.Pt(0, 0), image.Pt(0, 1), image.Pt(1, 0)}, // row 0 left, column 0 top
+		{6, 2, 3, image.Pt(1, 0), image.Pt(1, 1), image.Pt(0, 0)}, // row 0 right, column 0 top
+		{7, 2, 3, image.Pt(1, 2), image.Pt(1, 1), image.Pt(0, 2)}, // row 0 right, column 0 bottom
+		{8, 2, 3, image.Pt(0, 2), image.Pt(0, 1), image.Pt(1, 2)}, // row 0 left, column 0 bottom
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.orientation), func(t *testing.T) {
+			got := orient(stored, tt.orientation)
+			if b := got.Bounds(); b.Dx() != tt.w || b.Dy() != tt.h {
+				t.Fatalf("bounds = %v, want %dx%d", b, tt.w, tt.h)
+			}
+			for from, to := range map[image.Point]image.Point{
+				image.Pt(0, 0): tt.origin,
+				image.Pt(1, 0): tt.right,
+				image.Pt(0, 1): tt.below,
+			} {
+				if want, have := stored.At(from.X, from.Y), got.At(to.X, to.Y); want != have {
+					t.Errorf("pixel at %v = %v, want stored pixel %v (%v)", to, have, from, want)
+				}
+			}
+		})
+	}
+}
+
+func TestOrientSubImage(t *testing.T) {
+	// Images that do not start at the origin are turned by their own
+	// pixels.
+	m := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	red := color.RGBA{255, 0, 0, 255}
+	m.SetRGBA(1, 2, red)
+	got := orient(m.SubImage(image.Rect(1, 2, 4, 4)), 3)
+	if c := got.At(2, 1); c != red {
+		t.Errorf("pixel at (2, 1) = %v, want the sub-image's first pixel %v", c, red)
+	}
+}
@@ -0,0 +1,88 @@
+package webp
+
+// boolEncoder writes one partition of boolean-entropy-coded data, as
+// specified in chapter 7.
+type boolEncoder struct {
+	buf      []byte
+	rangeM1  uint32 // range minus 1, kept between 127 and 254
+	bottom   uint32
+	bitCount int // bits to shift in before the next byte is written
+}
+
+func newBoolEncoder() *boolEncoder {
+	return &boolEncoder{rangeM1: 254, bitCount: 24}
+}
+
+// putBit writes bit, which is false with probability prob/256.
+func (e *boolEncoder) putBit(bit bool, prob uint8) {
+	split := 1 + (e.rangeM1*uint32(prob))>>8
+	if bit {
+		e.bottom += split
+		e.rangeM1 -= split
+	} else {
+		e.rangeM1 = split - 1
+	}
+	for e.rangeM1 < 127 {
+		e.rangeM1 = e.rangeM1<<1 | 1
+		if e.bottom&(1<<31) != 0 {
+			e.carry()
+		}
+		e.bottom <<= 1
+		e.bitCount--
+		if e.bitCount == 0 {
+			e.buf = append(e.buf, byte(e.bottom>>24))
+			e.bottom &= 1<<24 - 1
+			e.bitCount = 8
+		}
+	}
+}
+
+// carry propagates an overflow of bottom into the bytes already written.
+func (e *boolEncoder) carry() {
+	i := len(e.buf) - 1
+	for i >= 0 && e.buf[i] == 0xff {
+		e.buf[i] = 0
+		i--
+	}
+	if i >= 0 {
+		e.buf[i]++
+	}
+}
+
+// putUint writes the low n bits of v, most significant first, each with
+// probability prob.
+func (e *boolEncoder) putUint(v uint32, n int, prob uint8) {
+	for n > 0 {
+		n--
+		e.putBit(v>>n&1 == 1, prob)
+	}
+}
+
+// putFlaggedInt writes a flag and, if v is non-zero, its n-bit magnitude and
+// sign, the encoding used for optional header fields.
+func (e *boolEncoder) putFlaggedInt(v int32, n int) {
+	if v == 0 {
+		e.putBit(false, uniformProb)
+		return
+	}
+	e.putBit(true, uniformProb)
+	if v < 0 {
+		e.putUint(uint32(-v), n, uniformProb)
+		e.putBit(true, uniformProb)
+		return
+	}
+	e.putUint(uint32(v), n, uniformProb)
+	e.putBit(false, uniformProb)
+}
+
+// finish pads the partition so the decoder can read past the last coded
+// bit, and returns its bytes.
+func (e *boolEncoder) finish() []byte {
+	for i := 0; i < 32; i++ {
+		e.putBit(false, uniformProb)
+	}
+	return e.buf
+}
+
+// uniformProb codes a bit that is equally likely to be 0 or 1.
+const uniformProb = 128
@@ -0,0 +1,135 @@
+package webp
+
+// This file implements the forward transforms the encoder applies to
+// residuals and the inverse transforms, sections 14.3 and 14.4, it applies to
+// rebuild exactly what the decoder will see. Blocks of coefficients are
+// row-major, with the vertical frequency in the row.
+
+// fdct4 transforms the difference between a 4x4 block of src and its
+// prediction pred, both with the given strides.
+func fdct4(src []uint8, srcStride int, pred []uint8, predStride int, out *[16]int32) {
+	var tmp [16]int32
+	for i := 0; i < 4; i++ {
+		s, p := src[i*srcStride:], pred[i*predStride:]
+		d0 := int32(s[0]) - int32(p[0])
+		d1 := int32(s[1]) - int32(p[1])
+		d2 := int32(s[2]) - int32(p[2])
+		d3 := int32(s[3]) - int32(p[3])
+		a0 := d0 + d3
+		a1 := d1 + d2
+		a2 := d1 - d2
+		a3 := d0 - d3
+		tmp[0+i*4] = (a0 + a1) * 8
+		tmp[1+i*4] = (a2*2217 + a3*5352 + 1812) >> 9
+		tmp[2+i*4] = (a0 - a1) * 8
+		tmp[3+i*4] = (a3*2217 - a2*5352 + 937) >> 9
+	}
+	for i := 0; i < 4; i++ {
+		a0 := tmp[0+i] + tmp[12+i]
+		a1 := tmp[4+i] + tmp[8+i]
+		a2 := tmp[4+i] - tmp[8+i]
+		a3 := tmp[0+i] - tmp[12+i]
+		out[0+i] = (a0 + a1 + 7) >> 4
+		out[4+i] = (a2*2217 + a3*5352 + 12000) >> 16
+		if a3 != 0 {
+			out[4+i]++
+		}
+		out[8+i] = (a0 - a1 + 7) >> 4
+		out[12+i] = (a3*2217 - a2*5352 + 51000) >> 16
+	}
+}
+
+// idct4 adds the inverse transform of coeff to a 4x4 block of dst.
+func idct4(coeff *[16]int16, dst []uint8, stride int) {
+	const (
+		c1 = 85627 // 65536 * cos(pi/8) * sqrt(2)
+		c2 = 35468 // 65536 * sin(pi/8) * sqrt(2)
+	)
+	var m [4][4]int32
+	for i := 0; i < 4; i++ {
+		a := int32(coeff[0+i]) + int32(coeff[8+i])
+		b := int32(coeff[0+i]) - int32(coeff[8+i])
+		c := (int32(coeff[4+i])*c2)>>16 - (int32(coeff[12+i])*c1)>>16
+		d := (int32(coeff[4+i])*c1)>>16 + (int32(coeff[12+i])*c2)>>16
+		m[i][0] = a + d
+		m[i][1] = b + c
+		m[i][2] = b - c
+		m[i][3] = a - d
+	}
+	for j := 0; j < 4; j++ {
+		dc := m[0][j] + 4
+		a := dc + m[2][j]
+		b := dc - m[2][j]
+		c := (m[1][j]*c2)>>16 - (m[3][j]*c1)>>16
+		d := (m[1][j]*c1)>>16 + (m[3][j]*c2)>>16
+		row := dst[j*stride:]
+		row[0] = clip8(int32(row[0]) + (a+d)>>3)
+		row[1] = clip8(int32(row[1]) + (b+c)>>3)
+		row[2] = clip8(int32(row[2]) + (b-c)>>3)
+		row[3] = clip8(int32(row[3]) + (a-d)>>3)
+	}
+}
+
+// fwht transforms the DC coefficients of the 16 luma blocks of a macroblock,
+// in raster order.
+func fwht(dc *[16]int32, out *[16]int32) {
+	var tmp [16]int32
+	for i := 0; i < 4; i++ {
+		in := dc[i*4:]
+		a0 := in[0] + in[2]
+		a1 := in[1] + in[3]
+		a2 := in[1] - in[3]
+		a3 := in[0] - in[2]
+		tmp[0+i*4] = a0 + a1
+		tmp[1+i*4] = a3 + a2
+		tmp[2+i*4] = a3 - a2
+		tmp[3+i*4] = a0 - a1
+	}
+	for i := 0; i < 4; i++ {
+		a0 := tmp[0+i] + tmp[8+i]
+		a1 := tmp[4+i] + tmp[12+i]
+		a2 := tmp[4+i] - tmp[12+i]
+		a3 := tmp[0+i] - tmp[8+i]
+		out[0+i] = (a0 + a1) >> 1
+		out[4+i] = (a3 + a2) >> 1
+		out[8+i] = (a3 - a2) >> 1
+		out[12+i] = (a0 - a1) >> 1
+	}
+}
+
+// iwht inverts fwht, returning the DC coefficient of each luma block.
+func iwht(coeff *[16]int16, dc *[16]int16) {
+	var m [16]int32
+	for i := 0; i < 4; i++ {
+		a0 := int32(coeff[0+i]) + int32(coeff[12+i])
+		a1 := int32(coeff[4+i]) + int32(coeff[8+i])
+		a2 := int32(coeff[4+i]) - int32(coeff[8+i])
+		a3 := int32(coeff[0+i]) - int32(coeff[12+i])
+		m[0+i] = a0 + a1
+		m[8+i] = a0 - a1
+		m[4+i] = a3 + a2
+		m[12+i] = a3 - a2
+	}
+	for i := 0; i < 4; i++ {
+		d := m[i*4:]
+		c := d[0] + 3
+		a0 := c + d[3]
+		a1 := d[1] + d[2]
+		a2 := d[1] - d[2]
+		a3 := c - d[3]
+		dc[i*4+0] = int16((a0 + a1) >> 3)
+		dc[i*4+1] = int16((a3 + a2) >> 3)
+		dc[i*4+2] = int16((a0 - a1) >> 3)
+		dc[i*4+3] = int16((a3 - a2) >> 3)
+	}
+}
+
+func clip8(v int32) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
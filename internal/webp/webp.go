@@ -0,0 +1,591 @@
+// Package webp encodes images in the lossy WebP format: a single VP8 key
+// frame, as specified in RFC 6386, in a RIFF container. Section numbers in
+// comments refer to the RFC.
+//
+// The encoder favours simplicity over compression. Every macroblock is
+// predicted as one 16x16 luma and two 8x8 chroma regions using whichever of
+// the four whole-block modes fits best, the default token probabilities are
+// used throughout, and there is no alpha channel: transparent areas are
+// flattened onto white. That is enough for photographs on a web page, which
+// is all the site needs it for.
+package webp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"math"
+)
+
+// DefaultQuality is the quality used when Options does not set one.
+const DefaultQuality = 85
+
+// maxDimension is the largest width or height VP8 can describe.
+const maxDimension = 1<<14 - 1
+
+// Options are the encoding parameters.
+type Options struct {
+	// Quality ranges from 1 (smallest files) to 100 (best quality). Zero
+	// means DefaultQuality.
+	Quality int
+}
+
+// Encode writes m to w in the lossy WebP format.
+func Encode(w io.Writer, m image.Image, o *Options) error {
+	b := m.Bounds()
+	if b.Dx() < 1 || b.Dy() < 1 || b.Dx() > maxDimension || b.Dy() > maxDimension {
+		return fmt.Errorf("webp: cannot encode a %dx%d image", b.Dx(), b.Dy())
+	}
+	quality := DefaultQuality
+	if o != nil && o.Quality > 0 {
+		quality = min(o.Quality, 100)
+	}
+
+	e := newEncoder(m, quality)
+	frame, err := e.encode()
+	if err != nil {
+		return err
+	}
+
+	pad := len(frame) & 1
+	var hdr [20]byte
+	copy(hdr[0:], "RIFF")
+	binary.LittleEndian.PutUint32(hdr[4:], uint32(4+8+len(frame)+pad))
+	copy(hdr[8:], "WEBPVP8 ")
+	binary.LittleEndian.PutUint32(hdr[16:], uint32(len(frame)))
+	if _, err := w.Write(hdr[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(frame); err != nil {
+		return err
+	}
+	if pad != 0 {
+		_, err = w.Write([]byte{0})
+	}
+	return err
+}
+
+// Predictor modes for 16x16 luma and 8x8 chroma regions, section 12.2.
+const (
+	predDC = iota
+	predTM
+	predVE
+	predHE
+	nPred
+)
+
+// quant holds the DC and AC quantizer step sizes and rounding biases of one
+// kind of block. Biases are in 1/256ths of a step.
+type quant struct {
+	step [2]int32
+	bias [2]int32
+}
+
+// nzContext records which blocks along one edge of a macroblock had
+// non-zero coefficients, section 13.3.
+type nzContext struct {
+	y    [4]uint8
+	u, v [2]uint8
+	y2   uint8
+}
+
+type encoder struct {
+	width, height int
+	mbw, mbh      int
+
+	// Source and reconstructed planes, padded to whole macroblocks. The
+	// reconstruction is what the decoder will produce before loop
+	// filtering, which later macroblocks are predicted from.
+	yStride, cStride int
+	y, u, v          []uint8
+	ry, ru, rv       []uint8
+
+	qIndex      int
+	filterLevel int
+	y1, y2, uv  quant
+
+	header, tokens *boolEncoder
+	top            []nzContext // for each macroblock of the row above
+	left           nzContext
+}
+
+func newEncoder(m image.Image, quality int) *encoder {
+	b := m.Bounds()
+	e := &encoder{
+		width:  b.Dx(),
+		height: b.Dy(),
+		mbw:    (b.Dx() + 15) / 16,
+		mbh:    (b.Dy() + 15) / 16,
+		header: newBoolEncoder(),
+		tokens: newBoolEncoder(),
+	}
+	e.yStride = 16 * e.mbw
+	e.cStride = 8 * e.mbw
+	e.y = make([]uint8, e.yStride*16*e.mbh)
+	e.u = make([]uint8, e.cStride*8*e.mbh)
+	e.v = make([]uint8, e.cStride*8*e.mbh)
+	e.ry = make([]uint8, len(e.y))
+	e.ru = make([]uint8, len(e.u))
+	e.rv = make([]uint8, len(e.v))
+	e.top = make([]nzContext, e.mbw)
+	e.importImage(m)
+	e.setQuality(quality)
+	return e
+}
+
+// importImage converts m to YCbCr 4:2:0 with the BT.601 coefficients VP8
+// decoders assume, repeating the right and bottom edges into the padding.
+func (e *encoder) importImage(m image.Image) {
+	b := m.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(rgba, rgba.Bounds(), m, b.Min, draw.Over)
+
+	pixel := func(x, y int) (int32, int32, int32) {
+		x = min(x, e.width-1)
+		y = min(y, e.height-1)
+		p := rgba.Pix[y*rgba.Stride+4*x:]
+		return int32(p[0]), int32(p[1]), int32(p[2])
+	}
+
+	for y := 0; y < 16*e.mbh; y++ {
+		for x := 0; x < 16*e.mbw; x++ {
+			r, g, b := pixel(x, y)
+			e.y[y*e.yStride+x] = uint8((16839*r + 33059*g + 6420*b + 16<<16 + 1<<15) >> 16)
+		}
+	}
+	for y := 0; y < 8*e.mbh; y++ {
+		for x := 0; x < 8*e.mbw; x++ {
+			var r, g, b int32
+			for _, d := range [4][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+				pr, pg, pb := pixel(2*x+d[0], 2*y+d[1])
+				r, g, b = r+pr, g+pg, b+pb
+			}
+			// r, g and b are sums of four pixels, hence the extra shift.
+			e.u[y*e.cStride+x] = uint8((-9719*r - 19081*g + 28800*b + 128<<18 + 1<<17) >> 18)
+			e.v[y*e.cStride+x] = uint8((28800*r - 24116*g - 4684*b + 128<<18 + 1<<17) >> 18)
+		}
+	}
+}
+
+// setQuality chooses the quantizer index and loop filter strength for a
+// quality from 1 to 100, following the curve libwebp uses, and derives the
+// step sizes as the decoder will, section 9.6.
+func (e *encoder) setQuality(quality int) {
+	q := float64(quality) / 100
+	c := 2*q - 1
+	if q < 0.75 {
+		c = q * 2 / 3
+	}
+	e.qIndex = min(127, max(0, int(math.Round(127*(1-c)))))
+	e.filterLevel = min(63, 4+e.qIndex/3)
+
+	dc := func(i int) int32 { return int32(dcTable[min(127, max(0, i))]) }
+	ac := func(i int) int32 { return int32(acTable[min(127, max(0, i))]) }
+	e.y1 = quant{step: [2]int32{dc(e.qIndex), ac(e.qIndex)}, bias: [2]int32{96, 110}}
+	e.y2 = quant{step: [2]int32{2 * dc(e.qIndex), max(8, ac(e.qIndex)*155/100)}, bias: [2]int32{96, 108}}
+	e.uv = quant{step: [2]int32{dc(min(e.qIndex, 117)), ac(e.qIndex)}, bias: [2]int32{110, 115}}
+}
+
+// encode codes every macroblock and returns the VP8 frame: the frame
+// header, the first partition with the frame settings and prediction modes,
+// and a single partition of coefficient tokens.
+func (e *encoder) encode() ([]byte, error) {
+	e.putFrameHeader()
+	for mby := 0; mby < e.mbh; mby++ {
+		e.left = nzContext{}
+		for mbx := 0; mbx < e.mbw; mbx++ {
+			e.encodeMacroblock(mbx, mby)
+		}
+	}
+
+	first := e.header.finish()
+	tokens := e.tokens.finish()
+	if len(first) >= 1<<19 {
+		return nil, errors.New("webp: image is too large to encode")
+	}
+
+	frame := make([]byte, 10, 10+len(first)+len(tokens))
+	// A shown key frame, version 0, followed by the first partition's size.
+	tag := uint32(len(first))<<5 | 1<<4
+	frame[0], frame[1], frame[2] = byte(tag), byte(tag>>8), byte(tag>>16)
+	frame[3], frame[4], frame[5] = 0x9d, 0x01, 0x2a
+	binary.LittleEndian.PutUint16(frame[6:], uint16(e.width))
+	binary.LittleEndian.PutUint16(frame[8:], uint16(e.height))
+	frame = append(frame, first...)
+	return append(frame, tokens...), nil
+}
+
+// putFrameHeader writes the key frame settings, section 9: no segments, a
+// normal loop filter, one token partition, and the default token
+// probabilities.
+func (e *encoder) putFrameHeader() {
+	h := e.header
+	h.putBit(false, uniformProb) // color space
+	h.putBit(false, uniformProb) // clamping required
+	h.putBit(false, uniformProb) // segmentation
+	h.putBit(false, uniformProb) // normal loop filter
+	h.putUint(uint32(e.filterLevel), 6, uniformProb)
+	h.putUint(0, 3, uniformProb) // sharpness
+	h.putBit(false, uniformProb) // loop filter deltas
+	h.putUint(0, 2, uniformProb) // one token partition
+	h.putUint(uint32(e.qIndex), 7, uniformProb)
+	for i := 0; i < 5; i++ {
+		h.putFlaggedInt(0, 4) // quantizer deltas
+	}
+	h.putBit(false, uniformProb) // refresh entropy probabilities
+	for i := range tokenProbUpdateProb {
+		for j := range tokenProbUpdateProb[i] {
+			for k := range tokenProbUpdateProb[i][j] {
+				for _, p := range tokenProbUpdateProb[i][j][k] {
+					h.putBit(false, p)
+				}
+			}
+		}
+	}
+	h.putBit(false, uniformProb) // no macroblock skip flags
+}
+
+// encodeMacroblock chooses prediction modes for one macroblock, writes them
+// and its coefficients, and reconstructs it.
+func (e *encoder) encodeMacroblock(mbx, mby int) {
+	yOff := 16*mby*e.yStride + 16*mbx
+	cOff := 8*mby*e.cStride + 8*mbx
+
+	var yPred [16 * 16]uint8
+	var uPred, vPred [8 * 8]uint8
+	yMode := choosePrediction(region{e.y, e.ry, e.yStride, 16 * mbx, 16 * mby, 16, yPred[:]})
+	cMode := choosePrediction(
+		region{e.u, e.ru, e.cStride, 8 * mbx, 8 * mby, 8, uPred[:]},
+		region{e.v, e.rv, e.cStride, 8 * mbx, 8 * mby, 8, vPred[:]},
+	)
+
+	// Intra 16x16 luma mode then chroma mode, sections 11.2 and 11.4.
+	h := e.header
+	h.putBit(true, 145)
+	switch yMode {
+	case predDC:
+		h.putBit(false, 156)
+		h.putBit(false, 163)
+	case predVE:
+		h.putBit(false, 156)
+		h.putBit(true, 163)
+	case predHE:
+		h.putBit(true, 156)
+		h.putBit(false, 128)
+	case predTM:
+		h.putBit(true, 156)
+		h.putBit(true, 128)
+	}
+	h.putBit(cMode != predDC, 142)
+	if cMode != predDC {
+		h.putBit(cMode != predVE, 114)
+		if cMode != predVE {
+			h.putBit(cMode == predTM, 183)
+		}
+	}
+
+	top := &e.top[mbx]
+	e.encodeLuma(e.y[yOff:], e.ry[yOff:], yPred[:], top, &e.left)
+	e.encodeChroma(e.u[cOff:], e.ru[cOff:], uPred[:], top.u[:], e.left.u[:])
+	e.encodeChroma(e.v[cOff:], e.rv[cOff:], vPred[:], top.v[:], e.left.v[:])
+}
+
+// encodeLuma codes the 16 luma blocks of a macroblock, whose DC
+// coefficients go through the second-order Y2 block, section 13.
+func (e *encoder) encodeLuma(src, rec, pred []uint8, top, left *nzContext) {
+	var coeffs [16][16]int32
+	var dc [16]int32
+	for n := range coeffs {
+		x, y := 4*(n%4), 4*(n/4)
+		fdct4(src[y*e.yStride+x:], e.yStride, pred[y*16+x:], 16, &coeffs[n])
+		dc[n] = coeffs[n][0]
+	}
+
+	var wht [16]int32
+	fwht(&dc, &wht)
+	var y2Levels [16]int16
+	var y2Deq, dcDeq [16]int16
+	last := quantize(&wht, 0, e.y2, &y2Levels, &y2Deq)
+	nz := e.putCoeffs(planeY2, int(top.y2+left.y2), &y2Levels, 0, last)
+	top.y2, left.y2 = nz, nz
+	iwht(&y2Deq, &dcDeq)
+
+	for n := range coeffs {
+		x, y := n%4, n/4
+		var levels [16]int16
+		var deq [16]int16
+		last := quantize(&coeffs[n], 1, e.y1, &levels, &deq)
+		nz := e.putCoeffs(planeY1WithY2, int(top.y[x]+left.y[y]), &levels, 1, last)
+		top.y[x], left.y[y] = nz, nz
+
+		deq[0] = dcDeq[n]
+		reconstruct(rec[4*y*e.yStride+4*x:], e.yStride, pred[4*y*16+4*x:], 16, &deq)
+	}
+}
+
+// encodeChroma codes the four blocks of one 8x8 chroma plane of a
+// macroblock.
+func (e *encoder) encodeChroma(src, rec, pred []uint8, top, left []uint8) {
+	for n := 0; n < 4; n++ {
+		x, y := n%2, n/2
+		var coeffs [16]int32
+		fdct4(src[4*y*e.cStride+4*x:], e.cStride, pred[4*y*8+4*x:], 8, &coeffs)
+
+		var levels [16]int16
+		var deq [16]int16
+		last := quantize(&coeffs, 0, e.uv, &levels, &deq)
+		nz := e.putCoeffs(planeUV, int(top[x]+left[y]), &levels, 0, last)
+		top[x], left[y] = nz, nz
+
+		reconstruct(rec[4*y*e.cStride+4*x:], e.cStride, pred[4*y*8+4*x:], 8, &deq)
+	}
+}
+
+// reconstruct writes a 4x4 block of pred plus the inverse transform of deq
+// into rec.
+func reconstruct(rec []uint8, recStride int, pred []uint8, predStride int, deq *[16]int16) {
+	for j := 0; j < 4; j++ {
+		copy(rec[j*recStride:j*recStride+4], pred[j*predStride:j*predStride+4])
+	}
+	idct4(deq, rec, recStride)
+}
+
+// quantize quantizes coefficients from scan position first onwards, storing
+// the levels in scan order and the values the decoder will dequantize them
+// to in natural order. It returns the scan position after the last non-zero
+// level, or zero if there is none.
+func quantize(coeffs *[16]int32, first int, q quant, levels, deq *[16]int16) int {
+	last := 0
+	for n := first; n < 16; n++ {
+		z := zigzag[n]
+		i := 0
+		if z > 0 {
+			i = 1
+		}
+		c := coeffs[z]
+		abs := c
+		if c < 0 {
+			abs = -c
+		}
+		level := min((abs*256+q.bias[i]*q.step[i])/(q.step[i]*256), 2047)
+		if level == 0 {
+			continue
+		}
+		if c < 0 {
+			level = -level
+		}
+		levels[n] = int16(level)
+		deq[z] = int16(level * q.step[i])
+		last = n + 1
+	}
+	return last
+}
+
+// putCoeffs writes the tokens for one block's levels, from scan position
+// first up to last, section 13.2. It returns 1 if any level is non-zero,
+// for the contexts of the blocks right of and below it.
+func (e *encoder) putCoeffs(plane, ctx int, levels *[16]int16, first, last int) uint8 {
+	t := e.tokens
+	probs := &defaultTokenProb[plane]
+	p := &probs[bands[first]][ctx]
+	if last == 0 {
+		t.putBit(false, p[0])
+		return 0
+	}
+	t.putBit(true, p[0])
+
+	for n := first; n < 16; {
+		v := int32(levels[n])
+		n++
+		if v == 0 {
+			t.putBit(false, p[1])
+			p = &probs[bands[n]][0]
+			continue
+		}
+		t.putBit(true, p[1])
+
+		abs := v
+		if v < 0 {
+			abs = -v
+		}
+		if abs == 1 {
+			t.putBit(false, p[2])
+			p = &probs[bands[n]][1]
+		} else {
+			t.putBit(true, p[2])
+			switch {
+			case abs <= 4:
+				t.putBit(false, p[3])
+				t.putBit(abs != 2, p[4])
+				if abs != 2 {
+					t.putBit(abs == 4, p[5])
+				}
+			case abs <= 10:
+				t.putBit(true, p[3])
+				t.putBit(false, p[6])
+				t.putBit(abs > 6, p[7])
+				if abs <= 6 {
+					t.putBit(abs == 6, 159)
+				} else {
+					t.putBit((abs-7)&2 != 0, 165)
+					t.putBit((abs-7)&1 != 0, 145)
+				}
+			default:
+				t.putBit(true, p[3])
+				t.putBit(true, p[6])
+				cat := 3
+				switch {
+				case abs < 19:
+					cat = 0
+				case abs < 35:
+					cat = 1
+				case abs < 67:
+					cat = 2
+				}
+				t.putBit(cat >= 2, p[8])
+				t.putBit(cat&1 == 1, p[9+cat>>1])
+				extra := abs - (3 + 8<<cat)
+				bits := cat3456[cat]
+				for i, prob := range bits {
+					t.putBit(extra>>(len(bits)-1-i)&1 == 1, prob)
+				}
+			}
+			p = &probs[bands[n]][2]
+		}
+		t.putBit(v < 0, uniformProb)
+
+		if n == 16 {
+			break
+		}
+		t.putBit(n < last, p[0])
+		if n == last {
+			break
+		}
+	}
+	return 1
+}
+
+// region is an n x n block at (x, y) of a source plane, along with the
+// plane's reconstruction and a buffer for the block's prediction.
+type region struct {
+	src, rec []uint8
+	stride   int
+	x, y, n  int
+	pred     []uint8
+}
+
+// choosePrediction predicts every region from its reconstructed neighbours
+// with each mode and returns the mode that matches the source best overall,
+// leaving its prediction in each region's pred.
+func choosePrediction(regions ...region) int {
+	edges := make([]edge, len(regions))
+	for i, r := range regions {
+		edges[i].load(r.rec, r.stride, r.x, r.y, r.n)
+	}
+
+	best, bestErr := predDC, int64(math.MaxInt64)
+	var cand [16 * 16]uint8
+	for mode := 0; mode < nPred; mode++ {
+		var err int64
+		for i, r := range regions {
+			edges[i].predict(mode, r.n, cand[:])
+			err += sse(r.src[r.y*r.stride+r.x:], r.stride, cand[:], r.n)
+		}
+		if err < bestErr {
+			best, bestErr = mode, err
+		}
+	}
+	for i, r := range regions {
+		edges[i].predict(best, r.n, r.pred)
+	}
+	return best
+}
+
+// edge holds the reconstructed pixels above and left of a region, with the
+// values section 12.2 substitutes outside the frame: 127 above and 129 to
+// the left.
+type edge struct {
+	top, left       [16]uint8
+	topLeft         uint8
+	hasTop, hasLeft bool
+}
+
+func (g *edge) load(rec []uint8, stride, x, y, n int) {
+	g.hasTop, g.hasLeft = y > 0, x > 0
+	g.topLeft = 127
+	for i := 0; i < n; i++ {
+		g.top[i], g.left[i] = 127, 129
+		if g.hasTop {
+			g.top[i] = rec[(y-1)*stride+x+i]
+		}
+		if g.hasLeft {
+			g.left[i] = rec[(y+i)*stride+x-1]
+		}
+	}
+	switch {
+	case g.hasTop && g.hasLeft:
+		g.topLeft = rec[(y-1)*stride+x-1]
+	case g.hasTop:
+		g.topLeft = 129
+	}
+}
+
+// predict fills the n x n dst with the prediction for mode, section 12.2.
+func (g *edge) predict(mode, n int, dst []uint8) {
+	switch mode {
+	case predDC:
+		var sum, count int
+		if g.hasTop {
+			for i := 0; i < n; i++ {
+				sum += int(g.top[i])
+			}
+			count += n
+		}
+		if g.hasLeft {
+			for i := 0; i < n; i++ {
+				sum += int(g.left[i])
+			}
+			count += n
+		}
+		v := uint8(128)
+		if count > 0 {
+			v = uint8((sum + count/2) / count)
+		}
+		for i := range dst[:n*n] {
+			dst[i] = v
+		}
+	case predTM:
+		for j := 0; j < n; j++ {
+			for i := 0; i < n; i++ {
+				dst[j*n+i] = clip8(int32(g.left[j]) + int32(g.top[i]) - int32(g.topLeft))
+			}
+		}
+	case predVE:
+		for j := 0; j < n; j++ {
+			copy(dst[j*n:j*n+n], g.top[:n])
+		}
+	case predHE:
+		for j := 0; j < n; j++ {
+			for i := 0; i < n; i++ {
+				dst[j*n+i] = g.left[j]
+			}
+		}
+	}
+}
+
+// sse returns the sum of squared differences between an n x n region of src
+// and pred.
+func sse(src []uint8, stride int, pred []uint8, n int) int64 {
+	var sum int64
+	for j := 0; j < n; j++ {
+		for i := 0; i < n; i++ {
+			d := int64(src[j*stride+i]) - int64(pred[j*n+i])
+			sum += d * d
+		}
+	}
+	return sum
+}
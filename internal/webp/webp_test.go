@@ -0,0 +1,180 @@
+package webp
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+	"math"
+	"testing"
+
+	xwebp "golang.org/x/image/webp"
+)
+
+// fixture draws a w x h photograph stand-in: colour that changes smoothly
+// across the frame, with a ripple and hard-edged shadows in brightness, so
+// both flat and detailed macroblocks are exercised. Like a photograph, it
+// has no sharp changes in colour alone, which 4:2:0 chroma subsampling
+// would blur whatever the quality.
+func fixture(w, h int) *image.RGBA {
+	m := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			fx, fy := float64(x)/64, float64(y)/64
+			light := 0.8 + 0.15*math.Sin(float64(x+2*y)/3)
+			if (x/12+y/12)%3 == 0 {
+				light *= 0.5
+			}
+			r := light * (150 + 80*math.Sin(fx))
+			g := light * (120 + 60*math.Cos(fy))
+			b := light * (90 + 60*math.Sin(fx+fy))
+			m.SetRGBA(x, y, color.RGBA{uint8(r), uint8(g), uint8(b), 255})
+		}
+	}
+	return m
+}
+
+// psnr returns the peak signal-to-noise ratio of got against want over the
+// red, green, and blue channels, in decibels.
+func psnr(want, got image.Image) float64 {
+	b := want.Bounds()
+	var sum float64
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r1, g1, b1, _ := want.At(x, y).RGBA()
+			r2, g2, b2, _ := got.At(x, y).RGBA()
+			for _, d := range []float64{
+				float64(r1>>8) - float64(r2>>8),
+				float64(g1>>8) - float64(g2>>8),
+				float64(b1>>8) - float64(b2>>8),
+			} {
+				sum += d * d
+			}
+		}
+	}
+	mse := sum / float64(3*b.Dx()*b.Dy())
+	if mse == 0 {
+		return math.Inf(1)
+	}
+	return 10 * math.Log10(255*255/mse)
+}
+
+// decode reads a WebP image and converts it to RGB as browsers do. The
+// decoder returns the VP8 planes as they are, but image.YCbCr treats them as
+// full-range JPEG samples, while VP8 uses BT.601 studio range (luma 16 to
+// 235), as importImage does.
+func decode(t *testing.T, r io.Reader) *image.RGBA {
+	t.Helper()
+	m, err := xwebp.Decode(r)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	ycc, ok := m.(*image.YCbCr)
+	if !ok {
+		t.Fatalf("Decode returned %T, want *image.YCbCr", m)
+	}
+
+	b := ycc.Bounds()
+	rgb := image.NewRGBA(b)
+	clamp := func(v float64) uint8 { return uint8(min(255, max(0, math.Round(v)))) }
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			l := 1.164 * (float64(ycc.Y[ycc.YOffset(x, y)]) - 16)
+			u := float64(ycc.Cb[ycc.COffset(x, y)]) - 128
+			v := float64(ycc.Cr[ycc.COffset(x, y)]) - 128
+			rgb.SetRGBA(x, y, color.RGBA{clamp(l + 1.596*v), clamp(l - 0.391*u - 0.813*v), clamp(l + 2.018*u), 255})
+		}
+	}
+	return rgb
+}
+
+func roundTrip(t *testing.T, m image.Image, o *Options) image.Image {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Encode(&buf, m, o); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	got := decode(t, &buf)
+	if got.Bounds().Dx() != m.Bounds().Dx() || got.Bounds().Dy() != m.Bounds().Dy() {
+		t.Fatalf("decoded %v, want the size of %v", got.Bounds(), m.Bounds())
+	}
+	return got
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	sizes := []struct{ w, h int }{
+		{1, 1},
+		{7, 5},
+		{16, 16},
+		{17, 33},
+		{64, 48},
+		{100, 75},
+		{257, 129},
+		{300, 300},
+	}
+	for _, size := range sizes {
+		t.Run(fmt.Sprintf("%dx%d", size.w, size.h), func(t *testing.T) {
+			m := fixture(size.w, size.h)
+			got := roundTrip(t, m, nil)
+			if p := psnr(m, got); p < 30 {
+				t.Errorf("PSNR = %.1f dB, want at least 30", p)
+			}
+		})
+	}
+}
+
+func TestEncodeOffsetBounds(t *testing.T) {
+	// Sub-images keep their parent's coordinates; only the visible part
+	// is encoded.
+	m := fixture(120, 90).SubImage(image.Rect(13, 7, 90, 60))
+	got := roundTrip(t, m, nil)
+
+	shifted := image.NewRGBA(image.Rect(0, 0, 77, 53))
+	for y := range 53 {
+		for x := range 77 {
+			shifted.Set(x, y, m.At(x+13, y+7))
+		}
+	}
+	if p := psnr(shifted, got); p < 30 {
+		t.Errorf("PSNR = %.1f dB, want at least 30", p)
+	}
+}
+
+func TestEncodeQuality(t *testing.T) {
+	m := fixture(128, 96)
+	low := psnr(m, roundTrip(t, m, &Options{Quality: 10}))
+	high := psnr(m, roundTrip(t, m, &Options{Quality: 95}))
+	if low >= high {
+		t.Errorf("quality 10 PSNR %.1f dB is not below quality 95 PSNR %.1f dB", low, high)
+	}
+	if low < 20 {
+		t.Errorf("quality 10 PSNR = %.1f dB, want at least 20", low)
+	}
+}
+
+func TestEncodeFlattensAlphaOntoWhite(t *testing.T) {
+	m := image.NewNRGBA(image.Rect(0, 0, 24, 24))
+	m.Set(3, 3, color.NRGBA{0, 0, 0, 128})
+	got := roundTrip(t, m, nil)
+
+	want := image.NewRGBA(m.Bounds())
+	draw.Draw(want, want.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(want, want.Bounds(), m, image.Point{}, draw.Over)
+	if p := psnr(want, got); p < 35 {
+		t.Errorf("PSNR against the image on white = %.1f dB, want at least 35", p)
+	}
+}
+
+func TestEncodeRejectsBadSizes(t *testing.T) {
+	for _, r := range []image.Rectangle{
+		image.Rect(0, 0, 0, 10),
+		image.Rect(0, 0, 10, 0),
+		image.Rect(0, 0, maxDimension+1, 1),
+	} {
+		if err := Encode(&bytes.Buffer{}, image.NewGray(r), nil); err == nil {
+			t.Errorf("Encode(%v) succeeded, want an error", r)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+CREATE TABLE users (
+    id                  BIGSERIAL PRIMARY KEY,
+    email               VARCHAR(255) UNIQUE NOT NULL,
+    password_hash       VARCHAR(255) NOT NULL,
+    first_name          VARCHAR(100) NOT NULL,
+    last_name           VARCHAR(100) NOT NULL,
+    phone               VARCHAR(20),
+    is_verified         BOOLEAN DEFAULT FALSE,
+    verification_token  VARCHAR(255),
+    reset_token         VARCHAR(255),
+    reset_token_expires TIMESTAMP,
+    last_login          TIMESTAMP,
+    failed_login_count  INTEGER DEFAULT 0,
+    locked_until        TIMESTAMP,
+    created_at          TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at          TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at          TIMESTAMP
+);
+
+CREATE INDEX idx_users_email ON users(email);
+CREATE INDEX idx_users_verification_token ON users(verification_token);
+CREATE INDEX idx_users_deleted_at ON users(deleted_at);
@@ -0,0 +1,27 @@
+CREATE TABLE roles (
+    id          SERIAL PRIMARY KEY,
+    name        VARCHAR(50) UNIQUE NOT NULL,
+    description TEXT,
+    created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE user_roles (
+    user_id     BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    role_id     INTEGER REFERENCES roles(id) ON DELETE CASCADE,
+    assigned_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    assigned_by BIGINT REFERENCES users(id),
+    PRIMARY KEY (user_id, role_id)
+);
+
+CREATE INDEX idx_user_roles_role_id ON user_roles(role_id);
+
+INSERT INTO roles (name, description) VALUES
+    ('public', 'Unauthenticated visitor'),
+    ('member', 'Church member with access to member resources'),
+    ('deacon', 'Deacon (future use)'),
+    ('elder', 'Ruling elder'),
+    ('staff', 'Church staff with content management access'),
+    ('musician', 'Musician with access to music schedules'),
+    ('pastor', 'Teaching elder'),
+    ('volunteer', 'Volunteer with access to volunteer schedules'),
+    ('admin', 'Administrator with access to all resources');
@@ -0,0 +1,16 @@
+CREATE TABLE email_outbox (
+    id              BIGSERIAL PRIMARY KEY,
+    to_email        VARCHAR(255) NOT NULL,
+    to_name         VARCHAR(255),
+    subject         VARCHAR(255) NOT NULL,
+    html_body       TEXT NOT NULL,
+    text_body       TEXT NOT NULL,
+    status          VARCHAR(20) NOT NULL DEFAULT 'pending',  -- pending, sending, sent, failed
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_error      TEXT,
+    created_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    sent_at         TIMESTAMP
+);
+
+CREATE INDEX idx_email_outbox_status_next_attempt ON email_outbox(status, next_attempt_at);
@@ -0,0 +1,13 @@
+CREATE TABLE event_exceptions (
+    id              BIGSERIAL PRIMARY KEY,
+    event_id        BIGINT NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    occurrence_date DATE NOT NULL,                -- date the recurrence rule generated
+    is_cancelled    BOOLEAN DEFAULT FALSE,
+    new_event_date  TIMESTAMP,                    -- set when the occurrence is moved
+    new_end_date    TIMESTAMP,
+    created_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (event_id, occurrence_date)
+);
+
+CREATE INDEX idx_event_exceptions_new_event_date ON event_exceptions(new_event_date);
@@ -0,0 +1,19 @@
+CREATE TABLE event_registrations (
+    id               BIGSERIAL PRIMARY KEY,
+    event_id         BIGINT REFERENCES events(id) ON DELETE CASCADE,
+    user_id          BIGINT REFERENCES users(id),
+    guest_name       VARCHAR(255),
+    guest_email      VARCHAR(255),
+    guest_phone      VARCHAR(20),
+    number_attending INTEGER DEFAULT 1,
+    special_needs    TEXT,
+    form_data        JSONB,
+    status           VARCHAR(20) NOT NULL DEFAULT 'confirmed',  -- confirmed, waitlisted, cancelled
+    cancel_token     VARCHAR(255),                              -- SHA-256 hash
+    registered_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    cancelled_at     TIMESTAMP,
+    CONSTRAINT unique_registration UNIQUE(event_id, user_id)
+);
+
+CREATE INDEX idx_event_registrations_event_status ON event_registrations(event_id, status, registered_at);
+CREATE INDEX idx_event_registrations_cancel_token ON event_registrations(cancel_token);
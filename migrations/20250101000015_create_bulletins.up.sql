@@ -0,0 +1,16 @@
+CREATE TABLE bulletins (
+    id            BIGSERIAL PRIMARY KEY,
+    bulletin_date DATE NOT NULL,
+    service_type  VARCHAR(20) NOT NULL,  -- 'morning' or 'evening'
+    file_path     VARCHAR(500) NOT NULL,
+    file_size     INTEGER,
+    uploaded_by   BIGINT REFERENCES users(id),
+    uploaded_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at    TIMESTAMP
+);
+
+-- One live bulletin per service; a deleted bulletin may be uploaded again.
+CREATE UNIQUE INDEX unique_bulletin ON bulletins(bulletin_date, service_type) WHERE deleted_at IS NULL;
+CREATE INDEX idx_bulletins_deleted_at ON bulletins(deleted_at);
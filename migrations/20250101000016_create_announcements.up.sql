@@ -0,0 +1,17 @@
+CREATE TABLE announcements (
+    id              BIGSERIAL PRIMARY KEY,
+    title           VARCHAR(255) NOT NULL,
+    content         TEXT NOT NULL,
+    author_id       BIGINT REFERENCES users(id),
+    audience        VARCHAR(20) NOT NULL DEFAULT 'public',  -- public, members
+    priority        VARCHAR(20) NOT NULL DEFAULT 'normal',  -- normal, high
+    visible_from    TIMESTAMP,
+    visible_until   TIMESTAMP,
+    is_active       BOOLEAN DEFAULT TRUE,
+    created_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at      TIMESTAMP
+);
+
+CREATE INDEX idx_announcements_visibility ON announcements(is_active, visible_from, visible_until);
+CREATE INDEX idx_announcements_deleted_at ON announcements(deleted_at);
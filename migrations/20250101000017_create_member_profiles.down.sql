@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_users_last_name_lower;
+DROP TABLE IF EXISTS member_profiles;
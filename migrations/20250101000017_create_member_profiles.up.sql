@@ -0,0 +1,21 @@
+CREATE TABLE member_profiles (
+    user_id              BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    photo_url            VARCHAR(500),
+    address_line1        VARCHAR(255),
+    address_line2        VARCHAR(255),
+    city                 VARCHAR(100),
+    state                VARCHAR(2),
+    zip_code             VARCHAR(10),
+    membership_date      DATE,
+    directory_opt_in     BOOLEAN DEFAULT FALSE,
+    show_email           BOOLEAN DEFAULT FALSE,
+    show_phone           BOOLEAN DEFAULT FALSE,
+    show_address         BOOLEAN DEFAULT FALSE,
+    emergency_contact    VARCHAR(255),
+    emergency_phone      VARCHAR(20),
+    created_at           TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at           TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_member_profiles_directory_opt_in ON member_profiles(directory_opt_in) WHERE directory_opt_in;
+CREATE INDEX idx_users_last_name_lower ON users(LOWER(last_name), LOWER(first_name));
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS event_registration_attendees;
+DROP TABLE IF EXISTS household_members;
+DROP TABLE IF EXISTS households;
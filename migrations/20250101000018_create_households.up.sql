@@ -0,0 +1,32 @@
+CREATE TABLE households (
+    id             BIGSERIAL PRIMARY KEY,
+    name           VARCHAR(100) NOT NULL,  -- family name, listed as "The {name} Family"
+    address_line1  VARCHAR(255),
+    address_line2  VARCHAR(255),
+    city           VARCHAR(100),
+    state          VARCHAR(2),
+    zip_code       VARCHAR(10),
+    phone          VARCHAR(20),
+    created_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE household_members (
+    id            BIGSERIAL PRIMARY KEY,
+    household_id  BIGINT NOT NULL REFERENCES households(id) ON DELETE CASCADE,
+    user_id       BIGINT UNIQUE REFERENCES users(id) ON DELETE CASCADE,  -- NULL for people without logins
+    first_name    VARCHAR(100),
+    last_name     VARCHAR(100),
+    relationship  VARCHAR(20) NOT NULL CHECK (relationship IN ('adult', 'child')),
+    birth_date    DATE,
+    created_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_household_members_household_id ON household_members(household_id);
+
+CREATE TABLE event_registration_attendees (
+    registration_id      BIGINT REFERENCES event_registrations(id) ON DELETE CASCADE,
+    household_member_id  BIGINT REFERENCES household_members(id) ON DELETE CASCADE,
+    PRIMARY KEY (registration_id, household_member_id)
+);
@@ -0,0 +1,11 @@
+CREATE TABLE ministry_assignments (
+    id           BIGSERIAL PRIMARY KEY,
+    user_id      BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    ministry_id  BIGINT NOT NULL REFERENCES ministries(id) ON DELETE CASCADE,
+    can_edit     BOOLEAN DEFAULT TRUE,  -- may edit the ministry's page content
+    assigned_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    assigned_by  BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    CONSTRAINT unique_ministry_assignment UNIQUE(user_id, ministry_id)
+);
+
+CREATE INDEX idx_ministry_assignments_ministry_id ON ministry_assignments(ministry_id);
@@ -0,0 +1,10 @@
+CREATE TABLE content_revisions (
+    id           BIGSERIAL PRIMARY KEY,
+    entity_type  VARCHAR(50) NOT NULL,  -- e.g. 'ministry_page', 'announcement'
+    entity_id    BIGINT NOT NULL,
+    content      JSONB NOT NULL,        -- the editable fields as they were before the change
+    author_id    BIGINT REFERENCES users(id) ON DELETE SET NULL,  -- who made the change
+    created_at   TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_content_revisions_entity ON content_revisions(entity_type, entity_id, created_at DESC);
@@ -0,0 +1,5 @@
+ALTER TABLE ministries
+    DROP COLUMN IF EXISTS draft_content,
+    DROP COLUMN IF EXISTS draft_publish_at,
+    DROP COLUMN IF EXISTS draft_updated_at,
+    DROP COLUMN IF EXISTS draft_updated_by;
@@ -0,0 +1,7 @@
+ALTER TABLE ministries
+    ADD COLUMN draft_content     TEXT,       -- NULL when there is no draft
+    ADD COLUMN draft_publish_at  TIMESTAMP,  -- publish the draft automatically at this time
+    ADD COLUMN draft_updated_at  TIMESTAMP,
+    ADD COLUMN draft_updated_by  BIGINT REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_ministries_draft_publish_at ON ministries(draft_publish_at) WHERE draft_publish_at IS NOT NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE staff_members DROP CONSTRAINT IF EXISTS fk_staff_members_category;
+DROP TABLE IF EXISTS staff_categories;
@@ -0,0 +1,30 @@
+CREATE TABLE staff_categories (
+    id             BIGSERIAL PRIMARY KEY,
+    slug           VARCHAR(50) UNIQUE NOT NULL,
+    label          VARCHAR(255) NOT NULL,
+    display_order  INTEGER DEFAULT 0,
+    created_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_staff_categories_display_order ON staff_categories(display_order);
+
+INSERT INTO staff_categories (slug, label, display_order) VALUES
+    ('pastor', 'Teaching Elders', 1),
+    ('ruling-elder', 'Ruling Elders', 2),
+    ('deacon', 'Deacons', 3),
+    ('staff', 'Staff', 4),
+    ('intern', 'Interns', 5),
+    ('emeritus', 'Emeriti', 6);
+
+-- Categories that existing rows use but that were never in the code are kept
+-- rather than failing the foreign key below.
+INSERT INTO staff_categories (slug, label, display_order)
+SELECT DISTINCT category, INITCAP(REPLACE(category, '-', ' ')), 100
+FROM staff_members
+WHERE category NOT IN (SELECT slug FROM staff_categories);
+
+-- A category in use cannot be deleted; renaming a slug carries its members along.
+ALTER TABLE staff_members
+    ADD CONSTRAINT fk_staff_members_category
+    FOREIGN KEY (category) REFERENCES staff_categories(slug) ON UPDATE CASCADE;
@@ -0,0 +1,3 @@
+ALTER TABLE staff_members
+    DROP COLUMN IF EXISTS photo_srcset,
+    DROP COLUMN IF EXISTS photo_thumbnail_url;
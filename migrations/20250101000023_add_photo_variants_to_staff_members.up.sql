@@ -0,0 +1,3 @@
+ALTER TABLE staff_members
+    ADD COLUMN photo_srcset         TEXT,          -- responsive WebP variants, as an img srcset value
+    ADD COLUMN photo_thumbnail_url  VARCHAR(500);  -- square WebP thumbnail
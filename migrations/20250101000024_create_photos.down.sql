@@ -0,0 +1,3 @@
+ALTER TABLE photo_albums DROP CONSTRAINT IF EXISTS fk_photo_albums_cover_photo;
+DROP TABLE IF EXISTS photos;
+DROP TABLE IF EXISTS photo_albums;
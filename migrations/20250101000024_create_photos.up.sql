@@ -0,0 +1,36 @@
+CREATE TABLE photo_albums (
+    id              BIGSERIAL PRIMARY KEY,
+    title           VARCHAR(255) NOT NULL,
+    slug            VARCHAR(100) UNIQUE NOT NULL,
+    description     TEXT,
+    visibility      VARCHAR(20) NOT NULL DEFAULT 'public',  -- 'public' or 'members'
+    cover_photo_id  BIGINT,
+    created_by      BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at      TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Variants are stored as URLs, like staff headshots; a photo stays a draft
+-- until it has alt text and is published.
+CREATE TABLE photos (
+    id             BIGSERIAL PRIMARY KEY,
+    album_id       BIGINT NOT NULL REFERENCES photo_albums(id),
+    caption        TEXT,
+    alt_text       VARCHAR(500),
+    photo_url      VARCHAR(500) NOT NULL,
+    srcset         TEXT,
+    thumbnail_url  VARCHAR(500),
+    width          INTEGER,
+    height         INTEGER,
+    file_size      INTEGER,
+    is_published   BOOLEAN NOT NULL DEFAULT FALSE,
+    uploaded_by    BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    uploaded_at    TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at     TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_photos_album_id ON photos(album_id);
+
+ALTER TABLE photo_albums
+    ADD CONSTRAINT fk_photo_albums_cover_photo
+    FOREIGN KEY (cover_photo_id) REFERENCES photos(id) ON DELETE SET NULL;
@@ -0,0 +1,9 @@
+UPDATE staff_members SET
+    photo_url           = REPLACE(photo_url, '/files/staff/', '/media/staff/'),
+    photo_srcset        = REPLACE(photo_srcset, '/files/staff/', '/media/staff/'),
+    photo_thumbnail_url = REPLACE(photo_thumbnail_url, '/files/staff/', '/media/staff/');
+
+UPDATE photos SET
+    photo_url     = REPLACE(photo_url, '/files/photos/', '/gallery/photos/'),
+    srcset        = REPLACE(srcset, '/files/photos/', '/gallery/photos/'),
+    thumbnail_url = REPLACE(thumbnail_url, '/files/photos/', '/gallery/photos/');
@@ -0,0 +1,10 @@
+-- Uploaded files are now served under /files/, by their location in the storage directory.
+UPDATE staff_members SET
+    photo_url           = REPLACE(photo_url, '/media/staff/', '/files/staff/'),
+    photo_srcset        = REPLACE(photo_srcset, '/media/staff/', '/files/staff/'),
+    photo_thumbnail_url = REPLACE(photo_thumbnail_url, '/media/staff/', '/files/staff/');
+
+UPDATE photos SET
+    photo_url     = REPLACE(photo_url, '/gallery/photos/', '/files/photos/'),
+    srcset        = REPLACE(srcset, '/gallery/photos/', '/files/photos/'),
+    thumbnail_url = REPLACE(thumbnail_url, '/gallery/photos/', '/files/photos/');
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS form_submissions;
+DROP TABLE IF EXISTS forms;
@@ -0,0 +1,27 @@
+-- Forms are defined by developers as a JSON schema (see services.ParseFormSchema);
+-- submissions keep the validated answers keyed by property name.
+CREATE TABLE forms (
+    id          BIGSERIAL PRIMARY KEY,
+    title       VARCHAR(255) NOT NULL,
+    description TEXT,
+    schema      JSONB NOT NULL,
+    is_active   BOOLEAN DEFAULT TRUE,
+    created_by  BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at  TIMESTAMP
+);
+
+CREATE INDEX idx_forms_deleted_at ON forms(deleted_at);
+
+CREATE TABLE form_submissions (
+    id           BIGSERIAL PRIMARY KEY,
+    form_id      BIGINT NOT NULL REFERENCES forms(id) ON DELETE CASCADE,
+    user_id      BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    data         JSONB NOT NULL,
+    ip_address   VARCHAR(45),
+    user_agent   TEXT,
+    submitted_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_form_submissions_form_id ON form_submissions(form_id, submitted_at DESC);
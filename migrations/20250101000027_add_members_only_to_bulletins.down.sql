@@ -0,0 +1 @@
+ALTER TABLE bulletins DROP COLUMN IF EXISTS members_only;
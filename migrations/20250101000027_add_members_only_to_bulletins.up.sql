@@ -0,0 +1,3 @@
+-- Members-only bulletins are listed publicly but downloaded only by members.
+ALTER TABLE bulletins
+    ADD COLUMN members_only BOOLEAN NOT NULL DEFAULT false;
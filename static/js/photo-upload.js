@@ -0,0 +1,103 @@
+// Uploads the photos chosen in a form marked data-photo-upload one file per
+// request, so a large batch neither exceeds the request size limit nor
+// waits on every photo being processed at once. Each file's outcome is
+// listed in the form's [data-photo-upload-results] as it finishes; when all
+// are done the browser continues to the album.
+(function () {
+  "use strict";
+
+  function report(list, name, message, failed) {
+    var item = document.createElement("li");
+    item.className = "photo-upload__result";
+    if (failed) {
+      item.classList.add("photo-upload__result--error");
+    }
+    var strong = document.createElement("strong");
+    strong.textContent = name;
+    item.appendChild(strong);
+    item.appendChild(document.createTextNode(" " + message));
+    list.appendChild(item);
+  }
+
+  function upload(form, album, file) {
+    var data = new FormData();
+    data.append("album_id", album);
+    data.append("photos", file);
+    return fetch(form.action, {
+      method: "POST",
+      body: data,
+      credentials: "same-origin",
+      headers: { Accept: "application/json" },
+    }).then(function (res) {
+      return res.json().catch(function () {
+        return { error: "The photo could not be uploaded. Reload the page and try again." };
+      });
+    });
+  }
+
+  function enhance(form) {
+    var input = form.querySelector('input[type="file"]');
+    var select = form.querySelector('select[name="album_id"]');
+    var list = form.querySelector("[data-photo-upload-results]");
+    var button = form.querySelector('button[type="submit"]');
+    if (!input || !select || !list || !window.fetch) {
+      return;
+    }
+
+    form.addEventListener("submit", function (e) {
+      var files = Array.prototype.slice.call(input.files);
+      if (files.length === 0) {
+        return; // let the server explain
+      }
+      e.preventDefault();
+
+      var album = select.value;
+      var failures = 0;
+      list.textContent = "";
+      button.disabled = true;
+
+      files
+        .reduce(function (done, file, i) {
+          return done.then(function () {
+            button.textContent = "Uploading " + (i + 1) + " of " + files.length + "…";
+            return upload(form, album, file).then(function (body) {
+              var result = body.photos ? body.photos[0] : null;
+              if (result && !result.error) {
+                report(list, file.name, "Uploaded.", false);
+                return;
+              }
+              failures++;
+              var message = result ? result.error : body.error;
+              if (body.fields) {
+                message = body.fields.photos || body.fields.album_id || message;
+              }
+              report(list, file.name, message, true);
+            });
+          });
+        }, Promise.resolve())
+        .catch(function () {
+          failures++;
+          report(list, "", "The upload was interrupted. Please try again.", true);
+        })
+        .then(function () {
+          if (failures === 0) {
+            window.location.href = "/admin/photos/albums/" + encodeURIComponent(album) + "?status=uploaded";
+            return;
+          }
+          if (failures < files.length) {
+            var link = document.createElement("a");
+            link.href = "/admin/photos/albums/" + encodeURIComponent(album) + "?status=uploaded";
+            link.textContent = "Review the photos that were uploaded";
+            var item = document.createElement("li");
+            item.appendChild(link);
+            list.appendChild(item);
+          }
+          button.disabled = false;
+          button.textContent = "Upload";
+          input.value = "";
+        });
+    });
+  }
+
+  Array.prototype.forEach.call(document.querySelectorAll("form[data-photo-upload]"), enhance);
+})();
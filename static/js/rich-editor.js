@@ -0,0 +1,113 @@
+// Rich text editor for textareas marked with data-rich-editor.
+//
+// The textarea stays in the form and is kept in sync with an editable area,
+// so the form submits HTML as usual and still works without JavaScript. The
+// server sanitizes whatever is submitted; the toolbar only offers formatting
+// that survives sanitizing.
+(function () {
+  "use strict";
+
+  var buttons = [
+    { label: "H2", title: "Heading", block: "h2" },
+    { label: "H3", title: "Subheading", block: "h3" },
+    { label: "¶", title: "Paragraph", block: "p" },
+    { label: "B", title: "Bold", command: "bold" },
+    { label: "I", title: "Italic", command: "italic" },
+    { label: "• List", title: "Bulleted list", command: "insertUnorderedList" },
+    { label: "1. List", title: "Numbered list", command: "insertOrderedList" },
+    { label: "“ ”", title: "Quotation", block: "blockquote" },
+    { label: "Link", title: "Add link", link: true },
+    { label: "Unlink", title: "Remove link", command: "unlink" },
+    { label: "Clear", title: "Clear formatting", command: "removeFormat" },
+  ];
+
+  function enhance(textarea) {
+    if (textarea.dataset.richEditorReady) {
+      return;
+    }
+    textarea.dataset.richEditorReady = "true";
+
+    var wrapper = document.createElement("div");
+    wrapper.className = "rich-editor";
+
+    var toolbar = document.createElement("div");
+    toolbar.className = "rich-editor__toolbar";
+    toolbar.setAttribute("role", "toolbar");
+    toolbar.setAttribute("aria-label", "Formatting");
+
+    var content = document.createElement("div");
+    content.className = "rich-editor__content ministry-content";
+    content.contentEditable = "true";
+    content.setAttribute("role", "textbox");
+    content.setAttribute("aria-multiline", "true");
+    content.setAttribute("aria-labelledby", textarea.id + "-label");
+    content.innerHTML = textarea.value;
+
+    var label = document.querySelector('label[for="' + textarea.id + '"]');
+    if (label) {
+      label.id = textarea.id + "-label";
+      label.addEventListener("click", function () {
+        content.focus();
+      });
+    }
+
+    function sync() {
+      textarea.value = content.innerHTML;
+    }
+
+    buttons.forEach(function (b) {
+      var button = document.createElement("button");
+      button.type = "button";
+      button.className = "rich-editor__button";
+      button.textContent = b.label;
+      button.title = b.title;
+      button.setAttribute("aria-label", b.title);
+      // Keep the selection in the editable area while clicking.
+      button.addEventListener("mousedown", function (e) {
+        e.preventDefault();
+      });
+      button.addEventListener("click", function () {
+        content.focus();
+        if (b.block) {
+          document.execCommand("formatBlock", false, "<" + b.block + ">");
+        } else if (b.link) {
+          var url = window.prompt("Link address (https://…, mailto:…, or /page)");
+          if (url) {
+            document.execCommand("createLink", false, url.trim());
+          }
+        } else {
+          document.execCommand(b.command, false, null);
+        }
+        sync();
+      });
+      toolbar.appendChild(button);
+    });
+
+    // Paste as plain text so formatting from other sites does not come along.
+    content.addEventListener("paste", function (e) {
+      e.preventDefault();
+      var text = (e.clipboardData || window.clipboardData).getData("text/plain");
+      document.execCommand("insertText", false, text);
+    });
+    content.addEventListener("input", sync);
+    content.addEventListener("focus", function () {
+      document.execCommand("defaultParagraphSeparator", false, "p");
+    });
+
+    textarea.hidden = true;
+    textarea.parentNode.insertBefore(wrapper, textarea);
+    wrapper.appendChild(toolbar);
+    wrapper.appendChild(content);
+    wrapper.appendChild(textarea);
+  }
+
+  function enhanceAll(root) {
+    root.querySelectorAll("textarea[data-rich-editor]").forEach(enhance);
+  }
+
+  enhanceAll(document);
+  // Forms swapped in by HTMX (e.g. after a validation error) need enhancing too.
+  document.addEventListener("htmx:load", function (e) {
+    enhanceAll(e.detail.elt);
+  });
+})();
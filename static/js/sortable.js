@@ -0,0 +1,101 @@
+// Drag-and-drop reordering for tables whose tbody is marked data-sortable.
+//
+// Rows are moved by their .sortable__handle, with the mouse or with the
+// arrow keys when the handle has focus. After a move the enclosing form
+// receives a "reorder" event; the form posts its inputs, in their new order,
+// with hx-trigger="reorder".
+(function () {
+  "use strict";
+
+  function notify(list) {
+    var form = list.closest("form");
+    if (form && window.htmx) {
+      window.htmx.trigger(form, "reorder");
+    }
+  }
+
+  function enhance(list) {
+    if (list.dataset.sortableReady) {
+      return;
+    }
+    list.dataset.sortableReady = "true";
+
+    var dragging = null;
+
+    Array.prototype.forEach.call(list.children, function (row) {
+      var handle = row.querySelector(".sortable__handle");
+      if (!handle) {
+        return;
+      }
+
+      // Only start a drag from the handle, so text in the row can still be
+      // selected and links clicked.
+      handle.addEventListener("mousedown", function () {
+        row.draggable = true;
+      });
+      handle.addEventListener("keydown", function (e) {
+        var target = null;
+        if (e.key === "ArrowUp") {
+          target = row.previousElementSibling;
+          if (target) {
+            list.insertBefore(row, target);
+          }
+        } else if (e.key === "ArrowDown") {
+          target = row.nextElementSibling;
+          if (target) {
+            list.insertBefore(target, row);
+          }
+        } else {
+          return;
+        }
+        e.preventDefault();
+        handle.focus();
+        if (target) {
+          notify(list);
+        }
+      });
+
+      row.addEventListener("dragstart", function (e) {
+        dragging = row;
+        row.classList.add("sortable__item--dragging");
+        e.dataTransfer.effectAllowed = "move";
+        e.dataTransfer.setData("text/plain", "");
+      });
+      row.addEventListener("dragend", function () {
+        row.classList.remove("sortable__item--dragging");
+        row.draggable = false;
+        dragging = null;
+      });
+    });
+
+    list.addEventListener("dragover", function (e) {
+      if (!dragging) {
+        return;
+      }
+      e.preventDefault();
+      var over = e.target.closest("tr");
+      if (!over || over === dragging || over.parentNode !== list) {
+        return;
+      }
+      var rect = over.getBoundingClientRect();
+      var after = e.clientY > rect.top + rect.height / 2;
+      list.insertBefore(dragging, after ? over.nextElementSibling : over);
+    });
+    list.addEventListener("drop", function (e) {
+      if (!dragging) {
+        return;
+      }
+      e.preventDefault();
+      notify(list);
+    });
+  }
+
+  function enhanceAll(root) {
+    root.querySelectorAll("[data-sortable]").forEach(enhance);
+  }
+
+  enhanceAll(document);
+  document.addEventListener("htmx:load", function (e) {
+    enhanceAll(e.detail.elt);
+  });
+})();